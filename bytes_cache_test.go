@@ -0,0 +1,223 @@
+package mcache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+func TestBytesCacheBasic(t *testing.T) {
+	c := NewBytesCache()
+	defer c.Close()
+
+	value := []byte("hello")
+	if !c.Set("key", value, 0) {
+		t.Fatal("Set failed")
+	}
+	value[0] = 'j' // Set must have copied
+
+	got, ok := c.Get("key")
+	if !ok || string(got) != "hello" {
+		t.Errorf("Expected hello, got %q, ok=%v", got, ok)
+	}
+
+	c.Set("key", []byte("world"), 0)
+	if got, _ := c.Get("key"); string(got) != "world" {
+		t.Errorf("Expected world, got %q", got)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected Len=1, got %d", c.Len())
+	}
+
+	if !c.Delete("key") {
+		t.Error("Expected Delete to succeed")
+	}
+	if c.Has("key") {
+		t.Error("Expected key to be deleted")
+	}
+
+	c.Set("", []byte("empty key"), 0)
+	if got, ok := c.Get(""); !ok || string(got) != "empty key" {
+		t.Errorf("Expected empty key to be stored, got %q, ok=%v", got, ok)
+	}
+}
+
+func TestBytesCacheTTL(t *testing.T) {
+	var expired []string
+	c := NewBytesCache(
+		WithExpirationResolution[string, []byte](10*time.Millisecond),
+		WithOnExpire(func(key string, value []byte) {
+			expired = append(expired, key+"="+string(value))
+		}),
+	)
+
+	c.Set("short", []byte("v"), 20*time.Millisecond)
+	c.Set("long", []byte("v"), time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	c.Close() // Stops the expiration worker before reading expired
+
+	if c.Has("short") {
+		t.Error("Expected short-lived entry to expire")
+	}
+	if len(expired) != 1 || expired[0] != "short=v" {
+		t.Errorf("Expected [short=v] to be reported, got %v", expired)
+	}
+	if m := c.Metrics(); m.Expirations != 1 {
+		t.Errorf("Expected 1 expiration, got %d", m.Expirations)
+	}
+}
+
+func TestBytesCacheMaxCost(t *testing.T) {
+	c := NewBytesCache(WithMaxCost[string, []byte](64 << 10))
+	defer c.Close()
+
+	value := make([]byte, 1000)
+	for i := 0; i < 1000; i++ {
+		c.Set(fmt.Sprintf("key-%d", i), value, 0)
+	}
+	// The policy evicts once the limit is exceeded, so allow one entry of slack.
+	if used := c.liveCost.Load(); used > 64<<10+store.GCFreeEntrySize(10, 1000) {
+		t.Errorf("Expected cost to stay within 64KB, got %d", used)
+	}
+	if m := c.Metrics(); m.Evictions+m.Rejections == 0 {
+		t.Error("Expected evictions or rejections once full")
+	}
+}
+
+func TestBytesCacheScan(t *testing.T) {
+	c := NewBytesCache()
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("user:%d", i), []byte{byte(i)}, 0)
+		c.Set(fmt.Sprintf("order:%d", i), []byte{byte(i)}, 0)
+	}
+	c.Delete("user:0")
+
+	if n := c.Scan(0, 7).Count(); n != 199 {
+		t.Errorf("Expected 199 entries, got %d", n)
+	}
+	if n := c.ScanPrefix("user:", 0, 10).Count(); n != 99 {
+		t.Errorf("Expected 99 user entries, got %d", n)
+	}
+
+	it := c.ScanMatch("order:1?", 0, 10)
+	seen := make(map[string]bool)
+	for it.Next() {
+		if it.Value()[0] < 10 || it.Value()[0] > 19 {
+			t.Errorf("Unexpected value %v for %s", it.Value(), it.Key())
+		}
+		seen[it.Key()] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("Expected 10 matches, got %d", len(seen))
+	}
+}
+
+func TestBytesCacheCompaction(t *testing.T) {
+	c := NewBytesCache(WithShardCount[string, []byte](1))
+	defer c.Close()
+
+	// Overwrite the same keys until the shard has to reclaim space.
+	value := make([]byte, 512)
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 100; i++ {
+			value[0] = byte(round)
+			c.Set(fmt.Sprintf("key-%d", i), value, 0)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		got, ok := c.Get(fmt.Sprintf("key-%d", i))
+		if !ok || got[0] != 49 {
+			t.Fatalf("Expected key-%d from the last round, ok=%v", i, ok)
+		}
+	}
+	if n := c.Scan(0, 10).Count(); n != 100 {
+		t.Errorf("Expected 100 entries after compaction, got %d", n)
+	}
+}
+
+func TestBytesCacheScanBadCursor(t *testing.T) {
+	c := NewBytesCache()
+	defer c.Close()
+	for i := 0; i < 2000; i++ {
+		c.Set(fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d", i)), 0)
+	}
+
+	// Cursors not made by Scan restart their shard instead of reading
+	// across entry boundaries.
+	for pos := uint64(1); pos < 64; pos++ {
+		it := c.Scan(pos, 50)
+		for it.Next() {
+			if want := "value-" + strings.TrimPrefix(it.Key(), "key-"); string(it.Value()) != want {
+				t.Errorf("Expected %s for %s from cursor %d, got %q", want, it.Key(), pos, it.Value())
+			}
+		}
+	}
+
+	// A forged cursor that matches the epoch of its shard but points into
+	// the middle of an entry restarts the shard as well.
+	for pos := uint64(1); pos < 512; pos++ {
+		it := c.Scan(1<<32|pos, 50)
+		for it.Next() {
+			if want := "value-" + strings.TrimPrefix(it.Key(), "key-"); string(it.Value()) != want {
+				t.Errorf("Expected %s for %s from cursor %d, got %q", want, it.Key(), 1<<32|pos, it.Value())
+			}
+		}
+	}
+
+	// Even when the bytes at the forged offset look like an entry.
+	one := NewBytesCache(WithShardCount[string, []byte](1))
+	defer one.Close()
+	fake := make([]byte, 24, 32)
+	binary.LittleEndian.PutUint32(fake[0:4], 4)
+	binary.LittleEndian.PutUint32(fake[4:8], 1)
+	one.Set("key", append(fake, "fakex"...), 0)
+	it := one.Scan(1<<32|uint64(24+len("key")), 10)
+	for it.Next() {
+		if it.Key() != "key" {
+			t.Errorf("Expected only key, got %q", it.Key())
+		}
+	}
+}
+
+func TestBytesCacheScanStaleCursor(t *testing.T) {
+	c := NewBytesCache(WithShardCount[string, []byte](1))
+	defer c.Close()
+	value := make([]byte, 512)
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("key-%d", i), value, 0)
+	}
+
+	it := c.Scan(0, 10)
+	for i := 0; i < 10 && it.Next(); i++ {
+	}
+	cursor := it.Cursor()
+	if cursor == 0 {
+		t.Fatal("Expected a cursor into the middle of the shard")
+	}
+
+	// Overwrites fill the shard until it is compacted, moving every entry.
+	for round := 1; round <= 40; round++ {
+		value[0] = byte(round)
+		for i := 0; i < 100; i++ {
+			c.Set(fmt.Sprintf("key-%d", i), value, 0)
+		}
+	}
+
+	seen := make(map[string]bool)
+	it = c.Scan(cursor, 10)
+	for it.Next() {
+		if len(it.Value()) != 512 || it.Value()[0] != 40 {
+			t.Errorf("Expected the last value of %s, got %d bytes", it.Key(), len(it.Value()))
+		}
+		seen[it.Key()] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected a stale cursor to restart the shard and see 100 keys, got %d", len(seen))
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/OrlovEvgeny/go-mcache/internal/policy"
 	"github.com/OrlovEvgeny/go-mcache/internal/radix"
 	"github.com/OrlovEvgeny/go-mcache/internal/store"
+	"github.com/OrlovEvgeny/go-mcache/internal/trace"
 )
 
 // Item represents an item to be stored in the cache.
@@ -23,6 +24,8 @@ type Item[K comparable, V any] struct {
 	Value V
 	Cost  int64         // 0 = auto-calculate (cost of 1)
 	TTL   time.Duration // 0 = no expiration
+	Tags  []string      // see SetWithTags
+	Deps  []K           // see SetWithDeps
 }
 
 // Cache is a generic, high-performance in-memory cache.
@@ -35,6 +38,17 @@ type Cache[K comparable, V any] struct {
 	config      *config[K, V]
 	writeBuffer *buffer.WriteBuffer[writeItem[K, V]]
 	readBuffer  *buffer.LossyBuffer[uint64]
+	loads       loadGroup[K, V]
+	observer    hitObserver          // nil unless the policy adapts to the hit rate
+	wal         *walWriter[K, V]     // nil unless WithWAL is set
+	recorder    *trace.Writer        // nil unless WithTraceRecorder is set
+	writer      *backingWriter[K, V] // nil unless WithWriter is set
+	invalidator *invalidator         // nil unless WithInvalidator is set
+	tags        linkIndex[string, K]
+	deps        linkIndex[K, K]
+	cascades    cascadeQueue[K]
+	namespaces  namespaceIndex[K]
+	watchers    watcherSet[K, V]
 
 	ctx      context.Context
 	cancel   context.CancelFunc
@@ -54,7 +68,20 @@ type writeItem[K comparable, V any] struct {
 }
 
 // NewCache creates a new generic Cache with the given options.
+// It panics if an option that touches external resources, such as WithWAL,
+// cannot be set up; use OpenCache to handle such errors.
 func NewCache[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
+	c, err := OpenCache(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// OpenCache is like NewCache but returns an error instead of panicking
+// when an option cannot be set up, for example when the WAL directory is
+// unusable or its contents cannot be replayed.
+func OpenCache[K comparable, V any](opts ...Option[K, V]) (*Cache[K, V], error) {
 	cfg := defaultConfig[K, V]()
 	for _, opt := range opts {
 		opt(cfg)
@@ -73,7 +100,11 @@ func NewCache[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
 
 	// Create policy based on configuration
 	var pol policy.Policer[K]
-	if cfg.UseLockFreePolicy {
+	if cfg.Policy != nil {
+		pol = cfg.Policy
+		pol.SetMaxCost(cfg.MaxCost)
+		pol.SetMaxEntries(cfg.MaxEntries)
+	} else if cfg.UseLockFreePolicy {
 		pol = policy.NewPolicyLockFree[K](cfg.NumCounters, cfg.MaxCost, cfg.MaxEntries)
 	} else {
 		pol = policy.NewPolicy[K](cfg.NumCounters, cfg.MaxCost, cfg.MaxEntries)
@@ -90,6 +121,15 @@ func NewCache[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
 	if cfg.MetricsEnabled {
 		c.metrics = newMetrics()
 	}
+	if o, ok := pol.(hitObserver); ok {
+		c.observer = o
+	}
+	if p, ok := pol.(partitioner[K]); ok && cfg.Partition != nil {
+		p.SetPartitions(cfg.Partition, cfg.PartitionWeights)
+	}
+	if cfg.TraceRecorder != nil {
+		c.recorder = trace.NewWriter(cfg.TraceRecorder)
+	}
 
 	// Check if K is string for prefix search support
 	var zeroK K
@@ -119,33 +159,77 @@ func NewCache[K comparable, V any](opts ...Option[K, V]) *Cache[K, V] {
 		)
 	}
 
+	if cfg.Writer != nil {
+		c.openWriter()
+	}
+
+	// Replay and enable the write-ahead log
+	if cfg.WALDir != "" {
+		if err := c.openWAL(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.Invalidator != nil {
+		if err := c.openInvalidator(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
 	// Start background expiration worker
 	c.wg.Add(1)
 	go c.expirationWorker()
 
-	return c
+	return c, nil
 }
 
 // Get retrieves a value from the cache.
 // Returns the value and true if found, zero value and false otherwise.
 func (c *Cache[K, V]) Get(key K) (V, bool) {
-	var zero V
+	entry, ok := c.getEntry(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return entry.Value, true
+}
 
+// GetWithVersion retrieves a value together with its version. Every write
+// to a key stores a new version, so the version identifies the exact write
+// that was read; pass it to CompareAndSwapVersion for optimistic updates.
+func (c *Cache[K, V]) GetWithVersion(key K) (V, uint64, bool) {
+	entry, ok := c.getEntry(key)
+	if !ok {
+		var zero V
+		return zero, 0, false
+	}
+	return entry.Value, entry.Version, true
+}
+
+// getEntry looks up a live entry and records the access.
+func (c *Cache[K, V]) getEntry(key K) (*store.Entry[K, V], bool) {
 	if c.closed.Load() {
-		return zero, false
+		return nil, false
 	}
 
 	keyHash := c.store.KeyHash(key)
+	c.traceEvent(trace.OpGet, keyHash, 0)
 	entry, ok := c.store.GetByHash(key, keyHash)
 	if !ok {
-		c.metrics.incMiss()
-		return zero, false
+		c.recordMiss(key)
+		return nil, false
 	}
 
 	c.recordAccess(keyHash)
-	c.metrics.incHit()
+	c.recordHit(key)
 
-	return entry.Value, true
+	if c.config.RefreshAfter > 0 {
+		c.maybeRefresh(entry)
+	}
+
+	return entry, true
 }
 
 // Set stores a value in the cache with the given TTL.
@@ -158,29 +242,26 @@ func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) bool {
 // SetWithCost stores a value with a specified cost.
 // Cost is used for eviction decisions when MaxCost is set.
 func (c *Cache[K, V]) SetWithCost(key K, value V, cost int64, ttl time.Duration) bool {
-	if c.closed.Load() {
-		return false
-	}
-
-	if cost <= 0 {
-		if c.config.CostFunc != nil {
-			cost = c.config.CostFunc(value)
-		} else {
-			cost = 1
-		}
-	}
+	ok, _ := c.setWithCost(context.Background(), key, value, cost, ttl, nil)
+	return ok
+}
 
-	// Apply default TTL if none specified or negative
-	if ttl <= 0 && c.config.DefaultTTL > 0 {
-		ttl = c.config.DefaultTTL
+// setWithCost implements SetWithCost for an entry with the given links. If
+// the write buffer is full and ctx is already done, the write is dropped
+// and ctx.Err() returned instead of falling back to a synchronous write.
+func (c *Cache[K, V]) setWithCost(ctx context.Context, key K, value V, cost int64, ttl time.Duration, links *store.Links[K]) (bool, error) {
+	if c.closed.Load() {
+		return false, ErrClosed
 	}
 
-	var expireAt int64
-	if ttl > 0 {
-		expireAt = clock.NowNano() + int64(ttl)
+	keyHash := c.store.KeyHash(key)
+	defer c.writerLock(keyHash)()
+	if err := c.persist(ctx, BackingWrite[K, V]{Key: key, Value: value}); err != nil {
+		return false, err
 	}
 
-	keyHash := c.store.KeyHash(key)
+	cost, expireAt := c.normalizeWrite(value, cost, ttl)
+	c.traceEvent(trace.OpSet, keyHash, cost)
 
 	if c.writeBuffer != nil {
 		entry := &store.Entry[K, V]{
@@ -189,42 +270,85 @@ func (c *Cache[K, V]) SetWithCost(key K, value V, cost int64, ttl time.Duration)
 			KeyHash:  keyHash,
 			ExpireAt: expireAt,
 			Cost:     cost,
+			Links:    links,
 		}
 		// Buffered write with synchronous fallback on buffer saturation
 		if !c.writeBuffer.Push(writeItem[K, V]{entry: entry, isSet: true}) {
 			c.metrics.incBufferDrop()
-			return c.setSync(key, value, keyHash, cost, expireAt)
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			ok := c.setSync(key, value, keyHash, cost, expireAt, links)
+			c.finishWrite()
+			return ok, nil
 		}
-		return true
+		return true, nil
+	}
+
+	ok := c.setSync(key, value, keyHash, cost, expireAt, links)
+	c.finishWrite()
+	return ok, nil
+}
+
+// normalizeWrite resolves the effective cost and absolute expiration
+// for a write, applying CostFunc and the default TTL.
+func (c *Cache[K, V]) normalizeWrite(value V, cost int64, ttl time.Duration) (int64, int64) {
+	if cost <= 0 {
+		if c.config.CostFunc != nil {
+			cost = c.config.CostFunc(value)
+		} else {
+			cost = 1
+		}
+	}
+
+	// Apply default TTL if none specified or negative
+	if ttl <= 0 && c.config.DefaultTTL > 0 {
+		ttl = c.config.DefaultTTL
 	}
 
-	return c.setSync(key, value, keyHash, cost, expireAt)
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = clock.NowNano() + int64(ttl)
+	}
+	return cost, expireAt
 }
 
-func (c *Cache[K, V]) setSync(key K, value V, keyHash uint64, cost int64, expireAt int64) bool {
-	if c.tryUpdateExisting(key, value, keyHash, cost, expireAt) {
+func (c *Cache[K, V]) setSync(key K, value V, keyHash uint64, cost int64, expireAt int64, links *store.Links[K]) bool {
+	defer c.walLock(keyHash)()
+
+	if c.tryUpdateExisting(key, value, keyHash, cost, expireAt, links) {
 		return true
 	}
 
 	entry := &store.Entry[K, V]{
-		Key:      key,
-		Value:    value,
-		KeyHash:  keyHash,
-		ExpireAt: expireAt,
-		Cost:     cost,
+		Key:       key,
+		Value:     value,
+		KeyHash:   keyHash,
+		ExpireAt:  expireAt,
+		WrittenAt: clock.NowNano(),
+		Cost:      cost,
+		Links:     links,
 	}
 	return c.doSet(entry)
 }
 
 // doSet performs the actual set operation for new entries.
 func (c *Cache[K, V]) doSet(entry *store.Entry[K, V]) bool {
+	if !c.admit(entry) {
+		return false
+	}
+	prev := c.store.Set(entry)
+	c.afterInsert(entry, prev)
+	return true
+}
+
+// admit asks the admission policy to make room for a new entry and evicts
+// the victims it selects. Returns false if the entry is rejected.
+func (c *Cache[K, V]) admit(entry *store.Entry[K, V]) bool {
 	// Check admission policy and get victims to evict
 	victims, added := c.policy.Add(entry.Key, entry.KeyHash, entry.Cost)
 	if !added {
-		c.metrics.incRejection()
-		if c.config.OnReject != nil {
-			c.config.OnReject(entry.Key, entry.Value)
-		}
+		c.reject(entry)
 		return false
 	}
 
@@ -232,10 +356,28 @@ func (c *Cache[K, V]) doSet(entry *store.Entry[K, V]) bool {
 	for _, victim := range victims {
 		c.evictVictim(victim)
 	}
+	return true
+}
 
-	// Store the entry
-	prev := c.store.Set(entry)
+// reject reports an entry refused by the admission policy.
+func (c *Cache[K, V]) reject(entry *store.Entry[K, V]) {
+	c.metrics.incRejection()
+	if c.config.OnReject != nil {
+		c.config.OnReject(entry.Key, entry.Value)
+	}
+	c.notify(Event[K, V]{Type: EventReject, Cause: CauseCapacity, Key: entry.Key, NewValue: entry.Value})
+}
+
+// afterInsert applies the bookkeeping for an entry just stored, replacing
+// prev if it is non-nil.
+func (c *Cache[K, V]) afterInsert(entry, prev *store.Entry[K, V]) {
 	c.liveCost.Add(entry.Cost)
+	c.unlink(prev)
+	c.link(entry.Key, entry.Cost, entry.Links)
+	if prev != nil {
+		c.dependencyChanged(entry.Key)
+	}
+	c.walSet(entry.Key, entry.Value, entry.ExpireAt, entry.Cost)
 
 	// Schedule background expiration if entry has TTL.
 	if entry.ExpireAt > 0 {
@@ -257,7 +399,11 @@ func (c *Cache[K, V]) doSet(entry *store.Entry[K, V]) bool {
 	c.metrics.incSet()
 	c.metrics.addCost(entry.Cost)
 
-	return true
+	if prev != nil {
+		c.notify(Event[K, V]{Type: EventUpdate, Key: entry.Key, OldValue: prev.Value, NewValue: entry.Value})
+	} else {
+		c.notify(Event[K, V]{Type: EventSet, Key: entry.Key, NewValue: entry.Value})
+	}
 }
 
 // evictVictim evicts an entry selected by the admission policy.
@@ -269,6 +415,9 @@ func (c *Cache[K, V]) evictVictim(victim policy.Victim[K]) {
 		return
 	}
 	c.liveCost.Add(-deleted.Cost)
+	c.walDelete(victim.Key)
+	c.unlink(deleted)
+	c.dependencyChanged(victim.Key)
 
 	// Remove from radix tree
 	if c.isStringKey && c.radixTree != nil {
@@ -279,20 +428,53 @@ func (c *Cache[K, V]) evictVictim(victim policy.Victim[K]) {
 
 	c.metrics.incEviction()
 	c.metrics.addEvictedCost(deleted.Cost)
+	if c.config.Partition != nil {
+		c.metrics.partition(c.config.Partition(victim.Key)).evictions.Add(1)
+	}
 
 	if c.config.OnEvict != nil {
 		c.config.OnEvict(deleted.Key, deleted.Value, deleted.Cost)
 	}
+	if c.config.Demote != nil {
+		c.metrics.incDemotion()
+		c.config.Demote(deleted.Key, deleted.Value, deleted.Cost, deleted.ExpireAt)
+	}
+	c.notify(Event[K, V]{Type: EventEvict, Cause: CauseCapacity, Key: deleted.Key, OldValue: deleted.Value})
 }
 
 // Delete removes a value from the cache.
 // Returns true if the value was found and deleted.
 func (c *Cache[K, V]) Delete(key K) bool {
+	ok, _ := c.delete(context.Background(), key)
+	return ok
+}
+
+// delete implements Delete, dropping a buffered delete like setWithCost
+// drops a buffered write.
+func (c *Cache[K, V]) delete(ctx context.Context, key K) (bool, error) {
 	if c.closed.Load() {
-		return false
+		return false, ErrClosed
 	}
 
+	unlock := c.writerLock(c.store.KeyHash(key))
+	err := c.persist(ctx, BackingWrite[K, V]{Key: key, Delete: true})
+	var ok bool
+	if err == nil {
+		ok, err = c.remove(ctx, key)
+	}
+	unlock()
+	if err != nil {
+		return false, err
+	}
+	c.publish(InvalidationDelete, key, "")
+	return ok, nil
+}
+
+// remove deletes key from the cache without passing the delete on to the
+// writer or the Invalidator.
+func (c *Cache[K, V]) remove(ctx context.Context, key K) (bool, error) {
 	keyHash := c.store.KeyHash(key)
+	c.traceEvent(trace.OpDelete, keyHash, 0)
 
 	if c.writeBuffer != nil {
 		// Buffered delete with synchronous fallback on buffer saturation
@@ -300,34 +482,59 @@ func (c *Cache[K, V]) Delete(key K) bool {
 		entry := &store.Entry[K, V]{Key: key, KeyHash: keyHash, Value: zero}
 		if !c.writeBuffer.Push(writeItem[K, V]{entry: entry, isSet: false}) {
 			c.metrics.incBufferDrop()
-			return c.doDelete(key, keyHash)
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			ok := c.doDelete(key, keyHash)
+			c.finishWrite()
+			return ok, nil
 		}
-		return true
+		return true, nil
 	}
 
-	return c.doDelete(key, keyHash)
+	ok := c.doDelete(key, keyHash)
+	c.finishWrite()
+	return ok, nil
 }
 
 // doDelete performs the actual delete operation.
 func (c *Cache[K, V]) doDelete(key K, keyHash uint64) bool {
+	defer c.walLock(keyHash)()
+
 	deleted := c.store.DeleteByHash(key, keyHash)
 	if deleted == nil {
 		return false
 	}
+	c.afterDelete(deleted)
+	return true
+}
+
+// afterDelete applies the bookkeeping for an entry just removed by a
+// delete.
+func (c *Cache[K, V]) afterDelete(deleted *store.Entry[K, V]) {
+	c.removed(deleted)
+	c.dependencyChanged(deleted.Key)
+	c.notify(Event[K, V]{Type: EventDelete, Key: deleted.Key, OldValue: deleted.Value})
+}
+
+// removed is afterDelete without scheduling a cascade, for entries deleted
+// by one.
+func (c *Cache[K, V]) removed(deleted *store.Entry[K, V]) {
 	c.liveCost.Add(-deleted.Cost)
+	c.walDelete(deleted.Key)
+	c.unlink(deleted)
 
 	// Remove from policy
-	c.policy.Del(key, keyHash)
+	c.policy.Del(deleted.Key, deleted.KeyHash)
 
 	// Remove from radix tree
 	if c.isStringKey && c.radixTree != nil {
-		if strKey, ok := any(key).(string); ok {
+		if strKey, ok := any(deleted.Key).(string); ok {
 			c.radixTree.Delete(strKey)
 		}
 	}
 
 	c.metrics.incDelete()
-	return true
 }
 
 // Has checks if a key exists in the cache.
@@ -338,6 +545,55 @@ func (c *Cache[K, V]) Has(key K) bool {
 	return c.store.Has(key)
 }
 
+// TTL returns the remaining time to live of an entry, or 0 if it never
+// expires. Returns false if the key is not in the cache.
+func (c *Cache[K, V]) TTL(key K) (time.Duration, bool) {
+	if c.closed.Load() {
+		return 0, false
+	}
+	entry, ok := c.store.GetByHash(key, c.store.KeyHash(key))
+	if !ok {
+		return 0, false
+	}
+	if entry.ExpireAt == 0 {
+		return 0, true
+	}
+	return time.Duration(max(entry.ExpireAt-clock.NowNano(), 0)), true
+}
+
+// Expire sets a new TTL on an existing entry without changing its value.
+// A TTL of 0 or less removes the expiration. Returns false if the key is
+// not in the cache.
+func (c *Cache[K, V]) Expire(key K, ttl time.Duration) bool {
+	if c.closed.Load() {
+		return false
+	}
+
+	keyHash := c.store.KeyHash(key)
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = clock.NowNano() + int64(ttl)
+	}
+
+	ok := c.expireSync(key, keyHash, expireAt)
+	c.walCommit()
+	return ok
+}
+
+func (c *Cache[K, V]) expireSync(key K, keyHash uint64, expireAt int64) bool {
+	defer c.walLock(keyHash)()
+
+	entry := c.store.UpdateExpireByHash(key, keyHash, expireAt)
+	if entry == nil {
+		return false
+	}
+	if expireAt > 0 {
+		c.expiryWheel.Schedule(key, keyHash, expireAt)
+	}
+	c.walSet(key, entry.Value, expireAt, entry.Cost)
+	return true
+}
+
 // GetMany retrieves multiple values from the cache.
 // Returns a map of found keys to their values.
 func (c *Cache[K, V]) GetMany(keys []K) map[K]V {
@@ -395,9 +651,9 @@ func (c *Cache[K, V]) GetBatch(keys []K) *BatchResult[K, V] {
 			result.Values[i] = req.Results[i].Value
 			result.Found[i] = true
 			c.recordAccess(result.Hashes[i])
-			c.metrics.incHit()
+			c.recordHit(keys[i])
 		} else {
-			c.metrics.incMiss()
+			c.recordMiss(keys[i])
 		}
 	}
 
@@ -438,9 +694,9 @@ func (c *Cache[K, V]) GetBatchOptimized(keys []K) *BatchResult[K, V] {
 			result.Values[i] = entries[i].Value
 			result.Found[i] = true
 			c.recordAccess(result.Hashes[i])
-			c.metrics.incHit()
+			c.recordHit(keys[i])
 		} else {
-			c.metrics.incMiss()
+			c.recordMiss(keys[i])
 		}
 	}
 
@@ -465,7 +721,7 @@ func (c *Cache[K, V]) GetBatchToMap(keys []K) map[K]V {
 func (c *Cache[K, V]) SetMany(items []Item[K, V]) int {
 	count := 0
 	for _, item := range items {
-		if c.SetWithCost(item.Key, item.Value, item.Cost, item.TTL) {
+		if ok, _ := c.setWithCost(context.Background(), item.Key, item.Value, item.Cost, item.TTL, newLinks(item.Tags, item.Deps)); ok {
 			count++
 		}
 	}
@@ -492,6 +748,9 @@ func (c *Cache[K, V]) Wait() {
 	if c.readBuffer != nil {
 		c.readBuffer.FlushSync()
 	}
+	if c.writer != nil && c.writer.buffer != nil {
+		c.writer.buffer.FlushSync()
+	}
 }
 
 // Scan returns an iterator over cache entries.
@@ -526,9 +785,71 @@ func (c *Cache[K, V]) ScanMatch(pattern string, cursor uint64, count int) *Itera
 	return newIterator(c, cursor, count, pat.Prefix(), pat)
 }
 
+// ScanKeys returns one page of keys with Redis SCAN semantics. Starting
+// at cursor (0 for a new scan), it examines whole shards until at least
+// count entries have been seen and returns the keys matching pattern
+// (all keys if pattern is empty; only string keys can match a non-empty
+// pattern) together with the cursor for the next call, which is 0 once
+// the scan is complete. A key present for the entire scan is returned at
+// least once; pages may be empty or hold more than count keys.
+func (c *Cache[K, V]) ScanKeys(cursor uint64, count int, pattern string) ([]K, uint64) {
+	if c.closed.Load() {
+		return nil, 0
+	}
+	if count <= 0 {
+		count = 10
+	}
+
+	var pat *glob.Pattern
+	if pattern != "" && pattern != "*" {
+		if !c.isStringKey {
+			return nil, 0
+		}
+		var err error
+		if pat, err = glob.Compile(pattern); err != nil {
+			return nil, 0
+		}
+	}
+
+	var keys []K
+	seen := 0
+	shard := int(cursor)
+	for ; shard < c.store.ShardCount() && seen < count; shard++ {
+		c.store.RangeShard(shard, func(entry *store.Entry[K, V]) bool {
+			seen++
+			if entry.IsExpired() {
+				return true
+			}
+			if pat != nil && !pat.Match(any(entry.Key).(string)) {
+				return true
+			}
+			keys = append(keys, entry.Key)
+			return true
+		})
+	}
+	if shard >= c.store.ShardCount() {
+		return keys, 0
+	}
+	return keys, uint64(shard)
+}
+
 // Metrics returns the cache metrics.
 func (c *Cache[K, V]) Metrics() MetricsSnapshot {
-	return c.metrics.Snapshot()
+	s := c.metrics.Snapshot()
+	if w, ok := c.policy.(interface{ WindowFraction() float64 }); ok {
+		s.WindowFraction = w.WindowFraction()
+	}
+	if p, ok := c.policy.(partitioner[K]); ok && c.metrics != nil && c.config.Partition != nil {
+		for name, cost := range p.PartitionCosts() {
+			if s.Partitions == nil {
+				s.Partitions = make(map[string]PartitionMetrics)
+			}
+			pm := s.Partitions[name]
+			pm.Cost = cost
+			s.Partitions[name] = pm
+		}
+	}
+	return s
 }
 
 // Len returns the number of entries in the cache.
@@ -536,19 +857,42 @@ func (c *Cache[K, V]) Len() int {
 	return c.store.Len()
 }
 
-// Clear removes all entries from the cache.
+// Clear removes all entries from the cache and resets its metrics.
 func (c *Cache[K, V]) Clear() {
+	c.clear()
+	c.metrics.Reset()
+	if !c.closed.Load() {
+		var zero K
+		c.publish(InvalidationClear, zero, "")
+	}
+}
+
+// clear implements Clear without resetting the metrics or publishing to
+// the Invalidator, for replayed and received clears.
+func (c *Cache[K, V]) clear() {
 	c.Wait()
+	defer c.notify(Event[K, V]{Type: EventClear, Cause: CauseUser})
 	c.clearMu.Lock()
 	defer c.clearMu.Unlock()
+	if c.wal != nil {
+		for i := range c.wal.stripes {
+			c.wal.stripes[i].Lock()
+			defer c.wal.stripes[i].Unlock()
+		}
+		c.wal.clear()
+		defer c.walCommit()
+	}
 	c.store.Clear()
 	c.policy.Clear()
 	c.expiryWheel.Clear()
 	if c.radixTree != nil {
 		c.radixTree.Clear()
 	}
-	c.metrics.Reset()
 	c.liveCost.Store(0)
+	c.tags.clear()
+	c.deps.clear()
+	c.cascades.clear()
+	c.namespaces.clear()
 }
 
 // Close stops the cache and releases resources.
@@ -558,6 +902,7 @@ func (c *Cache[K, V]) Close() {
 	}
 
 	c.cancel()
+	c.closeInvalidator()
 
 	if c.writeBuffer != nil {
 		c.writeBuffer.Close()
@@ -567,17 +912,21 @@ func (c *Cache[K, V]) Close() {
 	}
 
 	c.wg.Wait()
+	c.closeWriter()
+	c.closeWAL()
+	c.closeTrace()
 }
 
 // processWriteBatch processes a batch of pending writes.
 func (c *Cache[K, V]) processWriteBatch(items []writeItem[K, V]) {
 	for _, item := range items {
 		if item.isSet {
-			c.setSync(item.entry.Key, item.entry.Value, item.entry.KeyHash, item.entry.Cost, item.entry.ExpireAt)
+			c.setSync(item.entry.Key, item.entry.Value, item.entry.KeyHash, item.entry.Cost, item.entry.ExpireAt, item.entry.Links)
 		} else {
 			c.doDelete(item.entry.Key, item.entry.KeyHash)
 		}
 	}
+	c.finishWrite()
 }
 
 // processReadBatch replays access events in batches.
@@ -619,6 +968,9 @@ func (c *Cache[K, V]) removeExpired() {
 
 	now := clock.NowNano()
 	expired := c.expiryWheel.Advance(now)
+	if len(expired) > 0 {
+		defer c.finishWrite()
+	}
 
 	for _, item := range expired {
 		entry := c.store.DeleteIfExpired(item.Key, item.KeyHash, item.ExpireAt, now)
@@ -626,6 +978,9 @@ func (c *Cache[K, V]) removeExpired() {
 			continue
 		}
 		c.liveCost.Add(-entry.Cost)
+		c.walDelete(entry.Key)
+		c.unlink(entry)
+		c.dependencyChanged(entry.Key)
 		c.policy.Del(entry.Key, entry.KeyHash)
 
 		if c.isStringKey && c.radixTree != nil {
@@ -639,6 +994,31 @@ func (c *Cache[K, V]) removeExpired() {
 		if c.config.OnExpire != nil {
 			c.config.OnExpire(entry.Key, entry.Value)
 		}
+		c.notify(Event[K, V]{Type: EventExpire, Cause: CauseTTL, Key: entry.Key, OldValue: entry.Value})
+	}
+}
+
+// recordHit counts a hit in the metrics and reports it to an adaptive
+// policy.
+func (c *Cache[K, V]) recordHit(key K) {
+	c.metrics.incHit()
+	if c.metrics != nil && c.config.Partition != nil {
+		c.metrics.partition(c.config.Partition(key)).hits.Add(1)
+	}
+	if c.observer != nil {
+		c.observer.RecordHit()
+	}
+}
+
+// recordMiss counts a miss in the metrics and reports it to an adaptive
+// policy.
+func (c *Cache[K, V]) recordMiss(key K) {
+	c.metrics.incMiss()
+	if c.metrics != nil && c.config.Partition != nil {
+		c.metrics.partition(c.config.Partition(key)).misses.Add(1)
+	}
+	if c.observer != nil {
+		c.observer.RecordMiss()
 	}
 }
 
@@ -657,6 +1037,10 @@ func (c *Cache[K, V]) shouldTrackAccess() bool {
 	if c.config.MaxEntries <= 0 && c.config.MaxCost <= 0 {
 		return false
 	}
+	if c.config.Policy != nil {
+		// Recency-based policies need accesses from the start.
+		return true
+	}
 	if c.config.MaxEntries > 0 && int64(c.store.Len()) >= c.config.MaxEntries/2 {
 		return true
 	}
@@ -666,7 +1050,7 @@ func (c *Cache[K, V]) shouldTrackAccess() bool {
 	return false
 }
 
-func (c *Cache[K, V]) tryUpdateExisting(key K, value V, keyHash uint64, cost int64, expireAt int64) bool {
+func (c *Cache[K, V]) tryUpdateExisting(key K, value V, keyHash uint64, cost int64, expireAt int64, links *store.Links[K]) bool {
 	if _, ok := c.store.PeekByHash(key, keyHash); !ok {
 		return false
 	}
@@ -677,12 +1061,22 @@ func (c *Cache[K, V]) tryUpdateExisting(key K, value V, keyHash uint64, cost int
 		value,
 		cost,
 		expireAt,
-		c.config.OnEvict != nil,
+		links,
+		c.capturePrevious(),
 	)
 	if !updated {
 		return false
 	}
+	c.afterUpdate(key, value, keyHash, cost, expireAt, links, prev, costDelta)
+	return true
+}
 
+// afterUpdate applies the bookkeeping for an in-place update of an
+// existing entry. prev is required if the previous entry had links.
+func (c *Cache[K, V]) afterUpdate(key K, value V, keyHash uint64, cost, expireAt int64, links *store.Links[K], prev *store.Entry[K, V], costDelta int64) {
+	c.unlink(prev)
+	c.link(key, cost, links)
+	c.dependencyChanged(key)
 	if costDelta != 0 {
 		c.liveCost.Add(costDelta)
 		c.policy.Update(key, keyHash, cost)
@@ -690,6 +1084,7 @@ func (c *Cache[K, V]) tryUpdateExisting(key K, value V, keyHash uint64, cost int
 	if expireAt > 0 {
 		c.expiryWheel.Schedule(key, keyHash, expireAt)
 	}
+	c.walSet(key, value, expireAt, cost)
 
 	c.metrics.incSet()
 	c.metrics.addCost(cost)
@@ -698,7 +1093,25 @@ func (c *Cache[K, V]) tryUpdateExisting(key K, value V, keyHash uint64, cost int
 		c.config.OnEvict(prev.Key, prev.Value, prev.Cost)
 	}
 
-	return true
+	ev := Event[K, V]{Type: EventUpdate, Key: key, NewValue: value}
+	if prev != nil {
+		ev.OldValue = prev.Value
+	}
+	c.notify(ev)
+}
+
+// capturePrevious reports whether updates must return the entry they
+// replace, for OnEvict and watchers.
+func (c *Cache[K, V]) capturePrevious() bool {
+	return c.config.OnEvict != nil || c.watching()
+}
+
+// finishWrite completes a write operation once it has released its locks:
+// it deletes the dependents of the entries the operation changed, then
+// commits the WAL records of both.
+func (c *Cache[K, V]) finishWrite() {
+	c.cascade()
+	c.walCommit()
 }
 
 // defaultKeyHasher returns the default hasher for a key type.
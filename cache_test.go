@@ -3,6 +3,7 @@ package mcache
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -902,3 +903,112 @@ func TestCacheConcurrentSetExpiry(t *testing.T) {
 		}
 	}
 }
+
+func TestTTLAndExpire(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	if _, ok := c.TTL("missing"); ok {
+		t.Error("Expected TTL of a missing key to report false")
+	}
+	if c.Expire("missing", time.Minute) {
+		t.Error("Expected Expire of a missing key to return false")
+	}
+
+	c.Set("k", 1, 0)
+	if ttl, ok := c.TTL("k"); !ok || ttl != 0 {
+		t.Errorf("Expected no expiration, got %v, %v", ttl, ok)
+	}
+
+	if !c.Expire("k", time.Minute) {
+		t.Fatal("Expected Expire to succeed")
+	}
+	if ttl, ok := c.TTL("k"); !ok || ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("Expected a TTL of about a minute, got %v", ttl)
+	}
+	if v, ok := c.Get("k"); !ok || v != 1 {
+		t.Errorf("Expected Expire to keep the value, got %v, %v", v, ok)
+	}
+
+	c.Expire("k", 50*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	if c.Has("k") {
+		t.Error("Expected the key to expire")
+	}
+
+	c.Set("p", 1, 50*time.Millisecond)
+	c.Expire("p", 0)
+	time.Sleep(200 * time.Millisecond)
+	if ttl, ok := c.TTL("p"); !ok || ttl != 0 {
+		t.Errorf("Expected Expire(0) to remove the expiration, got %v, %v", ttl, ok)
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	c := NewCache[string, int](WithShardCount[string, int](8))
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(fmt.Sprintf("a:%d", i), i, 0)
+		c.Set(fmt.Sprintf("b:%d", i), i, 0)
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		keys, next := c.ScanKeys(cursor, 10, "a:*")
+		for _, key := range keys {
+			seen[key]++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 100 {
+		t.Errorf("Expected 100 keys, got %d", len(seen))
+	}
+	for key, n := range seen {
+		if !strings.HasPrefix(key, "a:") || n != 1 {
+			t.Errorf("Unexpected key %q seen %d times", key, n)
+		}
+	}
+
+	all, next := c.ScanKeys(0, 1000, "")
+	if len(all) != 200 || next != 0 {
+		t.Errorf("Expected all 200 keys in one page, got %d (cursor %d)", len(all), next)
+	}
+}
+
+func TestGetWithVersionAndCompareAndSwap(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	if _, _, ok := c.GetWithVersion("missing"); ok {
+		t.Error("Expected GetWithVersion of a missing key to report false")
+	}
+	if swapped, found := c.CompareAndSwapVersion("missing", 1, 1, 0); swapped || found {
+		t.Errorf("Expected a missing key to report not found, got %v, %v", swapped, found)
+	}
+
+	c.Set("k", 1, 0)
+	v, ver, ok := c.GetWithVersion("k")
+	if !ok || v != 1 || ver == 0 {
+		t.Fatalf("Expected value 1 with a version, got %v, %d, %v", v, ver, ok)
+	}
+
+	if swapped, found := c.CompareAndSwapVersion("k", 2, ver+1, 0); swapped || !found {
+		t.Errorf("Expected a stale version to fail, got %v, %v", swapped, found)
+	}
+	if swapped, _ := c.CompareAndSwapVersion("k", 2, ver, 0); !swapped {
+		t.Error("Expected the current version to swap")
+	}
+	if swapped, _ := c.CompareAndSwapVersion("k", 3, ver, 0); swapped {
+		t.Error("Expected the old version to fail after a swap")
+	}
+
+	v, ver2, _ := c.GetWithVersion("k")
+	if v != 2 || ver2 <= ver {
+		t.Errorf("Expected value 2 with a newer version, got %v, %d (was %d)", v, ver2, ver)
+	}
+}
@@ -0,0 +1,275 @@
+// Package cluster spreads a Cache[string, []byte] over a group of peers
+// so that each key is loaded by one peer only, in the style of
+// groupcache. Every process runs a Node with its own cache and the same
+// loader; a Ring of peer names assigns each key an owner.
+//
+// Node.Get serves a key from the local cache if present. Otherwise the
+// owner loads it into its cache with GetOrLoad, and any other peer
+// fetches it from the owner through a Transport instead of calling the
+// loader. Concurrent fetches of a key from one Node are deduplicated. If
+// the owner cannot be reached or does not answer within the fetch
+// timeout, the key is loaded locally, so a peer failure costs duplicate
+// loads rather than errors.
+//
+// Fetched values are not kept by default, so a hot key costs a peer
+// fetch per Get. WithHotCache replicates fetched values into a second
+// cache whose admission policy decides which keys are hot enough to keep,
+// with a TTL bounding how stale a replica can get.
+//
+// HTTPTransport fetches over HTTP from peers serving their Node as an
+// http.Handler; InProcessTransport connects Nodes in one process, for
+// tests and simulations.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// Node is the member of a cluster running in this process.
+type Node struct {
+	self      string
+	cache     *mcache.Cache[string, []byte]
+	loader    mcache.Loader[string, []byte]
+	transport Transport
+	ring      atomic.Pointer[Ring]
+	fetches   flightGroup
+
+	fetchTimeout time.Duration
+
+	hot    *mcache.Cache[string, []byte]
+	hotTTL time.Duration
+
+	loads       atomic.Int64
+	peerFetches atomic.Int64
+	peerErrors  atomic.Int64
+	hotHits     atomic.Int64
+	served      atomic.Int64
+}
+
+// defaultFetchTimeout bounds a fetch from another peer unless
+// WithFetchTimeout says otherwise.
+const defaultFetchTimeout = 10 * time.Second
+
+// Option configures a Node.
+type Option func(*Node)
+
+// WithFetchTimeout bounds each fetch from another peer. A fetch taking
+// longer fails and the key is loaded locally, so a peer that hangs cannot
+// hold up the Gets of its keys. d <= 0 disables the limit, leaving it to
+// the Transport. Default: 10s.
+func WithFetchTimeout(d time.Duration) Option {
+	return func(n *Node) {
+		n.fetchTimeout = d
+	}
+}
+
+// WithHotCache replicates values fetched from other peers into hot for at
+// most ttl (0 keeps the TTL set by the owner). hot should be small and
+// bounded; its admission policy keeps the frequently fetched keys. The
+// caller keeps ownership of hot. Default: fetched values are not kept.
+func WithHotCache(hot *mcache.Cache[string, []byte], ttl time.Duration) Option {
+	return func(n *Node) {
+		n.hot = hot
+		n.hotTTL = ttl
+	}
+}
+
+// New returns the Node named self, storing the keys it owns in cache and
+// loading them with loader, and fetching other keys with transport. self
+// is the name other peers use to reach this Node, such as its base URL
+// with HTTPTransport. The ring holds only self until SetPeers is called.
+// The caller keeps ownership of cache and closes it after the Node is no
+// longer used. New panics if loader is nil.
+func New(self string, cache *mcache.Cache[string, []byte], loader mcache.Loader[string, []byte], transport Transport, opts ...Option) *Node {
+	if loader == nil {
+		panic(mcache.ErrNoLoader)
+	}
+	n := &Node{
+		self:      self,
+		cache:     cache,
+		loader:    loader,
+		transport: transport,
+
+		fetchTimeout: defaultFetchTimeout,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.ring.Store(NewRing(self))
+	if t, ok := transport.(*InProcessTransport); ok {
+		t.add(n)
+	}
+	return n
+}
+
+// Self returns the name of the Node.
+func (n *Node) Self() string {
+	return n.self
+}
+
+// SetPeers replaces the peers of the ring. self is always included.
+// Keys moving to another owner are fetched from it from now on; values
+// this Node cached for keys it no longer owns expire as usual.
+func (n *Node) SetPeers(peers ...string) {
+	n.ring.Store(NewRing(append(slices.Clone(peers), n.self)...))
+}
+
+// Ring returns the current ring.
+func (n *Node) Ring() *Ring {
+	return n.ring.Load()
+}
+
+// Owner returns the peer owning key.
+func (n *Node) Owner(key string) string {
+	return n.ring.Load().Owner(key)
+}
+
+// Get returns the value for key from the local cache, the hot cache, the
+// owning peer or, if this Node owns key or the owner cannot be reached,
+// the loader. An error of the loader on the owner is returned as a
+// *LoadError.
+func (n *Node) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, ok := n.cache.Get(key); ok {
+		return value, nil
+	}
+	if n.hot != nil {
+		if value, ok := n.hot.Get(key); ok {
+			n.hotHits.Add(1)
+			return value, nil
+		}
+	}
+	owner := n.Owner(key)
+	if owner == n.self {
+		return n.cache.GetOrLoad(ctx, key, n.load)
+	}
+	return n.fetches.do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		return n.fetch(ctx, owner, key)
+	})
+}
+
+// fetch gets key from owner, falling back to the loader if owner cannot
+// be reached in time.
+func (n *Node) fetch(ctx context.Context, owner, key string) ([]byte, error) {
+	fctx := ctx
+	if n.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		fctx, cancel = context.WithTimeout(ctx, n.fetchTimeout)
+		defer cancel()
+	}
+	value, ttl, err := n.transport.Fetch(fctx, owner, key)
+	if err != nil {
+		var le *LoadError
+		if errors.As(err, &le) {
+			return nil, err
+		}
+		n.peerErrors.Add(1)
+		value, _, ttl, err = n.load(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		n.peerFetches.Add(1)
+	}
+	if n.hot != nil {
+		if n.hotTTL > 0 && (ttl <= 0 || ttl > n.hotTTL) {
+			ttl = n.hotTTL
+		}
+		n.hot.Set(key, value, ttl)
+	}
+	return value, nil
+}
+
+// load runs the loader, counting the load.
+func (n *Node) load(ctx context.Context, key string) ([]byte, int64, time.Duration, error) {
+	n.loads.Add(1)
+	return n.loader(ctx, key)
+}
+
+// serve answers a fetch from another peer. The key is loaded locally even
+// if this Node's ring names another owner, so peers with diverging rings
+// cannot forward a fetch in circles.
+func (n *Node) serve(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	n.served.Add(1)
+	value, err := n.cache.GetOrLoad(ctx, key, n.load)
+	if err != nil {
+		return nil, 0, err
+	}
+	ttl, _ := n.cache.TTL(key)
+	return value, ttl, nil
+}
+
+// Stats are counters of a Node since it was created.
+type Stats struct {
+	Loads       int64 // Loader calls, including fallbacks
+	PeerFetches int64 // Values fetched from other peers
+	PeerErrors  int64 // Fetches that failed and fell back to the loader
+	HotHits     int64 // Gets served from the hot cache
+	Served      int64 // Fetches answered for other peers
+}
+
+// Stats returns the counters of the Node.
+func (n *Node) Stats() Stats {
+	return Stats{
+		Loads:       n.loads.Load(),
+		PeerFetches: n.peerFetches.Load(),
+		PeerErrors:  n.peerErrors.Load(),
+		HotHits:     n.hotHits.Load(),
+		Served:      n.served.Load(),
+	}
+}
+
+// flightCall is an in-flight fetch shared by all waiters of a key.
+type flightCall struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// flightGroup deduplicates concurrent fetches of the same key.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do runs fn once for concurrent callers with the same key. fn runs
+// detached from the cancellation of ctx, and each caller returns early
+// with ctx.Err() if its own ctx is cancelled.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		if g.calls == nil {
+			g.calls = make(map[string]*flightCall)
+		}
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					call.value, call.err = nil, fmt.Errorf("cluster: fetch panic: %v", r)
+				}
+				g.mu.Lock()
+				delete(g.calls, key)
+				g.mu.Unlock()
+				close(call.done)
+			}()
+			call.value, call.err = fn(context.WithoutCancel(ctx))
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// countingLoader loads "value-<key>" and counts the loads of each key.
+type countingLoader struct {
+	mu    sync.Mutex
+	loads map[string]int
+}
+
+func (l *countingLoader) load(_ context.Context, key string) ([]byte, int64, time.Duration, error) {
+	if key == "fail" {
+		return nil, 0, 0, errors.New("boom")
+	}
+	l.mu.Lock()
+	if l.loads == nil {
+		l.loads = make(map[string]int)
+	}
+	l.loads[key]++
+	l.mu.Unlock()
+	return []byte("value-" + key), 0, time.Minute, nil
+}
+
+func (l *countingLoader) count(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.loads[key]
+}
+
+// startCluster returns n Nodes named peer0..peerN-1, connected in process
+// and sharing loader.
+func startCluster(t *testing.T, n int, loader *countingLoader, opts ...Option) ([]*Node, *InProcessTransport) {
+	t.Helper()
+	transport := NewInProcessTransport()
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("peer%d", i)
+	}
+	nodes := make([]*Node, n)
+	for i, name := range names {
+		cache := mcache.NewCache[string, []byte]()
+		t.Cleanup(func() { cache.Close() })
+		nodes[i] = New(name, cache, loader.load, transport, opts...)
+		nodes[i].SetPeers(names...)
+	}
+	return nodes, transport
+}
+
+func TestRing(t *testing.T) {
+	r := NewRing("c", "a", "b", "a", "")
+	if r.Len() != 3 {
+		t.Fatalf("Expected 3 peers, got %v", r.Peers())
+	}
+	if NewRing().Owner("k") != "" {
+		t.Error("Expected an empty ring to have no owner")
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		counts[r.Owner(fmt.Sprintf("key%d", i))]++
+	}
+	for _, p := range r.Peers() {
+		if counts[p] < 700 {
+			t.Errorf("Expected keys to spread evenly, got %v", counts)
+			break
+		}
+	}
+
+	// Adding a peer only moves keys to the new peer.
+	grown := NewRing("a", "b", "c", "d")
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if before, after := r.Owner(key), grown.Owner(key); before != after && after != "d" {
+			t.Fatalf("Expected %s to stay on %s or move to d, got %s", key, before, after)
+		}
+	}
+}
+
+func TestNodeGet(t *testing.T) {
+	loader := &countingLoader{}
+	nodes, _ := startCluster(t, 3, loader)
+	ctx := context.Background()
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key%d", i)
+		for _, n := range nodes {
+			value, err := n.Get(ctx, key)
+			if err != nil || string(value) != "value-"+key {
+				t.Fatalf("Expected value-%s from %s, got %q, %v", key, n.Self(), value, err)
+			}
+		}
+		if c := loader.count(key); c != 1 {
+			t.Errorf("Expected %s to be loaded once, got %d", key, c)
+		}
+	}
+
+	var loads, served int64
+	for _, n := range nodes {
+		s := n.Stats()
+		loads += s.Loads
+		served += s.Served
+	}
+	if loads != 30 || served != 60 {
+		t.Errorf("Expected 30 loads and 60 served fetches, got %d and %d", loads, served)
+	}
+
+	_, err := nodes[0].Get(ctx, "fail")
+	var le *LoadError
+	if nodes[0].Owner("fail") != nodes[0].Self() && !errors.As(err, &le) {
+		t.Errorf("Expected a LoadError from the owner, got %v", err)
+	}
+	if err == nil {
+		t.Error("Expected the loader error")
+	}
+}
+
+func TestNodeDedupFetches(t *testing.T) {
+	loader := &countingLoader{}
+	nodes, _ := startCluster(t, 2, loader)
+	key := "shared"
+	var n *Node
+	for _, node := range nodes {
+		if node.Owner(key) != node.Self() {
+			n = node
+		}
+	}
+
+	var wg sync.WaitGroup
+	var errs atomic.Int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := n.Get(context.Background(), key); err != nil {
+				errs.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if errs.Load() != 0 {
+		t.Errorf("Expected no errors, got %d", errs.Load())
+	}
+	if c := loader.count(key); c != 1 {
+		t.Errorf("Expected one load, got %d", c)
+	}
+}
+
+func TestNodePeerDown(t *testing.T) {
+	loader := &countingLoader{}
+	nodes, transport := startCluster(t, 2, loader)
+	key := "k"
+	owner, other := nodes[0], nodes[1]
+	if owner.Owner(key) != owner.Self() {
+		owner, other = other, owner
+	}
+
+	transport.SetDown(owner.Self(), true)
+	value, err := other.Get(context.Background(), key)
+	if err != nil || string(value) != "value-k" {
+		t.Fatalf("Expected a local load while the owner is down, got %q, %v", value, err)
+	}
+	if s := other.Stats(); s.PeerErrors != 1 || s.Loads != 1 {
+		t.Errorf("Expected 1 peer error and 1 local load, got %+v", s)
+	}
+
+	transport.SetDown(owner.Self(), false)
+	other.Get(context.Background(), key)
+	if s := other.Stats(); s.PeerFetches != 1 {
+		t.Errorf("Expected a fetch once the owner is back, got %+v", s)
+	}
+}
+
+// hangingTransport is a Transport whose fetches block until their
+// context is done.
+type hangingTransport struct{}
+
+func (hangingTransport) Fetch(ctx context.Context, _, _ string) ([]byte, time.Duration, error) {
+	<-ctx.Done()
+	return nil, 0, ctx.Err()
+}
+
+func TestNodeFetchTimeout(t *testing.T) {
+	loader := &countingLoader{}
+	cache := mcache.NewCache[string, []byte]()
+	defer cache.Close()
+	n := New("n", cache, loader.load, hangingTransport{}, WithFetchTimeout(20*time.Millisecond))
+	n.SetPeers("owner")
+
+	var key string
+	for i := 0; ; i++ {
+		if key = fmt.Sprintf("key%d", i); n.Owner(key) == "owner" {
+			break
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		value, err := n.Get(context.Background(), key)
+		if err != nil || string(value) != "value-"+key {
+			t.Errorf("Expected a local load after the fetch timed out, got %q, %v", value, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the fetch from a hanging peer to time out")
+	}
+	if s := n.Stats(); s.PeerErrors != 1 || s.Loads != 1 {
+		t.Errorf("Expected 1 peer error and 1 local load, got %+v", s)
+	}
+}
+
+func TestNodeHotCache(t *testing.T) {
+	loader := &countingLoader{}
+	transport := NewInProcessTransport()
+	ownerCache := mcache.NewCache[string, []byte]()
+	defer ownerCache.Close()
+	cache := mcache.NewCache[string, []byte]()
+	defer cache.Close()
+	hot := mcache.NewCache[string, []byte](mcache.WithMaxEntries[string, []byte](100))
+	defer hot.Close()
+
+	owner := New("owner", ownerCache, loader.load, transport)
+	n := New("n", cache, loader.load, transport, WithHotCache(hot, 10*time.Second))
+	n.SetPeers("owner")
+	owner.SetPeers("n")
+
+	var key string
+	for i := 0; ; i++ {
+		if key = fmt.Sprintf("key%d", i); n.Owner(key) == "owner" {
+			break
+		}
+	}
+	for i := 0; i < 10; i++ {
+		n.Get(context.Background(), key)
+		hot.Wait()
+	}
+	if s := n.Stats(); s.PeerFetches != 1 || s.HotHits != 9 {
+		t.Errorf("Expected 1 fetch and 9 hot hits, got %+v", s)
+	}
+	if ttl, ok := hot.TTL(key); !ok || ttl > 10*time.Second {
+		t.Errorf("Expected the replica TTL to be capped, got %v, %v", ttl, ok)
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	loader := &countingLoader{}
+	cache := mcache.NewCache[string, []byte]()
+	defer cache.Close()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	owner := New(srv.URL, cache, loader.load, &HTTPTransport{})
+	mux.Handle(DefaultBasePath, owner)
+
+	tr := &HTTPTransport{Client: srv.Client()}
+	ctx := context.Background()
+	value, ttl, err := tr.Fetch(ctx, srv.URL, "a b/c")
+	if err != nil || string(value) != "value-a b/c" {
+		t.Fatalf("Expected value-a b/c, got %q, %v", value, err)
+	}
+	if ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected the TTL of the owner, got %v", ttl)
+	}
+
+	_, _, err = tr.Fetch(ctx, srv.URL, "fail")
+	var le *LoadError
+	if !errors.As(err, &le) || le.Msg != "boom" {
+		t.Errorf("Expected a LoadError with the loader message, got %v", err)
+	}
+
+	if _, _, err := tr.Fetch(ctx, "http://127.0.0.1:1", "a"); err == nil || errors.As(err, &le) {
+		t.Errorf("Expected a transport error for an unreachable peer, got %v", err)
+	}
+}
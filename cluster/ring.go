@@ -0,0 +1,57 @@
+package cluster
+
+import (
+	"slices"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/hash"
+)
+
+// Ring assigns keys to peers by rendezvous (highest random weight)
+// hashing: each key is owned by the peer with the highest hash of the
+// pair. Adding or removing a peer only moves the keys that peer gains or
+// loses, and every process with the same peer set agrees on the owners.
+// A Ring is immutable and safe for concurrent use.
+type Ring struct {
+	peers  []string
+	hashes []uint64
+}
+
+// NewRing returns a Ring of the given peers. Duplicates and empty names
+// are ignored.
+func NewRing(peers ...string) *Ring {
+	peers = slices.Clone(peers)
+	peers = slices.DeleteFunc(peers, func(p string) bool { return p == "" })
+	slices.Sort(peers)
+	peers = slices.Compact(peers)
+
+	r := &Ring{peers: peers, hashes: make([]uint64, len(peers))}
+	for i, p := range peers {
+		r.hashes[i] = hash.String(p)
+	}
+	return r
+}
+
+// Owner returns the peer owning key, or "" if the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if len(r.peers) == 0 {
+		return ""
+	}
+	kh := hash.String(key)
+	best, bestScore := 0, uint64(0)
+	for i, ph := range r.hashes {
+		if score := hash.Uint64(hash.Combine(ph, kh)); score > bestScore || i == 0 {
+			best, bestScore = i, score
+		}
+	}
+	return r.peers[best]
+}
+
+// Peers returns the peers of the ring in sorted order.
+func (r *Ring) Peers() []string {
+	return slices.Clone(r.peers)
+}
+
+// Len returns the number of peers.
+func (r *Ring) Len() int {
+	return len(r.peers)
+}
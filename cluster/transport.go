@@ -0,0 +1,173 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPeerUnavailable is returned by InProcessTransport for a peer that is
+// not connected.
+var ErrPeerUnavailable = errors.New("cluster: peer unavailable")
+
+// Transport fetches a key from the Node of another peer. It returns the
+// value and its remaining TTL on the peer (0 if it never expires). A
+// *LoadError reports that the peer was reached but its loader failed; any
+// other error makes the caller load the key itself.
+type Transport interface {
+	Fetch(ctx context.Context, peer, key string) (value []byte, ttl time.Duration, err error)
+}
+
+// LoadError is the error of the loader on the peer owning a key.
+type LoadError struct {
+	Peer string
+	Msg  string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("cluster: load on %s: %s", e.Peer, e.Msg)
+}
+
+// InProcessTransport connects the Nodes created with it by calling them
+// directly. Values are copied as if they had crossed the network.
+type InProcessTransport struct {
+	mu    sync.RWMutex
+	nodes map[string]*Node
+	down  map[string]bool
+}
+
+// NewInProcessTransport returns an empty InProcessTransport. Nodes join it
+// when created with New.
+func NewInProcessTransport() *InProcessTransport {
+	return &InProcessTransport{
+		nodes: make(map[string]*Node),
+		down:  make(map[string]bool),
+	}
+}
+
+func (t *InProcessTransport) add(n *Node) {
+	t.mu.Lock()
+	t.nodes[n.self] = n
+	t.mu.Unlock()
+}
+
+// SetDown marks peer as unreachable, or reachable again, to simulate
+// network failures.
+func (t *InProcessTransport) SetDown(peer string, down bool) {
+	t.mu.Lock()
+	t.down[peer] = down
+	t.mu.Unlock()
+}
+
+// Fetch implements Transport.
+func (t *InProcessTransport) Fetch(ctx context.Context, peer, key string) ([]byte, time.Duration, error) {
+	t.mu.RLock()
+	n, ok := t.nodes[peer]
+	down := t.down[peer]
+	t.mu.RUnlock()
+	if !ok || down {
+		return nil, 0, fmt.Errorf("%w: %s", ErrPeerUnavailable, peer)
+	}
+	value, ttl, err := n.serve(ctx, key)
+	if err != nil {
+		return nil, 0, &LoadError{Peer: peer, Msg: err.Error()}
+	}
+	return append([]byte(nil), value...), ttl, nil
+}
+
+// DefaultBasePath is the path under which peers serve fetches by default.
+const DefaultBasePath = "/_mcache/"
+
+// Headers of fetch responses.
+const (
+	ttlHeader   = "X-Mcache-Ttl"   // Remaining TTL in milliseconds
+	errorHeader = "X-Mcache-Error" // Set when the loader failed
+)
+
+// HTTPTransport fetches keys with GET requests to peer + BasePath + the
+// escaped key, where peer is a base URL such as "http://10.0.0.1:8080".
+// Each peer serves its Node under BasePath:
+//
+//	http.Handle(cluster.DefaultBasePath, node)
+type HTTPTransport struct {
+	// Client sends the requests. Default: http.DefaultClient.
+	Client *http.Client
+	// BasePath is the path the peers serve their Node under.
+	// Default: DefaultBasePath.
+	BasePath string
+}
+
+// Fetch implements Transport.
+func (t *HTTPTransport) Fetch(ctx context.Context, peer, key string) ([]byte, time.Duration, error) {
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base := t.BasePath
+	if base == "" {
+		base = DefaultBasePath
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+base+url.PathEscape(key), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.Header.Get(errorHeader) != "" {
+			return nil, 0, &LoadError{Peer: peer, Msg: strings.TrimSpace(string(body))}
+		}
+		return nil, 0, fmt.Errorf("cluster: fetch from %s: %s", peer, resp.Status)
+	}
+	var ttl time.Duration
+	if ms, err := strconv.ParseInt(resp.Header.Get(ttlHeader), 10, 64); err == nil {
+		ttl = time.Duration(ms) * time.Millisecond
+	}
+	return body, ttl, nil
+}
+
+// ServeHTTP answers fetches from HTTPTransport. The key is the last
+// element of the request path.
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	escaped := r.URL.EscapedPath()
+	if escaped == "" || escaped[len(escaped)-1] == '/' {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+	key, err := url.PathUnescape(path.Base(escaped))
+	if err != nil {
+		http.Error(w, "bad key", http.StatusBadRequest)
+		return
+	}
+	value, ttl, err := n.serve(r.Context(), key)
+	if err != nil {
+		w.Header().Set(errorHeader, "load")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ttl > 0 {
+		// Round up so a live entry is never reported as never expiring.
+		w.Header().Set(ttlHeader, strconv.FormatInt(int64((ttl+time.Millisecond-1)/time.Millisecond), 10))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
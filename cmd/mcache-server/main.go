@@ -0,0 +1,120 @@
+// Command mcache-server serves an mcache instance over the Redis protocol,
+// so it can run as a sidecar cache behind stock Redis clients, and
+// optionally over the memcached protocols.
+//
+// Usage:
+//
+//	mcache-server [-addr :6379] [-memcache :11211] [-maxmemory 1gb] [-maxkeys N] [-wal dir]
+//
+// With -maxmemory, the cost of an entry is the size of its value in bytes
+// plus one.
+// With -wal, writes are logged to dir and replayed on restart.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+	"github.com/OrlovEvgeny/go-mcache/memcache"
+	"github.com/OrlovEvgeny/go-mcache/server"
+)
+
+func main() {
+	var (
+		addr      = flag.String("addr", ":6379", "TCP address to listen on")
+		mcAddr    = flag.String("memcache", "", "TCP address for the memcached protocols (empty = disabled)")
+		maxMemory = flag.String("maxmemory", "0", "maximum total value size, e.g. 512mb or 4gb (0 = unlimited)")
+		maxKeys   = flag.Int64("maxkeys", 0, "maximum number of keys (0 = unlimited)")
+		shards    = flag.Int("shards", 0, "number of shards (0 = default)")
+		walDir    = flag.String("wal", "", "write-ahead log directory (empty = no persistence)")
+	)
+	flag.Parse()
+
+	maxCost, err := parseBytes(*maxMemory)
+	if err != nil {
+		log.Fatalf("mcache-server: invalid -maxmemory: %v", err)
+	}
+
+	opts := []mcache.Option[string, []byte]{
+		mcache.WithCostFunc[string, []byte](func(v []byte) int64 { return int64(len(v)) + 1 }),
+	}
+	if maxCost > 0 {
+		opts = append(opts, mcache.WithMaxCost[string, []byte](maxCost))
+	}
+	if *maxKeys > 0 {
+		opts = append(opts, mcache.WithMaxEntries[string, []byte](*maxKeys))
+	}
+	if *shards > 0 {
+		opts = append(opts, mcache.WithShardCount[string, []byte](*shards))
+	}
+	if *walDir != "" {
+		opts = append(opts,
+			mcache.WithCodec[string, []byte](mcache.StringBytesCodec{}),
+			mcache.WithWAL[string, []byte](*walDir),
+		)
+	}
+
+	cache, err := mcache.OpenCache(opts...)
+	if err != nil {
+		log.Fatalf("mcache-server: %v", err)
+	}
+	srv := server.New(cache)
+	mc := memcache.New(cache)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		mc.Close()
+		srv.Close()
+	}()
+
+	if *mcAddr != "" {
+		go func() {
+			log.Printf("mcache-server: memcached protocols on %s", *mcAddr)
+			if err := mc.ListenAndServe(*mcAddr); err != nil && err != memcache.ErrServerClosed {
+				log.Printf("mcache-server: memcache: %v", err)
+				srv.Close()
+			}
+		}()
+	}
+
+	log.Printf("mcache-server: listening on %s", *addr)
+	err = srv.ListenAndServe(*addr)
+	mc.Close()
+	if err != nil && err != server.ErrServerClosed {
+		cache.Close()
+		log.Fatalf("mcache-server: %v", err)
+	}
+	cache.Close()
+	if err := cache.WALError(); err != nil {
+		log.Fatalf("mcache-server: write-ahead log: %v", err)
+	}
+}
+
+// parseBytes parses a size with an optional kb, mb or gb suffix.
+func parseBytes(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"b", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSuffix(s, u.suffix), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,195 @@
+// Command mcache-sim replays access traces against the cache's eviction
+// policies and prints hit-ratio curves, to help choose a policy,
+// MaxEntries/MaxCost and NumCounters before deploying.
+//
+// Usage:
+//
+//	mcache-sim [flags] trace-file
+//
+// Traces are recorded with mcache.WithTraceRecorder or taken from the
+// ARC, LIRS or Twitter cache-trace collections; files ending in .gz are
+// decompressed. Without a file, -zipf generates a synthetic trace.
+//
+// Example:
+//
+//	mcache-sim -format arc -sizes 1000,10000,100000 -policies lru,arc,wtinylfu P8.lis
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/trace"
+)
+
+func main() {
+	var (
+		format   = flag.String("format", "mcache", "trace format: "+strings.Join(trace.Formats, ", "))
+		sizes    = flag.String("sizes", "", "comma-separated capacities (default: 1%,2%,5%,10%,20%,50% of unique keys)")
+		policies = flag.String("policies", strings.Join(trace.PolicyNames(), ","), "comma-separated policies")
+		byCost   = flag.Bool("by-cost", false, "treat sizes as MaxCost instead of MaxEntries")
+		counters = flag.Float64("counters", 10, "NumCounters as a multiple of the capacity in entries")
+		zipfN    = flag.Int("zipf", 0, "generate a synthetic Zipf trace with this many reads instead of reading a file")
+		zipfS    = flag.Float64("zipf-s", 1.15, "Zipf exponent for -zipf")
+		zipfKeys = flag.Uint64("zipf-keys", 100000, "key universe for -zipf")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [trace-file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	events, err := load(flag.Arg(0), *format, *zipfN, *zipfS, *zipfKeys)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	stats := trace.Summarize(events)
+	if stats.Gets == 0 {
+		fatalf("trace has no reads")
+	}
+
+	names := strings.Split(*policies, ",")
+	for _, name := range names {
+		if trace.Policies[name] == nil {
+			fatalf("unknown policy %q (available: %s)", name, strings.Join(trace.PolicyNames(), ", "))
+		}
+	}
+
+	capacities, err := parseSizes(*sizes, stats, *byCost)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	results := run(events, names, capacities, stats, *byCost, *counters)
+
+	fmt.Printf("events=%d gets=%d unique keys=%d\n\n", stats.Events, stats.Gets, stats.UniqueKeys)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	unit := "entries"
+	if *byCost {
+		unit = "cost"
+	}
+	fmt.Fprintf(tw, "%s\t", unit)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t", name)
+	}
+	fmt.Fprintln(tw)
+	for i, capacity := range capacities {
+		fmt.Fprintf(tw, "%d\t", capacity)
+		for j := range names {
+			fmt.Fprintf(tw, "%.2f%%\t", 100*results[i][j].HitRatio())
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// load reads the trace file, or generates a Zipf trace if n > 0.
+func load(path, format string, n int, s float64, keys uint64) ([]trace.Event, error) {
+	if n > 0 {
+		return trace.ReadAll(trace.NewZipf(1, s, keys, n))
+	}
+	if path == "" {
+		return nil, fmt.Errorf("missing trace file (or -zipf)")
+	}
+
+	var r io.Reader
+	if path == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+		if strings.HasSuffix(path, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, err
+			}
+			defer gz.Close()
+			r = gz
+		}
+	}
+
+	src, err := trace.NewSource(format, r)
+	if err != nil {
+		return nil, err
+	}
+	return trace.ReadAll(src)
+}
+
+// parseSizes parses the -sizes flag or derives capacities from the trace.
+func parseSizes(flagValue string, stats trace.Stats, byCost bool) ([]int64, error) {
+	if flagValue == "" {
+		scale := float64(stats.UniqueKeys)
+		if byCost {
+			scale *= stats.AvgCost
+		}
+		var sizes []int64
+		for _, pct := range []float64{0.01, 0.02, 0.05, 0.1, 0.2, 0.5} {
+			if size := int64(scale * pct); size > 0 && (len(sizes) == 0 || size > sizes[len(sizes)-1]) {
+				sizes = append(sizes, size)
+			}
+		}
+		if len(sizes) == 0 {
+			sizes = append(sizes, 1)
+		}
+		return sizes, nil
+	}
+
+	var sizes []int64
+	for _, field := range strings.Split(flagValue, ",") {
+		size, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// run simulates every policy at every capacity in parallel and returns
+// results indexed by [capacity][policy].
+func run(events []trace.Event, names []string, capacities []int64, stats trace.Stats, byCost bool, counters float64) [][]trace.Result {
+	results := make([][]trace.Result, len(capacities))
+	for i := range results {
+		results[i] = make([]trace.Result, len(names))
+	}
+
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, capacity := range capacities {
+		entries := float64(capacity)
+		if byCost {
+			entries /= stats.AvgCost
+		}
+		numCounters := max(int64(entries*counters), 64)
+
+		for j, name := range names {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				p := trace.Policies[name](numCounters)
+				results[i][j] = trace.Simulate(events, p, capacity, byCost)
+			}()
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "mcache-sim: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,79 @@
+package mcache
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Codec converts keys and values to and from bytes.
+// It is used wherever entries leave the process heap: snapshots,
+// the write-ahead log and SerializedCache.
+// Append methods append the encoding to dst and return the extended slice.
+// Decode methods must not retain src after returning.
+type Codec[K comparable, V any] interface {
+	AppendKey(dst []byte, key K) ([]byte, error)
+	DecodeKey(src []byte) (K, error)
+	AppendValue(dst []byte, value V) ([]byte, error)
+	DecodeValue(src []byte) (V, error)
+}
+
+// GobCodec is a Codec based on encoding/gob.
+// It works for any gob-encodable K and V, but encodes type information
+// with every item; provide a dedicated Codec for hot paths.
+type GobCodec[K comparable, V any] struct{}
+
+// AppendKey implements Codec.
+func (GobCodec[K, V]) AppendKey(dst []byte, key K) ([]byte, error) {
+	return gobAppend(dst, key)
+}
+
+// DecodeKey implements Codec.
+func (GobCodec[K, V]) DecodeKey(src []byte) (K, error) {
+	var key K
+	err := gob.NewDecoder(bytes.NewReader(src)).Decode(&key)
+	return key, err
+}
+
+// AppendValue implements Codec.
+func (GobCodec[K, V]) AppendValue(dst []byte, value V) ([]byte, error) {
+	return gobAppend(dst, value)
+}
+
+// DecodeValue implements Codec.
+func (GobCodec[K, V]) DecodeValue(src []byte) (V, error) {
+	var value V
+	err := gob.NewDecoder(bytes.NewReader(src)).Decode(&value)
+	return value, err
+}
+
+func gobAppend[T any](dst []byte, v T) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StringBytesCodec is a zero-overhead Codec for string keys and []byte values.
+type StringBytesCodec struct{}
+
+// AppendKey implements Codec.
+func (StringBytesCodec) AppendKey(dst []byte, key string) ([]byte, error) {
+	return append(dst, key...), nil
+}
+
+// DecodeKey implements Codec.
+func (StringBytesCodec) DecodeKey(src []byte) (string, error) {
+	return string(src), nil
+}
+
+// AppendValue implements Codec.
+func (StringBytesCodec) AppendValue(dst []byte, value []byte) ([]byte, error) {
+	return append(dst, value...), nil
+}
+
+// DecodeValue implements Codec.
+// The returned slice is a copy and may be retained by the caller.
+func (StringBytesCodec) DecodeValue(src []byte) ([]byte, error) {
+	return bytes.Clone(src), nil
+}
@@ -0,0 +1,157 @@
+package mcache
+
+import (
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/policy"
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+	"github.com/OrlovEvgeny/go-mcache/internal/trace"
+)
+
+// ComputeOp tells Compute what to do with the value its callback returns.
+type ComputeOp int
+
+const (
+	// ComputeKeep leaves the entry as it is; the returned value is ignored.
+	ComputeKeep ComputeOp = iota
+	// ComputeSet stores the returned value.
+	ComputeSet
+	// ComputeDelete removes the entry.
+	ComputeDelete
+)
+
+// Compute atomically reads, transforms and writes the value of key. fn is
+// called with the current value, or the zero value and exists=false if the
+// key is not in the cache, and its result is applied according to op. It
+// returns the value associated with key afterwards and whether the key is
+// present; a new entry rejected by the admission policy is not present.
+//
+// fn runs while the key's shard is write-locked, so concurrent Compute,
+// Set and Delete calls on the key wait for it; it must be fast and must
+// not call the cache. An updated entry keeps its expiration and tags, and
+// a new one expires after the default TTL, if any. The cost of the new
+// value comes from CostFunc, or 1 if it is not set.
+func (c *Cache[K, V]) Compute(key K, fn func(old V, exists bool) (V, ComputeOp)) (V, bool) {
+	var zero V
+	if c.closed.Load() {
+		return zero, false
+	}
+
+	keyHash := c.store.KeyHash(key)
+	value, ok, deleted := c.compute(key, keyHash, fn)
+	c.finishWrite()
+	if deleted {
+		c.publish(InvalidationDelete, key, "")
+	}
+	return value, ok
+}
+
+// ComputeIfAbsent calls fn only if key is not in the cache, applying its
+// result as Compute does (ComputeDelete is the same as ComputeKeep). It
+// returns the current value if the key is present.
+func (c *Cache[K, V]) ComputeIfAbsent(key K, fn func() (V, ComputeOp)) (V, bool) {
+	return c.Compute(key, func(old V, exists bool) (V, ComputeOp) {
+		if exists {
+			return old, ComputeKeep
+		}
+		return fn()
+	})
+}
+
+// ComputeIfPresent calls fn only if key is in the cache, applying its
+// result as Compute does. It returns false if the key is not present.
+func (c *Cache[K, V]) ComputeIfPresent(key K, fn func(old V) (V, ComputeOp)) (V, bool) {
+	return c.Compute(key, func(old V, exists bool) (V, ComputeOp) {
+		if !exists {
+			return old, ComputeKeep
+		}
+		return fn(old)
+	})
+}
+
+// compute implements Compute. deleted reports whether fn deleted an
+// existing entry.
+func (c *Cache[K, V]) compute(key K, keyHash uint64, fn func(old V, exists bool) (V, ComputeOp)) (_ V, ok, deleted bool) {
+	defer c.walLock(keyHash)()
+
+	var (
+		zero     V
+		op       ComputeOp
+		current  *store.Entry[K, V]
+		next     *store.Entry[K, V]
+		victims  []policy.Victim[K]
+		admitted = true
+	)
+	prev, changed := c.store.Compute(key, keyHash, func(cur *store.Entry[K, V]) (*store.Entry[K, V], bool) {
+		current = cur
+		var old V
+		if cur != nil {
+			old = cur.Value
+		}
+
+		var value V
+		value, op = fn(old, cur != nil)
+		switch op {
+		case ComputeSet:
+			cost, expireAt := c.normalizeWrite(value, 0, 0)
+			var links *store.Links[K]
+			if cur != nil {
+				expireAt, links = cur.ExpireAt, cur.Links
+			}
+			next = &store.Entry[K, V]{
+				Key:       key,
+				Value:     value,
+				KeyHash:   keyHash,
+				ExpireAt:  expireAt,
+				WrittenAt: clock.NowNano(),
+				Cost:      cost,
+				Links:     links,
+			}
+			if cur == nil {
+				// Admit a new entry before it becomes visible. The
+				// victims are evicted once the shard is unlocked.
+				victims, admitted = c.policy.Add(key, keyHash, cost)
+				if !admitted {
+					return nil, false
+				}
+			}
+			return next, false
+		case ComputeDelete:
+			return nil, true
+		}
+		return nil, false
+	})
+
+	switch {
+	case !admitted:
+		c.traceEvent(trace.OpSet, keyHash, next.Cost)
+		c.reject(next)
+		return zero, false, false
+
+	case !changed:
+		c.traceEvent(trace.OpGet, keyHash, 0)
+		if current == nil {
+			return zero, false, false
+		}
+		return current.Value, true, false
+
+	case next == nil:
+		c.traceEvent(trace.OpDelete, keyHash, 0)
+		c.afterDelete(prev)
+		return zero, false, true
+
+	case current != nil:
+		c.traceEvent(trace.OpSet, keyHash, next.Cost)
+		c.afterUpdate(key, next.Value, keyHash, next.Cost, next.ExpireAt, next.Links, prev, next.Cost-prev.Cost)
+		return next.Value, true, false
+	}
+
+	c.traceEvent(trace.OpSet, keyHash, next.Cost)
+	for _, victim := range victims {
+		c.evictVictim(victim)
+	}
+	if prev != nil {
+		c.liveCost.Add(-prev.Cost)
+	}
+	c.afterInsert(next, prev)
+	return next.Value, true, false
+}
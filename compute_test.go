@@ -0,0 +1,172 @@
+package mcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestComputeOps(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	v, ok := c.Compute("k", func(old int, exists bool) (int, ComputeOp) {
+		if exists {
+			t.Error("Expected a missing key to report exists=false")
+		}
+		return 1, ComputeSet
+	})
+	if !ok || v != 1 {
+		t.Errorf("Expected 1, got %d, %v", v, ok)
+	}
+
+	v, ok = c.Compute("k", func(old int, exists bool) (int, ComputeOp) {
+		return old + 1, ComputeSet
+	})
+	if !ok || v != 2 {
+		t.Errorf("Expected 2, got %d, %v", v, ok)
+	}
+
+	v, ok = c.Compute("k", func(old int, exists bool) (int, ComputeOp) {
+		return 100, ComputeKeep
+	})
+	if !ok || v != 2 {
+		t.Errorf("Expected ComputeKeep to leave 2, got %d, %v", v, ok)
+	}
+
+	if _, ok := c.Compute("k", func(int, bool) (int, ComputeOp) { return 0, ComputeDelete }); ok {
+		t.Error("Expected ComputeDelete to report the key absent")
+	}
+	if c.Has("k") || c.Len() != 0 {
+		t.Error("Expected ComputeDelete to remove the key")
+	}
+	if m := c.Metrics(); m.Sets != 2 || m.Deletes != 1 {
+		t.Errorf("Expected 2 sets and 1 delete, got %d and %d", m.Sets, m.Deletes)
+	}
+}
+
+func TestComputeIfAbsentAndPresent(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	if _, ok := c.ComputeIfPresent("k", func(old int) (int, ComputeOp) {
+		t.Error("Expected fn not to be called for a missing key")
+		return 0, ComputeSet
+	}); ok {
+		t.Error("Expected ComputeIfPresent on a missing key to report false")
+	}
+
+	calls := 0
+	for i := 0; i < 2; i++ {
+		v, ok := c.ComputeIfAbsent("k", func() (int, ComputeOp) {
+			calls++
+			return 10, ComputeSet
+		})
+		if !ok || v != 10 {
+			t.Errorf("Expected 10, got %d, %v", v, ok)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected fn to be called once, got %d", calls)
+	}
+
+	if v, _ := c.ComputeIfPresent("k", func(old int) (int, ComputeOp) { return old * 2, ComputeSet }); v != 20 {
+		t.Errorf("Expected 20, got %d", v)
+	}
+}
+
+func TestComputeKeepsTTL(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	c.Set("k", 1, time.Minute)
+	c.Compute("k", func(old int, _ bool) (int, ComputeOp) { return old + 1, ComputeSet })
+	if ttl, _ := c.TTL("k"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("Expected the TTL to be kept, got %v", ttl)
+	}
+
+	c.Set("e", 1, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	c.Compute("e", func(old int, exists bool) (int, ComputeOp) {
+		if exists {
+			t.Error("Expected an expired entry to report exists=false")
+		}
+		return 5, ComputeSet
+	})
+	if ttl, ok := c.TTL("e"); !ok || ttl != 0 {
+		t.Errorf("Expected a new entry without expiration, got %v, %v", ttl, ok)
+	}
+}
+
+func TestComputeConcurrentCounter(t *testing.T) {
+	c := NewCache[string, int](WithMaxCost[string, int](1000))
+	defer c.Close()
+
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				c.Compute("counter", func(old int, _ bool) (int, ComputeOp) {
+					return old + 1, ComputeSet
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := c.Get("counter"); v != goroutines*increments {
+		t.Errorf("Expected %d, got %d", goroutines*increments, v)
+	}
+	if cost := c.liveCost.Load(); cost != 1 {
+		t.Errorf("Expected a live cost of 1, got %d", cost)
+	}
+}
+
+// blockingRejectPolicy rejects every key after blocking its Add until
+// release is closed.
+type blockingRejectPolicy struct {
+	Policer[int]
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingRejectPolicy) Add(key int, keyHash uint64, cost int64) ([]Victim[int], bool) {
+	close(p.entered)
+	<-p.release
+	return nil, false
+}
+
+func TestComputeRejectedNeverVisible(t *testing.T) {
+	p := &blockingRejectPolicy{
+		Policer: NewLRUPolicy[int](),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	c := NewCache[int, int](WithPolicy[int, int](p))
+	defer c.Close()
+
+	done := make(chan bool)
+	go func() {
+		_, ok := c.Compute(1, func(int, bool) (int, ComputeOp) { return 1, ComputeSet })
+		done <- ok
+	}()
+
+	<-p.entered
+	seen := make(chan bool)
+	go func() { seen <- c.Has(1) }()
+	time.Sleep(20 * time.Millisecond)
+	close(p.release)
+
+	if <-done {
+		t.Error("Expected Compute to report the rejected entry as absent")
+	}
+	if <-seen {
+		t.Error("Expected the entry not to be visible before it was admitted")
+	}
+	if c.Has(1) {
+		t.Error("Expected the rejected entry not to be stored")
+	}
+}
@@ -0,0 +1,216 @@
+package mcache
+
+import (
+	"bytes"
+	"reflect"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+	"github.com/OrlovEvgeny/go-mcache/internal/trace"
+)
+
+// The conditional writes below check and write under the shard lock, so
+// they are atomic with respect to each other and to Set and Delete. They
+// are applied synchronously even when WithBufferItems is set, and do not
+// see writes still waiting in the buffer. The cost of the new value comes
+// from CostFunc, or 1 if it is not set.
+
+// SetIfAbsent stores a value only if the key is not in the cache.
+// Returns false if the key is present or the write was rejected by the
+// admission policy.
+func (c *Cache[K, V]) SetIfAbsent(key K, value V, ttl time.Duration) bool {
+	if c.closed.Load() {
+		return false
+	}
+
+	cost, expireAt := c.normalizeWrite(value, 0, ttl)
+	keyHash := c.store.KeyHash(key)
+	c.traceEvent(trace.OpSet, keyHash, cost)
+
+	ok := c.setIfAbsentSync(key, value, keyHash, cost, expireAt)
+	c.finishWrite()
+	return ok
+}
+
+func (c *Cache[K, V]) setIfAbsentSync(key K, value V, keyHash uint64, cost int64, expireAt int64) bool {
+	defer c.walLock(keyHash)()
+
+	// Skip the policy when the key is clearly present.
+	if entry, ok := c.store.PeekByHash(key, keyHash); ok && !entry.IsExpired() {
+		return false
+	}
+
+	return c.insertIfAbsent(&store.Entry[K, V]{
+		Key:       key,
+		Value:     value,
+		KeyHash:   keyHash,
+		ExpireAt:  expireAt,
+		WrittenAt: clock.NowNano(),
+		Cost:      cost,
+	})
+}
+
+// insertIfAbsent stores entry if its key is missing or expired and the
+// admission policy accepts it. The entry is inserted before it is
+// admitted, so the policy only evicts victims for an entry that was
+// stored, and is taken back if it is rejected. Must be called with the
+// WAL lock of the key held.
+func (c *Cache[K, V]) insertIfAbsent(entry *store.Entry[K, V]) bool {
+	prev, inserted := c.store.InsertIfAbsent(entry)
+	if !inserted {
+		return false
+	}
+	if prev != nil {
+		// The expired entry is gone whether or not entry is admitted.
+		c.liveCost.Add(-prev.Cost)
+		c.unlink(prev)
+		c.dependencyChanged(prev.Key)
+	}
+
+	if !c.admit(entry) {
+		deleted, _ := c.store.DeleteIfByHash(entry.Key, entry.KeyHash, func(e *store.Entry[K, V]) bool {
+			return e == entry
+		})
+		if deleted != nil && prev != nil {
+			c.policy.Del(prev.Key, prev.KeyHash)
+			if c.isStringKey && c.radixTree != nil {
+				if strKey, ok := any(prev.Key).(string); ok {
+					c.radixTree.Delete(strKey)
+				}
+			}
+		}
+		return false
+	}
+	c.afterInsert(entry, nil)
+	return true
+}
+
+// Replace stores a value only if the key is already in the cache.
+// Returns false if the key is not present.
+func (c *Cache[K, V]) Replace(key K, value V, ttl time.Duration) bool {
+	replaced, _ := c.compareAndSwap(key, value, ttl, func(*store.Entry[K, V]) bool {
+		return true
+	})
+	return replaced
+}
+
+// CompareAndSwap replaces the value of key with new only if its current
+// value equals old, as reported by the function set with WithEqualFunc.
+// Returns false if the key is not present or its value differs.
+func (c *Cache[K, V]) CompareAndSwap(key K, old, new V, ttl time.Duration) bool {
+	swapped, _ := c.compareAndSwap(key, new, ttl, func(e *store.Entry[K, V]) bool {
+		return c.config.Equal(e.Value, old)
+	})
+	return swapped
+}
+
+// CompareAndSwapVersion replaces the value of key only if its current
+// version equals version, as returned by GetWithVersion. It returns
+// swapped=true on success, and found=false if the key is not in the cache.
+func (c *Cache[K, V]) CompareAndSwapVersion(key K, value V, version uint64, ttl time.Duration) (swapped, found bool) {
+	return c.compareAndSwap(key, value, ttl, func(e *store.Entry[K, V]) bool {
+		return e.Version == version
+	})
+}
+
+// compareAndSwap replaces the value of an existing key if match returns
+// true for its entry.
+func (c *Cache[K, V]) compareAndSwap(key K, value V, ttl time.Duration, match func(*store.Entry[K, V]) bool) (swapped, found bool) {
+	if c.closed.Load() {
+		return false, false
+	}
+
+	cost, expireAt := c.normalizeWrite(value, 0, ttl)
+	keyHash := c.store.KeyHash(key)
+	c.traceEvent(trace.OpSet, keyHash, cost)
+
+	swapped, found = c.updateIf(key, value, keyHash, cost, expireAt, match)
+	c.finishWrite()
+	return swapped, found
+}
+
+// updateIf replaces an existing entry if match returns true for it.
+func (c *Cache[K, V]) updateIf(key K, value V, keyHash uint64, cost, expireAt int64, match func(*store.Entry[K, V]) bool) (updated, found bool) {
+	defer c.walLock(keyHash)()
+
+	prev, found, updated, costDelta, _ := c.store.UpdateIfByHash(
+		key,
+		keyHash,
+		match,
+		value,
+		cost,
+		expireAt,
+		nil,
+		c.capturePrevious(),
+	)
+	if updated {
+		c.afterUpdate(key, value, keyHash, cost, expireAt, nil, prev, costDelta)
+	}
+	return updated, found
+}
+
+// CompareAndDelete removes key only if its current value equals old, as
+// reported by the function set with WithEqualFunc. Returns true if the
+// entry was deleted. A delete is published like one made by Delete.
+func (c *Cache[K, V]) CompareAndDelete(key K, old V) bool {
+	deleted, _ := c.compareAndDelete(key, func(e *store.Entry[K, V]) bool {
+		return c.config.Equal(e.Value, old)
+	})
+	if deleted {
+		c.publish(InvalidationDelete, key, "")
+	}
+	return deleted
+}
+
+// CompareAndDeleteVersion removes key only if its current version equals
+// version, as returned by GetWithVersion. It returns deleted=true on
+// success, and found=false if the key is not in the cache. A delete is
+// published like one made by Delete.
+func (c *Cache[K, V]) CompareAndDeleteVersion(key K, version uint64) (deleted, found bool) {
+	deleted, found = c.compareAndDelete(key, func(e *store.Entry[K, V]) bool {
+		return e.Version == version
+	})
+	if deleted {
+		c.publish(InvalidationDelete, key, "")
+	}
+	return deleted, found
+}
+
+func (c *Cache[K, V]) compareAndDelete(key K, match func(*store.Entry[K, V]) bool) (deleted, found bool) {
+	if c.closed.Load() {
+		return false, false
+	}
+
+	keyHash := c.store.KeyHash(key)
+	c.traceEvent(trace.OpDelete, keyHash, 0)
+
+	deleted, found = c.deleteIf(key, keyHash, match)
+	c.finishWrite()
+	return deleted, found
+}
+
+// deleteIf removes an existing entry if match returns true for it.
+func (c *Cache[K, V]) deleteIf(key K, keyHash uint64, match func(*store.Entry[K, V]) bool) (deleted, found bool) {
+	defer c.walLock(keyHash)()
+
+	entry, found := c.store.DeleteIfByHash(key, keyHash, match)
+	if entry == nil {
+		return false, found
+	}
+	c.afterDelete(entry)
+	return true, true
+}
+
+// defaultEqual compares values with ==, or with bytes.Equal and
+// reflect.DeepEqual for values that are not comparable.
+func defaultEqual[V any](a, b V) bool {
+	if x, ok := any(a).([]byte); ok {
+		return bytes.Equal(x, any(b).([]byte))
+	}
+	va, vb := reflect.ValueOf(any(a)), reflect.ValueOf(any(b))
+	if va.Comparable() && vb.Comparable() {
+		return any(a) == any(b)
+	}
+	return reflect.DeepEqual(any(a), any(b))
+}
@@ -0,0 +1,216 @@
+package mcache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetIfAbsent(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	if !c.SetIfAbsent("k", 1, 0) {
+		t.Fatal("Expected SetIfAbsent on a missing key to succeed")
+	}
+	if c.SetIfAbsent("k", 2, 0) {
+		t.Error("Expected SetIfAbsent on a present key to fail")
+	}
+	if v, _ := c.Get("k"); v != 1 {
+		t.Errorf("Expected 1, got %d", v)
+	}
+
+	c.Set("e", 1, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	if !c.SetIfAbsent("e", 2, 0) {
+		t.Error("Expected SetIfAbsent to replace an expired entry")
+	}
+	if v, _ := c.Get("e"); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Expected 2 entries, got %d", n)
+	}
+}
+
+func TestSetIfAbsentConcurrent(t *testing.T) {
+	c := NewCache[int, int](WithMaxEntries[int, int](10_000))
+	defer c.Close()
+
+	const keys, writers = 100, 8
+	var wins [keys]atomic.Int32
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for k := 0; k < keys; k++ {
+				if c.SetIfAbsent(k, w, 0) {
+					wins[k].Add(1)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for k := range wins {
+		if n := wins[k].Load(); n != 1 {
+			t.Errorf("Key %d: expected exactly one winner, got %d", k, n)
+		}
+	}
+}
+
+// raceOncePolicy lets a writer win the race for a key inside Add and then
+// selects a victim.
+type raceOncePolicy struct {
+	Policer[string]
+	race func(key string) []Victim[string]
+}
+
+func (p *raceOncePolicy) Add(key string, keyHash uint64, cost int64) ([]Victim[string], bool) {
+	victims, added := p.Policer.Add(key, keyHash, cost)
+	if race := p.race; race != nil {
+		p.race = nil
+		victims = append(victims, race(key)...)
+	}
+	return victims, added
+}
+
+func TestSetIfAbsentLosingRaceEvictsNothing(t *testing.T) {
+	p := &raceOncePolicy{Policer: NewLRUPolicy[string]()}
+	c := NewCache[string, int](WithPolicy[string, int](p))
+	defer c.Close()
+
+	c.Set("victim", 1, 0)
+	p.race = func(key string) []Victim[string] {
+		c.Set(key, 1, 0)
+		return []Victim[string]{{Key: "victim", KeyHash: c.store.KeyHash("victim")}}
+	}
+	if !c.SetIfAbsent("k", 2, 0) && !c.Has("victim") {
+		t.Error("Expected a SetIfAbsent that stored nothing not to evict")
+	}
+}
+
+func TestSetIfAbsentExpiredEvent(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := c.Watch(ctx, "", WithWatchTypes(EventSet, EventUpdate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("e", 1, 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	c.SetIfAbsent("e", 2, 0)
+
+	want := []Event[string, int]{
+		{Type: EventSet, Key: "e", NewValue: 1},
+		{Type: EventSet, Key: "e", NewValue: 2},
+	}
+	for i, w := range want {
+		if ev := nextEvent(t, ch); ev != w {
+			t.Errorf("Event %d: expected %+v, got %+v", i, w, ev)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	if c.Replace("k", 1, 0) {
+		t.Error("Expected Replace on a missing key to fail")
+	}
+	if c.Has("k") {
+		t.Error("Expected Replace not to create the key")
+	}
+
+	c.Set("k", 1, 0)
+	if !c.Replace("k", 2, 0) {
+		t.Error("Expected Replace on a present key to succeed")
+	}
+	if v, _ := c.Get("k"); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	c := NewCache[string, []byte]()
+	defer c.Close()
+
+	if c.CompareAndSwap("k", nil, []byte("a"), 0) {
+		t.Error("Expected CompareAndSwap on a missing key to fail")
+	}
+
+	c.Set("k", []byte("a"), 0)
+	if c.CompareAndSwap("k", []byte("x"), []byte("b"), 0) {
+		t.Error("Expected CompareAndSwap with a different old value to fail")
+	}
+	if !c.CompareAndSwap("k", []byte("a"), []byte("b"), 0) {
+		t.Error("Expected CompareAndSwap with the current value to succeed")
+	}
+	if v, _ := c.Get("k"); string(v) != "b" {
+		t.Errorf("Expected b, got %s", v)
+	}
+}
+
+func TestCompareAndSwapEqualFunc(t *testing.T) {
+	c := NewCache[string, string](WithEqualFunc[string, string](strings.EqualFold))
+	defer c.Close()
+
+	c.Set("k", "Hello", 0)
+	if !c.CompareAndSwap("k", "HELLO", "world", 0) {
+		t.Error("Expected the custom equality to be used")
+	}
+	if !c.CompareAndDelete("k", "WORLD") {
+		t.Error("Expected CompareAndDelete to use the custom equality")
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	c.Set("k", 1, 0)
+	if c.CompareAndDelete("k", 2) {
+		t.Error("Expected CompareAndDelete with a different value to fail")
+	}
+	if !c.CompareAndDelete("k", 1) {
+		t.Error("Expected CompareAndDelete with the current value to succeed")
+	}
+	if c.Has("k") {
+		t.Error("Expected the key to be deleted")
+	}
+
+	c.Set("v", 1, 0)
+	_, ver, _ := c.GetWithVersion("v")
+	if deleted, found := c.CompareAndDeleteVersion("v", ver+1); deleted || !found {
+		t.Errorf("Expected a stale version to fail, got %v, %v", deleted, found)
+	}
+	if deleted, _ := c.CompareAndDeleteVersion("v", ver); !deleted {
+		t.Error("Expected the current version to delete")
+	}
+	if deleted, found := c.CompareAndDeleteVersion("v", ver); deleted || found {
+		t.Errorf("Expected a missing key to report not found, got %v, %v", deleted, found)
+	}
+}
+
+func TestDefaultEqual(t *testing.T) {
+	type pair struct{ a, b int }
+	if !defaultEqual(pair{1, 2}, pair{1, 2}) || defaultEqual(pair{1, 2}, pair{2, 1}) {
+		t.Error("Expected structs to compare with ==")
+	}
+	if !defaultEqual([]int{1, 2}, []int{1, 2}) || defaultEqual([]int{1}, []int{2}) {
+		t.Error("Expected slices to compare deeply")
+	}
+	var x, y any = []int{1}, []int{1}
+	if !defaultEqual(x, y) {
+		t.Error("Expected interfaces holding slices not to panic")
+	}
+}
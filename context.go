@@ -0,0 +1,120 @@
+package mcache
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer starts spans around the context-aware cache methods: GetCtx,
+// SetCtx, SetWithCostCtx, DeleteCtx, WaitCtx and GetOrLoad. StartSpan
+// returns a context carrying the span, which is passed on to loaders, and
+// a function that ends the span with the error of the operation. op is
+// the method name, such as "mcache.Get".
+//
+// Adapters for OpenTelemetry or other tracing libraries are a few lines;
+// mcache itself has no tracing dependency.
+type Tracer interface {
+	StartSpan(ctx context.Context, op string) (context.Context, func(err error))
+}
+
+// startSpan starts a span with the configured Tracer, if any.
+func (c *Cache[K, V]) startSpan(ctx context.Context, op string) (context.Context, func(error)) {
+	if c.config.Tracer == nil {
+		return ctx, func(error) {}
+	}
+	return c.config.Tracer.StartSpan(ctx, op)
+}
+
+// GetCtx is Get with a context. It returns ctx.Err() without reading if
+// ctx is already done, and ErrClosed if the cache is closed.
+func (c *Cache[K, V]) GetCtx(ctx context.Context, key K) (value V, ok bool, err error) {
+	ctx, end := c.startSpan(ctx, "mcache.Get")
+	defer func() { end(err) }()
+
+	if err = ctx.Err(); err != nil {
+		return value, false, err
+	}
+	if c.closed.Load() {
+		return value, false, ErrClosed
+	}
+	value, ok = c.Get(key)
+	return value, ok, nil
+}
+
+// SetCtx is Set with a context. It returns ctx.Err() without writing if
+// ctx is already done. With WithBufferItems, a write that finds the
+// buffer full is dropped with ctx.Err() if ctx is done by then, instead of
+// being applied synchronously.
+func (c *Cache[K, V]) SetCtx(ctx context.Context, key K, value V, ttl time.Duration) (bool, error) {
+	return c.SetWithCostCtx(ctx, key, value, 1, ttl)
+}
+
+// SetWithCostCtx is SetWithCost with a context, as in SetCtx.
+func (c *Cache[K, V]) SetWithCostCtx(ctx context.Context, key K, value V, cost int64, ttl time.Duration) (ok bool, err error) {
+	ctx, end := c.startSpan(ctx, "mcache.Set")
+	defer func() { end(err) }()
+
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.setWithCost(ctx, key, value, cost, ttl, nil)
+}
+
+// DeleteCtx is Delete with a context, with the same buffering behaviour
+// as SetCtx.
+func (c *Cache[K, V]) DeleteCtx(ctx context.Context, key K) (ok bool, err error) {
+	ctx, end := c.startSpan(ctx, "mcache.Delete")
+	defer func() { end(err) }()
+
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+	return c.delete(ctx, key)
+}
+
+// WaitCtx is Wait that gives up when ctx is done, returning ctx.Err().
+// Pending writes are still applied in the background.
+func (c *Cache[K, V]) WaitCtx(ctx context.Context) (err error) {
+	ctx, end := c.startSpan(ctx, "mcache.Wait")
+	defer func() { end(err) }()
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	if c.writeBuffer != nil {
+		if err = c.writeBuffer.FlushSyncContext(ctx); err != nil {
+			return err
+		}
+	}
+	if c.readBuffer != nil {
+		if err = c.readBuffer.FlushSyncContext(ctx); err != nil {
+			return err
+		}
+	}
+	if c.writer != nil && c.writer.buffer != nil {
+		return c.writer.buffer.FlushSyncContext(ctx)
+	}
+	return nil
+}
+
+// ScanCtx is Scan with a context: once ctx is done, Next returns false
+// and Err returns ctx.Err().
+func (c *Cache[K, V]) ScanCtx(ctx context.Context, cursor uint64, count int) *Iterator[K, V] {
+	return withContext(ctx, c.Scan(cursor, count))
+}
+
+// ScanPrefixCtx is ScanPrefix with a context, as in ScanCtx.
+func (c *Cache[K, V]) ScanPrefixCtx(ctx context.Context, prefix string, cursor uint64, count int) *Iterator[K, V] {
+	return withContext(ctx, c.ScanPrefix(prefix, cursor, count))
+}
+
+// ScanMatchCtx is ScanMatch with a context, as in ScanCtx.
+func (c *Cache[K, V]) ScanMatchCtx(ctx context.Context, pattern string, cursor uint64, count int) *Iterator[K, V] {
+	return withContext(ctx, c.ScanMatch(pattern, cursor, count))
+}
+
+// withContext binds it to ctx.
+func withContext[K comparable, V any](ctx context.Context, it *Iterator[K, V]) *Iterator[K, V] {
+	it.ctx = ctx
+	return it
+}
@@ -0,0 +1,139 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTracer records the ops of started and ended spans.
+type recordingTracer struct {
+	mu    sync.Mutex
+	ended []string
+}
+
+type spanKey struct{}
+
+func (r *recordingTracer) StartSpan(ctx context.Context, op string) (context.Context, func(error)) {
+	return context.WithValue(ctx, spanKey{}, op), func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.ended = append(r.ended, fmt.Sprintf("%s:%v", op, err))
+	}
+}
+
+func TestContextMethods(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+	ctx := context.Background()
+
+	if ok, err := c.SetCtx(ctx, "k", 1, 0); !ok || err != nil {
+		t.Fatalf("Expected SetCtx to succeed, got %v, %v", ok, err)
+	}
+	if v, ok, err := c.GetCtx(ctx, "k"); !ok || v != 1 || err != nil {
+		t.Errorf("Expected 1, got %v, %v, %v", v, ok, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := c.GetCtx(cancelled, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from GetCtx, got %v", err)
+	}
+	if ok, err := c.SetCtx(cancelled, "k", 2, 0); ok || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected SetCtx not to write, got %v, %v", ok, err)
+	}
+	if ok, err := c.DeleteCtx(cancelled, "k"); ok || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected DeleteCtx not to delete, got %v, %v", ok, err)
+	}
+	if v, _ := c.Get("k"); v != 1 {
+		t.Errorf("Expected 1, got %d", v)
+	}
+	if err := c.WaitCtx(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from WaitCtx, got %v", err)
+	}
+	if ok, err := c.DeleteCtx(ctx, "k"); !ok || err != nil {
+		t.Errorf("Expected DeleteCtx to succeed, got %v, %v", ok, err)
+	}
+
+	c.Close()
+	if _, _, err := c.GetCtx(ctx, "k"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestWaitCtxBuffered(t *testing.T) {
+	c := NewCache[int, int](WithBufferItems[int, int](64))
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(i, i, 0)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.WaitCtx(ctx); err != nil {
+		t.Fatalf("Expected WaitCtx to succeed, got %v", err)
+	}
+	if n := c.Len(); n != 100 {
+		t.Errorf("Expected 100 entries after WaitCtx, got %d", n)
+	}
+}
+
+func TestScanCtxCancel(t *testing.T) {
+	c := NewCache[int, int](WithShardCount[int, int](16))
+	defer c.Close()
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i, 0)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it := c.ScanCtx(ctx, 0, 10)
+	n := 0
+	for it.Next() {
+		if n++; n == 10 {
+			cancel()
+		}
+	}
+	if n >= 1000 {
+		t.Errorf("Expected the scan to stop early, got %d entries", n)
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled from Err, got %v", it.Err())
+	}
+
+	if got := c.ScanCtx(context.Background(), 0, 10).Count(); got != 1000 {
+		t.Errorf("Expected 1000 entries, got %d", got)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	c := NewCache[string, int](WithTracer[string, int](tracer))
+	defer c.Close()
+	ctx := context.Background()
+
+	c.SetCtx(ctx, "a", 1, 0)
+	c.GetCtx(ctx, "a")
+
+	var loaderSpan any
+	_, err := c.GetOrLoad(ctx, "b", func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+		loaderSpan = ctx.Value(spanKey{})
+		return 0, 0, 0, errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("Expected the loader error")
+	}
+	if loaderSpan != "mcache.GetOrLoad" {
+		t.Errorf("Expected the loader to see the span, got %v", loaderSpan)
+	}
+
+	want := []string{"mcache.Set:<nil>", "mcache.Get:<nil>", "mcache.GetOrLoad:boom"}
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if fmt.Sprint(tracer.ended) != fmt.Sprint(want) {
+		t.Errorf("Expected spans %v, got %v", want, tracer.ended)
+	}
+}
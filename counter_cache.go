@@ -0,0 +1,108 @@
+package mcache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// CounterCache is a cache of int64 counters for rate limiters, quotas and
+// similar hot counters. Each counter is an *atomic.Int64 held by a Cache,
+// so incrementing an existing counter is a lookup and an atomic add: it
+// does not replace the entry or go through the admission policy, and
+// concurrent increments are never lost.
+//
+// A counter removed by Delete, eviction or expiration while another
+// goroutine is adding to it loses that addition. Increments do not go
+// through the write-ahead log, so WithWAL only persists counter creation.
+type CounterCache[K comparable] struct {
+	cache *Cache[K, *atomic.Int64]
+}
+
+// NewCounterCache creates a new CounterCache with the given options.
+func NewCounterCache[K comparable](opts ...Option[K, *atomic.Int64]) *CounterCache[K] {
+	return &CounterCache[K]{cache: NewCache(opts...)}
+}
+
+// Increment adds delta to the counter at key and returns the new value.
+// A missing counter starts at 0 and expires after the default TTL, if any.
+// A new counter rejected by the admission policy is lost, as with Add.
+func (c *CounterCache[K]) Increment(key K, delta int64) int64 {
+	return c.Add(key, delta, 0)
+}
+
+// Decrement subtracts delta from the counter at key and returns the new
+// value. A missing counter starts at 0 and expires after the default TTL,
+// if any. A new counter rejected by the admission policy is lost, as with
+// Add.
+func (c *CounterCache[K]) Decrement(key K, delta int64) int64 {
+	return c.Add(key, -delta, 0)
+}
+
+// Add adds delta to the counter at key and returns the new value. If the
+// counter does not exist it is created with the value delta and the given
+// TTL; the TTL of an existing counter is left unchanged. If the admission
+// policy rejects a new counter, nothing is stored and the addition is
+// lost, although delta is returned; use TryAdd to detect it.
+func (c *CounterCache[K]) Add(key K, delta int64, ttl time.Duration) int64 {
+	n, _ := c.TryAdd(key, delta, ttl)
+	return n
+}
+
+// TryAdd is like Add but also reports whether the addition was stored.
+// ok is false if the admission policy rejected a new counter, in which
+// case delta is returned and the counter still does not exist.
+func (c *CounterCache[K]) TryAdd(key K, delta int64, ttl time.Duration) (value int64, ok bool) {
+	if n, ok := c.cache.Get(key); ok {
+		return n.Add(delta), true
+	}
+
+	n := new(atomic.Int64)
+	n.Store(delta)
+	if c.cache.SetIfAbsent(key, n, ttl) {
+		return delta, true
+	}
+
+	// Another goroutine created the counter first, or it was rejected.
+	if n, ok := c.cache.Get(key); ok {
+		return n.Add(delta), true
+	}
+	return delta, false
+}
+
+// Get returns the value of the counter at key.
+func (c *CounterCache[K]) Get(key K) (int64, bool) {
+	n, ok := c.cache.Get(key)
+	if !ok {
+		return 0, false
+	}
+	return n.Load(), true
+}
+
+// Set replaces the counter at key with one holding value.
+// Returns false if rejected by the admission policy.
+func (c *CounterCache[K]) Set(key K, value int64, ttl time.Duration) bool {
+	n := new(atomic.Int64)
+	n.Store(value)
+	return c.cache.Set(key, n, ttl)
+}
+
+// Delete removes the counter at key.
+func (c *CounterCache[K]) Delete(key K) bool {
+	return c.cache.Delete(key)
+}
+
+// Len returns the number of counters.
+func (c *CounterCache[K]) Len() int {
+	return c.cache.Len()
+}
+
+// Cache returns the underlying cache, for expiration, scanning and
+// metrics.
+func (c *CounterCache[K]) Cache() *Cache[K, *atomic.Int64] {
+	return c.cache
+}
+
+// Close stops the cache's background workers.
+func (c *CounterCache[K]) Close() {
+	c.cache.Close()
+}
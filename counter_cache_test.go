@@ -0,0 +1,98 @@
+package mcache
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCounterCache(t *testing.T) {
+	c := NewCounterCache[string]()
+	defer c.Close()
+
+	if n := c.Increment("a", 5); n != 5 {
+		t.Errorf("Expected 5, got %d", n)
+	}
+	if n := c.Increment("a", 2); n != 7 {
+		t.Errorf("Expected 7, got %d", n)
+	}
+	if n := c.Decrement("a", 10); n != -3 {
+		t.Errorf("Expected -3, got %d", n)
+	}
+	if n, ok := c.Get("a"); !ok || n != -3 {
+		t.Errorf("Expected -3, got %d, %v", n, ok)
+	}
+
+	c.Set("a", 100, 0)
+	if n := c.Increment("a", 1); n != 101 {
+		t.Errorf("Expected 101, got %d", n)
+	}
+	if !c.Delete("a") {
+		t.Error("Expected Delete to succeed")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected the counter to be deleted")
+	}
+}
+
+func TestCounterCacheRejected(t *testing.T) {
+	p := &rejectOddPolicy{Policer: NewLRUPolicy[int]()}
+	c := NewCounterCache[int](WithPolicy[int, *atomic.Int64](p))
+	defer c.Close()
+
+	if n, ok := c.TryAdd(2, 5, 0); !ok || n != 5 {
+		t.Errorf("Expected 5 stored, got %d, %v", n, ok)
+	}
+	if n, ok := c.TryAdd(2, 1, 0); !ok || n != 6 {
+		t.Errorf("Expected 6 stored, got %d, %v", n, ok)
+	}
+	if n, ok := c.TryAdd(1, 5, 0); ok || n != 5 {
+		t.Errorf("Expected a rejected counter to report false, got %d, %v", n, ok)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("Expected the rejected counter not to exist")
+	}
+}
+
+func TestCounterCacheTTL(t *testing.T) {
+	c := NewCounterCache[string]()
+	defer c.Close()
+
+	c.Add("w", 1, 50*time.Millisecond)
+	c.Add("w", 1, time.Hour) // does not extend the window
+	if n, _ := c.Get("w"); n != 2 {
+		t.Errorf("Expected 2, got %d", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := c.Add("w", 1, 50*time.Millisecond); n != 1 {
+		t.Errorf("Expected a new window to start at 1, got %d", n)
+	}
+}
+
+func TestCounterCacheConcurrent(t *testing.T) {
+	c := NewCounterCache[int](WithMaxEntries[int, *atomic.Int64](1000))
+	defer c.Close()
+
+	const goroutines, increments, keys = 8, 2000, 10
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				c.Increment(i%keys, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for k := 0; k < keys; k++ {
+		if n, _ := c.Get(k); n != goroutines*increments/keys {
+			t.Errorf("Key %d: expected %d, got %d", k, goroutines*increments/keys, n)
+		}
+	}
+	if m := c.Cache().Metrics(); m.Sets != keys {
+		t.Errorf("Expected one set per counter, got %d", m.Sets)
+	}
+}
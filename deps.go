@@ -0,0 +1,131 @@
+package mcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+// SetWithDeps is Set for an entry derived from other entries, such as a
+// rendered page built from its fragments. When one of deps is updated,
+// deleted, evicted or expires, the entry is deleted as well, and so are
+// the entries depending on it in turn. A dependency cycle stops the
+// cascade at the entry that started it. Dependencies need not be in the
+// cache when the entry is set.
+//
+// Like tags, dependencies belong to the entry: any later write to the key
+// other than Compute and Expire replaces them, and they are not saved in
+// snapshots or the write-ahead log. Cascades run before the operation
+// that triggered them returns, or in the background for buffered writes
+// and expirations.
+func (c *Cache[K, V]) SetWithDeps(key K, value V, ttl time.Duration, deps ...K) bool {
+	ok, _ := c.setWithCost(context.Background(), key, value, 1, ttl, newLinks(nil, deps))
+	return ok
+}
+
+// dependencyChanged schedules a cascade for the dependents of key, whose
+// entry was just replaced or removed.
+func (c *Cache[K, V]) dependencyChanged(key K) {
+	if c.deps.has(key) {
+		c.cascades.push(key)
+	}
+}
+
+// cascade deletes the dependents of the entries changed since it last
+// ran. It must be called without holding any locks.
+func (c *Cache[K, V]) cascade() {
+	for {
+		key, ok := c.cascades.pop()
+		if !ok {
+			return
+		}
+		c.cascadeFrom(key)
+	}
+}
+
+// cascadeFrom deletes the transitive dependents of root, level by level.
+// Deleted entries are not scheduled for cascades of their own, and an
+// entry is deleted at most once, which ends cycles.
+func (c *Cache[K, V]) cascadeFrom(root K) {
+	visited := map[K]struct{}{root: {}}
+	level := []K{root}
+	depth := 0
+	for len(level) > 0 {
+		var next []K
+		for _, dep := range level {
+			for _, key := range c.deps.keys(dep) {
+				if _, ok := visited[key]; ok {
+					continue
+				}
+				if c.deleteDependent(key, dep) {
+					visited[key] = struct{}{}
+					next = append(next, key)
+				}
+			}
+		}
+		if len(next) > 0 {
+			depth++
+		}
+		level = next
+	}
+	if depth > 0 {
+		c.metrics.addCascade(int64(len(visited)-1), int64(depth))
+	}
+}
+
+// deleteDependent deletes key if its entry still depends on dep.
+func (c *Cache[K, V]) deleteDependent(key, dep K) bool {
+	keyHash := c.store.KeyHash(key)
+	defer c.walLock(keyHash)()
+
+	entry, _ := c.store.DeleteIfByHash(key, keyHash, func(e *store.Entry[K, V]) bool {
+		return e.Links.DependsOn(dep)
+	})
+	if entry == nil {
+		return false
+	}
+	c.removed(entry)
+	c.notify(Event[K, V]{Type: EventDelete, Cause: CauseDependency, Key: entry.Key, OldValue: entry.Value})
+	return true
+}
+
+// cascadeQueue holds the keys whose dependents are waiting to be deleted.
+// Any goroutine finishing a write drains it.
+type cascadeQueue[K comparable] struct {
+	mu   sync.Mutex
+	keys []K
+	size atomic.Int64 // len(keys), readable without the lock
+}
+
+func (q *cascadeQueue[K]) push(key K) {
+	q.mu.Lock()
+	q.keys = append(q.keys, key)
+	q.size.Store(int64(len(q.keys)))
+	q.mu.Unlock()
+}
+
+func (q *cascadeQueue[K]) pop() (K, bool) {
+	var key K
+	if q.size.Load() == 0 {
+		return key, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.keys) == 0 {
+		return key, false
+	}
+	key = q.keys[0]
+	q.keys = q.keys[1:]
+	q.size.Store(int64(len(q.keys)))
+	return key, true
+}
+
+func (q *cascadeQueue[K]) clear() {
+	q.mu.Lock()
+	q.keys = nil
+	q.size.Store(0)
+	q.mu.Unlock()
+}
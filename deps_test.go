@@ -0,0 +1,167 @@
+package mcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetWithDepsCascade(t *testing.T) {
+	c := NewCache[string, string]()
+	defer c.Close()
+
+	c.Set("header", "h", 0)
+	c.Set("body", "b", 0)
+	c.SetWithDeps("page", "h+b", 0, "header", "body")
+	c.SetWithDeps("site", "page", 0, "page")
+	c.SetWithDeps("other", "b", 0, "body")
+
+	c.Set("header", "h2", 0)
+	for _, key := range []string{"page", "site"} {
+		if c.Has(key) {
+			t.Errorf("Expected %s to be deleted with its dependency", key)
+		}
+	}
+	if !c.Has("other") || !c.Has("body") {
+		t.Error("Expected unrelated entries to remain")
+	}
+
+	m := c.Metrics()
+	if m.CascadeDeletes != 2 {
+		t.Errorf("Expected 2 cascade deletes, got %d", m.CascadeDeletes)
+	}
+	if m.MaxCascadeDepth != 2 {
+		t.Errorf("Expected a cascade depth of 2, got %d", m.MaxCascadeDepth)
+	}
+
+	c.Delete("body")
+	if c.Has("other") {
+		t.Error("Expected Delete to cascade")
+	}
+}
+
+func TestSetWithDepsTriggers(t *testing.T) {
+	c := NewCache[string, int](WithMaxEntries[string, int](100))
+	defer c.Close()
+
+	// Expiry.
+	c.Set("short", 1, 20*time.Millisecond)
+	c.SetWithDeps("derived", 2, 0, "short")
+	time.Sleep(1500 * time.Millisecond)
+	if c.Has("derived") {
+		t.Error("Expected expiry to cascade")
+	}
+
+	// Compute updates and conditional writes.
+	c.Set("n", 1, 0)
+	c.SetWithDeps("double", 2, 0, "n")
+	c.Compute("n", func(old int, exists bool) (int, ComputeOp) {
+		return old + 1, ComputeSet
+	})
+	if c.Has("double") {
+		t.Error("Expected Compute to cascade")
+	}
+	c.SetWithDeps("double", 4, 0, "n")
+	c.CompareAndDelete("n", 2)
+	if c.Has("double") {
+		t.Error("Expected CompareAndDelete to cascade")
+	}
+
+	// Rewriting a dependent drops its dependencies.
+	c.Set("n", 1, 0)
+	c.SetWithDeps("double", 2, 0, "n")
+	c.Set("double", 2, 0)
+	c.Set("n", 5, 0)
+	if !c.Has("double") {
+		t.Error("Expected a rewritten dependent to have no dependencies")
+	}
+}
+
+func TestSetWithDepsEviction(t *testing.T) {
+	c := NewCache[int, int](WithMaxEntries[int, int](10))
+	defer c.Close()
+
+	c.Set(-1, 0, 0)
+	c.SetWithDeps(-2, 0, 0, -1)
+	for i := 0; c.Has(-1) && i < 10000; i++ {
+		c.Set(i, i, 0)
+	}
+	if c.Has(-1) {
+		t.Fatal("Expected the dependency to be evicted")
+	}
+	if c.Has(-2) {
+		t.Error("Expected eviction to cascade")
+	}
+}
+
+func TestSetWithDepsCycle(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	c.SetWithDeps("a", 1, 0, "b")
+	c.SetWithDeps("b", 2, 0, "a")
+	c.SetWithDeps("self", 3, 0, "self")
+
+	// Rewriting a keeps the new entry even though it depends on b, which
+	// depended on the old a.
+	c.SetWithDeps("a", 10, 0, "b")
+	if v, ok := c.Get("a"); !ok || v != 10 {
+		t.Errorf("Expected a=10, got %d, %v", v, ok)
+	}
+	if c.Has("b") {
+		t.Error("Expected b to be deleted")
+	}
+
+	c.SetWithDeps("self", 4, 0, "self")
+	if !c.Has("self") {
+		t.Error("Expected a self-dependent entry to survive its own update")
+	}
+}
+
+func TestSetWithDepsBuffered(t *testing.T) {
+	c := NewCache[string, int](WithBufferItems[string, int](64))
+	defer c.Close()
+
+	c.Set("dep", 1, 0)
+	c.SetWithDeps("derived", 2, 0, "dep")
+	c.Wait()
+	c.Delete("dep")
+	c.Wait()
+	if c.Has("derived") {
+		t.Error("Expected a buffered delete to cascade")
+	}
+}
+
+func TestSetWithDepsConcurrent(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				dep := fmt.Sprintf("dep%d", i%5)
+				c.Set(dep, i, 0)
+				c.SetWithDeps(fmt.Sprintf("page%d-%d", g, i%20), i, 0, dep)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i := 0; i < 5; i++ {
+		c.Delete(fmt.Sprintf("dep%d", i))
+	}
+	for g := 0; g < 4; g++ {
+		for i := 0; i < 20; i++ {
+			if key := fmt.Sprintf("page%d-%d", g, i); c.Has(key) {
+				t.Errorf("Expected %s to be deleted", key)
+			}
+		}
+	}
+	if len(c.deps.byLink) != 0 {
+		t.Errorf("Expected an empty dependency index, got %v", c.deps.byLink)
+	}
+}
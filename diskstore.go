@@ -0,0 +1,231 @@
+package mcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+)
+
+// Disk store file format (all integers little-endian):
+//
+//	[4:magic "MCL2"][8:expireAt unix nanos][8:cost][uvarint:keyLen][key][value][4:crc32c]
+//
+// The checksum covers everything before it. expireAt is 0 for items that
+// do not expire.
+const (
+	diskStoreMagic      = "MCL2"
+	diskStoreHeaderSize = 20
+)
+
+// DiskStore is an L2Store that keeps each item in its own file under a
+// directory, named by a SHA-256 hash of the encoded key, for a TieredCache
+// with a warm tail on local disk. Files are replaced atomically by
+// renaming, so items survive a restart and a crash never leaves a torn
+// item. Files that are corrupt, or whose item has expired, read as
+// missing and are removed.
+//
+// DiskStore does not limit the space it uses: files are removed by
+// Delete, Clear and reads of expired items only.
+type DiskStore[K comparable, V any] struct {
+	dir   string
+	codec Codec[K, V]
+}
+
+// OpenDiskStore opens a DiskStore in dir, creating the directory if
+// needed, and encoding items with codec.
+func OpenDiskStore[K comparable, V any](dir string, codec Codec[K, V]) (*DiskStore[K, V], error) {
+	if codec == nil {
+		return nil, ErrNoCodec
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskStore[K, V]{dir: dir, codec: codec}, nil
+}
+
+// Get implements L2Store.
+func (s *DiskStore[K, V]) Get(ctx context.Context, key K) (Item[K, V], bool, error) {
+	var item Item[K, V]
+	if err := ctx.Err(); err != nil {
+		return item, false, err
+	}
+	encKey, path, err := s.locate(key)
+	if err != nil {
+		return item, false, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return item, false, nil
+	}
+	if err != nil {
+		return item, false, err
+	}
+
+	expireAt, cost, value, ok := parseDiskItem(data, encKey)
+	if !ok {
+		_ = os.Remove(path)
+		return item, false, nil
+	}
+	ttl, live := remainingTTL(expireAt)
+	if !live {
+		_ = os.Remove(path)
+		return item, false, nil
+	}
+	v, err := s.codec.DecodeValue(value)
+	if err != nil {
+		return item, false, err
+	}
+	return Item[K, V]{Key: key, Value: v, Cost: cost, TTL: ttl}, true, nil
+}
+
+// Set implements L2Store.
+func (s *DiskStore[K, V]) Set(ctx context.Context, item Item[K, V]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	encKey, path, err := s.locate(item.Key)
+	if err != nil {
+		return err
+	}
+
+	var expireAt int64
+	if item.TTL > 0 {
+		expireAt = clock.NowNano() + int64(item.TTL)
+	}
+	data := make([]byte, diskStoreHeaderSize, diskStoreHeaderSize+len(encKey)+64)
+	copy(data, diskStoreMagic)
+	binary.LittleEndian.PutUint64(data[4:12], uint64(expireAt))
+	binary.LittleEndian.PutUint64(data[12:20], uint64(item.Cost))
+	data = binary.AppendUvarint(data, uint64(len(encKey)))
+	data = append(data, encKey...)
+	if data, err = s.codec.AppendValue(data, item.Value); err != nil {
+		return err
+	}
+	data = binary.LittleEndian.AppendUint32(data, crc32.Checksum(data, snapshotCRC))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Delete implements L2Store.
+func (s *DiskStore[K, V]) Delete(ctx context.Context, key K) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	_, path, err := s.locate(key)
+	if err != nil {
+		return false, err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Clear implements L2Store. It removes the subdirectories holding the
+// items and leaves any other file in the directory alone.
+func (s *DiskStore[K, V]) Clear(ctx context.Context) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !e.IsDir() || !isDiskShard(e.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements L2Store. A DiskStore holds no open files, so it does
+// nothing.
+func (s *DiskStore[K, V]) Close() error {
+	return nil
+}
+
+// locate returns the encoded key and the path of the file of key. Files
+// are spread over 256 subdirectories by the first byte of their name.
+func (s *DiskStore[K, V]) locate(key K) ([]byte, string, error) {
+	encKey, err := s.codec.AppendKey(nil, key)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(encKey)
+	name := hex.EncodeToString(sum[:])
+	return encKey, filepath.Join(s.dir, name[:2], name), nil
+}
+
+// isDiskShard reports whether name is the name of a subdirectory made by
+// locate: two lowercase hex digits.
+func isDiskShard(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDiskItem decodes a disk store file, returning ok = false if it is
+// corrupt or holds a key other than encKey.
+func parseDiskItem(data, encKey []byte) (expireAt, cost int64, value []byte, ok bool) {
+	if len(data) < diskStoreHeaderSize+4 || string(data[:4]) != diskStoreMagic {
+		return 0, 0, nil, false
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.Checksum(body, snapshotCRC) != binary.LittleEndian.Uint32(sum) {
+		return 0, 0, nil, false
+	}
+	expireAt = int64(binary.LittleEndian.Uint64(body[4:12]))
+	cost = int64(binary.LittleEndian.Uint64(body[12:20]))
+
+	rest := body[diskStoreHeaderSize:]
+	keyLen, n := binary.Uvarint(rest)
+	if n <= 0 || keyLen > uint64(len(rest)-n) {
+		return 0, 0, nil, false
+	}
+	rest = rest[n:]
+	if !bytes.Equal(rest[:keyLen], encKey) {
+		return 0, 0, nil, false
+	}
+	return expireAt, cost, rest[keyLen:], true
+}
@@ -2,6 +2,7 @@
 package buffer
 
 import (
+	"context"
 	"runtime"
 	"sync/atomic"
 	"time"
@@ -274,13 +275,13 @@ func (wb *WriteBuffer[T]) Flush() {
 
 // FlushSync triggers an immediate flush and blocks until it completes.
 func (wb *WriteBuffer[T]) FlushSync() {
-	done := make(chan struct{})
-	select {
-	case wb.syncCh <- done:
-		<-done
-	case <-wb.doneCh:
-		// Buffer already closed
-	}
+	_ = wb.FlushSyncContext(context.Background())
+}
+
+// FlushSyncContext is FlushSync that stops waiting when ctx is done,
+// returning ctx.Err(). The flush still completes in the background.
+func (wb *WriteBuffer[T]) FlushSyncContext(ctx context.Context) error {
+	return flushSync(ctx, wb.syncCh, wb.doneCh)
 }
 
 // Close stops the write buffer and flushes remaining items.
@@ -368,11 +369,31 @@ func (lb *LossyBuffer[T]) flushAll(batch *[]T) {
 
 // FlushSync drains the lossy buffer before returning.
 func (lb *LossyBuffer[T]) FlushSync() {
+	_ = lb.FlushSyncContext(context.Background())
+}
+
+// FlushSyncContext is FlushSync that stops waiting when ctx is done,
+// returning ctx.Err().
+func (lb *LossyBuffer[T]) FlushSyncContext(ctx context.Context) error {
+	return flushSync(ctx, lb.syncCh, lb.doneCh)
+}
+
+// flushSync asks a flush loop to drain its ring and waits for it.
+func flushSync(ctx context.Context, syncCh chan chan struct{}, doneCh chan struct{}) error {
 	done := make(chan struct{})
 	select {
-	case lb.syncCh <- done:
-		<-done
-	case <-lb.doneCh:
+	case syncCh <- done:
+	case <-doneCh:
+		// Buffer already closed
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
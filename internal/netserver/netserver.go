@@ -0,0 +1,166 @@
+// Package netserver provides the listener and connection handling shared
+// by the protocol servers, and the key-striped locks that serialize their
+// writes.
+package netserver
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/hash"
+)
+
+// stripeCount is the number of locks in Stripes.
+const stripeCount = 256
+
+// Server accepts connections on any number of listeners, runs a handler
+// for each connection and tracks them until Close.
+type Server struct {
+	errClosed error
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+
+	totalConns atomic.Int64
+}
+
+// New returns a Server whose Serve returns errClosed after Close.
+func New(errClosed error) *Server {
+	return &Server{
+		errClosed: errClosed,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections
+// with handle.
+func (s *Server) ListenAndServe(addr string, handle func(net.Conn)) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln, handle)
+}
+
+// Serve accepts connections on ln and calls handle for each in its own
+// goroutine, closing the connection when handle returns. Temporary accept
+// errors are retried with backoff. Serve returns the Server's closed error
+// after Close, and ln is closed on return.
+func (s *Server) Serve(ln net.Listener, handle func(net.Conn)) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return s.errClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+		ln.Close()
+	}()
+
+	var backoff time.Duration
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return s.errClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				backoff = min(max(2*backoff, 5*time.Millisecond), time.Second)
+				time.Sleep(backoff)
+				continue
+			}
+			return err
+		}
+		backoff = 0
+
+		if !s.track(nc) {
+			nc.Close()
+			return s.errClosed
+		}
+		s.totalConns.Add(1)
+		go func() {
+			defer s.untrack(nc)
+			defer nc.Close()
+			handle(nc)
+		}()
+	}
+}
+
+// Close stops all listeners, closes all connections and waits for their
+// handlers to return.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// track registers a connection; it returns false after Close.
+func (s *Server) track(nc net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[nc] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrack(nc net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, nc)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// NumConns returns the number of open connections.
+func (s *Server) NumConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// TotalConns returns the number of connections accepted so far.
+func (s *Server) TotalConns() int64 {
+	return s.totalConns.Load()
+}
+
+// Stripes is a set of key-striped locks. The zero value is ready to use.
+type Stripes struct {
+	locks [stripeCount]sync.Mutex
+}
+
+// Lock locks the stripe for key and returns its unlock function.
+func (l *Stripes) Lock(key string) func() {
+	mu := &l.locks[hash.String(key)%stripeCount]
+	mu.Lock()
+	return mu.Unlock
+}
@@ -0,0 +1,78 @@
+package netserver
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+var errTestClosed = errors.New("test: closed")
+
+func TestServeAndClose(t *testing.T) {
+	s := New(errTestClosed)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := make(chan struct{})
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ln, func(nc net.Conn) {
+			close(handled)
+			io.Copy(io.Discard, nc) // Until Close closes the connection
+		})
+	}()
+
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	<-handled
+	if n := s.NumConns(); n != 1 {
+		t.Errorf("Expected 1 open connection, got %d", n)
+	}
+
+	s.Close()
+	if err := <-served; err != errTestClosed {
+		t.Errorf("Expected the closed error from Serve, got %v", err)
+	}
+	if n := s.NumConns(); n != 0 {
+		t.Errorf("Expected no open connections after Close, got %d", n)
+	}
+	if n := s.TotalConns(); n != 1 {
+		t.Errorf("Expected 1 accepted connection, got %d", n)
+	}
+	nc.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := nc.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Serve(ln2, func(net.Conn) {}); err != errTestClosed {
+		t.Errorf("Expected Serve after Close to fail, got %v", err)
+	}
+}
+
+func TestStripes(t *testing.T) {
+	var l Stripes
+	unlock := l.Lock("a")
+	locked := make(chan struct{})
+	go func() {
+		defer l.Lock("a")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Expected the stripe of a to be held")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
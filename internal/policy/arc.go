@@ -0,0 +1,206 @@
+package policy
+
+// ARC queue identifiers stored in node.region.
+const (
+	arcT1 uint8 = iota // Resident, seen once recently
+	arcT2              // Resident, seen at least twice
+	arcB1              // Ghost of an entry evicted from T1
+	arcB2              // Ghost of an entry evicted from T2
+)
+
+// ARC implements the Adaptive Replacement Cache policy (Megiddo & Modha).
+// Residents are split between a recency queue (T1) and a frequency queue
+// (T2); ghost queues (B1, B2) remember recently evicted keys and steer the
+// target size of T1 towards whichever queue would have produced a hit.
+// Sizes are measured in cost when a cost limit is set, otherwise in entries.
+type ARC[K comparable] struct {
+	listPolicy[K]
+	t1, t2, b1, b2 nodeList[K]
+	ghosts         map[K]*node[K]
+	p              int64 // Target weight of T1
+}
+
+// NewARC creates a new ARC policy. Limits are set with SetMaxCost and
+// SetMaxEntries.
+func NewARC[K comparable]() *ARC[K] {
+	return &ARC[K]{
+		listPolicy: listPolicy[K]{index: newNodeIndex[K]()},
+		ghosts:     make(map[K]*node[K]),
+	}
+}
+
+func (p *ARC[K]) queue(region uint8) *nodeList[K] {
+	switch region {
+	case arcT1:
+		return &p.t1
+	case arcT2:
+		return &p.t2
+	case arcB1:
+		return &p.b1
+	default:
+		return &p.b2
+	}
+}
+
+// Add inserts a key. A key remembered by a ghost queue enters T2 and
+// adapts the T1 target; any other key enters T1.
+func (p *ARC[K]) Add(key K, keyHash uint64, cost int64) (victims []Victim[K], added bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(p.queue(n.region), n, cost)
+		p.hitLocked(n)
+		return nil, true
+	}
+
+	if !p.limits.fits(cost) {
+		return nil, false
+	}
+
+	n := p.newNode(key, keyHash, cost)
+	c := p.limits.capacity()
+	ghostB2 := false
+
+	if g := p.ghosts[key]; g != nil {
+		switch g.region {
+		case arcB1:
+			delta := max(p.b2.weight/max(p.b1.weight, 1), 1) * n.weight
+			p.p = min(c, p.p+delta)
+		case arcB2:
+			delta := max(p.b1.weight/max(p.b2.weight, 1), 1) * n.weight
+			p.p = max(0, p.p-delta)
+			ghostB2 = true
+		}
+		p.dropGhostLocked(g)
+		n.region = arcT2
+	} else {
+		n.region = arcT1
+	}
+
+	p.index.add(n)
+	p.queue(n.region).pushFront(n)
+	p.limits.add(cost)
+
+	for p.limits.over() {
+		v := p.replaceLocked(ghostB2)
+		if v == nil {
+			break
+		}
+		victims = append(victims, Victim[K]{Key: v.key, KeyHash: v.keyHash})
+	}
+	p.trimGhostsLocked()
+	return rejectIncoming(key, victims)
+}
+
+// replaceLocked evicts the LRU resident of T1 or T2 into its ghost queue.
+func (p *ARC[K]) replaceLocked(ghostB2 bool) *node[K] {
+	var v *node[K]
+	if p.t1.len > 0 && (p.t1.weight > p.p || (ghostB2 && p.t1.weight == p.p) || p.t2.len == 0) {
+		v = p.t1.back()
+	} else {
+		v = p.t2.back()
+	}
+	if v == nil {
+		return nil
+	}
+
+	p.queue(v.region).remove(v)
+	p.index.del(v)
+	p.limits.sub(v.cost)
+
+	if p.limits.capacity() > 0 {
+		if v.region == arcT1 {
+			v.region = arcB1
+		} else {
+			v.region = arcB2
+		}
+		if old := p.ghosts[v.key]; old != nil {
+			p.dropGhostLocked(old)
+		}
+		p.ghosts[v.key] = v
+		p.queue(v.region).pushFront(v)
+	}
+	return v
+}
+
+// trimGhostsLocked bounds the ghost queues to the cache capacity.
+func (p *ARC[K]) trimGhostsLocked() {
+	c := p.limits.capacity()
+	for p.b1.len > 0 && p.t1.weight+p.b1.weight > c {
+		p.dropGhostLocked(p.b1.back())
+	}
+	for p.b2.len > 0 && p.t1.weight+p.t2.weight+p.b1.weight+p.b2.weight > 2*c {
+		p.dropGhostLocked(p.b2.back())
+	}
+}
+
+func (p *ARC[K]) dropGhostLocked(g *node[K]) {
+	p.queue(g.region).remove(g)
+	delete(p.ghosts, g.key)
+}
+
+// hitLocked promotes a resident to the MRU end of T2.
+func (p *ARC[K]) hitLocked(n *node[K]) {
+	if n.region == arcT2 {
+		p.t2.moveToFront(n)
+		return
+	}
+	p.t1.remove(n)
+	n.region = arcT2
+	p.t2.pushFront(n)
+}
+
+// Access records a hit on a resident key.
+func (p *ARC[K]) Access(keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.getHash(keyHash); n != nil {
+		p.hitLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// AccessBatch records accesses for multiple keys under one lock.
+func (p *ARC[K]) AccessBatch(keyHashes []uint64) {
+	p.mu.Lock()
+	for _, keyHash := range keyHashes {
+		if n := p.index.getHash(keyHash); n != nil {
+			p.hitLocked(n)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Del removes a key from the policy without remembering it as a ghost.
+func (p *ARC[K]) Del(key K, keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.queue(n.region).remove(n)
+		p.index.del(n)
+		p.limits.sub(n.cost)
+	}
+	p.mu.Unlock()
+}
+
+// Update updates the cost of an existing key.
+func (p *ARC[K]) Update(key K, keyHash uint64, cost int64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(p.queue(n.region), n, cost)
+	}
+	p.mu.Unlock()
+}
+
+// Clear resets the policy to its initial state.
+func (p *ARC[K]) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.index.clear()
+	p.ghosts = make(map[K]*node[K])
+	p.t1.clear()
+	p.t2.clear()
+	p.b1.clear()
+	p.b2.clear()
+	p.p = 0
+	p.limits.reset()
+}
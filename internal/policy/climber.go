@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+const (
+	// climbInitialStep is the first window fraction adjustment.
+	climbInitialStep = 0.0625
+
+	// climbStepDecay shrinks the step after every adjustment so that the
+	// window settles once the hit rate stops improving.
+	climbStepDecay = 0.98
+
+	// climbRestartThreshold is the hit rate change that signals a new
+	// workload phase and resets the step to its initial size.
+	climbRestartThreshold = 0.05
+
+	// climbSampleFactor sets the sample period to this many requests per
+	// unit of capacity.
+	climbSampleFactor = 10
+
+	// climbMinSample is the minimum number of requests per sample.
+	climbMinSample = 100
+)
+
+// hillClimber tunes a fraction by hill climbing on the hit rate.
+// Hits and misses are recorded lock-free; adjust is called under the owning
+// policy's lock once a full sample has been collected. The first sample
+// is a baseline taken before the fraction is first moved; after that, if
+// the hit rate improved since the last sample the fraction keeps moving in
+// the same direction, otherwise the direction is reversed.
+type hillClimber struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	prevHitRate float64
+	sampled     bool    // Whether prevHitRate holds a baseline
+	step        float64 // Signed; the sign is the current direction
+}
+
+func newHillClimber() hillClimber {
+	return hillClimber{step: climbInitialStep}
+}
+
+// recordHit counts a cache hit.
+func (h *hillClimber) recordHit() {
+	h.hits.Add(1)
+}
+
+// recordMiss counts a cache miss.
+func (h *hillClimber) recordMiss() {
+	h.misses.Add(1)
+}
+
+// adjust returns the change to apply to the fraction once sampleSize
+// requests have been recorded, or 0 while the sample is incomplete.
+func (h *hillClimber) adjust(sampleSize int64) float64 {
+	if h.hits.Load()+h.misses.Load() < sampleSize {
+		return 0
+	}
+	hits := h.hits.Swap(0)
+	misses := h.misses.Swap(0)
+	if hits+misses == 0 {
+		return 0
+	}
+
+	hitRate := float64(hits) / float64(hits+misses)
+	delta := hitRate - h.prevHitRate
+	h.prevHitRate = hitRate
+	if !h.sampled {
+		// Nothing has moved yet, so delta says nothing about the step.
+		h.sampled = true
+		return h.step
+	}
+
+	if delta < 0 {
+		h.step = -h.step
+	}
+	amount := h.step
+	if math.Abs(delta) >= climbRestartThreshold {
+		h.step = math.Copysign(climbInitialStep, h.step)
+	} else {
+		h.step *= climbStepDecay
+	}
+	return amount
+}
+
+// reset forgets the collected samples and restarts the climb.
+func (h *hillClimber) reset() {
+	h.hits.Store(0)
+	h.misses.Store(0)
+	h.prevHitRate = 0
+	h.sampled = false
+	h.step = climbInitialStep
+}
@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// simulate replays keys against p the way the cache does and returns the
+// hit rate.
+func simulate(p *WTinyLFU[int], keys []int) float64 {
+	hits := 0
+	for _, key := range keys {
+		if p.Has(key) {
+			hits++
+			p.RecordHit()
+			p.Access(hashOf(key))
+			continue
+		}
+		p.RecordMiss()
+		p.Add(key, hashOf(key), 1)
+	}
+	return float64(hits) / float64(len(keys))
+}
+
+// recencyTrace returns a trace where every key is popular for a short
+// time after it first appears and is never requested again.
+func recencyTrace(n, recent int) []int {
+	rng := rand.New(rand.NewSource(1))
+	keys := make([]int, 0, n)
+	next := 0
+	for len(keys) < n {
+		if next < recent || rng.Intn(4) == 0 {
+			keys = append(keys, next)
+			next++
+			continue
+		}
+		keys = append(keys, next-1-rng.Intn(recent))
+	}
+	return keys
+}
+
+// frequencyTrace returns a Zipf-distributed trace with stable popularity.
+func frequencyTrace(n int) []int {
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.2, 1, 100000)
+	keys := make([]int, n)
+	for i := range keys {
+		keys[i] = int(zipf.Uint64())
+	}
+	return keys
+}
+
+func TestAdaptiveWTinyLFUGrowsWindowForRecency(t *testing.T) {
+	keys := recencyTrace(200000, 400)
+
+	static := NewWTinyLFU[int](10000)
+	static.SetMaxEntries(500)
+	staticRate := simulate(static, keys)
+
+	adaptive := NewAdaptiveWTinyLFU[int](10000)
+	adaptive.SetMaxEntries(500)
+	adaptiveRate := simulate(adaptive, keys)
+
+	if f := adaptive.WindowFraction(); f <= defaultWindowFraction {
+		t.Errorf("Expected the window to grow above %v, got %v", defaultWindowFraction, f)
+	}
+	if adaptiveRate < staticRate {
+		t.Errorf("Expected adaptive hit rate %.3f >= static %.3f", adaptiveRate, staticRate)
+	}
+	if static.WindowFraction() != defaultWindowFraction {
+		t.Errorf("Expected the static window to stay at %v, got %v", defaultWindowFraction, static.WindowFraction())
+	}
+}
+
+func TestAdaptiveWTinyLFUFrequency(t *testing.T) {
+	keys := frequencyTrace(200000)
+
+	static := NewWTinyLFU[int](10000)
+	static.SetMaxEntries(500)
+	staticRate := simulate(static, keys)
+
+	adaptive := NewAdaptiveWTinyLFU[int](10000)
+	adaptive.SetMaxEntries(500)
+	adaptiveRate := simulate(adaptive, keys)
+
+	if f := adaptive.WindowFraction(); f < 0 || f > maxWindowFraction {
+		t.Errorf("Expected the window fraction within [0, %v], got %v", maxWindowFraction, f)
+	}
+	if adaptiveRate < staticRate-0.02 {
+		t.Errorf("Expected adaptive hit rate %.3f close to static %.3f", adaptiveRate, staticRate)
+	}
+}
+
+func TestHillClimberDirection(t *testing.T) {
+	h := newHillClimber()
+
+	record := func(hits, misses int) {
+		for i := 0; i < hits; i++ {
+			h.recordHit()
+		}
+		for i := 0; i < misses; i++ {
+			h.recordMiss()
+		}
+	}
+
+	if d := h.adjust(100); d != 0 {
+		t.Errorf("Expected no adjustment before a full sample, got %v", d)
+	}
+
+	record(50, 50)
+	first := h.adjust(100)
+	if first <= 0 {
+		t.Fatalf("Expected the first adjustment to grow the fraction, got %v", first)
+	}
+
+	record(60, 40) // Improved: keep the direction
+	if d := h.adjust(100); d <= 0 {
+		t.Errorf("Expected an improvement to keep the direction, got %v", d)
+	}
+
+	record(40, 60) // Worse: reverse
+	if d := h.adjust(100); d >= 0 {
+		t.Errorf("Expected a regression to reverse the direction, got %v", d)
+	}
+}
@@ -0,0 +1,151 @@
+package policy
+
+// lfuBucket holds the nodes with one access frequency, most recent first.
+// Buckets form a list ordered by ascending frequency.
+type lfuBucket[K comparable] struct {
+	freq       int64
+	items      nodeList[K]
+	prev, next *lfuBucket[K]
+}
+
+// LFU implements least-frequently-used eviction with O(1) operations.
+// Nodes are grouped into frequency buckets; the victim is the least
+// recently used node of the lowest-frequency bucket.
+// Frequencies are exact and never decay, so use W-TinyLFU for workloads
+// whose popularity shifts over time.
+type LFU[K comparable] struct {
+	listPolicy[K]
+	buckets lfuBucket[K] // Sentinel; buckets.next has the lowest frequency
+}
+
+// NewLFU creates a new LFU policy. Limits are set with SetMaxCost and
+// SetMaxEntries.
+func NewLFU[K comparable]() *LFU[K] {
+	p := &LFU[K]{listPolicy: listPolicy[K]{index: newNodeIndex[K]()}}
+	p.buckets.next = &p.buckets
+	p.buckets.prev = &p.buckets
+	return p
+}
+
+// Add inserts a key with frequency 1 and evicts the least frequently used
+// keys until the limits are met.
+func (p *LFU[K]) Add(key K, keyHash uint64, cost int64) (victims []Victim[K], added bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(&n.bucket.items, n, cost)
+		p.incrementLocked(n)
+		return nil, true
+	}
+
+	if !p.limits.fits(cost) {
+		return nil, false
+	}
+
+	n := p.newNode(key, keyHash, cost)
+	p.index.add(n)
+	p.limits.add(cost)
+
+	// Evict before linking the new node so that it does not compete with
+	// the existing frequency-1 entries.
+	for p.limits.over() {
+		first := p.buckets.next
+		if first == &p.buckets {
+			break
+		}
+		v := first.items.back()
+		p.removeLocked(v)
+		victims = append(victims, Victim[K]{Key: v.key, KeyHash: v.keyHash})
+	}
+	p.linkLocked(n, &p.buckets, 1)
+	return victims, true
+}
+
+// linkLocked links n into the bucket with frequency freq right after
+// bucket after, creating it if needed.
+func (p *LFU[K]) linkLocked(n *node[K], after *lfuBucket[K], freq int64) {
+	b := after.next
+	if b == &p.buckets || b.freq != freq {
+		b = &lfuBucket[K]{freq: freq, prev: after, next: after.next}
+		after.next.prev = b
+		after.next = b
+	}
+	n.freq = freq
+	n.bucket = b
+	b.items.pushFront(n)
+}
+
+// unlinkLocked removes n from its bucket, dropping the bucket if empty,
+// and returns the bucket preceding n's position.
+func (p *LFU[K]) unlinkLocked(n *node[K]) *lfuBucket[K] {
+	b := n.bucket
+	b.items.remove(n)
+	n.bucket = nil
+	if b.items.len > 0 {
+		return b
+	}
+	b.prev.next = b.next
+	b.next.prev = b.prev
+	return b.prev
+}
+
+func (p *LFU[K]) incrementLocked(n *node[K]) {
+	freq := n.freq + 1
+	after := p.unlinkLocked(n)
+	p.linkLocked(n, after, freq)
+}
+
+// Access increments the key's frequency.
+func (p *LFU[K]) Access(keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.getHash(keyHash); n != nil {
+		p.incrementLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// AccessBatch records accesses for multiple keys under one lock.
+func (p *LFU[K]) AccessBatch(keyHashes []uint64) {
+	p.mu.Lock()
+	for _, keyHash := range keyHashes {
+		if n := p.index.getHash(keyHash); n != nil {
+			p.incrementLocked(n)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Del removes a key from the policy.
+func (p *LFU[K]) Del(key K, keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.removeLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// Update updates the cost of an existing key.
+func (p *LFU[K]) Update(key K, keyHash uint64, cost int64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(&n.bucket.items, n, cost)
+	}
+	p.mu.Unlock()
+}
+
+func (p *LFU[K]) removeLocked(n *node[K]) {
+	p.unlinkLocked(n)
+	p.index.del(n)
+	p.limits.sub(n.cost)
+}
+
+// Clear resets the policy to its initial state.
+func (p *LFU[K]) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.index.clear()
+	p.buckets.next = &p.buckets
+	p.buckets.prev = &p.buckets
+	p.limits.reset()
+}
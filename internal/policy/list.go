@@ -0,0 +1,250 @@
+package policy
+
+import "sync"
+
+// node is an entry tracked by the list-based policies (LRU, LFU, ARC,
+// S3-FIFO, W-TinyLFU). Nodes are linked into intrusive doubly linked lists
+// so that moves between queues never allocate.
+type node[K comparable] struct {
+	key     K
+	keyHash uint64
+	cost    int64
+	weight  int64 // Size in the policy's capacity unit (see limits.weight)
+	freq    int64 // Policy-specific access counter
+	region  uint8 // Policy-specific queue the node is linked into
+
+	bucket     *lfuBucket[K] // LFU only
+	prev, next *node[K]
+}
+
+// nodeList is a doubly linked list of nodes with a sentinel root.
+// The front is the most recently inserted end.
+type nodeList[K comparable] struct {
+	root   node[K]
+	len    int
+	weight int64
+}
+
+func (l *nodeList[K]) lazyInit() {
+	if l.root.next == nil {
+		l.root.next = &l.root
+		l.root.prev = &l.root
+	}
+}
+
+// pushFront links n at the front of the list.
+func (l *nodeList[K]) pushFront(n *node[K]) {
+	l.lazyInit()
+	n.prev = &l.root
+	n.next = l.root.next
+	l.root.next.prev = n
+	l.root.next = n
+	l.len++
+	l.weight += n.weight
+}
+
+// remove unlinks n, which must be in l.
+func (l *nodeList[K]) remove(n *node[K]) {
+	n.prev.next = n.next
+	n.next.prev = n.prev
+	n.prev = nil
+	n.next = nil
+	l.len--
+	l.weight -= n.weight
+}
+
+// moveToFront moves n, which must be in l, to the front.
+func (l *nodeList[K]) moveToFront(n *node[K]) {
+	if l.root.next == n {
+		return
+	}
+	l.remove(n)
+	l.pushFront(n)
+}
+
+// front returns the most recently inserted node, or nil.
+func (l *nodeList[K]) front() *node[K] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.next
+}
+
+// back returns the least recently inserted node, or nil.
+func (l *nodeList[K]) back() *node[K] {
+	if l.len == 0 {
+		return nil
+	}
+	return l.root.prev
+}
+
+// reweigh adjusts the list weight after n.weight changed by delta.
+func (l *nodeList[K]) reweigh(delta int64) {
+	l.weight += delta
+}
+
+// clear drops all nodes.
+func (l *nodeList[K]) clear() {
+	l.root.next = &l.root
+	l.root.prev = &l.root
+	l.len = 0
+	l.weight = 0
+}
+
+// nodeIndex finds resident nodes by exact key, and by key hash for
+// Access, which only receives the hash. If two resident keys share a hash,
+// the most recently added one receives their accesses.
+type nodeIndex[K comparable] struct {
+	byKey  map[K]*node[K]
+	byHash map[uint64]*node[K]
+}
+
+func newNodeIndex[K comparable]() nodeIndex[K] {
+	return nodeIndex[K]{
+		byKey:  make(map[K]*node[K]),
+		byHash: make(map[uint64]*node[K]),
+	}
+}
+
+func (x *nodeIndex[K]) add(n *node[K]) {
+	x.byKey[n.key] = n
+	x.byHash[n.keyHash] = n
+}
+
+func (x *nodeIndex[K]) del(n *node[K]) {
+	delete(x.byKey, n.key)
+	if x.byHash[n.keyHash] == n {
+		delete(x.byHash, n.keyHash)
+	}
+}
+
+func (x *nodeIndex[K]) get(key K) *node[K] {
+	return x.byKey[key]
+}
+
+func (x *nodeIndex[K]) getHash(keyHash uint64) *node[K] {
+	return x.byHash[keyHash]
+}
+
+func (x *nodeIndex[K]) clear() {
+	x.byKey = make(map[K]*node[K])
+	x.byHash = make(map[uint64]*node[K])
+}
+
+// limits tracks usage against the cost and entry limits.
+type limits struct {
+	maxCost    int64
+	maxEntries int64
+	usedCost   int64
+	numEntries int64
+}
+
+// weight converts a cost into the unit used to size policy regions:
+// cost when a cost limit is set, otherwise one per entry.
+func (l *limits) weight(cost int64) int64 {
+	if l.maxCost > 0 {
+		return cost
+	}
+	return 1
+}
+
+// capacity returns the total size in weight units, or 0 if unbounded.
+func (l *limits) capacity() int64 {
+	if l.maxCost > 0 {
+		return l.maxCost
+	}
+	return l.maxEntries
+}
+
+// over reports whether either limit is exceeded.
+func (l *limits) over() bool {
+	return (l.maxCost > 0 && l.usedCost > l.maxCost) ||
+		(l.maxEntries > 0 && l.numEntries > l.maxEntries)
+}
+
+// fits reports whether an entry of the given cost can ever be stored.
+func (l *limits) fits(cost int64) bool {
+	return l.maxCost <= 0 || cost <= l.maxCost
+}
+
+func (l *limits) add(cost int64) {
+	l.usedCost += cost
+	l.numEntries++
+}
+
+func (l *limits) sub(cost int64) {
+	l.usedCost -= cost
+	l.numEntries--
+}
+
+func (l *limits) reset() {
+	l.usedCost = 0
+	l.numEntries = 0
+}
+
+// rejectIncoming removes the incoming key from victims, reporting whether
+// the policy kept it. A list policy admits by inserting first and then
+// evicting; if the new entry itself was chosen, it was not admitted.
+func rejectIncoming[K comparable](key K, victims []Victim[K]) ([]Victim[K], bool) {
+	for i, v := range victims {
+		if v.Key == key {
+			return append(victims[:i], victims[i+1:]...), false
+		}
+	}
+	return victims, true
+}
+
+// listPolicy holds the state and methods shared by the list-based policies.
+type listPolicy[K comparable] struct {
+	mu     sync.Mutex
+	limits limits
+	index  nodeIndex[K]
+}
+
+// Has checks if a key is tracked by the policy.
+func (p *listPolicy[K]) Has(key K) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.index.get(key) != nil
+}
+
+// Cost returns the current total cost.
+func (p *listPolicy[K]) Cost() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limits.usedCost
+}
+
+// NumEntries returns the current number of entries.
+func (p *listPolicy[K]) NumEntries() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limits.numEntries
+}
+
+// SetMaxCost updates the maximum cost limit.
+func (p *listPolicy[K]) SetMaxCost(maxCost int64) {
+	p.mu.Lock()
+	p.limits.maxCost = maxCost
+	p.mu.Unlock()
+}
+
+// SetMaxEntries updates the maximum entries limit.
+func (p *listPolicy[K]) SetMaxEntries(maxEntries int64) {
+	p.mu.Lock()
+	p.limits.maxEntries = maxEntries
+	p.mu.Unlock()
+}
+
+// newNode creates a node weighed against the current limits.
+func (p *listPolicy[K]) newNode(key K, keyHash uint64, cost int64) *node[K] {
+	return &node[K]{key: key, keyHash: keyHash, cost: cost, weight: p.limits.weight(cost)}
+}
+
+// resizeLocked applies a cost change to n and to the list it is in.
+func (p *listPolicy[K]) resizeLocked(l *nodeList[K], n *node[K], cost int64) {
+	weight := p.limits.weight(cost)
+	l.reweigh(weight - n.weight)
+	p.limits.usedCost += cost - n.cost
+	n.cost, n.weight = cost, weight
+}
@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// listPolicies returns fresh instances of every list-based policy.
+func listPolicies() map[string]Policer[int] {
+	return map[string]Policer[int]{
+		"LRU":      NewLRU[int](),
+		"LFU":      NewLFU[int](),
+		"ARC":      NewARC[int](),
+		"S3FIFO":   NewS3FIFO[int](),
+		"WTinyLFU": NewWTinyLFU[int](1000),
+	}
+}
+
+func hashOf(key int) uint64 {
+	return uint64(key)*0x9E3779B97F4A7C15 + 1
+}
+
+func TestListPoliciesRespectLimits(t *testing.T) {
+	for name, p := range listPolicies() {
+		t.Run(name, func(t *testing.T) {
+			p.SetMaxEntries(100)
+
+			resident := make(map[int]bool)
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 10000; i++ {
+				key := rng.Intn(500)
+				if resident[key] {
+					p.Access(hashOf(key))
+					continue
+				}
+				victims, added := p.Add(key, hashOf(key), 1)
+				for _, v := range victims {
+					if !resident[v.Key] {
+						t.Fatalf("Evicted non-resident key %d", v.Key)
+					}
+					if v.KeyHash != hashOf(v.Key) {
+						t.Fatalf("Victim %d has wrong hash", v.Key)
+					}
+					delete(resident, v.Key)
+				}
+				if added {
+					resident[key] = true
+				}
+				if n := p.NumEntries(); n > 100 {
+					t.Fatalf("Expected at most 100 entries, got %d", n)
+				}
+			}
+
+			if int(p.NumEntries()) != len(resident) {
+				t.Errorf("Expected %d entries tracked, got %d", len(resident), p.NumEntries())
+			}
+			for key := range resident {
+				if !p.Has(key) {
+					t.Errorf("Expected resident key %d to be tracked", key)
+				}
+			}
+		})
+	}
+}
+
+func TestListPoliciesCostAccounting(t *testing.T) {
+	for name, p := range listPolicies() {
+		t.Run(name, func(t *testing.T) {
+			p.SetMaxCost(100)
+
+			p.Add(1, hashOf(1), 10)
+			p.Add(2, hashOf(2), 20)
+			p.Update(2, hashOf(2), 30)
+			if c := p.Cost(); c != 40 {
+				t.Errorf("Expected cost 40, got %d", c)
+			}
+
+			p.Del(1, hashOf(1))
+			if p.Has(1) || p.Cost() != 30 || p.NumEntries() != 1 {
+				t.Errorf("Expected key 1 removed, cost=%d entries=%d", p.Cost(), p.NumEntries())
+			}
+
+			if _, added := p.Add(3, hashOf(3), 101); added {
+				t.Error("Expected entry larger than MaxCost to be rejected")
+			}
+
+			p.Clear()
+			if p.Cost() != 0 || p.NumEntries() != 0 || p.Has(2) {
+				t.Error("Expected Clear to reset the policy")
+			}
+		})
+	}
+}
+
+func TestListPoliciesUnbounded(t *testing.T) {
+	for name, p := range listPolicies() {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 1000; i++ {
+				if victims, added := p.Add(i, hashOf(i), 1); len(victims) > 0 || !added {
+					t.Fatalf("Expected unbounded policy to admit without evicting")
+				}
+			}
+		})
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	p := NewLRU[int]()
+	p.SetMaxEntries(3)
+
+	p.Add(1, hashOf(1), 1)
+	p.Add(2, hashOf(2), 1)
+	p.Add(3, hashOf(3), 1)
+	p.Access(hashOf(1))
+
+	victims, _ := p.Add(4, hashOf(4), 1)
+	if len(victims) != 1 || victims[0].Key != 2 {
+		t.Errorf("Expected key 2 to be evicted, got %v", victims)
+	}
+}
+
+func TestLFUEvictsLeastFrequentlyUsed(t *testing.T) {
+	p := NewLFU[int]()
+	p.SetMaxEntries(3)
+
+	p.Add(1, hashOf(1), 1)
+	p.Add(2, hashOf(2), 1)
+	p.Add(3, hashOf(3), 1)
+	for i := 0; i < 3; i++ {
+		p.Access(hashOf(1))
+		p.Access(hashOf(3))
+	}
+	p.Access(hashOf(2))
+	p.Access(hashOf(1))
+
+	victims, _ := p.Add(4, hashOf(4), 1)
+	if len(victims) != 1 || victims[0].Key != 2 {
+		t.Errorf("Expected key 2 to be evicted, got %v", victims)
+	}
+
+	// The newcomer has the lowest frequency now.
+	victims, _ = p.Add(5, hashOf(5), 1)
+	if len(victims) != 1 || victims[0].Key != 4 {
+		t.Errorf("Expected key 4 to be evicted, got %v", victims)
+	}
+}
+
+func TestARCGhostHitPromotes(t *testing.T) {
+	p := NewARC[int]()
+	p.SetMaxEntries(2)
+
+	p.Add(1, hashOf(1), 1)
+	p.Add(2, hashOf(2), 1)
+	p.Access(hashOf(1))                  // 1 moves to T2
+	victims, _ := p.Add(3, hashOf(3), 1) // Evicts 2 from T1 into B1
+	if len(victims) != 1 || victims[0].Key != 2 {
+		t.Fatalf("Expected key 2 to be evicted, got %v", victims)
+	}
+
+	p.Add(2, hashOf(2), 1) // Ghost hit
+	if p.p == 0 {
+		t.Error("Expected a B1 ghost hit to grow the T1 target")
+	}
+	if n := p.index.get(2); n == nil || n.region != arcT2 {
+		t.Error("Expected ghost hit to enter T2")
+	}
+}
+
+// scanResistance fills p with a hot set, runs a long one-pass scan and
+// reports how many hot keys survived.
+func scanResistance(p Policer[int], hot, capacity int) int {
+	p.SetMaxEntries(int64(capacity))
+	for round := 0; round < 5; round++ {
+		for key := 0; key < hot; key++ {
+			if p.Has(key) {
+				p.Access(hashOf(key))
+			} else {
+				p.Add(key, hashOf(key), 1)
+			}
+		}
+	}
+	for key := 1000; key < 1000+10*capacity; key++ {
+		p.Add(key, hashOf(key), 1)
+	}
+	survived := 0
+	for key := 0; key < hot; key++ {
+		if p.Has(key) {
+			survived++
+		}
+	}
+	return survived
+}
+
+func TestScanResistantPolicies(t *testing.T) {
+	for name, p := range map[string]Policer[int]{
+		"ARC":      NewARC[int](),
+		"S3FIFO":   NewS3FIFO[int](),
+		"WTinyLFU": NewWTinyLFU[int](10000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if n := scanResistance(p, 50, 100); n < 40 {
+				t.Errorf("Expected most of the hot set to survive a scan, %d/50 did", n)
+			}
+		})
+	}
+
+	if n := scanResistance(NewLRU[int](), 50, 100); n != 0 {
+		t.Errorf("Expected LRU to lose the hot set to a scan, %d/50 survived", n)
+	}
+}
+
+func BenchmarkListPolicies(b *testing.B) {
+	for name, p := range listPolicies() {
+		b.Run(name, func(b *testing.B) {
+			p.SetMaxEntries(10000)
+			rng := rand.New(rand.NewSource(1))
+			zipf := rand.NewZipf(rng, 1.1, 1, 100000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := int(zipf.Uint64())
+				if p.Has(key) {
+					p.Access(hashOf(key))
+				} else {
+					p.Add(key, hashOf(key), 1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package policy
+
+// LRU implements least-recently-used eviction without admission control.
+type LRU[K comparable] struct {
+	listPolicy[K]
+	list nodeList[K]
+}
+
+// NewLRU creates a new LRU policy. Limits are set with SetMaxCost and
+// SetMaxEntries.
+func NewLRU[K comparable]() *LRU[K] {
+	return &LRU[K]{listPolicy: listPolicy[K]{index: newNodeIndex[K]()}}
+}
+
+// Add inserts a key at the most recently used position and evicts from
+// the least recently used end until the limits are met.
+func (p *LRU[K]) Add(key K, keyHash uint64, cost int64) (victims []Victim[K], added bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(&p.list, n, cost)
+		p.list.moveToFront(n)
+		return nil, true
+	}
+
+	if !p.limits.fits(cost) {
+		return nil, false
+	}
+
+	n := p.newNode(key, keyHash, cost)
+	p.index.add(n)
+	p.list.pushFront(n)
+	p.limits.add(cost)
+
+	for p.limits.over() {
+		v := p.list.back()
+		if v == nil {
+			break
+		}
+		p.removeLocked(v)
+		victims = append(victims, Victim[K]{Key: v.key, KeyHash: v.keyHash})
+	}
+	return rejectIncoming(key, victims)
+}
+
+// Access moves the key to the most recently used position.
+func (p *LRU[K]) Access(keyHash uint64) {
+	p.mu.Lock()
+	p.accessLocked(keyHash)
+	p.mu.Unlock()
+}
+
+// AccessBatch records accesses for multiple keys under one lock.
+func (p *LRU[K]) AccessBatch(keyHashes []uint64) {
+	p.mu.Lock()
+	for _, keyHash := range keyHashes {
+		p.accessLocked(keyHash)
+	}
+	p.mu.Unlock()
+}
+
+func (p *LRU[K]) accessLocked(keyHash uint64) {
+	if n := p.index.getHash(keyHash); n != nil {
+		p.list.moveToFront(n)
+	}
+}
+
+// Del removes a key from the policy.
+func (p *LRU[K]) Del(key K, keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.removeLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// Update updates the cost of an existing key.
+func (p *LRU[K]) Update(key K, keyHash uint64, cost int64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(&p.list, n, cost)
+	}
+	p.mu.Unlock()
+}
+
+func (p *LRU[K]) removeLocked(n *node[K]) {
+	p.list.remove(n)
+	p.index.del(n)
+	p.limits.sub(n.cost)
+}
+
+// Clear resets the policy to its initial state.
+func (p *LRU[K]) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.index.clear()
+	p.list.clear()
+	p.limits.reset()
+}
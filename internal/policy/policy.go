@@ -61,15 +61,7 @@ func (p *Policy[K]) findVictimsLocked(incomingHash uint64, cost int64) []Victim[
 			break
 		}
 
-		var victim Victim[K]
-		lowestFreq := int64(1<<63 - 1)
-		for _, v := range sample {
-			freq := p.admit.Estimate(v.KeyHash)
-			if freq < lowestFreq {
-				lowestFreq = freq
-				victim = v
-			}
-		}
+		victim := p.evict.Victim(sample, p.admit.Estimate)
 
 		if !p.admit.Admit(incomingHash, victim.KeyHash) {
 			break
@@ -131,6 +123,17 @@ func (p *Policy[K]) SetMaxCost(maxCost int64) {
 	p.evict.SetMaxCost(maxCost)
 }
 
+// SetPartitions splits the keys into weighted partitions for eviction; see
+// SampledLFU.SetPartitions.
+func (p *Policy[K]) SetPartitions(partitionOf func(K) string, weights map[string]float64) {
+	p.evict.SetPartitions(partitionOf, weights)
+}
+
+// PartitionCosts returns the cost used by each partition.
+func (p *Policy[K]) PartitionCosts() map[string]int64 {
+	return p.evict.PartitionCosts()
+}
+
 // SetMaxEntries updates the maximum entries limit.
 func (p *Policy[K]) SetMaxEntries(maxEntries int64) {
 	p.evict.SetMaxEntries(maxEntries)
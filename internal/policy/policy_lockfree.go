@@ -62,15 +62,7 @@ func (p *PolicyLockFree[K]) findVictimsLocked(incomingHash uint64, cost int64) [
 			break
 		}
 
-		var victim Victim[K]
-		lowestFreq := int64(1<<63 - 1)
-		for _, v := range sample {
-			freq := p.admit.Estimate(v.KeyHash)
-			if freq < lowestFreq {
-				lowestFreq = freq
-				victim = v
-			}
-		}
+		victim := p.evict.Victim(sample, p.admit.Estimate)
 
 		if !p.admit.Admit(incomingHash, victim.KeyHash) {
 			break
@@ -138,6 +130,17 @@ func (p *PolicyLockFree[K]) SetMaxCost(maxCost int64) {
 	p.evict.SetMaxCost(maxCost)
 }
 
+// SetPartitions splits the keys into weighted partitions for eviction; see
+// SampledLFU.SetPartitions.
+func (p *PolicyLockFree[K]) SetPartitions(partitionOf func(K) string, weights map[string]float64) {
+	p.evict.SetPartitions(partitionOf, weights)
+}
+
+// PartitionCosts returns the cost used by each partition.
+func (p *PolicyLockFree[K]) PartitionCosts() map[string]int64 {
+	return p.evict.PartitionCosts()
+}
+
 // SetMaxEntries updates the maximum entries limit.
 func (p *PolicyLockFree[K]) SetMaxEntries(maxEntries int64) {
 	p.evict.SetMaxEntries(maxEntries)
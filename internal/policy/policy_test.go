@@ -162,6 +162,66 @@ func TestSampledLFU(t *testing.T) {
 	}
 }
 
+func TestSampledLFUPartitions(t *testing.T) {
+	s := NewSampledLFU[uint64](0, 0)
+	s.SetPartitions(func(key uint64) string {
+		if key < 100 {
+			return "a"
+		}
+		return "b"
+	}, map[string]float64{"a": 4})
+
+	for k := uint64(0); k < 6; k++ {
+		s.Add(k, k, 10) // a: 60, pressure 15
+	}
+	for k := uint64(100); k < 103; k++ {
+		s.Add(k, k, 10) // b: 30, pressure 30
+	}
+	s.Update(100, 100, 20) // b: 40
+
+	costs := s.PartitionCosts()
+	if costs["a"] != 60 || costs["b"] != 40 {
+		t.Errorf("Expected costs a=60 b=40, got %v", costs)
+	}
+
+	// b is the victim partition despite a's entries being colder.
+	freq := func(keyHash uint64) int64 {
+		if keyHash < 100 {
+			return 0
+		}
+		return int64(keyHash)
+	}
+	if v := s.Victim(s.Sample(), freq); v.Key != 100 {
+		t.Errorf("Expected the coldest entry of b, got %d", v.Key)
+	}
+
+	s.Del(100)
+	s.Del(101)
+	if v := s.Victim(s.Sample(), freq); v.Key >= 100 {
+		t.Errorf("Expected an entry of a once b is under its share, got %d", v.Key)
+	}
+
+	s.Clear()
+	if costs := s.PartitionCosts(); costs["a"] != 0 || costs["b"] != 0 {
+		t.Errorf("Expected Clear to reset the partition costs, got %v", costs)
+	}
+}
+
+func TestSampledLFUVictimAllocs(t *testing.T) {
+	s := NewSampledLFU[uint64](0, 0)
+	for k := uint64(0); k < 50; k++ {
+		s.Add(k, k, 1)
+	}
+	sample := s.Sample()
+	freq := func(keyHash uint64) int64 { return int64(keyHash) }
+	if v := s.Victim(sample, freq); v.Key != 0 {
+		t.Errorf("Expected the least frequent entry, got %d", v.Key)
+	}
+	if n := testing.AllocsPerRun(100, func() { s.Victim(sample, freq) }); n != 0 {
+		t.Errorf("Expected no allocations without partitions, got %v", n)
+	}
+}
+
 func TestPolicy(t *testing.T) {
 	p := NewPolicy[uint64](1000, 100, 0) // 100 max cost, unlimited entries
 
@@ -0,0 +1,196 @@
+package policy
+
+// S3-FIFO queue identifiers stored in node.region.
+const (
+	s3Small uint8 = iota
+	s3Main
+)
+
+const (
+	// s3SmallRatio is the share of the capacity given to the small queue.
+	s3SmallRatio = 0.1
+
+	// s3MaxFreq caps the per-entry access counter.
+	s3MaxFreq = 3
+)
+
+// S3FIFO implements the S3-FIFO policy (Yang et al., SOSP '23).
+// New keys enter a small FIFO queue; keys that are accessed again while
+// there move to the main FIFO queue, the rest are evicted quickly and
+// remembered in a ghost queue so that they go straight to main if they
+// return. Main is managed as a CLOCK-like FIFO with a 2-bit counter.
+// This makes the policy resistant to one-hit wonders and scans.
+type S3FIFO[K comparable] struct {
+	listPolicy[K]
+	small, main nodeList[K]
+	ghost       nodeList[K]
+	ghosts      map[uint64]*node[K] // Ghost queue entries by key hash
+}
+
+// NewS3FIFO creates a new S3-FIFO policy. Limits are set with SetMaxCost
+// and SetMaxEntries.
+func NewS3FIFO[K comparable]() *S3FIFO[K] {
+	return &S3FIFO[K]{
+		listPolicy: listPolicy[K]{index: newNodeIndex[K]()},
+		ghosts:     make(map[uint64]*node[K]),
+	}
+}
+
+func (p *S3FIFO[K]) queue(region uint8) *nodeList[K] {
+	if region == s3Small {
+		return &p.small
+	}
+	return &p.main
+}
+
+// Add inserts a key into the small queue, or into the main queue if it
+// was recently evicted from the small queue.
+func (p *S3FIFO[K]) Add(key K, keyHash uint64, cost int64) (victims []Victim[K], added bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(p.queue(n.region), n, cost)
+		p.accessLocked(n)
+		return nil, true
+	}
+
+	if !p.limits.fits(cost) {
+		return nil, false
+	}
+
+	n := p.newNode(key, keyHash, cost)
+	if g := p.ghosts[keyHash]; g != nil {
+		p.ghost.remove(g)
+		delete(p.ghosts, keyHash)
+		n.region = s3Main
+	} else {
+		n.region = s3Small
+	}
+	p.index.add(n)
+	p.queue(n.region).pushFront(n)
+	p.limits.add(cost)
+
+	for p.limits.over() {
+		v := p.evictLocked()
+		if v == nil {
+			break
+		}
+		victims = append(victims, Victim[K]{Key: v.key, KeyHash: v.keyHash})
+	}
+	return rejectIncoming(key, victims)
+}
+
+// evictLocked removes and returns one resident.
+func (p *S3FIFO[K]) evictLocked() *node[K] {
+	smallTarget := max(int64(float64(p.limits.capacity())*s3SmallRatio), 1)
+	for {
+		if p.small.len > 0 && (p.small.weight >= smallTarget || p.main.len == 0) {
+			t := p.small.back()
+			p.small.remove(t)
+			if t.freq > 0 {
+				// Accessed while in the small queue: promote.
+				t.freq = 0
+				t.region = s3Main
+				p.main.pushFront(t)
+				continue
+			}
+			p.removeResidentLocked(t)
+			p.rememberLocked(t)
+			return t
+		}
+
+		t := p.main.back()
+		if t == nil {
+			return nil
+		}
+		p.main.remove(t)
+		if t.freq > 0 {
+			// Reinsert with a decremented counter.
+			t.freq--
+			p.main.pushFront(t)
+			continue
+		}
+		p.removeResidentLocked(t)
+		return t
+	}
+}
+
+// removeResidentLocked drops an already unlinked resident's bookkeeping.
+func (p *S3FIFO[K]) removeResidentLocked(n *node[K]) {
+	p.index.del(n)
+	p.limits.sub(n.cost)
+}
+
+// rememberLocked records an evicted key in the ghost queue, which holds
+// at most as many keys as the main queue.
+func (p *S3FIFO[K]) rememberLocked(n *node[K]) {
+	if old := p.ghosts[n.keyHash]; old != nil {
+		p.ghost.remove(old)
+	}
+	g := &node[K]{keyHash: n.keyHash, weight: 1}
+	p.ghosts[n.keyHash] = g
+	p.ghost.pushFront(g)
+	for p.ghost.len > max(p.main.len, 1) {
+		old := p.ghost.back()
+		p.ghost.remove(old)
+		delete(p.ghosts, old.keyHash)
+	}
+}
+
+func (p *S3FIFO[K]) accessLocked(n *node[K]) {
+	if n.freq < s3MaxFreq {
+		n.freq++
+	}
+}
+
+// Access increments the key's access counter.
+func (p *S3FIFO[K]) Access(keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.getHash(keyHash); n != nil {
+		p.accessLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// AccessBatch records accesses for multiple keys under one lock.
+func (p *S3FIFO[K]) AccessBatch(keyHashes []uint64) {
+	p.mu.Lock()
+	for _, keyHash := range keyHashes {
+		if n := p.index.getHash(keyHash); n != nil {
+			p.accessLocked(n)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Del removes a key from the policy.
+func (p *S3FIFO[K]) Del(key K, keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.queue(n.region).remove(n)
+		p.removeResidentLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// Update updates the cost of an existing key.
+func (p *S3FIFO[K]) Update(key K, keyHash uint64, cost int64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(p.queue(n.region), n, cost)
+	}
+	p.mu.Unlock()
+}
+
+// Clear resets the policy to its initial state.
+func (p *S3FIFO[K]) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.index.clear()
+	p.ghosts = make(map[uint64]*node[K])
+	p.small.clear()
+	p.main.clear()
+	p.ghost.clear()
+	p.limits.reset()
+}
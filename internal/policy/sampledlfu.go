@@ -3,6 +3,7 @@ package policy
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -10,17 +11,28 @@ const (
 	defaultSampleSize = 5
 )
 
-// trackedItem stores the hash and cost for a tracked key.
+// trackedItem stores the hash, cost and partition for a tracked key.
 type trackedItem struct {
-	keyHash uint64
-	cost    int64
+	keyHash   uint64
+	cost      int64
+	partition int32 // index in partitions; 0 without a partitioner
+}
+
+// partition tracks the cost used by one partition of the keys.
+type partition struct {
+	name     string
+	weight   float64
+	usedCost int64
 }
 
 // SampledLFU implements sampled LFU eviction policy.
 // Generic over K for exact-key identity (no hash collision ambiguity).
 // Uses a dense array for O(sampleSize) random sampling.
+//
+// Keys may be split into weighted partitions, such as tenants; eviction
+// then prefers the partition using the most cost relative to its weight.
 type SampledLFU[K comparable] struct {
-	costs      map[K]trackedItem // key -> {hash, cost}
+	costs      map[K]trackedItem // key -> {hash, cost, partition}
 	keys       []K               // dense array for O(1) random access
 	keyIndex   map[K]int         // key -> index in keys[]
 	maxCost    int64
@@ -30,6 +42,12 @@ type SampledLFU[K comparable] struct {
 	sampleSize int
 	mu         sync.Mutex
 	rng        *rand.Rand
+
+	partitionOf    func(K) string     // nil = a single partition
+	partitioned    atomic.Bool        // partitionOf != nil, read without mu
+	weights        map[string]float64 // partition -> weight, default 1
+	partitions     []partition
+	partitionIndex map[string]int32
 }
 
 // NewSampledLFU creates a new SampledLFU eviction policy.
@@ -52,12 +70,16 @@ func (s *SampledLFU[K]) Add(key K, keyHash uint64, cost int64) {
 
 	if existing, exists := s.costs[key]; exists {
 		// Update cost
-		s.usedCost += cost - existing.cost
-		s.costs[key] = trackedItem{keyHash: keyHash, cost: cost}
+		s.updateLocked(key, existing, keyHash, cost)
 		return
 	}
 
-	s.costs[key] = trackedItem{keyHash: keyHash, cost: cost}
+	item := trackedItem{keyHash: keyHash, cost: cost}
+	if s.partitionOf != nil {
+		item.partition = s.partitionLocked(s.partitionOf(key))
+		s.partitions[item.partition].usedCost += cost
+	}
+	s.costs[key] = item
 	// Add to dense array
 	s.keyIndex[key] = len(s.keys)
 	s.keys = append(s.keys, key)
@@ -85,6 +107,9 @@ func (s *SampledLFU[K]) Del(key K) {
 
 	s.usedCost -= item.cost
 	s.numEntries--
+	if s.partitionOf != nil {
+		s.partitions[item.partition].usedCost -= item.cost
+	}
 	delete(s.costs, key)
 
 	// Swap-delete from dense array
@@ -108,9 +133,112 @@ func (s *SampledLFU[K]) Update(key K, keyHash uint64, cost int64) {
 	defer s.mu.Unlock()
 
 	if existing, exists := s.costs[key]; exists {
-		s.usedCost += cost - existing.cost
-		s.costs[key] = trackedItem{keyHash: keyHash, cost: cost}
+		s.updateLocked(key, existing, keyHash, cost)
+	}
+}
+
+// updateLocked sets the cost of a tracked key. Must be called with s.mu
+// held.
+func (s *SampledLFU[K]) updateLocked(key K, existing trackedItem, keyHash uint64, cost int64) {
+	s.usedCost += cost - existing.cost
+	if s.partitionOf != nil {
+		s.partitions[existing.partition].usedCost += cost - existing.cost
+	}
+	s.costs[key] = trackedItem{keyHash: keyHash, cost: cost, partition: existing.partition}
+}
+
+// SetPartitions splits keys into partitions named by partitionOf, weighted
+// by weights (1 for partitions not listed). It must be called before any
+// key is added.
+func (s *SampledLFU[K]) SetPartitions(partitionOf func(K) string, weights map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitionOf = partitionOf
+	s.partitioned.Store(partitionOf != nil)
+	s.weights = weights
+	s.partitions = nil
+	s.partitionIndex = make(map[string]int32)
+}
+
+// partitionLocked returns the index of the named partition, creating it if
+// needed. Must be called with s.mu held.
+func (s *SampledLFU[K]) partitionLocked(name string) int32 {
+	if i, ok := s.partitionIndex[name]; ok {
+		return i
+	}
+	weight := s.weights[name]
+	if weight <= 0 {
+		weight = 1
+	}
+	i := int32(len(s.partitions))
+	s.partitions = append(s.partitions, partition{name: name, weight: weight})
+	s.partitionIndex[name] = i
+	return i
+}
+
+// PartitionCosts returns the cost used by each partition, or nil without
+// partitions.
+func (s *SampledLFU[K]) PartitionCosts() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.partitionOf == nil {
+		return nil
 	}
+	costs := make(map[string]int64, len(s.partitions))
+	for _, p := range s.partitions {
+		costs[p.name] = p.usedCost
+	}
+	return costs
+}
+
+// Victim picks the entry to evict from a sample: the one with the lowest
+// frequency, as estimated by freq, among the sampled entries of the
+// partition using the most cost relative to its weight. Without
+// partitions it is simply the least frequently used sampled entry. The
+// sample must not be empty.
+func (s *SampledLFU[K]) Victim(sample []Victim[K], freq func(keyHash uint64) int64) Victim[K] {
+	if !s.partitioned.Load() {
+		best := 0
+		bestFreq := freq(sample[0].KeyHash)
+		for i := 1; i < len(sample); i++ {
+			if f := freq(sample[i].KeyHash); f < bestFreq {
+				best, bestFreq = i, f
+			}
+		}
+		return sample[best]
+	}
+
+	// Samples hold at most 20 entries once the cache has 100.
+	var buf [20]float64
+	var pressure []float64
+	if len(sample) <= len(buf) {
+		pressure = buf[:len(sample)]
+	} else {
+		pressure = make([]float64, len(sample))
+	}
+	s.mu.Lock()
+	if s.partitionOf != nil {
+		for i, v := range sample {
+			if item, ok := s.costs[v.Key]; ok {
+				p := &s.partitions[item.partition]
+				pressure[i] = float64(p.usedCost) / p.weight
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	best := 0
+	bestFreq := freq(sample[0].KeyHash)
+	for i := 1; i < len(sample); i++ {
+		if pressure[i] < pressure[best] {
+			continue
+		}
+		f := freq(sample[i].KeyHash)
+		if pressure[i] > pressure[best] || f < bestFreq {
+			best, bestFreq = i, f
+		}
+	}
+	return sample[best]
 }
 
 // UsedCost returns the total cost of all tracked items.
@@ -216,6 +344,9 @@ func (s *SampledLFU[K]) Clear() {
 	s.keyIndex = make(map[K]int)
 	s.usedCost = 0
 	s.numEntries = 0
+	for i := range s.partitions {
+		s.partitions[i].usedCost = 0
+	}
 }
 
 // SetMaxCost updates the maximum cost limit.
@@ -0,0 +1,294 @@
+package policy
+
+// W-TinyLFU queue identifiers stored in node.region.
+const (
+	wtlfuWindow uint8 = iota
+	wtlfuProbation
+	wtlfuProtected
+)
+
+const (
+	// defaultWindowFraction is the share of the capacity given to the
+	// admission window.
+	defaultWindowFraction = 0.01
+
+	// protectedRatio is the share of the main space given to the
+	// protected segment.
+	protectedRatio = 0.8
+
+	// maxWindowFraction bounds the admission window of the adaptive
+	// variant.
+	maxWindowFraction = 0.8
+)
+
+// WTinyLFU implements Window TinyLFU (Einziger et al.), the policy used by
+// Caffeine. New keys enter a small LRU admission window. Keys leaving the
+// window become candidates for the main space, a segmented LRU with a
+// probation and a protected segment; when the main space is full, a
+// candidate is only admitted if the TinyLFU sketch estimates it to be more
+// popular than the probation segment's LRU victim.
+// The window absorbs recency bursts while the sketch filters out
+// one-hit wonders and scans.
+//
+// The adaptive variant resizes the window online: RecordHit and RecordMiss
+// feed a hill climber that grows the window while a larger one improves the
+// hit rate (recency-biased workloads) and shrinks it otherwise
+// (frequency-biased workloads).
+type WTinyLFU[K comparable] struct {
+	listPolicy[K]
+	admit                        *TinyLFULockFree
+	window, probation, protected nodeList[K]
+	windowFraction               float64
+
+	adaptive bool
+	climber  hillClimber
+}
+
+// NewWTinyLFU creates a new W-TinyLFU policy with the given number of
+// sketch counters (10x the expected number of entries is recommended).
+// Limits are set with SetMaxCost and SetMaxEntries.
+func NewWTinyLFU[K comparable](numCounters int64) *WTinyLFU[K] {
+	return &WTinyLFU[K]{
+		listPolicy:     listPolicy[K]{index: newNodeIndex[K]()},
+		admit:          NewTinyLFULockFree(numCounters),
+		windowFraction: defaultWindowFraction,
+	}
+}
+
+// NewAdaptiveWTinyLFU creates a W-TinyLFU policy whose window fraction is
+// tuned by hill climbing on the hit rate reported through RecordHit and
+// RecordMiss.
+func NewAdaptiveWTinyLFU[K comparable](numCounters int64) *WTinyLFU[K] {
+	p := NewWTinyLFU[K](numCounters)
+	p.adaptive = true
+	p.climber = newHillClimber()
+	return p
+}
+
+func (p *WTinyLFU[K]) queue(region uint8) *nodeList[K] {
+	switch region {
+	case wtlfuWindow:
+		return &p.window
+	case wtlfuProbation:
+		return &p.probation
+	default:
+		return &p.protected
+	}
+}
+
+// capacities returns the window and protected segment capacities.
+func (p *WTinyLFU[K]) capacities() (window, protected int64) {
+	c := p.limits.capacity()
+	window = max(int64(float64(c)*p.windowFraction), 1)
+	protected = int64(float64(c-window) * protectedRatio)
+	return window, protected
+}
+
+// Add inserts a key into the admission window, moves window overflow to
+// the probation segment and evicts until the limits are met. The new key
+// itself may lose admission, in which case added is false.
+func (p *WTinyLFU[K]) Add(key K, keyHash uint64, cost int64) (victims []Victim[K], added bool) {
+	p.admit.Increment(keyHash)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(p.queue(n.region), n, cost)
+		p.hitLocked(n)
+		return nil, true
+	}
+
+	if !p.limits.fits(cost) {
+		return nil, false
+	}
+
+	n := p.newNode(key, keyHash, cost)
+	n.region = wtlfuWindow
+	p.index.add(n)
+	p.window.pushFront(n)
+	p.limits.add(cost)
+
+	if p.limits.capacity() == 0 {
+		return nil, true
+	}
+
+	if p.adaptive {
+		p.climbLocked()
+	}
+
+	// Window overflow becomes admission candidates for the main space.
+	windowCap, _ := p.capacities()
+	var candidates []*node[K]
+	for p.window.len > 0 && p.window.weight > windowCap {
+		c := p.window.back()
+		p.window.remove(c)
+		c.region = wtlfuProbation
+		p.probation.pushFront(c)
+		candidates = append(candidates, c)
+	}
+
+	for p.limits.over() {
+		v := p.evictLocked(&candidates)
+		if v == nil {
+			break
+		}
+		victims = append(victims, Victim[K]{Key: v.key, KeyHash: v.keyHash})
+	}
+	return rejectIncoming(key, victims)
+}
+
+// evictLocked removes and returns one resident. The newest remaining
+// candidate duels the probation LRU victim and the less popular one goes.
+func (p *WTinyLFU[K]) evictLocked(candidates *[]*node[K]) *node[K] {
+	// Drop candidates that were already evicted.
+	for len(*candidates) > 0 && (*candidates)[len(*candidates)-1].next == nil {
+		*candidates = (*candidates)[:len(*candidates)-1]
+	}
+
+	victim := p.probation.back()
+	if victim == nil {
+		victim = p.protected.back()
+	}
+	if victim == nil {
+		victim = p.window.back()
+	}
+	if victim == nil {
+		return nil
+	}
+
+	if n := len(*candidates); n > 0 {
+		candidate := (*candidates)[n-1]
+		if candidate != victim && p.admit.Estimate(candidate.keyHash) <= p.admit.Estimate(victim.keyHash) {
+			victim = candidate
+		}
+	}
+
+	p.queue(victim.region).remove(victim)
+	p.index.del(victim)
+	p.limits.sub(victim.cost)
+	return victim
+}
+
+// hitLocked reorders a resident after an access: window and protected
+// entries move to their MRU end, probation entries are promoted to the
+// protected segment.
+func (p *WTinyLFU[K]) hitLocked(n *node[K]) {
+	switch n.region {
+	case wtlfuWindow:
+		p.window.moveToFront(n)
+	case wtlfuProtected:
+		p.protected.moveToFront(n)
+	case wtlfuProbation:
+		p.probation.remove(n)
+		n.region = wtlfuProtected
+		p.protected.pushFront(n)
+
+		// Demote protected overflow back to probation.
+		_, protectedCap := p.capacities()
+		for p.protected.len > 1 && p.protected.weight > protectedCap {
+			d := p.protected.back()
+			p.protected.remove(d)
+			d.region = wtlfuProbation
+			p.probation.pushFront(d)
+		}
+	}
+}
+
+// Access records an access in the sketch and reorders the key.
+func (p *WTinyLFU[K]) Access(keyHash uint64) {
+	p.admit.Increment(keyHash)
+
+	p.mu.Lock()
+	if n := p.index.getHash(keyHash); n != nil {
+		p.hitLocked(n)
+	}
+	p.mu.Unlock()
+}
+
+// AccessBatch records accesses for multiple keys.
+func (p *WTinyLFU[K]) AccessBatch(keyHashes []uint64) {
+	p.admit.IncrementBatch(keyHashes)
+
+	p.mu.Lock()
+	for _, keyHash := range keyHashes {
+		if n := p.index.getHash(keyHash); n != nil {
+			p.hitLocked(n)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Del removes a key from the policy.
+func (p *WTinyLFU[K]) Del(key K, keyHash uint64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.queue(n.region).remove(n)
+		p.index.del(n)
+		p.limits.sub(n.cost)
+	}
+	p.mu.Unlock()
+}
+
+// Update updates the cost of an existing key.
+func (p *WTinyLFU[K]) Update(key K, keyHash uint64, cost int64) {
+	p.mu.Lock()
+	if n := p.index.get(key); n != nil {
+		p.resizeLocked(p.queue(n.region), n, cost)
+	}
+	p.mu.Unlock()
+}
+
+// Clear resets the policy to its initial state.
+func (p *WTinyLFU[K]) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.admit.Clear()
+	p.index.clear()
+	p.window.clear()
+	p.probation.clear()
+	p.protected.clear()
+	p.limits.reset()
+	if p.adaptive {
+		p.windowFraction = defaultWindowFraction
+		p.climber.reset()
+	}
+}
+
+// climbLocked applies the hill climber's adjustment to the window fraction
+// once a sample of about ten requests per resident entry is complete. A
+// shrunken window drains into probation as new keys arrive; a grown window
+// takes its space from the main segments as they evict.
+func (p *WTinyLFU[K]) climbLocked() {
+	sample := max(climbSampleFactor*p.limits.numEntries, climbMinSample)
+	if delta := p.climber.adjust(sample); delta != 0 {
+		p.windowFraction = min(max(p.windowFraction+delta, 0), maxWindowFraction)
+	}
+}
+
+// RecordHit reports a cache hit to the adaptive window sizing.
+func (p *WTinyLFU[K]) RecordHit() {
+	if p.adaptive {
+		p.climber.recordHit()
+	}
+}
+
+// RecordMiss reports a cache miss to the adaptive window sizing.
+func (p *WTinyLFU[K]) RecordMiss() {
+	if p.adaptive {
+		p.climber.recordMiss()
+	}
+}
+
+// WindowFraction returns the share of the capacity currently given to the
+// admission window.
+func (p *WTinyLFU[K]) WindowFraction() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.windowFraction
+}
+
+// Estimate returns the estimated frequency for a key.
+func (p *WTinyLFU[K]) Estimate(keyHash uint64) int64 {
+	return p.admit.Estimate(keyHash)
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"encoding/binary"
+	"slices"
 	"sync"
 	"sync/atomic"
 
@@ -12,14 +13,31 @@ const (
 	// defaultGCFreeShardCount is the default number of shards for GC-free storage.
 	defaultGCFreeShardCount = 256
 
+	// maxGCFreeShardCount is the most shards a Scan cursor can address.
+	maxGCFreeShardCount = 1 << 16
+
 	// entryHeaderSize is the size of entry header in bytes.
 	// Format: [4:keyLen][4:valueLen][8:expireAt][8:cost] = 24 bytes
 	entryHeaderSize = 24
 
 	// defaultInitialSize is the default initial size for data buffer.
 	defaultInitialSize = 1 << 20 // 1MB
+
+	// deletedFlag is set in the key length of a dead entry. The length
+	// itself is kept so that sequential scans can skip over it.
+	deletedFlag = 1 << 31
+
+	// scanMarkEvery is the number of entries between the offsets a shard
+	// remembers for checking that a Scan cursor is at an entry start.
+	scanMarkEvery = 64
 )
 
+// GCFreeEntrySize returns the number of bytes an entry occupies in
+// GC-free storage.
+func GCFreeEntrySize(keyLen, valueLen int) int64 {
+	return int64(entryHeaderSize + keyLen + valueLen)
+}
+
 // GCFreeEntry represents an entry in GC-free storage.
 type GCFreeEntry struct {
 	KeyHash  uint64
@@ -35,7 +53,10 @@ type gcFreeShard struct {
 	data    []byte            // Serialized entries
 	tail    int               // Current write position
 	deleted int               // Number of deleted/invalid bytes
-	_       [64 - 40]byte     // Cache line padding
+	epoch   uint16            // Changes whenever offsets are reused, never 0
+	entries uint32            // Entries written since offsets were last reused
+	marks   []uint32          // Offset of every scanMarkEvery-th entry written
+	_       [128 - 104]byte   // Cache line padding
 }
 
 // GCFreeStore is a GC-free storage backend.
@@ -52,7 +73,7 @@ func NewGCFreeStore(shardCount int, initialSize int) *GCFreeStore {
 	if shardCount <= 0 {
 		shardCount = defaultGCFreeShardCount
 	}
-	shardCount = nextPowerOf2(shardCount)
+	shardCount = nextPowerOf2(min(shardCount, maxGCFreeShardCount))
 
 	if initialSize <= 0 {
 		initialSize = defaultInitialSize
@@ -71,6 +92,7 @@ func NewGCFreeStore(shardCount int, initialSize int) *GCFreeStore {
 		s.shards[i] = &gcFreeShard{
 			hashmap: make(map[uint64]uint32),
 			data:    make([]byte, shardSize),
+			epoch:   1,
 		}
 	}
 
@@ -85,19 +107,35 @@ func (s *GCFreeStore) getShard(keyHash uint64) *gcFreeShard {
 // Get retrieves a value by key hash.
 // Returns the value, expiration time, and true if found; nil, 0, false otherwise.
 func (s *GCFreeStore) Get(keyHash uint64, key []byte) ([]byte, int64, bool) {
+	var value []byte
+	expireAt, ok := s.GetFunc(keyHash, key, func(v []byte) {
+		// Copy value to avoid holding lock
+		value = make([]byte, len(v))
+		copy(value, v)
+	})
+	if !ok {
+		return nil, 0, false
+	}
+	return value, expireAt, true
+}
+
+// GetFunc looks up a value by key hash and calls fn with it while the
+// shard is read-locked; the slice is only valid during the call.
+// Returns the expiration time and true if found; 0, false otherwise.
+func (s *GCFreeStore) GetFunc(keyHash uint64, key []byte, fn func(value []byte)) (int64, bool) {
 	sh := s.getShard(keyHash)
 
 	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
 	offset, exists := sh.hashmap[keyHash]
 	if !exists {
-		sh.mu.RUnlock()
-		return nil, 0, false
+		return 0, false
 	}
 
 	// Read entry header
 	if int(offset)+entryHeaderSize > len(sh.data) {
-		sh.mu.RUnlock()
-		return nil, 0, false
+		return 0, false
 	}
 
 	data := sh.data[offset:]
@@ -108,34 +146,39 @@ func (s *GCFreeStore) Get(keyHash uint64, key []byte) ([]byte, int64, bool) {
 	// Check if entry is valid
 	entrySize := entryHeaderSize + int(keyLen) + int(valueLen)
 	if int(offset)+entrySize > len(sh.data) {
-		sh.mu.RUnlock()
-		return nil, 0, false
+		return 0, false
 	}
 
 	// Verify key matches (in case of hash collision)
 	storedKey := data[entryHeaderSize : entryHeaderSize+int(keyLen)]
 	if !bytesEqual(storedKey, key) {
-		sh.mu.RUnlock()
-		return nil, 0, false
+		return 0, false
 	}
 
 	// Check expiration
 	if expireAt > 0 && clock.NowNano() > expireAt {
-		sh.mu.RUnlock()
-		return nil, 0, false
+		return 0, false
 	}
 
-	// Copy value to avoid holding lock
-	value := make([]byte, valueLen)
-	copy(value, data[entryHeaderSize+int(keyLen):entryHeaderSize+int(keyLen)+int(valueLen)])
-	sh.mu.RUnlock()
+	fn(data[entryHeaderSize+int(keyLen) : entrySize])
+	return expireAt, true
+}
 
-	return value, expireAt, true
+// Set stores a key-value pair, replacing any entry with the same key hash.
+// Returns the cost of the replaced entry and whether one existed.
+func (s *GCFreeStore) Set(keyHash uint64, key, value []byte, expireAt int64, cost int64) (prevCost int64, replaced bool) {
+	prevCost, replaced, _ = s.put(keyHash, key, value, expireAt, cost, false)
+	return prevCost, replaced
 }
 
-// Set stores a key-value pair.
-// Returns true if stored successfully, false if storage is full.
-func (s *GCFreeStore) Set(keyHash uint64, key, value []byte, expireAt int64, cost int64) bool {
+// Update replaces the entry with the given key hash only if one exists.
+// Returns the cost of the replaced entry and whether the update happened.
+func (s *GCFreeStore) Update(keyHash uint64, key, value []byte, expireAt int64, cost int64) (prevCost int64, ok bool) {
+	prevCost, ok, _ = s.put(keyHash, key, value, expireAt, cost, true)
+	return prevCost, ok
+}
+
+func (s *GCFreeStore) put(keyHash uint64, key, value []byte, expireAt, cost int64, onlyIfExists bool) (prevCost int64, existed, stored bool) {
 	sh := s.getShard(keyHash)
 
 	entrySize := entryHeaderSize + len(key) + len(value)
@@ -145,14 +188,11 @@ func (s *GCFreeStore) Set(keyHash uint64, key, value []byte, expireAt int64, cos
 
 	// Check if key already exists
 	if oldOffset, exists := sh.hashmap[keyHash]; exists {
-		// Read old entry sizes BEFORE marking as deleted
-		if int(oldOffset)+4 <= len(sh.data) {
-			oldKeyLen := binary.LittleEndian.Uint32(sh.data[oldOffset : oldOffset+4])
-			oldValueLen := binary.LittleEndian.Uint32(sh.data[oldOffset+4 : oldOffset+8])
-			// Mark old entry as deleted by zeroing the key length
-			binary.LittleEndian.PutUint32(sh.data[oldOffset:oldOffset+4], 0)
-			sh.deleted += entryHeaderSize + int(oldKeyLen) + int(oldValueLen)
-		}
+		existed = true
+		prevCost = int64(binary.LittleEndian.Uint64(sh.data[oldOffset+16 : oldOffset+24]))
+		sh.markDeleted(oldOffset)
+	} else if onlyIfExists {
+		return 0, false, false
 	} else {
 		s.size.Add(1)
 	}
@@ -162,7 +202,8 @@ func (s *GCFreeStore) Set(keyHash uint64, key, value []byte, expireAt int64, cos
 		if sh.deleted > len(sh.data)/4 {
 			// Compact to reclaim space
 			sh.compact()
-		} else {
+		}
+		if sh.tail+entrySize > len(sh.data) {
 			// Grow the buffer
 			sh.grow(entrySize)
 		}
@@ -179,13 +220,64 @@ func (s *GCFreeStore) Set(keyHash uint64, key, value []byte, expireAt int64, cos
 
 	sh.tail += entrySize
 	sh.hashmap[keyHash] = offset
+	sh.mark(offset)
 
-	return true
+	return prevCost, existed, true
+}
+
+// mark records offset as the start of the entry just written. Must be
+// called with sh.mu held.
+func (sh *gcFreeShard) mark(offset uint32) {
+	if sh.entries%scanMarkEvery == 0 {
+		sh.marks = append(sh.marks, offset)
+	}
+	sh.entries++
+}
+
+// isEntryStart reports whether pos is the offset of an entry, live or
+// dead, or the tail. It walks from the nearest marked offset before pos,
+// so it reads at most scanMarkEvery headers. Must be called with sh.mu
+// held.
+func (sh *gcFreeShard) isEntryStart(pos int) bool {
+	if pos == 0 || pos == sh.tail {
+		return true
+	}
+	if pos < 0 || pos > sh.tail {
+		return false
+	}
+	i, found := slices.BinarySearch(sh.marks, uint32(pos))
+	if found {
+		return true
+	}
+	p := int(sh.marks[i-1]) // marks[0] is 0 once there is an entry
+	for p < pos {
+		keyLen := binary.LittleEndian.Uint32(sh.data[p : p+4])
+		valueLen := binary.LittleEndian.Uint32(sh.data[p+4 : p+8])
+		p += entryHeaderSize + int(keyLen&^deletedFlag) + int(valueLen)
+	}
+	return p == pos
+}
+
+// markDeleted flags the entry at offset as dead and accounts its space.
+// Must be called with sh.mu held.
+func (sh *gcFreeShard) markDeleted(offset uint32) {
+	keyLen := binary.LittleEndian.Uint32(sh.data[offset : offset+4])
+	valueLen := binary.LittleEndian.Uint32(sh.data[offset+4 : offset+8])
+	binary.LittleEndian.PutUint32(sh.data[offset:offset+4], keyLen|deletedFlag)
+	sh.deleted += entryHeaderSize + int(keyLen) + int(valueLen)
 }
 
 // Delete removes an entry by key hash.
 // Returns true if deleted, false if not found.
 func (s *GCFreeStore) Delete(keyHash uint64) bool {
+	return s.DeleteFunc(keyHash, nil, nil)
+}
+
+// DeleteFunc removes the entry with the given key hash if match reports
+// true for it (a nil match always matches). If fn is non-nil it is called
+// with the removed entry while the shard lock is held; the slices are only
+// valid during the call. Returns true if an entry was removed.
+func (s *GCFreeStore) DeleteFunc(keyHash uint64, match func(key []byte, expireAt int64) bool, fn func(key, value []byte, expireAt, cost int64)) bool {
 	sh := s.getShard(keyHash)
 
 	sh.mu.Lock()
@@ -195,14 +287,19 @@ func (s *GCFreeStore) Delete(keyHash uint64) bool {
 		return false
 	}
 
-	// Mark as deleted and remove from hashmap
-	if int(offset)+entryHeaderSize <= len(sh.data) {
-		keyLen := binary.LittleEndian.Uint32(sh.data[offset : offset+4])
-		valueLen := binary.LittleEndian.Uint32(sh.data[offset+4 : offset+8])
-		binary.LittleEndian.PutUint32(sh.data[offset:offset+4], 0) // Zero key length marks as deleted
-		sh.deleted += entryHeaderSize + int(keyLen) + int(valueLen)
+	if match != nil || fn != nil {
+		key, value, expireAt, cost := sh.entryAt(offset)
+		if match != nil && !match(key, expireAt) {
+			sh.mu.Unlock()
+			return false
+		}
+		if fn != nil {
+			fn(key, value, expireAt, cost)
+		}
 	}
 
+	// Mark as deleted and remove from hashmap
+	sh.markDeleted(offset)
 	delete(sh.hashmap, keyHash)
 	sh.mu.Unlock()
 
@@ -210,9 +307,31 @@ func (s *GCFreeStore) Delete(keyHash uint64) bool {
 	return true
 }
 
+// Contains reports whether an entry with the given key hash is stored,
+// regardless of its key or expiration.
+func (s *GCFreeStore) Contains(keyHash uint64) bool {
+	sh := s.getShard(keyHash)
+	sh.mu.RLock()
+	_, exists := sh.hashmap[keyHash]
+	sh.mu.RUnlock()
+	return exists
+}
+
+// entryAt decodes the live entry at offset. Must be called with sh.mu held.
+func (sh *gcFreeShard) entryAt(offset uint32) (key, value []byte, expireAt, cost int64) {
+	data := sh.data[offset:]
+	keyLen := binary.LittleEndian.Uint32(data[0:4])
+	valueLen := binary.LittleEndian.Uint32(data[4:8])
+	expireAt = int64(binary.LittleEndian.Uint64(data[8:16]))
+	cost = int64(binary.LittleEndian.Uint64(data[16:24]))
+	key = data[entryHeaderSize : entryHeaderSize+keyLen]
+	value = data[entryHeaderSize+keyLen : entryHeaderSize+keyLen+valueLen]
+	return key, value, expireAt, cost
+}
+
 // Has checks if a key exists and is not expired.
 func (s *GCFreeStore) Has(keyHash uint64, key []byte) bool {
-	_, _, ok := s.Get(keyHash, key)
+	_, ok := s.GetFunc(keyHash, key, func([]byte) {})
 	return ok
 }
 
@@ -228,6 +347,7 @@ func (s *GCFreeStore) Clear() {
 		sh.hashmap = make(map[uint64]uint32)
 		sh.tail = 0
 		sh.deleted = 0
+		sh.nextEpoch()
 		sh.mu.Unlock()
 	}
 	s.size.Store(0)
@@ -253,7 +373,7 @@ func (sh *gcFreeShard) compact() {
 		}
 
 		keyLen := binary.LittleEndian.Uint32(oldData[offset : offset+4])
-		if keyLen == 0 {
+		if keyLen&deletedFlag != 0 {
 			// Deleted entry
 			continue
 		}
@@ -275,6 +395,25 @@ func (sh *gcFreeShard) compact() {
 	sh.hashmap = newHashmap
 	sh.tail = newTail
 	sh.deleted = 0
+	sh.nextEpoch()
+	for pos := 0; pos < newTail; {
+		sh.mark(uint32(pos))
+		keyLen := binary.LittleEndian.Uint32(newData[pos : pos+4])
+		valueLen := binary.LittleEndian.Uint32(newData[pos+4 : pos+8])
+		pos += entryHeaderSize + int(keyLen) + int(valueLen)
+	}
+}
+
+// nextEpoch invalidates the scan cursors into the shard after its
+// entries moved, and forgets the marked offsets. Must be called with
+// sh.mu held.
+func (sh *gcFreeShard) nextEpoch() {
+	sh.epoch++
+	if sh.epoch == 0 {
+		sh.epoch = 1
+	}
+	sh.entries = 0
+	sh.marks = sh.marks[:0]
 }
 
 // grow increases the buffer size.
@@ -301,7 +440,7 @@ func (s *GCFreeStore) Range(fn func(keyHash uint64, key, value []byte, expireAt,
 			}
 
 			keyLen := binary.LittleEndian.Uint32(sh.data[offset : offset+4])
-			if keyLen == 0 {
+			if keyLen&deletedFlag != 0 {
 				continue // Deleted
 			}
 
@@ -326,6 +465,77 @@ func (s *GCFreeStore) Range(fn func(keyHash uint64, key, value []byte, expireAt,
 	}
 }
 
+// ShardCount returns the number of shards.
+func (s *GCFreeStore) ShardCount() int {
+	return len(s.shards)
+}
+
+// Scan calls fn for up to count live, unexpired entries starting at cursor
+// and returns the cursor to resume from, or 0 when the scan is complete.
+// Entries are visited in storage order, so a scan that is not interrupted
+// by a compaction sees every entry that was present throughout at most
+// once. The slices passed to fn are only valid during the call.
+//
+// A cursor holds the shard index, the shard's epoch and the offset of the
+// next entry. A compaction or Clear changes the epoch. A cursor with
+// another epoch, or whose offset is not an entry start, restarts its
+// shard, so stale or made-up cursors may repeat entries but never read
+// across entry boundaries.
+func (s *GCFreeStore) Scan(cursor uint64, count int, fn func(key, value []byte, expireAt, cost int64)) uint64 {
+	if count <= 0 {
+		count = 10
+	}
+	now := clock.NowNano()
+
+	shardIdx := int(cursor >> 48)
+	epoch := uint16(cursor >> 32)
+	pos := int(cursor & 0xFFFFFFFF)
+	seen := 0
+
+	for ; shardIdx < len(s.shards); shardIdx++ {
+		sh := s.shards[shardIdx]
+
+		sh.mu.RLock()
+		if epoch != sh.epoch || !sh.isEntryStart(pos) {
+			pos = 0
+		}
+		for pos < sh.tail {
+			keyLen := binary.LittleEndian.Uint32(sh.data[pos : pos+4])
+			valueLen := binary.LittleEndian.Uint32(sh.data[pos+4 : pos+8])
+			entrySize := entryHeaderSize + int(keyLen&^deletedFlag) + int(valueLen)
+			offset := pos
+			pos += entrySize
+
+			if keyLen&deletedFlag != 0 {
+				continue
+			}
+			key, value, expireAt, cost := sh.entryAt(uint32(offset))
+			if expireAt > 0 && now > expireAt {
+				continue
+			}
+			fn(key, value, expireAt, cost)
+			seen++
+			if seen >= count {
+				epoch = sh.epoch
+				if pos >= sh.tail {
+					shardIdx++
+					pos = 0
+					epoch = 0
+				}
+				sh.mu.RUnlock()
+				if shardIdx >= len(s.shards) {
+					return 0
+				}
+				return uint64(shardIdx)<<48 | uint64(epoch)<<32 | uint64(pos)
+			}
+		}
+		sh.mu.RUnlock()
+		pos = 0
+		epoch = 0
+	}
+	return 0
+}
+
 // bytesEqual compares two byte slices for equality.
 func bytesEqual(a, b []byte) bool {
 	if len(a) != len(b) {
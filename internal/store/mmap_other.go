@@ -0,0 +1,25 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package store
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// mapFile reads the first size bytes of f into memory on platforms
+// without mmap support. Writes reach the file when it is unmapped.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unmapFile writes data back to f.
+func unmapFile(f *os.File, data []byte) error {
+	_, err := f.WriteAt(data, 0)
+	return err
+}
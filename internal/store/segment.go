@@ -0,0 +1,477 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/hash"
+)
+
+// Segment file layout: a header [4:magic "MCSG"][4:version][8:reserved]
+// followed by records in the GCFreeStore format,
+//
+//	[4:keyLen][4:valueLen][8:expireAt][8:cost][key][value]
+//
+// with deletedFlag set in the key length of dead records. The unused end
+// of a segment is zero, and a zero record header marks the end of the log.
+const (
+	segmentMagic      = "MCSG"
+	segmentVersion    = 1
+	segmentHeaderSize = 16
+	segmentExt        = ".seg"
+
+	// DefaultSegmentSize is the default size of a segment file.
+	DefaultSegmentSize = 64 << 20
+)
+
+var (
+	// ErrEntryTooLarge is returned by SegmentStore.Set for an entry that
+	// does not fit in a segment.
+	ErrEntryTooLarge = errors.New("store: entry larger than a segment")
+
+	// ErrSegmentCorrupt is returned by OpenSegmentStore for a segment file
+	// with an invalid header.
+	ErrSegmentCorrupt = errors.New("store: corrupt segment file")
+
+	// errSegmentFull is returned by appendLocked for a record that does
+	// not fit in the rest of the active segment.
+	errSegmentFull = errors.New("store: active segment full")
+)
+
+// segment is one memory-mapped segment file.
+type segment struct {
+	id   uint64
+	f    *os.File
+	data []byte
+	tail int // End of the last record
+	dead int // Bytes of dead records
+}
+
+// used returns the bytes of records in the segment.
+func (seg *segment) used() int {
+	return seg.tail - segmentHeaderSize
+}
+
+// segmentLoc is the location of a record.
+type segmentLoc struct {
+	seg *segment
+	off uint32
+}
+
+// SegmentStore is a disk-backed variant of GCFreeStore: records in the
+// same layout are appended to fixed-size segment files that are mapped
+// into memory, with an in-memory index from key hash to record. Space of
+// dead records is reclaimed by rewriting the live records of a segment
+// once half of it is dead. With a size limit, the oldest segment is
+// dropped, entries and all, when a new one would exceed it.
+//
+// Like GCFreeStore, entries are identified by the hash of their key: Get
+// never returns another key's value, but a write replaces an entry whose
+// key hashes alike. The index is rebuilt from the segment files on open,
+// so entries survive a restart.
+type SegmentStore struct {
+	mu       sync.RWMutex
+	dir      string
+	segSize  int
+	maxBytes int64
+	segments []*segment // Oldest first; the last one is active
+	index    map[uint64]segmentLoc
+	nextID   uint64
+}
+
+// OpenSegmentStore opens the segment files in dir, creating the directory
+// if needed. segSize is the size of new segment files (DefaultSegmentSize
+// if <= 0), and maxBytes limits their total size (0 = unlimited).
+func OpenSegmentStore(dir string, segSize int, maxBytes int64) (*SegmentStore, error) {
+	if segSize <= 0 {
+		segSize = DefaultSegmentSize
+	}
+	if segSize < segmentHeaderSize+entryHeaderSize {
+		return nil, fmt.Errorf("store: segment size %d too small", segSize)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &SegmentStore{
+		dir:      dir,
+		segSize:  segSize,
+		maxBytes: maxBytes,
+		index:    make(map[uint64]segmentLoc),
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(names) // Hex ids of equal width sort by age
+	for _, name := range names {
+		var id uint64
+		base := strings.TrimSuffix(filepath.Base(name), segmentExt)
+		if _, err := fmt.Sscanf(base, "%016x", &id); err != nil {
+			continue
+		}
+		seg, err := s.openSegment(name, id)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.segments = append(s.segments, seg)
+		s.replay(seg)
+		s.nextID = id + 1
+	}
+	if len(s.segments) == 0 {
+		if err := s.rotateLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// openSegment maps an existing segment file.
+func (s *SegmentStore) openSegment(name string, id uint64) (*segment, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() < segmentHeaderSize || fi.Size() > 1<<32 {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrSegmentCorrupt, name)
+	}
+	data, err := mapFile(f, int(fi.Size()))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if string(data[:4]) != segmentMagic || binary.LittleEndian.Uint32(data[4:8]) != segmentVersion {
+		unmapFile(f, data)
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrSegmentCorrupt, name)
+	}
+	return &segment{id: id, f: f, data: data, tail: segmentHeaderSize}, nil
+}
+
+// replay indexes the live records of seg, stopping at the end of the log
+// or at a record that runs past the end of the segment.
+func (s *SegmentStore) replay(seg *segment) {
+	pos := segmentHeaderSize
+	for pos+entryHeaderSize <= len(seg.data) {
+		data := seg.data[pos:]
+		keyLen := binary.LittleEndian.Uint32(data[0:4])
+		valueLen := binary.LittleEndian.Uint32(data[4:8])
+		if keyLen == 0 && valueLen == 0 && binary.LittleEndian.Uint64(data[8:16]) == 0 &&
+			binary.LittleEndian.Uint64(data[16:24]) == 0 {
+			break
+		}
+		size := entryHeaderSize + int(keyLen&^deletedFlag) + int(valueLen)
+		if pos+size > len(seg.data) {
+			break
+		}
+		if keyLen&deletedFlag != 0 {
+			seg.dead += size
+		} else {
+			keyHash := hash.Bytes(data[entryHeaderSize : entryHeaderSize+keyLen])
+			if old, ok := s.index[keyHash]; ok {
+				old.seg.markDeleted(old.off)
+			}
+			s.index[keyHash] = segmentLoc{seg: seg, off: uint32(pos)}
+		}
+		pos += size
+	}
+	seg.tail = pos
+}
+
+// Get returns a copy of the value stored under key with its expiration
+// and cost, or false if there is none or it has expired.
+func (s *SegmentStore) Get(key []byte) (value []byte, expireAt, cost int64, ok bool) {
+	keyHash := hash.Bytes(key)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	loc, exists := s.index[keyHash]
+	if !exists {
+		return nil, 0, 0, false
+	}
+	storedKey, v, expireAt, cost := loc.seg.entryAt(loc.off)
+	if !bytesEqual(storedKey, key) {
+		return nil, 0, 0, false
+	}
+	if expireAt > 0 && clock.NowNano() > expireAt {
+		return nil, 0, 0, false
+	}
+	return append([]byte(nil), v...), expireAt, cost, true
+}
+
+// Set stores key and value, replacing any entry with the same key hash.
+func (s *SegmentStore) Set(key, value []byte, expireAt, cost int64) error {
+	size := entryHeaderSize + len(key) + len(value)
+	if size > s.segSize-segmentHeaderSize {
+		return ErrEntryTooLarge
+	}
+	keyHash := hash.Bytes(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.index[keyHash]; ok {
+		old.seg.markDeleted(old.off)
+		delete(s.index, keyHash)
+	}
+	if !s.active().fits(size) {
+		if err := s.rotateLocked(); err != nil {
+			return err
+		}
+		if err := s.compactLocked(); err != nil {
+			return err
+		}
+		// Compaction may have filled the new segment.
+		if !s.active().fits(size) {
+			if err := s.rotateLocked(); err != nil {
+				return err
+			}
+		}
+		s.enforceLimitLocked()
+	}
+	return s.appendLocked(keyHash, key, value, expireAt, cost)
+}
+
+// Delete removes the entry stored under key, reporting whether there was
+// one.
+func (s *SegmentStore) Delete(key []byte) bool {
+	keyHash := hash.Bytes(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loc, ok := s.index[keyHash]
+	if !ok {
+		return false
+	}
+	storedKey, _, _, _ := loc.seg.entryAt(loc.off)
+	if !bytesEqual(storedKey, key) {
+		return false
+	}
+	loc.seg.markDeleted(loc.off)
+	delete(s.index, keyHash)
+	return true
+}
+
+// Len returns the number of entries, including expired ones not yet
+// overwritten or deleted.
+func (s *SegmentStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.index)
+}
+
+// Size returns the total size of the segment files.
+func (s *SegmentStore) Size() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var total int64
+	for _, seg := range s.segments {
+		total += int64(len(seg.data))
+	}
+	return total
+}
+
+// Clear removes all entries and their segment files.
+func (s *SegmentStore) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.segments) > 0 {
+		if err := s.dropLocked(); err != nil {
+			return err
+		}
+	}
+	clear(s.index)
+	return s.rotateLocked()
+}
+
+// Close unmaps and closes the segment files. The store must not be used
+// afterwards.
+func (s *SegmentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first error
+	for _, seg := range s.segments {
+		if err := seg.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	s.segments = nil
+	return first
+}
+
+// active returns the segment written to. Must be called with s.mu held.
+func (s *SegmentStore) active() *segment {
+	return s.segments[len(s.segments)-1]
+}
+
+// appendLocked writes a record to the active segment and indexes it. It
+// returns errSegmentFull, writing nothing, if the record does not fit.
+// Must be called with s.mu held.
+func (s *SegmentStore) appendLocked(keyHash uint64, key, value []byte, expireAt, cost int64) error {
+	seg := s.active()
+	if !seg.fits(entryHeaderSize + len(key) + len(value)) {
+		return errSegmentFull
+	}
+	pos := seg.tail
+	data := seg.data[pos:]
+	copy(data[entryHeaderSize:], key)
+	copy(data[entryHeaderSize+len(key):], value)
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(value)))
+	binary.LittleEndian.PutUint64(data[8:16], uint64(expireAt))
+	binary.LittleEndian.PutUint64(data[16:24], uint64(cost))
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(key)))
+
+	seg.tail += entryHeaderSize + len(key) + len(value)
+	s.index[keyHash] = segmentLoc{seg: seg, off: uint32(pos)}
+	return nil
+}
+
+// rotateLocked creates a new, empty active segment. Must be called with
+// s.mu held.
+func (s *SegmentStore) rotateLocked() error {
+	id := s.nextID
+	name := filepath.Join(s.dir, fmt.Sprintf("%016x%s", id, segmentExt))
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(int64(s.segSize)); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	data, err := mapFile(f, s.segSize)
+	if err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	copy(data[:4], segmentMagic)
+	binary.LittleEndian.PutUint32(data[4:8], segmentVersion)
+
+	s.nextID++
+	s.segments = append(s.segments, &segment{id: id, f: f, data: data, tail: segmentHeaderSize})
+	return nil
+}
+
+// compactLocked rewrites the live records of every sealed segment that is
+// at least half dead into the active segment and removes it. Must be
+// called with s.mu held.
+func (s *SegmentStore) compactLocked() error {
+	for i := 0; i < len(s.segments)-1; i++ {
+		seg := s.segments[i]
+		if seg.dead*2 < seg.used() {
+			continue
+		}
+		for pos := segmentHeaderSize; pos < seg.tail; {
+			key, value, expireAt, cost := seg.entryAt(uint32(pos))
+			size := entryHeaderSize + len(key) + len(value)
+			keyLen := binary.LittleEndian.Uint32(seg.data[pos : pos+4])
+			if keyLen&deletedFlag == 0 && (expireAt == 0 || clock.NowNano() <= expireAt) {
+				if !s.active().fits(size) {
+					if err := s.rotateLocked(); err != nil {
+						return err
+					}
+				}
+				if err := s.appendLocked(hash.Bytes(key), key, value, expireAt, cost); err != nil {
+					return err
+				}
+			} else if keyLen&deletedFlag == 0 {
+				delete(s.index, hash.Bytes(key))
+			}
+			pos += size
+		}
+		if err := seg.remove(); err != nil {
+			return err
+		}
+		s.segments = slices.Delete(s.segments, i, i+1)
+		i--
+	}
+	return nil
+}
+
+// enforceLimitLocked drops the oldest segments while the files exceed
+// maxBytes, keeping the active one. Must be called with s.mu held.
+func (s *SegmentStore) enforceLimitLocked() {
+	if s.maxBytes <= 0 {
+		return
+	}
+	for len(s.segments) > 1 && int64(len(s.segments)*s.segSize) > s.maxBytes {
+		if err := s.dropLocked(); err != nil {
+			return
+		}
+	}
+}
+
+// dropLocked removes the oldest segment and its entries. Must be called
+// with s.mu held.
+func (s *SegmentStore) dropLocked() error {
+	seg := s.segments[0]
+	for pos := segmentHeaderSize; pos < seg.tail; {
+		key, value, _, _ := seg.entryAt(uint32(pos))
+		keyLen := binary.LittleEndian.Uint32(seg.data[pos : pos+4])
+		if keyLen&deletedFlag == 0 {
+			delete(s.index, hash.Bytes(key))
+		}
+		pos += entryHeaderSize + len(key) + len(value)
+	}
+	s.segments = s.segments[1:]
+	return seg.remove()
+}
+
+// fits reports whether a record of size bytes fits after the tail.
+func (seg *segment) fits(size int) bool {
+	return seg.tail+size <= len(seg.data)
+}
+
+// entryAt decodes the record at offset, live or dead.
+func (seg *segment) entryAt(offset uint32) (key, value []byte, expireAt, cost int64) {
+	data := seg.data[offset:]
+	keyLen := binary.LittleEndian.Uint32(data[0:4]) &^ deletedFlag
+	valueLen := binary.LittleEndian.Uint32(data[4:8])
+	expireAt = int64(binary.LittleEndian.Uint64(data[8:16]))
+	cost = int64(binary.LittleEndian.Uint64(data[16:24]))
+	key = data[entryHeaderSize : entryHeaderSize+keyLen]
+	value = data[entryHeaderSize+keyLen : entryHeaderSize+keyLen+valueLen]
+	return key, value, expireAt, cost
+}
+
+// markDeleted flags the record at offset as dead and accounts its space.
+func (seg *segment) markDeleted(offset uint32) {
+	keyLen := binary.LittleEndian.Uint32(seg.data[offset : offset+4])
+	valueLen := binary.LittleEndian.Uint32(seg.data[offset+4 : offset+8])
+	binary.LittleEndian.PutUint32(seg.data[offset:offset+4], keyLen|deletedFlag)
+	seg.dead += entryHeaderSize + int(keyLen&^deletedFlag) + int(valueLen)
+}
+
+// close unmaps and closes the segment file.
+func (seg *segment) close() error {
+	err := unmapFile(seg.f, seg.data)
+	if cerr := seg.f.Close(); err == nil {
+		err = cerr
+	}
+	seg.data = nil
+	return err
+}
+
+// remove closes and deletes the segment file.
+func (seg *segment) remove() error {
+	name := seg.f.Name()
+	if err := seg.close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
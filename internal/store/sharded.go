@@ -26,11 +26,35 @@ const (
 
 // Entry represents a cache entry.
 type Entry[K comparable, V any] struct {
-	Key      K
-	Value    V
-	KeyHash  uint64
-	ExpireAt int64 // Unix nanoseconds, 0 = no expiration
-	Cost     int64
+	Key       K
+	Value     V
+	KeyHash   uint64
+	ExpireAt  int64 // Unix nanoseconds, 0 = no expiration
+	WrittenAt int64 // Unix nanoseconds of the write that produced this entry
+	Cost      int64
+	Version   uint64    // Unique per write; assigned by the store
+	Links     *Links[K] // Optional tags and dependencies, nil for most entries
+
+	refreshing uint32 // Refresh-ahead in-flight marker, accessed atomically
+}
+
+// Links holds the tags, dependencies and namespace of an entry. It is kept
+// out of line so entries without any pay for one pointer only, and is never
+// modified once the entry is stored.
+type Links[K comparable] struct {
+	Tags      []string
+	Deps      []K
+	Namespace string
+}
+
+// HasTag reports whether the entry carrying l is tagged with tag.
+func (l *Links[K]) HasTag(tag string) bool {
+	return l != nil && slices.Contains(l.Tags, tag)
+}
+
+// DependsOn reports whether the entry carrying l depends on key.
+func (l *Links[K]) DependsOn(key K) bool {
+	return l != nil && slices.Contains(l.Deps, key)
 }
 
 // IsExpired returns true if the entry has expired.
@@ -38,6 +62,17 @@ func (e *Entry[K, V]) IsExpired() bool {
 	return e.ExpireAt > 0 && clock.NowNano() > e.ExpireAt
 }
 
+// TryStartRefresh marks the entry as being refreshed.
+// Returns false if a refresh is already in flight for this entry.
+func (e *Entry[K, V]) TryStartRefresh() bool {
+	return atomic.CompareAndSwapUint32(&e.refreshing, 0, 1)
+}
+
+// EndRefresh clears the refresh marker so a later access may retry.
+func (e *Entry[K, V]) EndRefresh() {
+	atomic.StoreUint32(&e.refreshing, 0)
+}
+
 // shard represents a single shard of the sharded store.
 // Optimized with cache line padding to prevent false sharing between shards.
 type shard[K comparable, V any] struct {
@@ -53,6 +88,7 @@ type ShardedStore[K comparable, V any] struct {
 	shardMask uint64
 	size      atomic.Int64
 	hasher    func(K) uint64
+	versions  atomic.Uint64 // Last assigned entry version
 }
 
 // NewShardedStore creates a new sharded store.
@@ -196,7 +232,7 @@ func (s *ShardedStore[K, V]) PeekByHash(key K, keyHash uint64) (*Entry[K, V], bo
 	return entry, exists
 }
 
-// Set stores an entry.
+// Set stores an entry and assigns it a new version.
 // Returns the previous entry if it existed, nil otherwise.
 func (s *ShardedStore[K, V]) Set(entry *Entry[K, V]) *Entry[K, V] {
 	if entry.KeyHash == 0 {
@@ -206,6 +242,7 @@ func (s *ShardedStore[K, V]) Set(entry *Entry[K, V]) *Entry[K, V] {
 	sh := s.getShard(entry.KeyHash)
 
 	sh.mu.Lock()
+	entry.Version = s.versions.Add(1)
 	prev, existed := sh.m[entry.Key]
 	sh.m[entry.Key] = entry
 	sh.mu.Unlock()
@@ -255,43 +292,199 @@ func (s *ShardedStore[K, V]) DeleteByHash(key K, keyHash uint64) *Entry[K, V] {
 	return entry
 }
 
+// InsertIfAbsent stores entry only if its key is missing or expired, and
+// assigns it a new version. If a live entry exists it is returned with
+// inserted=false; otherwise prev is the expired entry that was replaced, if
+// any.
+func (s *ShardedStore[K, V]) InsertIfAbsent(entry *Entry[K, V]) (prev *Entry[K, V], inserted bool) {
+	if entry.KeyHash == 0 {
+		entry.KeyHash = s.getKeyHash(entry.Key)
+	}
+
+	sh := s.getShard(entry.KeyHash)
+
+	sh.mu.Lock()
+	prev, existed := sh.m[entry.Key]
+	if existed && !prev.IsExpired() {
+		sh.mu.Unlock()
+		return prev, false
+	}
+	entry.Version = s.versions.Add(1)
+	sh.m[entry.Key] = entry
+	sh.mu.Unlock()
+
+	if !existed {
+		s.size.Add(1)
+	}
+
+	return prev, true
+}
+
+// DeleteIfByHash removes an unexpired entry if match, called under the
+// shard lock, returns true for it. found reports whether a live entry
+// existed.
+func (s *ShardedStore[K, V]) DeleteIfByHash(
+	key K,
+	keyHash uint64,
+	match func(current *Entry[K, V]) bool,
+) (deleted *Entry[K, V], found bool) {
+	sh := s.getShard(keyHash)
+
+	sh.mu.Lock()
+	entry, exists := sh.m[key]
+	if !exists || entry.IsExpired() {
+		sh.mu.Unlock()
+		return nil, false
+	}
+	if !match(entry) {
+		sh.mu.Unlock()
+		return nil, true
+	}
+	delete(sh.m, key)
+	sh.mu.Unlock()
+
+	s.size.Add(-1)
+	return entry, true
+}
+
+// Compute calls fn under the shard write lock with the live entry for key,
+// or nil if the key is missing or expired, and applies its result: a
+// non-nil next is stored in place of the current entry and assigned a new
+// version, del removes the current entry, and (nil, false) leaves the shard
+// unchanged. prev is the entry that was replaced or removed, which may be
+// expired, and is nil if there was none.
+func (s *ShardedStore[K, V]) Compute(
+	key K,
+	keyHash uint64,
+	fn func(current *Entry[K, V]) (next *Entry[K, V], del bool),
+) (prev *Entry[K, V], changed bool) {
+	sh := s.getShard(keyHash)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock() // fn may panic
+
+	entry, exists := sh.m[key]
+	current := entry
+	if exists && entry.IsExpired() {
+		current = nil
+	}
+	next, del := fn(current)
+
+	switch {
+	case next != nil:
+		next.Version = s.versions.Add(1)
+		sh.m[key] = next
+		if !exists {
+			s.size.Add(1)
+		}
+		return entry, true
+	case del && current != nil:
+		delete(sh.m, key)
+		s.size.Add(-1)
+		return entry, true
+	}
+	return nil, false
+}
+
 // UpdateExistingByHash replaces an existing entry with a fresh snapshot.
 // Stored entries are treated as immutable after publication so readers can
 // safely access them after releasing the shard read lock.
-// Returns the previous entry snapshot only when capturePrevious is true.
+// Returns the previous entry snapshot only when capturePrevious is true or
+// the previous entry has Links.
 func (s *ShardedStore[K, V]) UpdateExistingByHash(
 	key K,
 	keyHash uint64,
 	value V,
 	cost int64,
 	expireAt int64,
+	links *Links[K],
 	capturePrevious bool,
 ) (prev *Entry[K, V], updated bool, costDelta int64, oldExpireAt int64) {
+	prev, _, updated, costDelta, oldExpireAt = s.UpdateIfByHash(key, keyHash, nil, value, cost, expireAt, links, capturePrevious)
+	return prev, updated, costDelta, oldExpireAt
+}
+
+// UpdateIfByHash is UpdateExistingByHash with a precondition: the entry is
+// only replaced if match, called under the shard lock, returns true (a nil
+// match always matches). found reports whether the key existed; with a
+// match, an expired entry counts as missing.
+func (s *ShardedStore[K, V]) UpdateIfByHash(
+	key K,
+	keyHash uint64,
+	match func(current *Entry[K, V]) bool,
+	value V,
+	cost int64,
+	expireAt int64,
+	links *Links[K],
+	capturePrevious bool,
+) (prev *Entry[K, V], found, updated bool, costDelta int64, oldExpireAt int64) {
 	sh := s.getShard(keyHash)
 
 	sh.mu.Lock()
 	defer sh.mu.Unlock()
 
 	entry, exists := sh.m[key]
-	if !exists {
-		return nil, false, 0, 0
-	}
-
-	if capturePrevious {
-		snapshot := *entry
-		prev = &snapshot
+	if !exists || (match != nil && entry.IsExpired()) {
+		return nil, false, false, 0, 0
+	}
+	if match != nil && !match(entry) {
+		return nil, true, false, 0, 0
+	}
+
+	if capturePrevious || entry.Links != nil {
+		// Copy field by field: the refresh marker may be updated concurrently.
+		prev = &Entry[K, V]{
+			Key:       entry.Key,
+			Value:     entry.Value,
+			KeyHash:   entry.KeyHash,
+			ExpireAt:  entry.ExpireAt,
+			WrittenAt: entry.WrittenAt,
+			Cost:      entry.Cost,
+			Version:   entry.Version,
+			Links:     entry.Links,
+		}
 	}
 
 	oldExpireAt = entry.ExpireAt
 	costDelta = cost - entry.Cost
 	sh.m[key] = &Entry[K, V]{
-		Key:      entry.Key,
-		Value:    value,
-		KeyHash:  entry.KeyHash,
-		ExpireAt: expireAt,
-		Cost:     cost,
+		Key:       entry.Key,
+		Value:     value,
+		KeyHash:   entry.KeyHash,
+		ExpireAt:  expireAt,
+		WrittenAt: clock.NowNano(),
+		Cost:      cost,
+		Version:   s.versions.Add(1),
+		Links:     links,
+	}
+	return prev, true, true, costDelta, oldExpireAt
+}
+
+// UpdateExpireByHash replaces an existing, unexpired entry with a copy
+// that expires at expireAt, keeping its version. Returns the new entry, or
+// nil if the key is missing or expired.
+func (s *ShardedStore[K, V]) UpdateExpireByHash(key K, keyHash uint64, expireAt int64) *Entry[K, V] {
+	sh := s.getShard(keyHash)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	entry, exists := sh.m[key]
+	if !exists || entry.IsExpired() {
+		return nil
+	}
+	updated := &Entry[K, V]{
+		Key:       entry.Key,
+		Value:     entry.Value,
+		KeyHash:   entry.KeyHash,
+		ExpireAt:  expireAt,
+		WrittenAt: entry.WrittenAt,
+		Cost:      entry.Cost,
+		Version:   entry.Version,
+		Links:     entry.Links,
 	}
-	return prev, true, costDelta, oldExpireAt
+	sh.m[key] = updated
+	return updated
 }
 
 // Has checks if a key exists and is not expired.
@@ -433,6 +626,12 @@ func (s *ShardedStore[K, V]) KeyHash(key K) uint64 {
 	return s.getKeyHash(key)
 }
 
+// LastVersion returns the version assigned to the latest write. Entries
+// written later have a greater version.
+func (s *ShardedStore[K, V]) LastVersion() uint64 {
+	return s.versions.Load()
+}
+
 // CollectExpired atomically removes all expired entries from each shard.
 // Returns the removed entries for post-processing (policy/heap/radix cleanup).
 // Uses a single write lock per shard for the entire sweep.
@@ -0,0 +1,201 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/hash"
+)
+
+// Formats lists the names accepted by NewSource.
+var Formats = []string{"mcache", "arc", "lirs", "twitter"}
+
+// NewSource returns a Source for the named format:
+//
+//	mcache   native format written by Writer
+//	arc      ARC traces: "start count ignored request" per line, a read of
+//	         count consecutive blocks
+//	lirs     LIRS traces: one block number per line
+//	twitter  Twitter cache traces: CSV "time,key,key size,value size,
+//	         client,op,ttl"
+func NewSource(format string, r io.Reader) (Source, error) {
+	switch format {
+	case "mcache":
+		return NewReader(r), nil
+	case "arc":
+		return NewARCReader(r), nil
+	case "lirs":
+		return NewLIRSReader(r), nil
+	case "twitter":
+		return NewTwitterReader(r), nil
+	default:
+		return nil, fmt.Errorf("trace: unknown format %q", format)
+	}
+}
+
+// lineReader reads non-empty, non-comment lines.
+type lineReader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func newLineReader(r io.Reader) lineReader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	return lineReader{sc: sc}
+}
+
+func (l *lineReader) next() (string, error) {
+	for l.sc.Scan() {
+		l.line++
+		line := strings.TrimSpace(l.sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		return line, nil
+	}
+	if err := l.sc.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+func (l *lineReader) errorf(format string, args ...any) error {
+	return fmt.Errorf("trace: line %d: %s: %w", l.line, fmt.Sprintf(format, args...), ErrFormat)
+}
+
+// ARCReader reads block traces in the format used by the ARC paper.
+type ARCReader struct {
+	lines     lineReader
+	block     uint64
+	remaining uint64
+}
+
+// NewARCReader returns a Source for ARC-format traces.
+func NewARCReader(r io.Reader) *ARCReader {
+	return &ARCReader{lines: newLineReader(r)}
+}
+
+// Next returns a read of the next block.
+func (a *ARCReader) Next() (Event, error) {
+	for a.remaining == 0 {
+		line, err := a.lines.next()
+		if err != nil {
+			return Event{}, err
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return Event{}, a.lines.errorf("expected start and count")
+		}
+		start, err1 := strconv.ParseUint(fields[0], 10, 64)
+		count, err2 := strconv.ParseUint(fields[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return Event{}, a.lines.errorf("invalid block range %q", line)
+		}
+		a.block, a.remaining = start, count
+	}
+	e := Event{Op: OpGet, KeyHash: hash.Uint64(a.block)}
+	a.block++
+	a.remaining--
+	return e, nil
+}
+
+// LIRSReader reads block traces in the format used by the LIRS paper.
+// Lines that are not block numbers, such as "*" separators, are skipped.
+type LIRSReader struct {
+	lines lineReader
+}
+
+// NewLIRSReader returns a Source for LIRS-format traces.
+func NewLIRSReader(r io.Reader) *LIRSReader {
+	return &LIRSReader{lines: newLineReader(r)}
+}
+
+// Next returns a read of the next block.
+func (l *LIRSReader) Next() (Event, error) {
+	for {
+		line, err := l.lines.next()
+		if err != nil {
+			return Event{}, err
+		}
+		block, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			continue
+		}
+		return Event{Op: OpGet, KeyHash: hash.Uint64(block)}, nil
+	}
+}
+
+// TwitterReader reads the Twitter production cache traces
+// (github.com/twitter/cache-trace).
+type TwitterReader struct {
+	lines lineReader
+}
+
+// NewTwitterReader returns a Source for Twitter-format traces.
+func NewTwitterReader(r io.Reader) *TwitterReader {
+	return &TwitterReader{lines: newLineReader(r)}
+}
+
+// Next returns the next request. Reads map to OpGet, writes and
+// read-modify-writes to OpSet with the key and value size as cost, and
+// deletes to OpDelete.
+func (t *TwitterReader) Next() (Event, error) {
+	line, err := t.lines.next()
+	if err != nil {
+		return Event{}, err
+	}
+	fields := strings.Split(line, ",")
+	if len(fields) < 6 {
+		return Event{}, t.lines.errorf("expected at least 6 fields, got %d", len(fields))
+	}
+	ts, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return Event{}, t.lines.errorf("invalid timestamp %q", fields[0])
+	}
+	keySize, _ := strconv.ParseInt(fields[2], 10, 64)
+	valueSize, _ := strconv.ParseInt(fields[3], 10, 64)
+
+	e := Event{KeyHash: hash.String(fields[1]), Time: ts * 1e9}
+	switch fields[5] {
+	case "get", "gets":
+		e.Op = OpGet
+	case "set", "add", "replace", "cas", "append", "prepend", "incr", "decr":
+		e.Op = OpSet
+		e.Cost = keySize + valueSize
+	case "delete":
+		e.Op = OpDelete
+	default:
+		return Event{}, t.lines.errorf("unknown operation %q", fields[5])
+	}
+	return e, nil
+}
+
+// Zipf is a synthetic Source of n reads drawn from a Zipf distribution
+// with exponent s over universe keys.
+type Zipf struct {
+	zipf      *rand.Zipf
+	remaining int
+}
+
+// NewZipf returns a deterministic Zipf Source. s must be greater than 1.
+func NewZipf(seed int64, s float64, universe uint64, n int) *Zipf {
+	rng := rand.New(rand.NewSource(seed))
+	return &Zipf{
+		zipf:      rand.NewZipf(rng, s, 1, universe-1),
+		remaining: n,
+	}
+}
+
+// Next returns the next read.
+func (z *Zipf) Next() (Event, error) {
+	if z.remaining <= 0 {
+		return Event{}, io.EOF
+	}
+	z.remaining--
+	return Event{Op: OpGet, KeyHash: hash.Uint64(z.zipf.Uint64())}, nil
+}
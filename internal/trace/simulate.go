@@ -0,0 +1,152 @@
+package trace
+
+import (
+	"io"
+	"slices"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/policy"
+)
+
+// PolicyFactory creates a policy with the given number of sketch counters.
+type PolicyFactory func(numCounters int64) policy.Policer[uint64]
+
+// Policies lists the policies available to Simulate by name.
+var Policies = map[string]PolicyFactory{
+	"tinylfu":  func(n int64) policy.Policer[uint64] { return policy.NewPolicyLockFree[uint64](n, 0, 0) },
+	"wtinylfu": func(n int64) policy.Policer[uint64] { return policy.NewWTinyLFU[uint64](n) },
+	"wtinylfu-adaptive": func(n int64) policy.Policer[uint64] {
+		return policy.NewAdaptiveWTinyLFU[uint64](n)
+	},
+	"lru":    func(int64) policy.Policer[uint64] { return policy.NewLRU[uint64]() },
+	"lfu":    func(int64) policy.Policer[uint64] { return policy.NewLFU[uint64]() },
+	"arc":    func(int64) policy.Policer[uint64] { return policy.NewARC[uint64]() },
+	"s3fifo": func(int64) policy.Policer[uint64] { return policy.NewS3FIFO[uint64]() },
+}
+
+// PolicyNames returns the names in Policies in sorted order.
+func PolicyNames() []string {
+	names := make([]string, 0, len(Policies))
+	for name := range Policies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ReadAll reads every event from src.
+func ReadAll(src Source) ([]Event, error) {
+	var events []Event
+	for {
+		e, err := src.Next()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// Stats summarizes a trace.
+type Stats struct {
+	Events     int
+	Gets       int
+	UniqueKeys int
+	AvgCost    float64 // Mean cost of sets, 1 if the trace has none
+}
+
+// Summarize computes trace statistics.
+func Summarize(events []Event) Stats {
+	keys := make(map[uint64]struct{})
+	var s Stats
+	var sets, cost int64
+	for _, e := range events {
+		keys[e.KeyHash] = struct{}{}
+		switch e.Op {
+		case OpGet:
+			s.Gets++
+		case OpSet:
+			sets++
+			cost += e.Cost
+		}
+	}
+	s.Events = len(events)
+	s.UniqueKeys = len(keys)
+	s.AvgCost = 1
+	if sets > 0 && cost > 0 {
+		s.AvgCost = float64(cost) / float64(sets)
+	}
+	return s
+}
+
+// Result is the outcome of replaying a trace against one policy.
+type Result struct {
+	Gets int64
+	Hits int64
+}
+
+// HitRatio returns hits / gets.
+func (r Result) HitRatio() float64 {
+	if r.Gets == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Gets)
+}
+
+// Simulate replays events against p with the given capacity, measured in
+// cost if byCost is set and in entries otherwise.
+//
+// A get is a hit if p tracks the key; a miss is filled as a cache-aside
+// caller would, with the cost of the key's last set (1 if unknown).
+// Adaptive policies are fed the hit and miss signals.
+func Simulate(events []Event, p policy.Policer[uint64], capacity int64, byCost bool) Result {
+	if byCost {
+		p.SetMaxCost(capacity)
+	} else {
+		p.SetMaxEntries(capacity)
+	}
+	observer, _ := p.(interface {
+		RecordHit()
+		RecordMiss()
+	})
+
+	costs := make(map[uint64]int64)
+	costOf := func(keyHash uint64) int64 {
+		if c, ok := costs[keyHash]; ok {
+			return c
+		}
+		return 1
+	}
+
+	var r Result
+	for _, e := range events {
+		switch e.Op {
+		case OpGet:
+			r.Gets++
+			if p.Has(e.KeyHash) {
+				r.Hits++
+				p.Access(e.KeyHash)
+				if observer != nil {
+					observer.RecordHit()
+				}
+				continue
+			}
+			if observer != nil {
+				observer.RecordMiss()
+			}
+			p.Add(e.KeyHash, e.KeyHash, costOf(e.KeyHash))
+		case OpSet:
+			cost := max(e.Cost, 1)
+			costs[e.KeyHash] = cost
+			if p.Has(e.KeyHash) {
+				p.Update(e.KeyHash, e.KeyHash, cost)
+			} else {
+				p.Add(e.KeyHash, e.KeyHash, cost)
+			}
+		case OpDelete:
+			p.Del(e.KeyHash, e.KeyHash)
+		}
+	}
+	return r
+}
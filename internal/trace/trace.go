@@ -0,0 +1,178 @@
+// Package trace records and reads cache access traces for offline
+// hit-ratio simulation.
+//
+// The native format starts with the 8-byte magic "MCTRACE\x01" followed by
+// records of the form [1:op][8:keyHash][uvarint:cost][uvarint:delta], where
+// delta is the number of nanoseconds since the previous record (or since
+// the Unix epoch for the first one). Keys are never stored, only their hash.
+package trace
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Op is the kind of access recorded in an event.
+type Op uint8
+
+const (
+	OpGet    Op = iota + 1 // Read
+	OpSet                  // Insert or update
+	OpDelete               // Explicit removal
+)
+
+// String returns the lower-case name of the op.
+func (o Op) String() string {
+	switch o {
+	case OpGet:
+		return "get"
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	default:
+		return fmt.Sprintf("op(%d)", uint8(o))
+	}
+}
+
+// Event is a single recorded access.
+type Event struct {
+	Op      Op
+	KeyHash uint64
+	Cost    int64 // Entry cost for OpSet, 0 otherwise
+	Time    int64 // Unix nanoseconds, 0 if the source has no timestamps
+}
+
+// Source yields events in order. Next returns io.EOF after the last event.
+type Source interface {
+	Next() (Event, error)
+}
+
+var magic = [8]byte{'M', 'C', 'T', 'R', 'A', 'C', 'E', 1}
+
+// maxRecordSize is the largest encoded record.
+const maxRecordSize = 1 + 8 + 2*binary.MaxVarintLen64
+
+// ErrFormat is returned when the input is not a valid trace.
+var ErrFormat = errors.New("trace: invalid format")
+
+// Writer encodes events in the native format. It is safe for concurrent
+// use; records are buffered until Flush. The first write error is sticky.
+type Writer struct {
+	mu       sync.Mutex
+	bw       *bufio.Writer
+	started  bool
+	lastTime int64
+	err      error
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bw: bufio.NewWriterSize(w, 64<<10)}
+}
+
+// Write appends an event.
+func (w *Writer) Write(e Event) error {
+	var buf [maxRecordSize]byte
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if !w.started {
+		w.started = true
+		if _, w.err = w.bw.Write(magic[:]); w.err != nil {
+			return w.err
+		}
+	}
+
+	delta := max(e.Time-w.lastTime, 0)
+	w.lastTime += delta
+
+	buf[0] = byte(e.Op)
+	binary.LittleEndian.PutUint64(buf[1:], e.KeyHash)
+	n := 9
+	n += binary.PutUvarint(buf[n:], uint64(max(e.Cost, 0)))
+	n += binary.PutUvarint(buf[n:], uint64(delta))
+	_, w.err = w.bw.Write(buf[:n])
+	return w.err
+}
+
+// Flush writes buffered records to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.bw.Flush()
+	return w.err
+}
+
+// Err returns the first write error, if any.
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Reader decodes events written by Writer.
+type Reader struct {
+	br       *bufio.Reader
+	started  bool
+	lastTime int64
+}
+
+// NewReader returns a Reader that reads the native format from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// Next returns the next event. An empty input is a valid empty trace.
+func (r *Reader) Next() (Event, error) {
+	if !r.started {
+		r.started = true
+		var m [8]byte
+		if _, err := io.ReadFull(r.br, m[:]); err != nil {
+			if err == io.EOF {
+				return Event{}, io.EOF
+			}
+			return Event{}, ErrFormat
+		}
+		if m != magic {
+			return Event{}, ErrFormat
+		}
+	}
+
+	op, err := r.br.ReadByte()
+	if err != nil {
+		return Event{}, err
+	}
+	var h [8]byte
+	if _, err := io.ReadFull(r.br, h[:]); err != nil {
+		return Event{}, ErrFormat
+	}
+	cost, err := binary.ReadUvarint(r.br)
+	if err != nil {
+		return Event{}, ErrFormat
+	}
+	delta, err := binary.ReadUvarint(r.br)
+	if err != nil {
+		return Event{}, ErrFormat
+	}
+	if Op(op) < OpGet || Op(op) > OpDelete {
+		return Event{}, ErrFormat
+	}
+
+	r.lastTime += int64(delta)
+	return Event{
+		Op:      Op(op),
+		KeyHash: binary.LittleEndian.Uint64(h[:]),
+		Cost:    int64(cost),
+		Time:    r.lastTime,
+	}, nil
+}
@@ -0,0 +1,170 @@
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/hash"
+	"github.com/OrlovEvgeny/go-mcache/internal/policy"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	events := []Event{
+		{Op: OpSet, KeyHash: 1, Cost: 100, Time: 1_700_000_000_000_000_000},
+		{Op: OpGet, KeyHash: 1, Time: 1_700_000_000_000_000_500},
+		{Op: OpGet, KeyHash: 2, Time: 1_700_000_000_000_000_400}, // Clock went back
+		{Op: OpDelete, KeyHash: 1 << 63, Time: 1_700_000_001_000_000_000},
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("Expected %d events, got %d", len(events), len(got))
+	}
+	events[2].Time = events[1].Time // Timestamps never go backwards
+	for i := range events {
+		if got[i] != events[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, events[i], got[i])
+		}
+	}
+}
+
+func TestReaderRejectsInvalidInput(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("not a trace")).Next(); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat for bad magic, got %v", err)
+	}
+	if _, err := NewReader(strings.NewReader("")).Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty input, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Write(Event{Op: OpGet, KeyHash: 1})
+	w.Flush()
+	truncated := buf.Bytes()[:buf.Len()-3]
+	if _, err := NewReader(bytes.NewReader(truncated)).Next(); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat for a truncated record, got %v", err)
+	}
+}
+
+func TestARCReader(t *testing.T) {
+	events, err := ReadAll(NewARCReader(strings.NewReader("10 3 0 1\n\n100 1 0 2\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint64{10, 11, 12, 100}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(events))
+	}
+	for i, block := range want {
+		if events[i].Op != OpGet || events[i].KeyHash != hash.Uint64(block) {
+			t.Errorf("Event %d: expected get of block %d, got %+v", i, block, events[i])
+		}
+	}
+
+	if _, err := ReadAll(NewARCReader(strings.NewReader("x y\n"))); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat, got %v", err)
+	}
+}
+
+func TestLIRSReader(t *testing.T) {
+	events, err := ReadAll(NewLIRSReader(strings.NewReader("5\n*\n7\n5\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].KeyHash != events[2].KeyHash || events[1].KeyHash != hash.Uint64(7) {
+		t.Errorf("Unexpected events %+v", events)
+	}
+}
+
+func TestTwitterReader(t *testing.T) {
+	input := "0,key-a,5,100,1,set,0\n" +
+		"1,key-a,5,0,1,get,0\n" +
+		"2,key-a,5,0,2,delete,0\n"
+	events, err := ReadAll(NewTwitterReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		{Op: OpSet, KeyHash: hash.String("key-a"), Cost: 105},
+		{Op: OpGet, KeyHash: hash.String("key-a"), Time: 1e9},
+		{Op: OpDelete, KeyHash: hash.String("key-a"), Time: 2e9},
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+
+	if _, err := ReadAll(NewTwitterReader(strings.NewReader("0,k,1,1,1,frob,0\n"))); !errors.Is(err, ErrFormat) {
+		t.Errorf("Expected ErrFormat for an unknown op, got %v", err)
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	for _, format := range Formats {
+		if _, err := NewSource(format, strings.NewReader("")); err != nil {
+			t.Errorf("Expected format %q to be supported, got %v", format, err)
+		}
+	}
+	if _, err := NewSource("csv", strings.NewReader("")); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestSimulateLRU(t *testing.T) {
+	// Capacity 2: 1 2 1 3 1 2 -> hits on the second 1 and the third 1.
+	var events []Event
+	for _, key := range []uint64{1, 2, 1, 3, 1, 2} {
+		events = append(events, Event{Op: OpGet, KeyHash: key})
+	}
+	r := Simulate(events, policy.NewLRU[uint64](), 2, false)
+	if r.Gets != 6 || r.Hits != 2 {
+		t.Errorf("Expected 2/6 hits, got %d/%d", r.Hits, r.Gets)
+	}
+}
+
+func TestSimulateDeleteAndCost(t *testing.T) {
+	events := []Event{
+		{Op: OpSet, KeyHash: 1, Cost: 60},
+		{Op: OpGet, KeyHash: 1},
+		{Op: OpDelete, KeyHash: 1},
+		{Op: OpGet, KeyHash: 1}, // Miss, refilled with cost 60
+		{Op: OpSet, KeyHash: 2, Cost: 60},
+		{Op: OpGet, KeyHash: 1}, // Evicted: 120 > 100
+	}
+	r := Simulate(events, policy.NewLRU[uint64](), 100, true)
+	if r.Gets != 3 || r.Hits != 1 {
+		t.Errorf("Expected 1/3 hits, got %d/%d", r.Hits, r.Gets)
+	}
+}
+
+func TestSimulateAllPolicies(t *testing.T) {
+	events, _ := ReadAll(NewZipf(1, 1.2, 10000, 50000))
+	stats := Summarize(events)
+	if stats.Gets != 50000 || stats.UniqueKeys == 0 || stats.AvgCost != 1 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+	for _, name := range PolicyNames() {
+		r := Simulate(events, Policies[name](10000), 1000, false)
+		if ratio := r.HitRatio(); ratio < 0.3 || ratio > 1 {
+			t.Errorf("%s: unexpected hit ratio %.3f on a Zipf trace", name, ratio)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+// Package wal provides a segmented append-only log with snapshot compaction.
+//
+// Records are opaque byte strings framed as [4:len][4:crc32c][payload].
+// Segments are named wal-<seq>.log and snapshots snapshot-<seq>.snap, where
+// a snapshot with sequence N covers every segment below N.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentPrefix  = "wal-"
+	segmentSuffix  = ".log"
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".snap"
+
+	frameHeaderSize = 8
+
+	// DefaultSegmentSize is the segment size at which the log rotates.
+	DefaultSegmentSize = 64 << 20
+
+	// maxRecordSize bounds record allocations when reading corrupt input.
+	maxRecordSize = 1 << 30
+)
+
+// ErrCorrupt is returned when a segment other than the last one contains
+// an invalid frame.
+var ErrCorrupt = errors.New("wal: corrupt segment")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SyncPolicy controls when appended records are fsynced.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs on every Commit.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes on Commit and leaves fsync to the caller's timer.
+	SyncInterval
+	// SyncNever flushes on Commit and only fsyncs on Close.
+	SyncNever
+)
+
+// Options configures a Log.
+type Options struct {
+	SegmentSize int64      // Rotate after this many bytes (default 64MB)
+	Sync        SyncPolicy // When Commit fsyncs
+}
+
+// Log is a segmented append-only log. It is safe for concurrent use.
+type Log struct {
+	dir  string
+	opts Options
+
+	mu    sync.Mutex
+	f     *os.File
+	bw    *bufio.Writer
+	seq   uint64 // Sequence of the active segment
+	size  int64  // Bytes written to the active segment
+	dirty bool   // Data written since the last fsync
+	err   error  // Sticky write error
+}
+
+// Open opens the log in dir, creating the directory if needed.
+// Appends always go to a fresh segment, so a torn tail left by a crash
+// is never extended.
+func Open(dir string, opts Options) (*Log, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	segs, err := listSeq(dir, segmentPrefix, segmentSuffix)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := listSeq(dir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var next uint64 = 1
+	if n := len(segs); n > 0 {
+		next = segs[n-1] + 1
+	}
+	if n := len(snaps); n > 0 && snaps[n-1] >= next {
+		next = snaps[n-1] + 1
+	}
+
+	l := &Log{dir: dir, opts: opts}
+	if err := l.openSegment(next); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Dir returns the log directory.
+func (l *Log) Dir() string {
+	return l.dir
+}
+
+// openSegment makes seq the active segment. Must be called with l.mu held
+// or before the log is shared.
+func (l *Log) openSegment(seq uint64) error {
+	f, err := os.OpenFile(segmentPath(l.dir, seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := syncDir(l.dir); err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.bw = bufio.NewWriterSize(f, 64<<10)
+	l.seq = seq
+	l.size = 0
+	return nil
+}
+
+// Append adds a record to the log. The record is buffered until Commit.
+func (l *Log) Append(rec []byte) error {
+	var hdr [frameHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(rec)))
+	binary.LittleEndian.PutUint32(hdr[4:8], crc32.Checksum(rec, crcTable))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+	if l.size > 0 && l.size+int64(len(rec))+frameHeaderSize > l.opts.SegmentSize {
+		if err := l.rotateLocked(); err != nil {
+			return l.fail(err)
+		}
+	}
+	if _, err := l.bw.Write(hdr[:]); err != nil {
+		return l.fail(err)
+	}
+	if _, err := l.bw.Write(rec); err != nil {
+		return l.fail(err)
+	}
+	l.size += int64(len(rec)) + frameHeaderSize
+	l.dirty = true
+	return nil
+}
+
+// Commit makes appended records visible to the OS, and durable when the
+// sync policy is SyncAlways. Callers group several appends per Commit.
+func (l *Log) Commit() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+	if l.opts.Sync == SyncAlways {
+		return l.syncLocked()
+	}
+	if err := l.bw.Flush(); err != nil {
+		return l.fail(err)
+	}
+	return nil
+}
+
+// Sync flushes and fsyncs the active segment.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+	return l.syncLocked()
+}
+
+func (l *Log) syncLocked() error {
+	if err := l.bw.Flush(); err != nil {
+		return l.fail(err)
+	}
+	if !l.dirty {
+		return nil
+	}
+	if err := l.f.Sync(); err != nil {
+		return l.fail(err)
+	}
+	l.dirty = false
+	return nil
+}
+
+// Rotate closes the active segment and starts a new one.
+// Returns the sequence of the new segment.
+func (l *Log) Rotate() (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return 0, l.err
+	}
+	if err := l.rotateLocked(); err != nil {
+		return 0, l.fail(err)
+	}
+	return l.seq, nil
+}
+
+func (l *Log) rotateLocked() error {
+	if err := l.syncLocked(); err != nil {
+		return err
+	}
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	return l.openSegment(l.seq + 1)
+}
+
+// fail records a sticky error. Must be called with l.mu held.
+func (l *Log) fail(err error) error {
+	if l.err == nil {
+		l.err = err
+	}
+	return l.err
+}
+
+// Err returns the first write error encountered, if any.
+func (l *Log) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// WriteSnapshot atomically writes a snapshot covering all segments below
+// seq, then removes those segments and any older snapshots.
+func (l *Log) WriteSnapshot(seq uint64, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(l.dir, snapshotPrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op after a successful rename
+
+	bw := bufio.NewWriterSize(tmp, 256<<10)
+	if err := write(bw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, snapshotPath(l.dir, seq)); err != nil {
+		return err
+	}
+	if err := syncDir(l.dir); err != nil {
+		return err
+	}
+
+	segs, err := listSeq(l.dir, segmentPrefix, segmentSuffix)
+	if err != nil {
+		return err
+	}
+	for _, s := range segs {
+		if s < seq {
+			if err := os.Remove(segmentPath(l.dir, s)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	snaps, err := listSeq(l.dir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return err
+	}
+	for _, s := range snaps {
+		if s < seq {
+			if err := os.Remove(snapshotPath(l.dir, s)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close flushes, fsyncs and closes the active segment.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return l.err
+	}
+	err := l.err
+	if err == nil {
+		err = l.syncLocked()
+	}
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	if l.err == nil {
+		l.err = os.ErrClosed
+	}
+	return err
+}
+
+// Recover replays the state stored in dir: the newest snapshot is passed to
+// loadSnapshot (if one exists), then every record of the segments it does
+// not cover is passed to apply in order. An invalid frame in the last
+// segment is treated as a torn write: replay stops there and the segment
+// is truncated to its last valid frame. Recover must run before Open.
+func Recover(dir string, loadSnapshot func(r io.Reader) error, apply func(rec []byte) error) error {
+	snaps, err := listSeq(dir, snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var from uint64
+	if n := len(snaps); n > 0 {
+		from = snaps[n-1]
+		f, err := os.Open(snapshotPath(dir, from))
+		if err != nil {
+			return err
+		}
+		err = loadSnapshot(bufio.NewReaderSize(f, 256<<10))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("wal: load snapshot %d: %w", from, err)
+		}
+	}
+
+	segs, err := listSeq(dir, segmentPrefix, segmentSuffix)
+	if err != nil {
+		return err
+	}
+	for i, seq := range segs {
+		if seq < from {
+			continue
+		}
+		path := segmentPath(dir, seq)
+		valid, cause, err := replaySegment(path, apply)
+		if err != nil {
+			return err
+		}
+		if cause == nil {
+			continue
+		}
+		if i != len(segs)-1 {
+			return fmt.Errorf("%w: %s: %v", ErrCorrupt, filepath.Base(path), cause)
+		}
+		if err := os.Truncate(path, valid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaySegment applies every valid frame of one segment. It returns the
+// offset just past the last valid frame and, if the segment does not end
+// cleanly, the reason it stopped.
+func replaySegment(path string, apply func(rec []byte) error) (valid int64, cause error, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReaderSize(f, 256<<10)
+	var (
+		hdr [frameHeaderSize]byte
+		rec []byte
+	)
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF {
+				return valid, nil, nil
+			}
+			return valid, err, nil
+		}
+		size := binary.LittleEndian.Uint32(hdr[0:4])
+		if size > maxRecordSize {
+			return valid, errors.New("record too large"), nil
+		}
+		if cap(rec) < int(size) {
+			rec = make([]byte, size)
+		}
+		rec = rec[:size]
+		if _, err := io.ReadFull(br, rec); err != nil {
+			return valid, err, nil
+		}
+		if crc32.Checksum(rec, crcTable) != binary.LittleEndian.Uint32(hdr[4:8]) {
+			return valid, errors.New("checksum mismatch"), nil
+		}
+		if err := apply(rec); err != nil {
+			return valid, nil, err
+		}
+		valid += frameHeaderSize + int64(size)
+	}
+}
+
+// listSeq returns the sorted sequence numbers of files named prefix<seq>suffix.
+func listSeq(dir, prefix, suffix string) ([]uint64, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, e := range ents {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	return seqs, nil
+}
+
+func segmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", segmentPrefix, seq, segmentSuffix))
+}
+
+func snapshotPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", snapshotPrefix, seq, snapshotSuffix))
+}
+
+// syncDir fsyncs a directory so that created and renamed entries persist.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
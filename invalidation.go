@@ -0,0 +1,186 @@
+package mcache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrMalformedInvalidation is returned by Invalidation.UnmarshalBinary
+// for data that is not an encoded Invalidation.
+var ErrMalformedInvalidation = errors.New("mcache: malformed invalidation")
+
+// InvalidationOp is the operation carried by an Invalidation.
+type InvalidationOp uint8
+
+const (
+	// InvalidationDelete deletes Key.
+	InvalidationDelete InvalidationOp = iota + 1
+	// InvalidationClear removes every entry.
+	InvalidationClear
+	// InvalidationTag deletes every entry tagged with Tag.
+	InvalidationTag
+)
+
+// Invalidation is a Delete, Clear or InvalidateTag made on one Cache, to
+// be applied to the other caches sharing an Invalidator.
+type Invalidation struct {
+	Op     InvalidationOp
+	Source string // Identifies the Cache that published it
+	Key    []byte // Key encoded with the Codec, for InvalidationDelete
+	Tag    string // For InvalidationTag
+}
+
+// AppendBinary appends the encoding of inv to dst, for Invalidators that
+// send invalidations as bytes.
+func (inv Invalidation) AppendBinary(dst []byte) ([]byte, error) {
+	dst = append(dst, byte(inv.Op))
+	dst = binary.AppendUvarint(dst, uint64(len(inv.Source)))
+	dst = append(dst, inv.Source...)
+	switch inv.Op {
+	case InvalidationDelete:
+		dst = append(dst, inv.Key...)
+	case InvalidationTag:
+		dst = append(dst, inv.Tag...)
+	}
+	return dst, nil
+}
+
+// UnmarshalBinary decodes an Invalidation encoded with AppendBinary. Key
+// is a copy, so data may be reused afterwards.
+func (inv *Invalidation) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return ErrMalformedInvalidation
+	}
+	op := InvalidationOp(data[0])
+	n, size := binary.Uvarint(data[1:])
+	if size <= 0 || n > uint64(len(data)-1-size) {
+		return ErrMalformedInvalidation
+	}
+	data = data[1+size:]
+	*inv = Invalidation{Op: op, Source: string(data[:n])}
+	data = data[n:]
+	switch op {
+	case InvalidationDelete:
+		inv.Key = append([]byte(nil), data...)
+	case InvalidationClear:
+	case InvalidationTag:
+		inv.Tag = string(data)
+	default:
+		return ErrMalformedInvalidation
+	}
+	return nil
+}
+
+// Invalidator broadcasts invalidations between caches, usually in
+// different processes. Publish sends inv to every subscriber, possibly
+// including the publisher's own; Subscribe calls fn for each invalidation
+// received until cancel is called. Delivery may be lossy and unordered
+// across publishers, depending on the implementation. fn must not retain
+// inv.Key. The invalidation package provides implementations.
+type Invalidator interface {
+	Publish(ctx context.Context, inv Invalidation) error
+	Subscribe(fn func(inv Invalidation)) (cancel func(), err error)
+}
+
+// invalidator publishes local invalidations and applies remote ones.
+type invalidator struct {
+	inv    Invalidator
+	source string
+	cancel func()
+
+	mu  sync.Mutex // Guards err
+	err error      // First failed publish or undecodable invalidation
+}
+
+// openInvalidator subscribes the cache to the configured Invalidator.
+func (c *Cache[K, V]) openInvalidator() error {
+	if c.config.Codec == nil {
+		return fmt.Errorf("mcache: WithInvalidator requires WithCodec: %w", ErrNoCodec)
+	}
+	iv := &invalidator{inv: c.config.Invalidator, source: rand.Text()}
+	c.invalidator = iv
+	cancel, err := iv.inv.Subscribe(c.applyInvalidation)
+	if err != nil {
+		return err
+	}
+	iv.cancel = cancel
+	return nil
+}
+
+// publish sends an invalidation made on this cache, if an Invalidator is
+// configured. Failures are counted and surfaced by InvalidatorError
+// rather than failing the local operation, which has already happened.
+func (c *Cache[K, V]) publish(op InvalidationOp, key K, tag string) {
+	iv := c.invalidator
+	if iv == nil {
+		return
+	}
+	inv := Invalidation{Op: op, Source: iv.source, Tag: tag}
+	var err error
+	if op == InvalidationDelete {
+		inv.Key, err = c.config.Codec.AppendKey(nil, key)
+	}
+	if err == nil {
+		err = iv.inv.Publish(c.ctx, inv)
+	}
+	if err != nil {
+		c.metrics.incInvalidationError()
+		iv.fail(fmt.Errorf("mcache: publish invalidation: %w", err))
+		return
+	}
+	c.metrics.incInvalidationSent()
+}
+
+// applyInvalidation applies an invalidation received from another cache
+// without publishing it again. The cache's own invalidations are ignored.
+func (c *Cache[K, V]) applyInvalidation(inv Invalidation) {
+	if inv.Source == c.invalidator.source || c.closed.Load() {
+		return
+	}
+	c.metrics.incInvalidationReceived()
+	switch inv.Op {
+	case InvalidationDelete:
+		key, err := c.config.Codec.DecodeKey(inv.Key)
+		if err != nil {
+			c.metrics.incInvalidationError()
+			c.invalidator.fail(fmt.Errorf("mcache: apply invalidation: %w", err))
+			return
+		}
+		c.remove(context.Background(), key)
+	case InvalidationClear:
+		c.clear()
+	case InvalidationTag:
+		c.invalidateTag(inv.Tag)
+	}
+}
+
+func (iv *invalidator) fail(err error) {
+	iv.mu.Lock()
+	if iv.err == nil {
+		iv.err = err
+	}
+	iv.mu.Unlock()
+}
+
+// InvalidatorError returns the first error publishing an invalidation or
+// decoding a received one, or nil. Such invalidations are dropped and
+// counted in MetricsSnapshot.InvalidationErrors.
+func (c *Cache[K, V]) InvalidatorError() error {
+	if c.invalidator == nil {
+		return nil
+	}
+	c.invalidator.mu.Lock()
+	defer c.invalidator.mu.Unlock()
+	return c.invalidator.err
+}
+
+// closeInvalidator stops applying remote invalidations.
+func (c *Cache[K, V]) closeInvalidator() {
+	if c.invalidator != nil && c.invalidator.cancel != nil {
+		c.invalidator.cancel()
+	}
+}
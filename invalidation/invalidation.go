@@ -0,0 +1,85 @@
+// Package invalidation provides Invalidators that broadcast the deletes,
+// clears and tag invalidations of a Cache configured with
+// mcache.WithInvalidator to the caches of other processes:
+//
+//   - MemoryBus connects caches in one process, synchronously.
+//   - PacketBus sends datagrams, either to a UDP multicast group
+//     (ListenMulticast) or to the Unix sockets of the other processes on
+//     the host (ListenUnix).
+//   - PubSubBus sends through a Redis-style publish/subscribe channel.
+//     LocalPubSub is an in-process stand-in for Redis pub/sub, so code
+//     using a PubSubBus can be tested without a Redis server.
+//
+// Datagrams and pub/sub messages are fire-and-forget: an invalidation
+// sent while a process is down or its socket is full is lost, so bound
+// staleness with TTLs as well.
+package invalidation
+
+import (
+	"context"
+	"sync"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// subscribers is a set of subscriber callbacks.
+type subscribers struct {
+	mu   sync.RWMutex
+	fns  map[int]func(mcache.Invalidation)
+	next int
+}
+
+// add registers fn and returns a function removing it.
+func (s *subscribers) add(fn func(mcache.Invalidation)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fns == nil {
+		s.fns = make(map[int]func(mcache.Invalidation))
+	}
+	id := s.next
+	s.next++
+	s.fns[id] = fn
+	return func() {
+		s.mu.Lock()
+		delete(s.fns, id)
+		s.mu.Unlock()
+	}
+}
+
+// deliver calls every subscriber with inv.
+func (s *subscribers) deliver(inv mcache.Invalidation) {
+	s.mu.RLock()
+	fns := make([]func(mcache.Invalidation), 0, len(s.fns))
+	for _, fn := range s.fns {
+		fns = append(fns, fn)
+	}
+	s.mu.RUnlock()
+	for _, fn := range fns {
+		fn(inv)
+	}
+}
+
+// MemoryBus is an Invalidator for caches in the same process. Publish
+// calls every subscriber before returning.
+type MemoryBus struct {
+	subs subscribers
+}
+
+// NewMemoryBus returns an empty MemoryBus.
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+// Publish implements mcache.Invalidator.
+func (b *MemoryBus) Publish(ctx context.Context, inv mcache.Invalidation) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.subs.deliver(inv)
+	return nil
+}
+
+// Subscribe implements mcache.Invalidator.
+func (b *MemoryBus) Subscribe(fn func(mcache.Invalidation)) (func(), error) {
+	return b.subs.add(fn), nil
+}
@@ -0,0 +1,174 @@
+package invalidation
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+func newCache(t *testing.T, inv mcache.Invalidator) *mcache.Cache[string, []byte] {
+	t.Helper()
+	c, err := mcache.OpenCache(
+		mcache.WithCodec[string, []byte](mcache.StringBytesCodec{}),
+		mcache.WithInvalidator[string, []byte](inv),
+	)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// eventually reports whether cond becomes true within a second.
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+// checkBroadcast checks that the deletes, clears and tag invalidations of
+// a reach b.
+func checkBroadcast(t *testing.T, a, b *mcache.Cache[string, []byte]) {
+	t.Helper()
+	for _, c := range []*mcache.Cache[string, []byte]{a, b} {
+		c.Set("k", []byte("v"), 0)
+		c.SetWithTags("tagged", []byte("v"), 0, "red")
+		c.Set("other", []byte("v"), 0)
+	}
+
+	a.Delete("k")
+	if !eventually(func() bool { return !b.Has("k") }) {
+		t.Error("Expected a Delete to reach the other cache")
+	}
+	a.InvalidateTag("red")
+	if !eventually(func() bool { return !b.Has("tagged") }) {
+		t.Error("Expected a tag invalidation to reach the other cache")
+	}
+	if !b.Has("other") {
+		t.Error("Expected other entries to stay")
+	}
+	a.Clear()
+	if !eventually(func() bool { return b.Len() == 0 }) {
+		t.Error("Expected a Clear to reach the other cache")
+	}
+	if err := a.InvalidatorError(); err != nil {
+		t.Errorf("Expected no invalidator error, got %v", err)
+	}
+	if m := b.Metrics(); m.InvalidationsSent != 0 {
+		t.Errorf("Expected received invalidations not to be published again, got %d", m.InvalidationsSent)
+	}
+}
+
+func TestMemoryBus(t *testing.T) {
+	bus := NewMemoryBus()
+	checkBroadcast(t, newCache(t, bus), newCache(t, bus))
+}
+
+func TestPubSubBus(t *testing.T) {
+	ps := NewLocalPubSub()
+	checkBroadcast(t,
+		newCache(t, NewPubSubBus(ps, "invalidations")),
+		newCache(t, NewPubSubBus(ps, "invalidations")),
+	)
+	if ps.Dropped() != 0 {
+		t.Errorf("Expected no dropped messages, got %d", ps.Dropped())
+	}
+}
+
+func TestUnixBus(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, which t.TempDir can
+	// exceed.
+	dir, err := os.MkdirTemp("", "mcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pathA, pathB := filepath.Join(dir, "a.sock"), filepath.Join(dir, "b.sock")
+
+	busA, err := ListenUnix(pathA, pathB)
+	if err != nil {
+		t.Fatalf("ListenUnix: %v", err)
+	}
+	defer busA.Close()
+	busB, err := ListenUnix(pathB, pathA)
+	if err != nil {
+		t.Fatalf("ListenUnix: %v", err)
+	}
+	checkBroadcast(t, newCache(t, busA), newCache(t, busB))
+
+	busB.Close()
+	if _, err := os.Stat(pathB); !os.IsNotExist(err) {
+		t.Errorf("Expected Close to remove the socket, got %v", err)
+	}
+	c := newCache(t, busA)
+	c.Delete("k")
+	if c.InvalidatorError() == nil {
+		t.Error("Expected an error publishing to a peer that is gone")
+	}
+}
+
+func TestMulticastBus(t *testing.T) {
+	busA, err := ListenMulticast("239.255.77.77:47946", nil)
+	if err != nil {
+		t.Skipf("Multicast unavailable: %v", err)
+	}
+	defer busA.Close()
+	busB, err := ListenMulticast("239.255.77.77:47946", nil)
+	if err != nil {
+		t.Skipf("Multicast unavailable: %v", err)
+	}
+	defer busB.Close()
+
+	a, b := newCache(t, busA), newCache(t, busB)
+	b.Set("probe", nil, 0)
+	a.Delete("probe")
+	if !eventually(func() bool { return !b.Has("probe") }) {
+		t.Skip("Multicast datagrams are not delivered on this host")
+	}
+	checkBroadcast(t, a, b)
+}
+
+// failingConn is a PacketConn whose reads fail until it is closed.
+type failingConn struct {
+	net.PacketConn
+	reads  atomic.Int64
+	closed atomic.Bool
+}
+
+func (c *failingConn) ReadFrom([]byte) (int, net.Addr, error) {
+	c.reads.Add(1)
+	if c.closed.Load() {
+		return 0, nil, net.ErrClosed
+	}
+	return 0, nil, errors.New("read failed")
+}
+
+func (c *failingConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestPacketBusReadErrors(t *testing.T) {
+	conn := &failingConn{}
+	bus := NewPacketBus(conn)
+	time.Sleep(200 * time.Millisecond)
+	start := time.Now()
+	bus.Close()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("Expected Close not to wait for the backoff, took %v", d)
+	}
+	if n := conn.reads.Load(); n > 20 {
+		t.Errorf("Expected failed reads to back off, got %d reads", n)
+	}
+}
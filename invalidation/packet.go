@@ -0,0 +1,162 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// maxPacketSize is the largest invalidation a PacketBus sends, the
+// largest UDP payload.
+const maxPacketSize = 65507
+
+// Bounds of the wait after a failed read, which doubles while reads keep
+// failing so that a persistent error does not spin the read loop.
+const (
+	minReadBackoff = 10 * time.Millisecond
+	maxReadBackoff = time.Second
+)
+
+// ErrPacketTooLarge is returned by PacketBus.Publish for an invalidation
+// that does not fit in a datagram.
+var ErrPacketTooLarge = errors.New("invalidation: invalidation too large for a datagram")
+
+// PacketBus is an Invalidator sending each invalidation as a datagram to
+// a fixed set of addresses, and delivering the datagrams it receives to
+// its subscribers. A publisher may receive its own datagrams, which the
+// Cache ignores.
+type PacketBus struct {
+	conn  net.PacketConn
+	peers []net.Addr
+	subs  subscribers
+	path  string // Unix socket to remove on Close
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+// NewPacketBus returns a PacketBus reading from conn and publishing to
+// peers. It takes ownership of conn and reads from it until Close.
+func NewPacketBus(conn net.PacketConn, peers ...net.Addr) *PacketBus {
+	b := &PacketBus{conn: conn, peers: peers, stop: make(chan struct{}), done: make(chan struct{})}
+	go b.readLoop()
+	return b
+}
+
+// ListenMulticast returns a PacketBus for the UDP multicast group at
+// address, such as "239.1.2.3:7946", joined on ifi (nil for the default
+// interface). Every process listening on the group receives the
+// invalidations published by the others.
+func ListenMulticast(address string, ifi *net.Interface) (*PacketBus, error) {
+	group, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp", ifi, group)
+	if err != nil {
+		return nil, err
+	}
+	return NewPacketBus(conn, group), nil
+}
+
+// ListenUnix returns a PacketBus bound to the Unix datagram socket at
+// path and publishing to the sockets of the other processes at peers.
+// A stale socket left at path by a process that exited is replaced. The
+// socket is removed on Close. Publish reports an error for each peer that
+// is not listening, but still sends to the others.
+func ListenUnix(path string, peers ...string) (*PacketBus, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]net.Addr, len(peers))
+	for i, p := range peers {
+		addrs[i] = &net.UnixAddr{Name: p, Net: "unixgram"}
+	}
+	b := NewPacketBus(conn, addrs...)
+	b.path = path
+	return b, nil
+}
+
+// Publish implements mcache.Invalidator. It returns the errors of the
+// peers the datagram could not be sent to.
+func (b *PacketBus) Publish(ctx context.Context, inv mcache.Invalidation) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := inv.AppendBinary(nil)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxPacketSize {
+		return ErrPacketTooLarge
+	}
+	var errs []error
+	for _, peer := range b.peers {
+		if _, err := b.conn.WriteTo(data, peer); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Subscribe implements mcache.Invalidator.
+func (b *PacketBus) Subscribe(fn func(mcache.Invalidation)) (func(), error) {
+	return b.subs.add(fn), nil
+}
+
+// Addr returns the local address of the bus.
+func (b *PacketBus) Addr() net.Addr {
+	return b.conn.LocalAddr()
+}
+
+// readLoop delivers received datagrams until the connection is closed.
+// Datagrams that do not decode are ignored. After a failed read it waits
+// before reading again, longer while reads keep failing.
+func (b *PacketBus) readLoop() {
+	defer close(b.done)
+	buf := make([]byte, maxPacketSize)
+	var backoff time.Duration
+	for {
+		n, _, err := b.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			backoff = min(max(2*backoff, minReadBackoff), maxReadBackoff)
+			select {
+			case <-time.After(backoff):
+			case <-b.stop:
+				return
+			}
+			continue
+		}
+		backoff = 0
+		var inv mcache.Invalidation
+		if inv.UnmarshalBinary(buf[:n]) == nil {
+			b.subs.deliver(inv)
+		}
+	}
+}
+
+// Close stops receiving and closes the connection.
+func (b *PacketBus) Close() error {
+	b.once.Do(func() {
+		close(b.stop)
+		b.err = b.conn.Close()
+		<-b.done
+		if b.path != "" {
+			os.Remove(b.path)
+		}
+	})
+	return b.err
+}
@@ -0,0 +1,140 @@
+package invalidation
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// PubSub is a Redis-style publish/subscribe client: a message published
+// to a channel reaches the subscribers of the channel at that moment, in
+// publish order for each subscriber. Wrap the PUBLISH and SUBSCRIBE of a
+// Redis client in it to invalidate through Redis.
+type PubSub interface {
+	Publish(ctx context.Context, channel string, msg []byte) error
+	Subscribe(channel string, fn func(msg []byte)) (cancel func(), err error)
+}
+
+// PubSubBus is an Invalidator sending invalidations through a channel of
+// a PubSub.
+type PubSubBus struct {
+	ps      PubSub
+	channel string
+}
+
+// NewPubSubBus returns a PubSubBus using channel of ps.
+func NewPubSubBus(ps PubSub, channel string) *PubSubBus {
+	return &PubSubBus{ps: ps, channel: channel}
+}
+
+// Publish implements mcache.Invalidator.
+func (b *PubSubBus) Publish(ctx context.Context, inv mcache.Invalidation) error {
+	msg, err := inv.AppendBinary(nil)
+	if err != nil {
+		return err
+	}
+	return b.ps.Publish(ctx, b.channel, msg)
+}
+
+// Subscribe implements mcache.Invalidator. Messages that do not decode
+// are ignored.
+func (b *PubSubBus) Subscribe(fn func(mcache.Invalidation)) (func(), error) {
+	return b.ps.Subscribe(b.channel, func(msg []byte) {
+		var inv mcache.Invalidation
+		if inv.UnmarshalBinary(msg) == nil {
+			fn(inv)
+		}
+	})
+}
+
+// localQueueSize is the number of messages a LocalPubSub subscription
+// holds before dropping new ones, like the output buffer limit Redis
+// applies to pub/sub clients.
+const localQueueSize = 1024
+
+// LocalPubSub is an in-process PubSub with the delivery semantics of
+// Redis pub/sub, for tests: Publish returns without waiting for the
+// subscribers, each subscription receives its messages in order on its
+// own goroutine, and a subscription that falls too far behind loses
+// messages instead of slowing the publisher down.
+type LocalPubSub struct {
+	mu       sync.Mutex
+	channels map[string]map[*localSub]struct{}
+	dropped  atomic.Int64
+}
+
+// localSub is a subscription of a LocalPubSub.
+type localSub struct {
+	queue chan []byte
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewLocalPubSub returns a LocalPubSub without subscribers.
+func NewLocalPubSub() *LocalPubSub {
+	return &LocalPubSub{channels: make(map[string]map[*localSub]struct{})}
+}
+
+// Publish implements PubSub. msg is copied.
+func (p *LocalPubSub) Publish(ctx context.Context, channel string, msg []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msg = append([]byte(nil), msg...)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for sub := range p.channels[channel] {
+		select {
+		case sub.queue <- msg:
+		default:
+			p.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Subscribe implements PubSub. cancel waits for a message being delivered
+// to fn and drops the messages still queued.
+func (p *LocalPubSub) Subscribe(channel string, fn func(msg []byte)) (func(), error) {
+	sub := &localSub{
+		queue: make(chan []byte, localQueueSize),
+		done:  make(chan struct{}),
+	}
+	p.mu.Lock()
+	if p.channels[channel] == nil {
+		p.channels[channel] = make(map[*localSub]struct{})
+	}
+	p.channels[channel][sub] = struct{}{}
+	p.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case msg := <-sub.queue:
+				fn(msg)
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		sub.once.Do(func() {
+			p.mu.Lock()
+			delete(p.channels[channel], sub)
+			p.mu.Unlock()
+			close(sub.done)
+			<-stopped
+		})
+	}, nil
+}
+
+// Dropped returns the number of messages dropped because a subscription
+// was full.
+func (p *LocalPubSub) Dropped() int64 {
+	return p.dropped.Load()
+}
@@ -0,0 +1,189 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// memInvalidator is an Invalidator delivering to its subscribers
+// synchronously, including to the publisher.
+type memInvalidator struct {
+	mu   sync.Mutex
+	subs map[int]func(Invalidation)
+	next int
+	sent int
+}
+
+func (m *memInvalidator) Publish(_ context.Context, inv Invalidation) error {
+	m.mu.Lock()
+	m.sent++
+	subs := make([]func(Invalidation), 0, len(m.subs))
+	for _, fn := range m.subs {
+		subs = append(subs, fn)
+	}
+	m.mu.Unlock()
+	for _, fn := range subs {
+		fn(inv)
+	}
+	return nil
+}
+
+func (m *memInvalidator) Subscribe(fn func(Invalidation)) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.subs == nil {
+		m.subs = make(map[int]func(Invalidation))
+	}
+	id := m.next
+	m.next++
+	m.subs[id] = fn
+	return func() {
+		m.mu.Lock()
+		delete(m.subs, id)
+		m.mu.Unlock()
+	}, nil
+}
+
+func TestInvalidator(t *testing.T) {
+	bus := &memInvalidator{}
+	newCache := func() *Cache[string, int] {
+		c, err := OpenCache(
+			WithCodec[string, int](GobCodec[string, int]{}),
+			WithInvalidator[string, int](bus),
+		)
+		if err != nil {
+			t.Fatalf("OpenCache: %v", err)
+		}
+		t.Cleanup(c.Close)
+		return c
+	}
+	a, b := newCache(), newCache()
+
+	for _, c := range []*Cache[string, int]{a, b} {
+		c.Set("k", 1, 0)
+		c.SetWithTags("t1", 1, 0, "red")
+		c.SetWithTags("t2", 2, 0, "red")
+		c.Set("other", 3, 0)
+	}
+
+	a.Delete("k")
+	if _, ok := b.Get("k"); ok {
+		t.Error("Expected a Delete to reach the other cache")
+	}
+	if n := b.InvalidateTag("red"); n != 2 {
+		t.Errorf("Expected 2 tagged entries deleted locally, got %d", n)
+	}
+	if _, ok := a.Get("t1"); ok {
+		t.Error("Expected a tag invalidation to reach the other cache")
+	}
+
+	// Applied invalidations are not published again.
+	if bus.sent != 2 {
+		t.Errorf("Expected 2 published invalidations, got %d", bus.sent)
+	}
+	if m := a.Metrics(); m.InvalidationsSent != 1 || m.InvalidationsReceived != 1 {
+		t.Errorf("Expected 1 sent and 1 received, got %d and %d", m.InvalidationsSent, m.InvalidationsReceived)
+	}
+
+	a.Clear()
+	if _, ok := b.Get("other"); ok {
+		t.Error("Expected a Clear to reach the other cache")
+	}
+	if bus.sent != 3 {
+		t.Errorf("Expected 3 published invalidations, got %d", bus.sent)
+	}
+
+	// Set is not published.
+	a.Set("k", 2, 0)
+	if bus.sent != 3 {
+		t.Error("Expected Set not to be published")
+	}
+	if err := a.InvalidatorError(); err != nil {
+		t.Errorf("Expected no invalidator error, got %v", err)
+	}
+
+	b.Close()
+	a.Delete("k")
+	if len(bus.subs) != 1 {
+		t.Errorf("Expected Close to unsubscribe, got %d subscribers", len(bus.subs))
+	}
+}
+
+func TestInvalidatorConditionalDeletes(t *testing.T) {
+	bus := &memInvalidator{}
+	newCache := func() *Cache[string, int] {
+		c, err := OpenCache(
+			WithCodec[string, int](GobCodec[string, int]{}),
+			WithInvalidator[string, int](bus),
+		)
+		if err != nil {
+			t.Fatalf("OpenCache: %v", err)
+		}
+		t.Cleanup(c.Close)
+		return c
+	}
+	a, b := newCache(), newCache()
+	for _, c := range []*Cache[string, int]{a, b} {
+		for _, key := range []string{"cas", "version", "compute", "kept"} {
+			c.Set(key, 1, 0)
+		}
+		c.Wait()
+	}
+
+	if !a.CompareAndDelete("cas", 1) {
+		t.Error("Expected CompareAndDelete to delete cas")
+	}
+	_, version, _ := a.GetWithVersion("version")
+	if deleted, _ := a.CompareAndDeleteVersion("version", version); !deleted {
+		t.Error("Expected CompareAndDeleteVersion to delete version")
+	}
+	a.Compute("compute", func(int, bool) (int, ComputeOp) { return 0, ComputeDelete })
+	for _, key := range []string{"cas", "version", "compute"} {
+		if b.Has(key) {
+			t.Errorf("Expected the delete of %s to reach the other cache", key)
+		}
+	}
+
+	// Deletes that do not happen are not published.
+	a.CompareAndDelete("kept", 2)
+	a.Compute("missing", func(int, bool) (int, ComputeOp) { return 0, ComputeDelete })
+	if !b.Has("kept") || bus.sent != 3 {
+		t.Errorf("Expected 3 published invalidations, got %d", bus.sent)
+	}
+}
+
+func TestInvalidatorRequiresCodec(t *testing.T) {
+	_, err := OpenCache(WithInvalidator[string, int](&memInvalidator{}))
+	if !errors.Is(err, ErrNoCodec) {
+		t.Errorf("Expected ErrNoCodec, got %v", err)
+	}
+}
+
+func TestInvalidationBinary(t *testing.T) {
+	for _, inv := range []Invalidation{
+		{Op: InvalidationDelete, Source: "a", Key: []byte("key")},
+		{Op: InvalidationClear, Source: "b"},
+		{Op: InvalidationTag, Source: "c", Tag: "red"},
+	} {
+		data, err := inv.AppendBinary(nil)
+		if err != nil {
+			t.Fatalf("AppendBinary: %v", err)
+		}
+		var got Invalidation
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary: %v", err)
+		}
+		if got.Op != inv.Op || got.Source != inv.Source || string(got.Key) != string(inv.Key) || got.Tag != inv.Tag {
+			t.Errorf("Expected %+v, got %+v", inv, got)
+		}
+	}
+
+	var inv Invalidation
+	for _, data := range [][]byte{nil, {byte(InvalidationDelete), 10, 'a'}, {9, 0}} {
+		if err := inv.UnmarshalBinary(data); !errors.Is(err, ErrMalformedInvalidation) {
+			t.Errorf("Expected ErrMalformedInvalidation for %v, got %v", data, err)
+		}
+	}
+}
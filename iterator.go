@@ -1,6 +1,7 @@
 package mcache
 
 import (
+	"context"
 	"strings"
 
 	"github.com/OrlovEvgeny/go-mcache/internal/glob"
@@ -10,6 +11,7 @@ import (
 // Iterator provides a Redis-style iterator over cache entries.
 type Iterator[K comparable, V any] struct {
 	cache   *Cache[K, V]
+	scan    func(cursor uint64, count int) ([]*store.Entry[K, V], uint64)
 	cursor  uint64
 	count   int
 	prefix  string
@@ -19,16 +21,24 @@ type Iterator[K comparable, V any] struct {
 	pos     int
 	err     error
 	done    bool
+	ctx     context.Context // nil unless created by a ...Ctx method
 }
 
 // newIterator creates a new iterator.
 func newIterator[K comparable, V any](c *Cache[K, V], cursor uint64, count int, prefix string, pattern *glob.Pattern) *Iterator[K, V] {
+	it := newScanIterator(c.store.Scan, cursor, count, prefix, pattern)
+	it.cache = c
+	return it
+}
+
+// newScanIterator creates an iterator over pages returned by scan.
+func newScanIterator[K comparable, V any](scan func(cursor uint64, count int) ([]*store.Entry[K, V], uint64), cursor uint64, count int, prefix string, pattern *glob.Pattern) *Iterator[K, V] {
 	if count <= 0 {
 		count = 10
 	}
 
 	return &Iterator[K, V]{
-		cache:   c,
+		scan:    scan,
 		cursor:  cursor,
 		count:   count,
 		prefix:  prefix,
@@ -72,8 +82,8 @@ func (it *Iterator[K, V]) Next() bool {
 
 	// Need to fetch next page?
 	if it.pos >= len(it.page) {
-		// If scan already completed, no more pages to fetch
-		if it.done {
+		// If scan already completed and nothing is buffered, we're exhausted
+		if it.done && len(it.buffer) == 0 {
 			return false
 		}
 		if !it.fetchPage() {
@@ -87,23 +97,26 @@ func (it *Iterator[K, V]) Next() bool {
 
 // fetchPage retrieves the next page of entries.
 func (it *Iterator[K, V]) fetchPage() bool {
-	if it.cache == nil {
+	if it.scan == nil {
 		it.done = true
 		return false
 	}
 
-	if it.done {
+	if it.done && len(it.buffer) == 0 {
 		return false
 	}
 
 	// Use prefix search if we have a prefix and string keys
-	if it.prefix != "" && it.cache.isStringKey && it.cache.radixTree != nil {
+	if it.prefix != "" && it.cache != nil && it.cache.isStringKey && it.cache.radixTree != nil {
 		return it.fetchPrefixPage()
 	}
 
 	// Fill buffer if empty
 	for len(it.buffer) == 0 && !it.done {
-		entries, nextCursor := it.cache.store.Scan(it.cursor, it.count*2)
+		if it.cancelled() {
+			return false
+		}
+		entries, nextCursor := it.scan(it.cursor, it.count*2)
 
 		for _, entry := range entries {
 			if it.matchEntry(entry) {
@@ -169,6 +182,9 @@ func (it *Iterator[K, V]) fetchPrefixPage() bool {
 
 	// Collect entries
 	for i := start; i < len(hashes) && len(entries) < it.count; i++ {
+		if it.cancelled() {
+			return false
+		}
 		keyHash := hashes[i]
 		// Find entry by hash
 		it.cache.store.Range(func(entry *store.Entry[K, V]) bool {
@@ -194,6 +210,19 @@ func (it *Iterator[K, V]) fetchPrefixPage() bool {
 	return len(entries) > 0
 }
 
+// cancelled reports whether the iterator's context is done, recording its
+// error for Err.
+func (it *Iterator[K, V]) cancelled() bool {
+	if it.ctx == nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return true
+	}
+	return false
+}
+
 // matchEntry checks if an entry matches the iterator's filters.
 func (it *Iterator[K, V]) matchEntry(entry *store.Entry[K, V]) bool {
 	// Check expiration
@@ -251,7 +280,8 @@ func (it *Iterator[K, V]) Cursor() uint64 {
 	return it.cursor
 }
 
-// Err returns any error that occurred during iteration.
+// Err returns any error that occurred during iteration, such as the
+// context error of an iterator created by ScanCtx.
 func (it *Iterator[K, V]) Err() error {
 	return it.err
 }
@@ -0,0 +1,169 @@
+package mcache
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+// newLinks returns the links for an entry with the given tags and
+// dependencies, or nil if it has neither.
+func newLinks[K comparable](tags []string, deps []K) *store.Links[K] {
+	if len(tags) == 0 && len(deps) == 0 {
+		return nil
+	}
+	links := &store.Links[K]{}
+	if len(tags) > 0 {
+		links.Tags = slices.Clone(tags)
+		slices.Sort(links.Tags)
+		links.Tags = slices.Compact(links.Tags)
+	}
+	if len(deps) > 0 {
+		links.Deps = make([]K, 0, len(deps))
+		for _, dep := range deps {
+			if !slices.Contains(links.Deps, dep) {
+				links.Deps = append(links.Deps, dep)
+			}
+		}
+	}
+	return links
+}
+
+// link adds a stored entry with links to the tag, dependency and
+// namespace indexes.
+func (c *Cache[K, V]) link(key K, cost int64, links *store.Links[K]) {
+	if links != nil {
+		c.tags.add(links.Tags, key)
+		c.deps.add(links.Deps, key)
+		c.namespaces.add(links.Namespace, key, cost)
+	}
+}
+
+// unlink removes an entry that was deleted or replaced from the tag,
+// dependency and namespace indexes.
+func (c *Cache[K, V]) unlink(entry *store.Entry[K, V]) {
+	if entry != nil && entry.Links != nil {
+		c.tags.remove(entry.Links.Tags, entry.Key)
+		c.deps.remove(entry.Links.Deps, entry.Key)
+		c.namespaces.remove(entry.Links.Namespace, entry.Key, entry.Cost)
+	}
+}
+
+// linkIndex maps each link, a tag or a dependency, to the keys of the
+// entries carrying it.
+//
+// Index updates for one key may run out of order: the writer that replaced
+// an entry and the writer that replaced its successor can update the index
+// concurrently. The index therefore counts, per link and key, the entries
+// linked minus the entries unlinked. The counts commute, so the index is
+// exact once the writers are done; until then readers re-check the live
+// entry.
+type linkIndex[L, K comparable] struct {
+	mu     sync.Mutex
+	byLink map[L]map[K]int
+	size   atomic.Int64 // len(byLink), readable without the lock
+}
+
+func (x *linkIndex[L, K]) add(links []L, key K)    { x.update(links, key, 1) }
+func (x *linkIndex[L, K]) remove(links []L, key K) { x.update(links, key, -1) }
+
+// update adds delta to the count of key under each link, dropping zero
+// counts.
+func (x *linkIndex[L, K]) update(links []L, key K, delta int) {
+	if len(links) == 0 {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.byLink == nil {
+		x.byLink = make(map[L]map[K]int)
+	}
+	for _, link := range links {
+		keys := x.byLink[link]
+		if keys == nil {
+			keys = make(map[K]int)
+			x.byLink[link] = keys
+		}
+		if n := keys[key] + delta; n != 0 {
+			keys[key] = n
+			continue
+		}
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(x.byLink, link)
+		}
+	}
+	x.size.Store(int64(len(x.byLink)))
+}
+
+// keys returns the keys of the entries carrying link.
+func (x *linkIndex[L, K]) keys(link L) []K {
+	if x.size.Load() == 0 {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keys := make([]K, 0, len(x.byLink[link]))
+	for key, n := range x.byLink[link] {
+		if n > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// count returns the number of entries carrying link.
+func (x *linkIndex[L, K]) count(link L) int {
+	if x.size.Load() == 0 {
+		return 0
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	n := 0
+	for _, c := range x.byLink[link] {
+		if c > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// sample returns up to n keys of the entries carrying link, starting at a
+// random position.
+func (x *linkIndex[L, K]) sample(link L, n int) []K {
+	if x.size.Load() == 0 {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keys := make([]K, 0, n)
+	for key, c := range x.byLink[link] { // map iteration order is random
+		if len(keys) == n {
+			break
+		}
+		if c > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// has reports whether any entry carries link.
+func (x *linkIndex[L, K]) has(link L) bool {
+	if x.size.Load() == 0 {
+		return false
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	_, ok := x.byLink[link]
+	return ok
+}
+
+func (x *linkIndex[L, K]) clear() {
+	x.mu.Lock()
+	x.byLink = nil
+	x.size.Store(0)
+	x.mu.Unlock()
+}
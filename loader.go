@@ -0,0 +1,265 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+var (
+	// ErrNoLoader is returned by GetOrLoad when neither a per-call loader
+	// nor a cache-wide loader (WithLoader) is available.
+	ErrNoLoader = errors.New("mcache: no loader configured")
+
+	// ErrClosed is returned by operations on a closed cache.
+	ErrClosed = errors.New("mcache: cache is closed")
+)
+
+// Loader computes the value for a key that is missing from the cache.
+// It returns the value together with its cost and TTL, which are applied
+// exactly as in SetWithCost (cost <= 0 uses CostFunc or 1, ttl <= 0 uses
+// the default TTL). A non-nil error is returned to every waiter and
+// nothing is stored.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (value V, cost int64, ttl time.Duration, err error)
+
+// loadCall is an in-flight or completed load shared by all waiters of a key.
+type loadCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// loadGroup deduplicates concurrent loads of the same key.
+type loadGroup[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*loadCall[V]
+}
+
+// acquire returns the in-flight call for key, creating one if none exists.
+// leader is true when the caller created the call and must run the load.
+func (g *loadGroup[K, V]) acquire(key K) (call *loadCall[V], leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, ok := g.calls[key]; ok {
+		return call, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[K]*loadCall[V])
+	}
+	call = &loadCall[V]{done: make(chan struct{})}
+	g.calls[key] = call
+	return call, true
+}
+
+// release publishes the result of call and wakes all waiters.
+func (g *loadGroup[K, V]) release(key K, call *loadCall[V], val V, err error) {
+	call.val = val
+	call.err = err
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	close(call.done)
+}
+
+// GetOrLoad returns the cached value for key, or loads it on a miss.
+// Concurrent misses for the same key share a single loader invocation;
+// each waiter returns early with ctx.Err() if its own ctx is cancelled,
+// without affecting the load or other waiters.
+// If loader is nil, the loader configured with WithLoader is used.
+// The loader receives the context of the span started by WithTracer.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (_ V, err error) {
+	var zero V
+
+	ctx, end := c.startSpan(ctx, "mcache.GetOrLoad")
+	defer func() { end(err) }()
+
+	if c.closed.Load() {
+		return zero, ErrClosed
+	}
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	if loader == nil {
+		loader = c.config.Loader
+	}
+	if loader == nil {
+		return zero, ErrNoLoader
+	}
+
+	call, leader := c.loads.acquire(key)
+	if leader {
+		go c.runLoad(ctx, key, loader, call)
+	}
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// runLoad invokes loader on behalf of every waiter of call.
+// The load keeps the values of the initiating context but is only
+// cancelled when the cache is closed, so one impatient caller cannot
+// fail the load for everyone else. A value written to the key during the
+// load is kept over the loaded one. With GuardLoad set, the guard taken
+// before the load also decides whether the value is stored.
+func (c *Cache[K, V]) runLoad(ctx context.Context, key K, loader Loader[K, V], call *loadCall[V]) {
+	lctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer func() {
+		stop()
+		cancel()
+	}()
+
+	var guard func(store func() bool) bool
+	if c.config.GuardLoad != nil {
+		guard = c.config.GuardLoad(key)
+	}
+	since := c.store.LastVersion()
+	value, cost, ttl, err := callLoader(lctx, key, loader)
+	if err == nil {
+		if guard != nil {
+			guard(func() bool { return c.setLoaded(key, value, cost, ttl, since) })
+		} else {
+			c.setLoaded(key, value, cost, ttl, since)
+		}
+	}
+	c.loads.release(key, call, value, err)
+}
+
+// callLoader runs loader, converting a panic into an error so that
+// waiters are never left blocked.
+func callLoader[K comparable, V any](ctx context.Context, key K, loader Loader[K, V]) (value V, cost int64, ttl time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			var zero V
+			value, cost, ttl = zero, 0, 0
+			err = fmt.Errorf("mcache: loader panic: %v", r)
+		}
+	}()
+	return loader(ctx, key)
+}
+
+// setLoaded stores a loaded value synchronously, bypassing the write buffer
+// so that the value is visible before waiters are released. It does not
+// replace an entry written after version since, the last version before
+// the load started.
+func (c *Cache[K, V]) setLoaded(key K, value V, cost int64, ttl time.Duration, since uint64) bool {
+	if c.closed.Load() {
+		return false
+	}
+	cost, expireAt := c.normalizeWrite(value, cost, ttl)
+	ok := c.setLoadedSync(key, value, c.store.KeyHash(key), cost, expireAt, since)
+	c.finishWrite()
+	return ok
+}
+
+func (c *Cache[K, V]) setLoadedSync(key K, value V, keyHash uint64, cost, expireAt int64, since uint64) bool {
+	defer c.walLock(keyHash)()
+
+	prev, found, updated, costDelta, _ := c.store.UpdateIfByHash(
+		key,
+		keyHash,
+		func(e *store.Entry[K, V]) bool { return e.Version <= since },
+		value,
+		cost,
+		expireAt,
+		nil,
+		c.capturePrevious(),
+	)
+	if updated {
+		c.afterUpdate(key, value, keyHash, cost, expireAt, nil, prev, costDelta)
+		return true
+	}
+	if found {
+		return false
+	}
+
+	// A live entry inserted meanwhile was written after the load started.
+	return c.insertIfAbsent(&store.Entry[K, V]{
+		Key:       key,
+		Value:     value,
+		KeyHash:   keyHash,
+		ExpireAt:  expireAt,
+		WrittenAt: clock.NowNano(),
+		Cost:      cost,
+	})
+}
+
+// maybeRefresh starts an asynchronous reload of entry when it is older than
+// RefreshAfter but not yet expired. At most one refresh per entry is in
+// flight, and refreshes share the singleflight group with GetOrLoad.
+func (c *Cache[K, V]) maybeRefresh(entry *store.Entry[K, V]) {
+	loader := c.config.Loader
+	if loader == nil || entry.WrittenAt == 0 {
+		return
+	}
+	if clock.NowNano()-entry.WrittenAt < int64(c.config.RefreshAfter) {
+		return
+	}
+	if !entry.TryStartRefresh() {
+		return
+	}
+
+	call, leader := c.loads.acquire(entry.Key)
+	if !leader {
+		// A load for this key is already running and will store its result.
+		entry.EndRefresh()
+		return
+	}
+	go c.runRefresh(entry, loader, call)
+}
+
+// runRefresh reloads entry in the background and replaces it only if the
+// key is still present, so a refresh never resurrects a deleted entry.
+func (c *Cache[K, V]) runRefresh(entry *store.Entry[K, V], loader Loader[K, V], call *loadCall[V]) {
+	value, cost, ttl, err := callLoader(c.ctx, entry.Key, loader)
+	if err == nil && !c.closed.Load() {
+		c.refreshExisting(entry, value, cost, ttl)
+		c.finishWrite()
+	} else {
+		entry.EndRefresh()
+	}
+	c.loads.release(entry.Key, call, value, err)
+}
+
+// refreshExisting replaces entry with the reloaded value if entry is
+// still the current write of its key, so a refresh never overwrites a
+// value written during the reload. The tags and dependencies of the entry
+// are kept.
+func (c *Cache[K, V]) refreshExisting(entry *store.Entry[K, V], value V, cost int64, ttl time.Duration) {
+	key, keyHash := entry.Key, entry.KeyHash
+	cost, expireAt := c.normalizeWrite(value, cost, ttl)
+	defer c.walLock(keyHash)()
+
+	var next *store.Entry[K, V]
+	prev, changed := c.store.Compute(key, keyHash, func(cur *store.Entry[K, V]) (*store.Entry[K, V], bool) {
+		if cur == nil || cur.Version != entry.Version {
+			return nil, false
+		}
+		next = &store.Entry[K, V]{
+			Key:       key,
+			Value:     value,
+			KeyHash:   keyHash,
+			ExpireAt:  expireAt,
+			WrittenAt: clock.NowNano(),
+			Cost:      cost,
+			Links:     cur.Links,
+		}
+		return next, false
+	})
+	if changed {
+		c.afterUpdate(key, value, keyHash, cost, expireAt, next.Links, prev, cost-prev.Cost)
+	}
+}
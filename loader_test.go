@@ -0,0 +1,252 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadDeduplicatesConcurrentMisses(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+		calls.Add(1)
+		<-release
+		return 42, 0, 0, nil
+	}
+
+	const waiters = 200
+	var wg sync.WaitGroup
+	results := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(context.Background(), "key", loader)
+			if err != nil {
+				t.Errorf("GetOrLoad: %v", err)
+				return
+			}
+			results <- v
+		}()
+	}
+
+	// Give the waiters time to pile up behind the leader.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected loader to run once, ran %d times", n)
+	}
+	for v := range results {
+		if v != 42 {
+			t.Errorf("Expected 42, got %d", v)
+		}
+	}
+	if v, ok := c.Get("key"); !ok || v != 42 {
+		t.Errorf("Expected loaded value to be cached, got %d, ok=%v", v, ok)
+	}
+}
+
+func TestGetOrLoadErrorNotCached(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	errBackend := errors.New("backend down")
+	_, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+		return 0, 0, 0, errBackend
+	})
+	if !errors.Is(err, errBackend) {
+		t.Errorf("Expected backend error, got %v", err)
+	}
+	if c.Has("key") {
+		t.Error("Expected failed load not to populate the cache")
+	}
+}
+
+func TestGetOrLoadWaiterCancellation(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+		<-release
+		return 7, 0, 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "key", loader)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// The load keeps running for other waiters.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	v, err := c.GetOrLoad(context.Background(), "key", loader)
+	if err != nil || v != 7 {
+		t.Errorf("Expected 7, got %d, err=%v", v, err)
+	}
+}
+
+func TestGetOrLoadCacheWideLoader(t *testing.T) {
+	c := NewCache[string, int](
+		WithLoader(func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+			return len(key), 0, 50 * time.Millisecond, nil
+		}),
+	)
+	defer c.Close()
+
+	v, err := c.GetOrLoad(context.Background(), "abcd", nil)
+	if err != nil || v != 4 {
+		t.Errorf("Expected 4, got %d, err=%v", v, err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if c.Has("abcd") {
+		t.Error("Expected loaded entry to honour loader TTL")
+	}
+
+	plain := NewCache[string, int]()
+	defer plain.Close()
+	if _, err := plain.GetOrLoad(context.Background(), "x", nil); !errors.Is(err, ErrNoLoader) {
+		t.Errorf("Expected ErrNoLoader, got %v", err)
+	}
+}
+
+func TestRefreshAfterServesStaleAndReloadsOnce(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	c := NewCache[string, int](
+		WithLoader(func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+			n := calls.Add(1)
+			<-release
+			return int(n) * 10, 0, time.Second, nil
+		}),
+		WithRefreshAfter[string, int](20*time.Millisecond),
+	)
+	defer c.Close()
+
+	c.Set("key", 1, time.Second)
+	time.Sleep(40 * time.Millisecond)
+
+	// Every hit past the threshold serves the cached value without blocking.
+	for i := 0; i < 100; i++ {
+		if v, ok := c.Get("key"); !ok || v != 1 {
+			t.Fatalf("Expected stale value 1, got %d, ok=%v", v, ok)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if v, _ := c.Get("key"); v == 10 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if v, _ := c.Get("key"); v != 10 {
+		t.Errorf("Expected refreshed value 10, got %d", v)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected exactly one refresh, got %d", n)
+	}
+}
+
+func TestRefreshAfterDoesNotResurrectDeleted(t *testing.T) {
+	release := make(chan struct{})
+	c := NewCache[string, int](
+		WithLoader(func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+			<-release
+			return 2, 0, 0, nil
+		}),
+		WithRefreshAfter[string, int](10*time.Millisecond),
+	)
+	defer c.Close()
+
+	c.Set("key", 1, 0)
+	time.Sleep(20 * time.Millisecond)
+	c.Get("key") // triggers refresh
+	c.Delete("key")
+	close(release)
+
+	time.Sleep(50 * time.Millisecond)
+	if c.Has("key") {
+		t.Error("Expected refresh not to re-insert a deleted key")
+	}
+}
+
+func TestRefreshAfterKeepsNewerWrite(t *testing.T) {
+	release := make(chan struct{})
+	c := NewCache[string, int](
+		WithLoader(func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+			<-release
+			return 3, 0, 0, nil
+		}),
+		WithRefreshAfter[string, int](10*time.Millisecond),
+	)
+	defer c.Close()
+
+	c.SetWithTags("key", 1, 0, "old")
+	time.Sleep(20 * time.Millisecond)
+	c.Get("key") // triggers refresh
+	c.SetWithTags("key", 2, 0, "new")
+	close(release)
+
+	time.Sleep(50 * time.Millisecond)
+	if v, _ := c.Get("key"); v != 2 {
+		t.Errorf("Expected the refresh not to overwrite the newer value 2, got %d", v)
+	}
+	if n := c.InvalidateTag("old"); n != 0 {
+		t.Errorf("Expected the refresh not to restore the old tag, got %d deleted", n)
+	}
+	if n := c.InvalidateTag("new"); n != 1 {
+		t.Errorf("Expected the entry to keep the new tag, got %d deleted", n)
+	}
+}
+
+func TestGetOrLoadKeepsSetDuringLoad(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context, key string) (int, int64, time.Duration, error) {
+			close(started)
+			<-release
+			return 1, 0, 0, nil
+		})
+		done <- err
+	}()
+
+	<-started
+	c.Set("key", 2, 0)
+	c.Wait()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("GetOrLoad failed: %v", err)
+	}
+
+	if v, _ := c.Get("key"); v != 2 {
+		t.Errorf("Expected the load not to overwrite the value set meanwhile, got %d", v)
+	}
+}
@@ -0,0 +1,48 @@
+package memcache
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// flagsSize is the size of the client flags header stored before the data.
+const flagsSize = 4
+
+// relativeExptimeLimit is the largest exptime treated as relative seconds;
+// larger values are absolute Unix timestamps (30 days, as in memcached).
+const relativeExptimeLimit = 60 * 60 * 24 * 30
+
+// encodeItem returns the cache value for data with client flags.
+func encodeItem(flags uint32, data []byte) []byte {
+	v := make([]byte, flagsSize+len(data))
+	binary.BigEndian.PutUint32(v, flags)
+	copy(v[flagsSize:], data)
+	return v
+}
+
+// decodeItem splits a cache value into client flags and data. A value too
+// short for the header was not written by this package and is returned as
+// data with flags 0.
+func decodeItem(v []byte) (flags uint32, data []byte) {
+	if len(v) < flagsSize {
+		return 0, v
+	}
+	return binary.BigEndian.Uint32(v), v[flagsSize:]
+}
+
+// exptimeTTL converts a memcached exptime to a TTL. expired is true for
+// negative exptimes and absolute times in the past, which memcached treats
+// as already expired.
+func exptimeTTL(exptime int64) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= relativeExptimeLimit:
+		return time.Duration(exptime) * time.Second, false
+	default:
+		ttl = time.Until(time.Unix(exptime, 0))
+		return ttl, ttl <= 0
+	}
+}
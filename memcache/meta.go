@@ -0,0 +1,371 @@
+package memcache
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// The meta commands and the flags they accept. Flags outside these sets,
+// such as base64 keys (b), invalidation (I) and the stale-while-revalidate
+// flags, are rejected with "CLIENT_ERROR invalid flag".
+//
+//	mg <key> <flags>*          c f k O q s t v T
+//	ms <key> <datalen> <flags>* F k O q T C M(S E A P R)
+//	md <key> <flags>*          C k O q
+//	ma <key> <flags>*          D J N k O q t v T M(I + D -)
+//	mn
+const (
+	metaGetFlags    = "cfkOqstvT"
+	metaSetFlags    = "FkOqTCM"
+	metaDeleteFlags = "CkOq"
+	metaArithFlags  = "DJNkOqtvTM"
+)
+
+// metaFlag is one flag of a meta command: a letter and an optional token.
+type metaFlag struct {
+	flag  byte
+	token string
+}
+
+// parseMetaFlags parses flag tokens, accepting only the letters in allowed.
+func parseMetaFlags(args [][]byte, allowed string) ([]metaFlag, bool) {
+	flags := make([]metaFlag, 0, len(args))
+	for _, arg := range args {
+		if bytes.IndexByte([]byte(allowed), arg[0]) < 0 {
+			return nil, false
+		}
+		flags = append(flags, metaFlag{flag: arg[0], token: string(arg[1:])})
+	}
+	return flags, true
+}
+
+// lookupFlag returns the token of flag f and whether it is present.
+func lookupFlag(flags []metaFlag, f byte) (string, bool) {
+	for _, mf := range flags {
+		if mf.flag == f {
+			return mf.token, true
+		}
+	}
+	return "", false
+}
+
+// numericFlag parses the token of flag f as an integer, returning def if
+// the flag is absent.
+func numericFlag(flags []metaFlag, f byte, def int64) (int64, bool) {
+	tok, ok := lookupFlag(flags, f)
+	if !ok {
+		return def, true
+	}
+	n, err := strconv.ParseInt(tok, 10, 64)
+	return n, err == nil
+}
+
+// writeMeta writes a meta status line with the requested return flags.
+// value returns the value of a return flag, or false if f returns nothing;
+// the k and O flags are handled here.
+func writeMeta(c *conn, status, key string, flags []metaFlag, value func(f byte) (string, bool)) {
+	c.bw.WriteString(status)
+	for _, mf := range flags {
+		var v string
+		switch mf.flag {
+		case 'k':
+			v = key
+		case 'O':
+			v = mf.token
+		default:
+			var ok bool
+			if value == nil {
+				continue
+			}
+			if v, ok = value(mf.flag); !ok {
+				continue
+			}
+		}
+		c.bw.WriteByte(' ')
+		c.bw.WriteByte(mf.flag)
+		c.bw.WriteString(v)
+	}
+	c.bw.WriteString("\r\n")
+}
+
+// remainingTTL returns the remaining TTL of key in whole seconds, rounded
+// up, or -1 if the key does not expire.
+func (s *Server) remainingTTL(key string) string {
+	ttl, ok := s.cache.TTL(key)
+	if !ok || ttl <= 0 {
+		return "-1"
+	}
+	secs := (ttl + time.Second - 1) / time.Second
+	return strconv.FormatInt(int64(secs), 10)
+}
+
+// metaGet implements mg.
+func (s *Server) metaGet(c *conn, args [][]byte) {
+	if len(args) == 0 || !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return
+	}
+	key := string(args[0])
+	flags, ok := parseMetaFlags(args[1:], metaGetFlags)
+	if !ok {
+		clientError(c, "invalid flag")
+		return
+	}
+	exptime, ok := numericFlag(flags, 'T', 0)
+	if !ok {
+		clientError(c, "bad token in command line format")
+		return
+	}
+	_, quiet := lookupFlag(flags, 'q')
+	_, touch := lookupFlag(flags, 'T')
+	_, withValue := lookupFlag(flags, 'v')
+
+	s.cmdGet.Add(1)
+	if touch {
+		s.cmdTouch.Add(1)
+		s.touchKey(key, exptime)
+	}
+	v, ver, found := s.cache.GetWithVersion(key)
+	if !found {
+		if !quiet {
+			writeMeta(c, "EN", key, flags, nil)
+		}
+		return
+	}
+
+	itemFlags, data := decodeItem(v)
+	ret := func(f byte) (string, bool) {
+		switch f {
+		case 'c':
+			return strconv.FormatUint(ver, 10), true
+		case 'f':
+			return strconv.FormatUint(uint64(itemFlags), 10), true
+		case 's':
+			return strconv.Itoa(len(data)), true
+		case 't':
+			return s.remainingTTL(key), true
+		}
+		return "", false
+	}
+	if !withValue {
+		writeMeta(c, "HD", key, flags, ret)
+		return
+	}
+	writeMeta(c, "VA "+strconv.Itoa(len(data)), key, flags, ret)
+	c.bw.Write(data)
+	c.bw.WriteString("\r\n")
+}
+
+// metaSet implements ms. The data block is always consumed, even when the
+// flags are invalid, so the connection stays in sync.
+func (s *Server) metaSet(c *conn, args [][]byte) error {
+	if len(args) < 2 {
+		clientError(c, "bad command line format")
+		return nil
+	}
+	size, err := strconv.Atoi(string(args[1]))
+	if err != nil || size < 0 {
+		clientError(c, "bad data chunk")
+		return nil
+	}
+	if size > maxItemSize {
+		if _, err := c.br.Discard(size + 2); err != nil {
+			return err
+		}
+		c.bw.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return nil
+	}
+	data, err := c.readData(size)
+	if err == errBadChunk {
+		clientError(c, "bad data chunk")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return nil
+	}
+	key := string(args[0])
+	flags, ok := parseMetaFlags(args[2:], metaSetFlags)
+	if !ok {
+		clientError(c, "invalid flag")
+		return nil
+	}
+	itemFlags, ok1 := numericFlag(flags, 'F', 0)
+	exptime, ok2 := numericFlag(flags, 'T', 0)
+	cas, ok3 := numericFlag(flags, 'C', 0)
+	if !ok1 || !ok2 || !ok3 || itemFlags < 0 || itemFlags > 1<<32-1 {
+		clientError(c, "bad token in command line format")
+		return nil
+	}
+	mode := modeSet
+	if tok, ok := lookupFlag(flags, 'M'); ok {
+		switch tok {
+		case "S", "s":
+			mode = modeSet
+		case "E", "e":
+			mode = modeAdd
+		case "A", "a":
+			mode = modeAppend
+		case "P", "p":
+			mode = modePrepend
+		case "R", "r":
+			mode = modeReplace
+		default:
+			clientError(c, "invalid mode for ms")
+			return nil
+		}
+	}
+	_, withCAS := lookupFlag(flags, 'C')
+	if withCAS && mode != modeSet {
+		clientError(c, "invalid mode for ms")
+		return nil
+	}
+
+	s.cmdSet.Add(1)
+	var result storeResult
+	if withCAS {
+		result = s.compareAndSwap(key, uint32(itemFlags), exptime, data, uint64(cas))
+	} else {
+		result = s.storeItem(mode, key, uint32(itemFlags), exptime, data)
+	}
+
+	_, quiet := lookupFlag(flags, 'q')
+	switch result {
+	case resultStored:
+		if !quiet {
+			writeMeta(c, "HD", key, flags, nil)
+		}
+	case resultNotStored:
+		writeMeta(c, "NS", key, flags, nil)
+	case resultExists:
+		writeMeta(c, "EX", key, flags, nil)
+	case resultNotFound:
+		writeMeta(c, "NF", key, flags, nil)
+	}
+	return nil
+}
+
+// metaDelete implements md.
+func (s *Server) metaDelete(c *conn, args [][]byte) {
+	if len(args) == 0 || !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return
+	}
+	key := string(args[0])
+	flags, ok := parseMetaFlags(args[1:], metaDeleteFlags)
+	if !ok {
+		clientError(c, "invalid flag")
+		return
+	}
+	cas, ok := numericFlag(flags, 'C', 0)
+	if !ok {
+		clientError(c, "bad token in command line format")
+		return
+	}
+	_, withCAS := lookupFlag(flags, 'C')
+
+	status := func() string {
+		defer s.locks.Lock(key)()
+		if withCAS {
+			deleted, found := s.cache.CompareAndDeleteVersion(key, uint64(cas))
+			switch {
+			case !found:
+				return "NF"
+			case !deleted:
+				return "EX"
+			}
+			return "HD"
+		}
+		if s.cache.Delete(key) {
+			return "HD"
+		}
+		return "NF"
+	}()
+
+	if _, quiet := lookupFlag(flags, 'q'); quiet && status != "EX" {
+		return
+	}
+	writeMeta(c, status, key, flags, nil)
+}
+
+// metaArithmetic implements ma. With N, a missing key is created with the
+// J initial value (default 0) and the N TTL.
+func (s *Server) metaArithmetic(c *conn, args [][]byte) {
+	if len(args) == 0 || !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return
+	}
+	key := string(args[0])
+	flags, ok := parseMetaFlags(args[1:], metaArithFlags)
+	if !ok {
+		clientError(c, "invalid flag")
+		return
+	}
+	delta, ok1 := numericFlag(flags, 'D', 1)
+	initial, ok2 := numericFlag(flags, 'J', 0)
+	vivify, ok3 := numericFlag(flags, 'N', 0)
+	exptime, ok4 := numericFlag(flags, 'T', 0)
+	if !ok1 || !ok2 || !ok3 || !ok4 || delta < 0 || initial < 0 {
+		clientError(c, "bad token in command line format")
+		return
+	}
+	up := true
+	if tok, ok := lookupFlag(flags, 'M'); ok {
+		switch tok {
+		case "I", "i", "+":
+		case "D", "d", "-":
+			up = false
+		default:
+			clientError(c, "invalid mode for ma")
+			return
+		}
+	}
+
+	n, result := s.applyDelta(key, uint64(delta), up)
+	if _, ok := lookupFlag(flags, 'N'); ok && result == resultNotFound {
+		value := strconv.AppendInt(nil, initial, 10)
+		if s.storeItem(modeAdd, key, 0, vivify, value) == resultStored {
+			n, result = uint64(initial), resultStored
+		} else {
+			// Created concurrently by another client.
+			n, result = s.applyDelta(key, uint64(delta), up)
+		}
+	}
+	if _, touch := lookupFlag(flags, 'T'); touch && result == resultStored {
+		s.touchKey(key, exptime)
+	}
+
+	_, quiet := lookupFlag(flags, 'q')
+	switch result {
+	case resultNotFound:
+		if !quiet {
+			writeMeta(c, "NF", key, flags, nil)
+		}
+		return
+	case resultNotStored:
+		clientError(c, "cannot increment or decrement non-numeric value")
+		return
+	}
+
+	ret := func(f byte) (string, bool) {
+		if f == 't' {
+			return s.remainingTTL(key), true
+		}
+		return "", false
+	}
+	if _, withValue := lookupFlag(flags, 'v'); !withValue {
+		if !quiet {
+			writeMeta(c, "HD", key, flags, ret)
+		}
+		return
+	}
+	value := strconv.FormatUint(n, 10)
+	writeMeta(c, "VA "+strconv.Itoa(len(value)), key, flags, ret)
+	c.bw.WriteString(value)
+	c.bw.WriteString("\r\n")
+}
@@ -0,0 +1,152 @@
+// Package memcache serves a Cache[string, []byte] over the memcached text
+// and meta protocols, for legacy clients that speak memcached.
+//
+// Text commands: get, gets, set, add, replace, append, prepend, cas,
+// delete, incr, decr, touch, flush_all, stats, version, verbosity and quit.
+// Meta commands: mg, ms, md, ma and mn with the common flags (see
+// meta.go). The deprecated binary protocol is not supported.
+//
+// Client flags are stored in a 4-byte header in front of the data, so a
+// cache served by this package should not be shared with other writers.
+// CAS values are the entry versions from Cache.GetWithVersion. Writes to a
+// key are serialized by the Server, so add, replace, append, prepend,
+// incr and decr are atomic with respect to other clients of the same
+// Server.
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+	"github.com/OrlovEvgeny/go-mcache/internal/netserver"
+)
+
+const (
+	// maxKeyLen is the longest key memcached accepts.
+	maxKeyLen = 250
+
+	// maxItemSize bounds the data of one item (memcached's default -I).
+	maxItemSize = 1 << 20
+
+	// maxLineLen bounds a command line.
+	maxLineLen = 8 << 10
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("memcache: server closed")
+
+// Server serves a cache over the memcached protocols.
+type Server struct {
+	cache   *mcache.Cache[string, []byte]
+	base    *netserver.Server
+	locks   netserver.Stripes // Serialize writes per key
+	started time.Time
+
+	mu    sync.Mutex  // Guards flush
+	flush *time.Timer // Pending flush_all with a delay
+
+	cmdGet    atomic.Int64
+	cmdSet    atomic.Int64
+	cmdTouch  atomic.Int64
+	casHits   atomic.Int64
+	casMisses atomic.Int64
+	casBadval atomic.Int64
+}
+
+// New returns a Server for cache. The caller keeps ownership of the cache
+// and closes it after the server.
+func New(cache *mcache.Cache[string, []byte]) *Server {
+	return &Server{
+		cache:   cache,
+		base:    netserver.New(ErrServerClosed),
+		started: time.Now(),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.base.ListenAndServe(addr, s.serveConn)
+}
+
+// Serve accepts connections on ln until Close is called, when it returns
+// ErrServerClosed. ln is closed on return.
+func (s *Server) Serve(ln net.Listener) error {
+	return s.base.Serve(ln, s.serveConn)
+}
+
+// Close stops all listeners, closes all connections, waits for the
+// handlers to return and cancels a pending delayed flush_all.
+func (s *Server) Close() error {
+	s.base.Close()
+
+	s.mu.Lock()
+	if s.flush != nil {
+		s.flush.Stop()
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// conn is the state of one client connection.
+type conn struct {
+	br   *bufio.Reader
+	bw   *bufio.Writer
+	quit bool
+}
+
+// serveConn reads and executes commands until the client disconnects.
+// Replies to pipelined commands are flushed together.
+func (s *Server) serveConn(nc net.Conn) {
+	c := &conn{
+		br: bufio.NewReaderSize(nc, maxLineLen),
+		bw: bufio.NewWriterSize(nc, 16<<10),
+	}
+	for !c.quit {
+		line, err := c.br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			c.bw.WriteString("CLIENT_ERROR line too long\r\n")
+			c.bw.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if err := s.execute(c, trimLine(line)); err != nil {
+			c.bw.Flush()
+			return
+		}
+		if c.br.Buffered() == 0 {
+			if err := c.bw.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	c.bw.Flush()
+}
+
+// trimLine strips the LF or CRLF terminator.
+func trimLine(line []byte) []byte {
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
+
+// readData reads a data block of n bytes followed by CRLF.
+func (c *conn) readData(n int) ([]byte, error) {
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		return nil, err
+	}
+	if data[n] != '\r' || data[n+1] != '\n' {
+		return nil, errBadChunk
+	}
+	return data[:n:n], nil
+}
@@ -0,0 +1,257 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// client is a minimal memcached text client for tests.
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	br *bufio.Reader
+}
+
+func startServer(t *testing.T, opts ...mcache.Option[string, []byte]) (*client, *mcache.Cache[string, []byte]) {
+	t.Helper()
+	c, cache, _ := startServerWith(t, opts...)
+	return c, cache
+}
+
+// startServerWith is startServer also returning the Server.
+func startServerWith(t *testing.T, opts ...mcache.Option[string, []byte]) (*client, *mcache.Cache[string, []byte], *Server) {
+	t.Helper()
+	cache := mcache.NewCache[string, []byte](opts...)
+	srv := New(cache)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		srv.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+		cache.Close()
+	})
+	return &client{t: t, nc: nc, br: bufio.NewReader(nc)}, cache, srv
+}
+
+// send writes lines, each terminated by CRLF.
+func (c *client) send(lines ...string) {
+	c.t.Helper()
+	if _, err := c.nc.Write([]byte(strings.Join(lines, "\r\n") + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// line reads one reply line without its terminator.
+func (c *client) line() string {
+	c.t.Helper()
+	c.nc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+// expect sends lines and checks the reply lines.
+func (c *client) expect(send []string, want ...string) {
+	c.t.Helper()
+	c.send(send...)
+	for _, w := range want {
+		if got := c.line(); got != w {
+			c.t.Fatalf("%q: expected %q, got %q", send, w, got)
+		}
+	}
+}
+
+func TestStorageCommands(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect([]string{"set foo 42 0 3", "bar"}, "STORED")
+	c.expect([]string{"get foo"}, "VALUE foo 42 3", "bar", "END")
+	c.expect([]string{"get missing"}, "END")
+
+	c.expect([]string{"add foo 0 0 1", "x"}, "NOT_STORED")
+	c.expect([]string{"add new 0 0 1", "x"}, "STORED")
+	c.expect([]string{"replace absent 0 0 1", "x"}, "NOT_STORED")
+	c.expect([]string{"replace new 7 0 1", "y"}, "STORED")
+
+	c.expect([]string{"append foo 0 0 2", "!!"}, "STORED")
+	c.expect([]string{"prepend foo 0 0 2", ">>"}, "STORED")
+	c.expect([]string{"get foo new"}, "VALUE foo 42 7", ">>bar!!", "VALUE new 7 1", "y", "END")
+	c.expect([]string{"append absent 0 0 1", "x"}, "NOT_STORED")
+
+	c.expect([]string{"delete foo"}, "DELETED")
+	c.expect([]string{"delete foo"}, "NOT_FOUND")
+
+	c.expect([]string{"set quiet 0 0 1 noreply", "q", "get quiet"}, "VALUE quiet 0 1", "q", "END")
+	c.expect([]string{"set gone 0 -1 1", "x", "get gone"}, "STORED", "END")
+}
+
+func TestCAS(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect([]string{"set k 0 0 1", "a"}, "STORED")
+	c.send("gets k")
+	var cas uint64
+	if _, err := fmt.Sscanf(c.line(), "VALUE k 0 1 %d", &cas); err != nil {
+		t.Fatal(err)
+	}
+	c.line()
+	c.line()
+
+	c.expect([]string{fmt.Sprintf("cas k 0 0 1 %d", cas+1), "b"}, "EXISTS")
+	c.expect([]string{fmt.Sprintf("cas k 0 0 1 %d", cas), "b"}, "STORED")
+	c.expect([]string{fmt.Sprintf("cas k 0 0 1 %d", cas), "c"}, "EXISTS")
+	c.expect([]string{"cas missing 0 0 1 1", "c"}, "NOT_FOUND")
+	c.expect([]string{"get k"}, "VALUE k 0 1", "b", "END")
+}
+
+func TestIncrDecrTouch(t *testing.T) {
+	c, cache := startServer(t)
+
+	c.expect([]string{"set n 5 100 2", "10"}, "STORED")
+	c.expect([]string{"incr n 5"}, "15")
+	c.expect([]string{"decr n 20"}, "0")
+	c.expect([]string{"incr n 18446744073709551615"}, "18446744073709551615")
+	c.expect([]string{"incr n 1"}, "0")
+	c.expect([]string{"get n"}, "VALUE n 5 1", "0", "END")
+	if ttl, _ := cache.TTL("n"); ttl <= 0 || ttl > 100*time.Second {
+		t.Errorf("Expected incr to keep the TTL, got %v", ttl)
+	}
+
+	c.expect([]string{"incr missing 1"}, "NOT_FOUND")
+	c.expect([]string{"set s 0 0 3", "abc", "incr s 1"}, "STORED", "CLIENT_ERROR cannot increment or decrement non-numeric value")
+	c.expect([]string{"incr n x"}, "CLIENT_ERROR invalid numeric delta argument")
+
+	c.expect([]string{"touch n 0"}, "TOUCHED")
+	if ttl, ok := cache.TTL("n"); !ok || ttl != 0 {
+		t.Errorf("Expected touch 0 to remove the TTL, got %v, %v", ttl, ok)
+	}
+	c.expect([]string{"touch missing 10"}, "NOT_FOUND")
+}
+
+func TestRewriteKeepsNoExpiry(t *testing.T) {
+	c, cache := startServer(t, mcache.WithDefaultTTL[string, []byte](time.Hour))
+
+	c.expect([]string{"set n 0 0 1", "1", "touch n 0"}, "STORED", "TOUCHED")
+	c.expect([]string{"incr n 1"}, "2")
+	c.expect([]string{"append n 0 0 1", "0"}, "STORED")
+	c.expect([]string{"get n"}, "VALUE n 0 2", "20", "END")
+	if ttl, ok := cache.TTL("n"); !ok || ttl != 0 {
+		t.Errorf("Expected incr and append to keep no expiry, got %v, %v", ttl, ok)
+	}
+}
+
+func TestDelayedFlushAll(t *testing.T) {
+	c, cache, srv := startServerWith(t)
+
+	c.expect([]string{"set a 0 0 1", "1", "flush_all 100"}, "STORED", "OK")
+	c.expect([]string{"get a"}, "VALUE a 0 1", "1", "END")
+	srv.mu.Lock()
+	flush := srv.flush
+	srv.mu.Unlock()
+	if flush == nil {
+		t.Fatal("Expected a pending flush")
+	}
+
+	// A later flush_all replaces the pending one.
+	c.expect([]string{"flush_all 200"}, "OK")
+	if flush.Stop() {
+		t.Error("Expected a new flush_all to cancel the pending one")
+	}
+	srv.mu.Lock()
+	flush = srv.flush
+	srv.mu.Unlock()
+
+	srv.Close()
+	if flush.Stop() {
+		t.Error("Expected Close to cancel the pending flush")
+	}
+	if !cache.Has("a") {
+		t.Error("Expected a to stay until the flush is due")
+	}
+}
+
+func TestStatsAndMisc(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect([]string{"set a 0 0 1", "1", "get a b"}, "STORED", "VALUE a 0 1", "1", "END")
+	c.send("stats")
+	stats := make(map[string]string)
+	for {
+		line := c.line()
+		if line == "END" {
+			break
+		}
+		f := strings.Fields(line)
+		if len(f) != 3 || f[0] != "STAT" {
+			t.Fatalf("Expected STAT line, got %q", line)
+		}
+		stats[f[1]] = f[2]
+	}
+	for name, want := range map[string]string{
+		"get_hits": "1", "get_misses": "1", "cmd_get": "2", "cmd_set": "1",
+		"curr_items": "1", "curr_connections": "1",
+	} {
+		if stats[name] != want {
+			t.Errorf("Expected %s %s, got %q", name, want, stats[name])
+		}
+	}
+
+	c.expect([]string{"version"}, "VERSION "+version)
+	c.expect([]string{"bogus"}, "ERROR")
+	c.expect([]string{"flush_all", "get a"}, "OK", "END")
+
+	c.send("quit")
+	if _, err := c.br.ReadByte(); err == nil {
+		t.Error("Expected the connection to be closed after quit")
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect([]string{"ms foo 3 F9 T60", "bar"}, "HD")
+	c.expect([]string{"mg foo v f s t k Oabc"}, "VA 3 f9 s3 t60 kfoo Oabc", "bar")
+	c.expect([]string{"mg missing v Oxy"}, "EN Oxy")
+	c.expect([]string{"mg missing v q", "mn"}, "MN")
+
+	c.send("mg foo c")
+	var cas uint64
+	if _, err := fmt.Sscanf(c.line(), "HD c%d", &cas); err != nil {
+		t.Fatal(err)
+	}
+	c.expect([]string{fmt.Sprintf("ms foo 1 C%d", cas+1), "x"}, "EX")
+	c.expect([]string{fmt.Sprintf("ms foo 1 C%d", cas), "x"}, "HD")
+	c.expect([]string{"ms foo 1 ME", "y"}, "NS")
+	c.expect([]string{"ms foo 1 MA", "z"}, "HD")
+	c.expect([]string{"mg foo v"}, "VA 2", "xz")
+
+	c.expect([]string{"ma cnt"}, "NF")
+	c.expect([]string{"ma cnt N0 J10 v"}, "VA 2", "10")
+	c.expect([]string{"ma cnt D5 v"}, "VA 2", "15")
+	c.expect([]string{"ma cnt MD D20 v"}, "VA 1", "0")
+
+	c.expect([]string{"md foo C1"}, "EX")
+	c.expect([]string{"md foo q", "md foo", "mn"}, "NF", "MN")
+	c.expect([]string{"mg foo x"}, "CLIENT_ERROR invalid flag")
+}
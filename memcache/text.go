@@ -0,0 +1,447 @@
+package memcache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// version is reported by the version and stats commands.
+const version = "1.6.0"
+
+// errBadChunk is returned when a data block is not terminated by CRLF; the
+// connection is closed because the stream can no longer be parsed.
+var errBadChunk = errors.New("memcache: bad data chunk")
+
+// storeMode selects the semantics of a storage command.
+type storeMode int
+
+const (
+	modeSet storeMode = iota
+	modeAdd
+	modeReplace
+	modeAppend
+	modePrepend
+)
+
+// storeResult is the outcome of a storage command.
+type storeResult int
+
+const (
+	resultStored storeResult = iota
+	resultNotStored
+	resultExists   // CAS mismatch
+	resultNotFound // CAS on a missing key
+)
+
+var storeModes = map[string]storeMode{
+	"set":     modeSet,
+	"add":     modeAdd,
+	"replace": modeReplace,
+	"append":  modeAppend,
+	"prepend": modePrepend,
+	"cas":     modeSet,
+}
+
+// execute runs one command line. A returned error closes the connection.
+func (s *Server) execute(c *conn, line []byte) error {
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		c.bw.WriteString("ERROR\r\n")
+		return nil
+	}
+	name, args := string(fields[0]), fields[1:]
+
+	switch name {
+	case "get", "gets":
+		s.get(c, args, name == "gets")
+	case "set", "add", "replace", "append", "prepend", "cas":
+		return s.storage(c, name, args)
+	case "delete":
+		s.delete(c, args)
+	case "incr", "decr":
+		s.incr(c, args, name == "incr")
+	case "touch":
+		s.touch(c, args)
+	case "flush_all":
+		s.flushAll(c, args)
+	case "stats":
+		s.stats(c, args)
+	case "version":
+		c.bw.WriteString("VERSION " + version + "\r\n")
+	case "verbosity":
+		reply(c, args, 1, "OK")
+	case "quit":
+		c.quit = true
+	case "mg":
+		s.metaGet(c, args)
+	case "ms":
+		return s.metaSet(c, args)
+	case "md":
+		s.metaDelete(c, args)
+	case "ma":
+		s.metaArithmetic(c, args)
+	case "mn":
+		c.bw.WriteString("MN\r\n")
+	default:
+		c.bw.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+// reply writes msg unless args[noreplyAt] is "noreply".
+func reply(c *conn, args [][]byte, noreplyAt int, msg string) {
+	if len(args) > noreplyAt && string(args[noreplyAt]) == "noreply" {
+		return
+	}
+	c.bw.WriteString(msg)
+	c.bw.WriteString("\r\n")
+}
+
+func clientError(c *conn, msg string) {
+	c.bw.WriteString("CLIENT_ERROR " + msg + "\r\n")
+}
+
+func validKey(key []byte) bool {
+	return len(key) > 0 && len(key) <= maxKeyLen
+}
+
+// get implements get and gets <key>*.
+func (s *Server) get(c *conn, keys [][]byte, withCAS bool) {
+	if len(keys) == 0 {
+		c.bw.WriteString("ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			clientError(c, "bad command line format")
+			return
+		}
+	}
+	for _, key := range keys {
+		s.cmdGet.Add(1)
+		v, ver, ok := s.cache.GetWithVersion(string(key))
+		if !ok {
+			continue
+		}
+		flags, data := decodeItem(v)
+		c.bw.WriteString("VALUE ")
+		c.bw.Write(key)
+		if withCAS {
+			fmt.Fprintf(c.bw, " %d %d %d\r\n", flags, len(data), ver)
+		} else {
+			fmt.Fprintf(c.bw, " %d %d\r\n", flags, len(data))
+		}
+		c.bw.Write(data)
+		c.bw.WriteString("\r\n")
+	}
+	c.bw.WriteString("END\r\n")
+}
+
+// storage implements the storage commands:
+//
+//	<set|add|replace|append|prepend> <key> <flags> <exptime> <bytes> [noreply]
+//	cas <key> <flags> <exptime> <bytes> <cas unique> [noreply]
+func (s *Server) storage(c *conn, name string, args [][]byte) error {
+	n := 4
+	if name == "cas" {
+		n = 5
+	}
+	if len(args) < n || len(args) > n+1 || !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return nil
+	}
+	flags, err1 := strconv.ParseUint(string(args[1]), 10, 32)
+	exptime, err2 := strconv.ParseInt(string(args[2]), 10, 64)
+	size, err3 := strconv.Atoi(string(args[3]))
+	var cas uint64
+	var err4 error
+	if name == "cas" {
+		cas, err4 = strconv.ParseUint(string(args[4]), 10, 64)
+	}
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || size < 0 {
+		clientError(c, "bad command line format")
+		return nil
+	}
+
+	if size > maxItemSize {
+		if _, err := c.br.Discard(size + 2); err != nil {
+			return err
+		}
+		c.bw.WriteString("SERVER_ERROR object too large for cache\r\n")
+		return nil
+	}
+	data, err := c.readData(size)
+	if err == errBadChunk {
+		clientError(c, "bad data chunk")
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	s.cmdSet.Add(1)
+	var result storeResult
+	if name == "cas" {
+		result = s.compareAndSwap(string(args[0]), uint32(flags), exptime, data, cas)
+	} else {
+		result = s.storeItem(storeModes[name], string(args[0]), uint32(flags), exptime, data)
+	}
+	reply(c, args, n, textResult(result))
+	return nil
+}
+
+func textResult(r storeResult) string {
+	switch r {
+	case resultStored:
+		return "STORED"
+	case resultExists:
+		return "EXISTS"
+	case resultNotFound:
+		return "NOT_FOUND"
+	default:
+		return "NOT_STORED"
+	}
+}
+
+// storeItem applies a storage command under the key's lock.
+// An exptime of 0 stores the item with the cache's default TTL, if any.
+func (s *Server) storeItem(mode storeMode, key string, flags uint32, exptime int64, data []byte) storeResult {
+	defer s.locks.Lock(key)()
+
+	switch mode {
+	case modeAdd:
+		if s.cache.Has(key) {
+			return resultNotStored
+		}
+	case modeReplace:
+		if !s.cache.Has(key) {
+			return resultNotStored
+		}
+	case modeAppend, modePrepend:
+		// The existing flags and expiration are kept.
+		cur, ok := s.cache.Get(key)
+		if !ok {
+			return resultNotStored
+		}
+		curFlags, curData := decodeItem(cur)
+		if mode == modeAppend {
+			data = append(bytes.Clone(curData), data...)
+		} else {
+			data = append(data, curData...)
+		}
+		s.rewrite(key, curFlags, data)
+		return resultStored
+	}
+
+	ttl, expired := exptimeTTL(exptime)
+	if expired {
+		s.cache.Delete(key)
+		return resultStored
+	}
+	s.cache.SetWithCost(key, encodeItem(flags, data), 0, ttl)
+	return resultStored
+}
+
+// compareAndSwap stores the item only if the key's version is cas.
+func (s *Server) compareAndSwap(key string, flags uint32, exptime int64, data []byte, cas uint64) storeResult {
+	defer s.locks.Lock(key)()
+
+	ttl, expired := exptimeTTL(exptime)
+	swapped, found := s.cache.CompareAndSwapVersion(key, encodeItem(flags, data), cas, ttl)
+	switch {
+	case !found:
+		s.casMisses.Add(1)
+		return resultNotFound
+	case !swapped:
+		s.casBadval.Add(1)
+		return resultExists
+	}
+	s.casHits.Add(1)
+	if expired {
+		s.cache.Delete(key)
+	}
+	return resultStored
+}
+
+// rewrite replaces the item at key, keeping its expiration, or lack of
+// one. The caller holds the key's lock.
+func (s *Server) rewrite(key string, flags uint32, data []byte) {
+	s.cache.ComputeIfPresent(key, func([]byte) ([]byte, mcache.ComputeOp) {
+		return encodeItem(flags, data), mcache.ComputeSet
+	})
+}
+
+// delete implements delete <key> [0] [noreply].
+func (s *Server) delete(c *conn, args [][]byte) {
+	noreplyAt := 1
+	if len(args) > 1 && string(args[1]) == "0" {
+		noreplyAt = 2
+	}
+	if len(args) == 0 || len(args) > noreplyAt+1 || !validKey(args[0]) {
+		clientError(c, "bad command line format. Usage: delete <key> [noreply]")
+		return
+	}
+	key := string(args[0])
+
+	unlock := s.locks.Lock(key)
+	deleted := s.cache.Delete(key)
+	unlock()
+
+	if deleted {
+		reply(c, args, noreplyAt, "DELETED")
+	} else {
+		reply(c, args, noreplyAt, "NOT_FOUND")
+	}
+}
+
+// incr implements incr and decr <key> <value> [noreply].
+func (s *Server) incr(c *conn, args [][]byte, up bool) {
+	if len(args) < 2 || len(args) > 3 || !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return
+	}
+	delta, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		clientError(c, "invalid numeric delta argument")
+		return
+	}
+
+	value, result := s.applyDelta(string(args[0]), delta, up)
+	switch result {
+	case resultNotFound:
+		reply(c, args, 2, "NOT_FOUND")
+	case resultNotStored:
+		clientError(c, "cannot increment or decrement non-numeric value")
+	default:
+		reply(c, args, 2, strconv.FormatUint(value, 10))
+	}
+}
+
+// applyDelta increments or decrements a decimal item. Increments wrap at
+// 64 bits and decrements stop at 0, as in memcached. It returns
+// resultNotFound for a missing key and resultNotStored for a non-numeric
+// value.
+func (s *Server) applyDelta(key string, delta uint64, up bool) (uint64, storeResult) {
+	defer s.locks.Lock(key)()
+
+	cur, ok := s.cache.Get(key)
+	if !ok {
+		return 0, resultNotFound
+	}
+	flags, data := decodeItem(cur)
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(data)), 10, 64)
+	if err != nil {
+		return 0, resultNotStored
+	}
+	if up {
+		n += delta
+	} else {
+		n -= min(n, delta)
+	}
+	s.rewrite(key, flags, strconv.AppendUint(nil, n, 10))
+	return n, resultStored
+}
+
+// touch implements touch <key> <exptime> [noreply].
+func (s *Server) touch(c *conn, args [][]byte) {
+	if len(args) < 2 || len(args) > 3 || !validKey(args[0]) {
+		clientError(c, "bad command line format")
+		return
+	}
+	exptime, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		clientError(c, "invalid exptime argument")
+		return
+	}
+	s.cmdTouch.Add(1)
+	if s.touchKey(string(args[0]), exptime) {
+		reply(c, args, 2, "TOUCHED")
+	} else {
+		reply(c, args, 2, "NOT_FOUND")
+	}
+}
+
+// touchKey updates the expiration of key. An exptime of 0 removes it.
+func (s *Server) touchKey(key string, exptime int64) bool {
+	defer s.locks.Lock(key)()
+
+	ttl, expired := exptimeTTL(exptime)
+	if expired {
+		return s.cache.Delete(key)
+	}
+	return s.cache.Expire(key, ttl)
+}
+
+// flushAll implements flush_all [delay] [noreply].
+func (s *Server) flushAll(c *conn, args [][]byte) {
+	noreplyAt := 0
+	var delay int64
+	if len(args) > 0 && string(args[0]) != "noreply" {
+		var err error
+		if delay, err = strconv.ParseInt(string(args[0]), 10, 64); err != nil || delay < 0 {
+			clientError(c, "bad command line format")
+			return
+		}
+		noreplyAt = 1
+	}
+	// A flush replaces a delayed one that is still pending.
+	s.mu.Lock()
+	if s.flush != nil {
+		s.flush.Stop()
+		s.flush = nil
+	}
+	if delay > 0 {
+		s.flush = time.AfterFunc(time.Duration(delay)*time.Second, s.cache.Clear)
+	}
+	s.mu.Unlock()
+	if delay == 0 {
+		s.cache.Clear()
+	}
+	reply(c, args, noreplyAt, "OK")
+}
+
+// stats implements the general-purpose stats command; statistics groups
+// such as "stats items" return an empty list.
+func (s *Server) stats(c *conn, args [][]byte) {
+	if len(args) > 0 {
+		c.bw.WriteString("END\r\n")
+		return
+	}
+
+	m := s.cache.Metrics()
+	now := time.Now()
+	stat := func(name string, value any) {
+		fmt.Fprintf(c.bw, "STAT %s %v\r\n", name, value)
+	}
+	stat("pid", os.Getpid())
+	stat("uptime", int64(now.Sub(s.started).Seconds()))
+	stat("time", now.Unix())
+	stat("version", version)
+	stat("pointer_size", strconv.IntSize)
+	stat("threads", runtime.GOMAXPROCS(0))
+	stat("curr_connections", s.base.NumConns())
+	stat("total_connections", s.base.TotalConns())
+	stat("cmd_get", s.cmdGet.Load())
+	stat("cmd_set", s.cmdSet.Load())
+	stat("cmd_touch", s.cmdTouch.Load())
+	stat("get_hits", m.Hits)
+	stat("get_misses", m.Misses)
+	stat("cas_misses", s.casMisses.Load())
+	stat("cas_hits", s.casHits.Load())
+	stat("cas_badval", s.casBadval.Load())
+	stat("curr_items", s.cache.Len())
+	stat("total_items", m.Sets)
+	stat("evictions", m.Evictions)
+	stat("expired", m.Expirations)
+	stat("rejections", m.Rejections)
+	stat("deletes", m.Deletes)
+	stat("hit_ratio", strconv.FormatFloat(m.HitRatio, 'f', 4, 64))
+	c.bw.WriteString("END\r\n")
+}
@@ -1,6 +1,9 @@
 package mcache
 
-import "sync/atomic"
+import (
+	"sync"
+	"sync/atomic"
+)
 
 // Metrics holds cache statistics.
 type Metrics struct {
@@ -14,6 +17,31 @@ type Metrics struct {
 	costAdded   atomic.Int64 // Total cost added
 	costEvicted atomic.Int64 // Total cost evicted
 	bufferDrops atomic.Int64 // Buffer saturation drops (sync fallback used)
+
+	cascadeDeletes  atomic.Int64 // Entries deleted because a dependency changed
+	maxCascadeDepth atomic.Int64 // Longest dependency chain deleted at once
+
+	watchDrops atomic.Int64 // Events dropped for lossy watchers that fell behind
+
+	writerErrors atomic.Int64 // Write-behind writes dropped after every retry failed
+
+	l2Hits    atomic.Int64 // L1 misses served by L2 (TieredCache)
+	l2Misses  atomic.Int64 // L1 misses not found in L2 either
+	l2Errors  atomic.Int64 // Failed L2 operations
+	demotions atomic.Int64 // Evicted entries handed to L2
+
+	invalidationsSent     atomic.Int64 // Invalidations published (WithInvalidator)
+	invalidationsReceived atomic.Int64 // Invalidations applied from other caches
+	invalidationErrors    atomic.Int64 // Invalidations that failed to publish or decode
+
+	partitions sync.Map // Partition name -> *partitionMetrics (WithPartitions)
+}
+
+// partitionMetrics holds the counters of one partition.
+type partitionMetrics struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
 }
 
 // MetricsSnapshot is a point-in-time snapshot of cache metrics.
@@ -29,6 +57,39 @@ type MetricsSnapshot struct {
 	CostEvicted int64   // Total cost evicted over time
 	BufferDrops int64   // Times buffer was full and sync fallback was used
 	HitRatio    float64 // Hit ratio (hits / (hits + misses))
+
+	CascadeDeletes  int64 // Total entries deleted because a dependency changed
+	MaxCascadeDepth int64 // Longest chain of dependents deleted by one cascade
+	WatchDrops      int64 // Events dropped because a watcher's buffer was full
+
+	WriterErrors int64 // Write-behind writes dropped because every attempt failed
+
+	L2Hits    int64 // L1 misses served by L2 and promoted (TieredCache)
+	L2Misses  int64 // L1 misses not found in L2 either
+	L2Errors  int64 // Failed L2 reads and writes
+	Demotions int64 // Entries evicted from L1 and written to L2
+
+	InvalidationsSent     int64 // Deletes, Clears and tag invalidations published
+	InvalidationsReceived int64 // Invalidations received from other caches and applied
+	InvalidationErrors    int64 // Invalidations dropped because publishing or decoding failed
+
+	// Partitions holds the metrics of each partition set with
+	// WithPartitions; nil without partitions.
+	Partitions map[string]PartitionMetrics
+
+	// WindowFraction is the share of the capacity given to the admission
+	// window by W-TinyLFU policies; it changes over time with
+	// NewAdaptiveWTinyLFUPolicy. Zero for other policies.
+	WindowFraction float64
+}
+
+// PartitionMetrics is a point-in-time snapshot of the metrics of one
+// partition.
+type PartitionMetrics struct {
+	Hits      int64 // Total cache hits on keys of the partition
+	Misses    int64 // Total cache misses on keys of the partition
+	Evictions int64 // Total evictions of entries of the partition
+	Cost      int64 // Cost of the partition's entries, if the policy tracks it
 }
 
 // newMetrics creates a new Metrics instance.
@@ -116,6 +177,103 @@ func (m *Metrics) incBufferDrop() {
 	m.bufferDrops.Add(1)
 }
 
+// incWatchDrop increments the dropped watch event counter.
+func (m *Metrics) incWatchDrop() {
+	if m == nil {
+		return
+	}
+	m.watchDrops.Add(1)
+}
+
+// addWriterErrors adds to the dropped backing write counter.
+func (m *Metrics) addWriterErrors(n int64) {
+	if m == nil {
+		return
+	}
+	m.writerErrors.Add(n)
+}
+
+// incL2Hit increments the L2 hit counter.
+func (m *Metrics) incL2Hit() {
+	if m == nil {
+		return
+	}
+	m.l2Hits.Add(1)
+}
+
+// incL2Miss increments the L2 miss counter.
+func (m *Metrics) incL2Miss() {
+	if m == nil {
+		return
+	}
+	m.l2Misses.Add(1)
+}
+
+// incL2Error increments the L2 error counter.
+func (m *Metrics) incL2Error() {
+	if m == nil {
+		return
+	}
+	m.l2Errors.Add(1)
+}
+
+// incDemotion increments the demotion counter.
+func (m *Metrics) incDemotion() {
+	if m == nil {
+		return
+	}
+	m.demotions.Add(1)
+}
+
+// incInvalidationSent increments the published invalidation counter.
+func (m *Metrics) incInvalidationSent() {
+	if m == nil {
+		return
+	}
+	m.invalidationsSent.Add(1)
+}
+
+// incInvalidationReceived increments the applied invalidation counter.
+func (m *Metrics) incInvalidationReceived() {
+	if m == nil {
+		return
+	}
+	m.invalidationsReceived.Add(1)
+}
+
+// incInvalidationError increments the dropped invalidation counter.
+func (m *Metrics) incInvalidationError() {
+	if m == nil {
+		return
+	}
+	m.invalidationErrors.Add(1)
+}
+
+// addCascade records a dependency cascade that deleted n entries, depth
+// levels deep.
+func (m *Metrics) addCascade(n, depth int64) {
+	if m == nil {
+		return
+	}
+	m.cascadeDeletes.Add(n)
+	for {
+		cur := m.maxCascadeDepth.Load()
+		if depth <= cur || m.maxCascadeDepth.CompareAndSwap(cur, depth) {
+			return
+		}
+	}
+}
+
+// partition returns the counters of the named partition, creating them if
+// needed. m must not be nil.
+func (m *Metrics) partition(name string) *partitionMetrics {
+	if p, ok := m.partitions.Load(name); ok {
+		return p.(*partitionMetrics)
+	}
+	p, _ := m.partitions.LoadOrStore(name, &partitionMetrics{})
+	return p.(*partitionMetrics)
+}
+
 // Snapshot returns a point-in-time snapshot of the metrics.
 func (m *Metrics) Snapshot() MetricsSnapshot {
 	if m == nil {
@@ -130,6 +288,20 @@ func (m *Metrics) Snapshot() MetricsSnapshot {
 		hitRatio = float64(hits) / float64(total)
 	}
 
+	var partitions map[string]PartitionMetrics
+	m.partitions.Range(func(name, p any) bool {
+		if partitions == nil {
+			partitions = make(map[string]PartitionMetrics)
+		}
+		pm := p.(*partitionMetrics)
+		partitions[name.(string)] = PartitionMetrics{
+			Hits:      pm.hits.Load(),
+			Misses:    pm.misses.Load(),
+			Evictions: pm.evictions.Load(),
+		}
+		return true
+	})
+
 	return MetricsSnapshot{
 		Hits:        hits,
 		Misses:      misses,
@@ -142,6 +314,20 @@ func (m *Metrics) Snapshot() MetricsSnapshot {
 		CostEvicted: m.costEvicted.Load(),
 		BufferDrops: m.bufferDrops.Load(),
 		HitRatio:    hitRatio,
+
+		CascadeDeletes:  m.cascadeDeletes.Load(),
+		MaxCascadeDepth: m.maxCascadeDepth.Load(),
+		WatchDrops:      m.watchDrops.Load(),
+		WriterErrors:    m.writerErrors.Load(),
+		L2Hits:          m.l2Hits.Load(),
+		L2Misses:        m.l2Misses.Load(),
+		L2Errors:        m.l2Errors.Load(),
+		Demotions:       m.demotions.Load(),
+
+		InvalidationsSent:     m.invalidationsSent.Load(),
+		InvalidationsReceived: m.invalidationsReceived.Load(),
+		InvalidationErrors:    m.invalidationErrors.Load(),
+		Partitions:            partitions,
 	}
 }
 
@@ -160,4 +346,16 @@ func (m *Metrics) Reset() {
 	m.costAdded.Store(0)
 	m.costEvicted.Store(0)
 	m.bufferDrops.Store(0)
+	m.cascadeDeletes.Store(0)
+	m.maxCascadeDepth.Store(0)
+	m.watchDrops.Store(0)
+	m.writerErrors.Store(0)
+	m.l2Hits.Store(0)
+	m.l2Misses.Store(0)
+	m.l2Errors.Store(0)
+	m.demotions.Store(0)
+	m.invalidationsSent.Store(0)
+	m.invalidationsReceived.Store(0)
+	m.invalidationErrors.Store(0)
+	m.partitions.Clear()
 }
@@ -0,0 +1,306 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+var (
+	// ErrNamespaceKeys is the panic value of Namespace on a cache whose
+	// keys are not strings.
+	ErrNamespaceKeys = errors.New("mcache: namespaces require string keys")
+
+	// ErrNamespaceName is the panic value of Namespace for an empty name
+	// or one containing a NUL byte.
+	ErrNamespaceName = errors.New("mcache: invalid namespace name")
+)
+
+// quotaSamples is the number of entries Namespace compares to pick one to
+// evict when it is over its quota.
+const quotaSamples = 5
+
+// Namespace is a view of a string-keyed Cache with its own key space,
+// metrics and optional cost quota. All namespaces share the store,
+// admission policy, capacity and background workers of their cache, so
+// many tenants cost little more than one cache, while a quota keeps any
+// one of them from taking more than its share.
+//
+// A key k of namespace name is stored in the cache as name + "\x00" + k,
+// so namespaces never collide with each other and the cache itself can
+// still scan or delete their entries. Membership is recorded with the
+// entry like tags: it is not saved in snapshots or the write-ahead log,
+// and an entry written through the cache rather than the view does not
+// count towards the namespace.
+type Namespace[V any] struct {
+	c      *Cache[string, V]
+	prefix string
+	state  *namespaceState
+}
+
+// NamespaceOption configures a namespace when it is first created.
+type NamespaceOption func(*namespaceConfig)
+
+type namespaceConfig struct {
+	MaxCost int64
+}
+
+// WithNamespaceMaxCost limits the total cost of the entries in a
+// namespace. A write that would exceed it first evicts the least recently
+// written of a few sampled entries of the namespace, repeatedly; a value
+// costing more than the quota is rejected. Concurrent and buffered writes
+// may overshoot the quota briefly. Default: 0 (limited only by the cache).
+func WithNamespaceMaxCost(maxCost int64) NamespaceOption {
+	return func(cfg *namespaceConfig) {
+		cfg.MaxCost = maxCost
+	}
+}
+
+// Namespace returns a view of the namespace called name, creating it with
+// opts on first use; later calls return a view of the same namespace and
+// ignore opts. It panics with ErrNamespaceKeys if the cache keys are not
+// strings, and with ErrNamespaceName if name is empty or contains a NUL
+// byte.
+func (c *Cache[K, V]) Namespace(name string, opts ...NamespaceOption) *Namespace[V] {
+	sc, ok := any(c).(*Cache[string, V])
+	if !ok {
+		panic(ErrNamespaceKeys)
+	}
+	if name == "" || strings.IndexByte(name, 0) >= 0 {
+		panic(ErrNamespaceName)
+	}
+	return &Namespace[V]{
+		c:      sc,
+		prefix: name + "\x00",
+		state:  c.namespaces.get(name, opts, c.metrics != nil),
+	}
+}
+
+// NamespacePartition returns the namespace of a key written through a
+// Namespace view, or "" for other keys. Pass it to WithPartitions to
+// weight eviction by namespace.
+func NamespacePartition(key string) string {
+	if i := strings.IndexByte(key, 0); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// Name returns the name of the namespace.
+func (n *Namespace[V]) Name() string {
+	return n.state.name
+}
+
+// Get retrieves a value from the namespace.
+func (n *Namespace[V]) Get(key string) (V, bool) {
+	value, ok := n.c.Get(n.prefix + key)
+	if ok {
+		n.state.metrics.incHit()
+	} else {
+		n.state.metrics.incMiss()
+	}
+	return value, ok
+}
+
+// Has checks if a key exists in the namespace.
+func (n *Namespace[V]) Has(key string) bool {
+	return n.c.Has(n.prefix + key)
+}
+
+// Set stores a value in the namespace with a cost of 1.
+func (n *Namespace[V]) Set(key string, value V, ttl time.Duration) bool {
+	return n.SetWithCost(key, value, 1, ttl)
+}
+
+// SetWithCost stores a value in the namespace with an explicit cost,
+// evicting entries of the namespace first if it would exceed its quota.
+func (n *Namespace[V]) SetWithCost(key string, value V, cost int64, ttl time.Duration) bool {
+	if n.c.closed.Load() {
+		return false
+	}
+
+	key = n.prefix + key
+	cost, _ = n.c.normalizeWrite(value, cost, 0)
+	if !n.makeRoom(key, cost) {
+		n.c.metrics.incRejection()
+		n.state.metrics.incRejection()
+		return false
+	}
+
+	links := &store.Links[string]{Namespace: n.state.name}
+	ok, _ := n.c.setWithCost(context.Background(), key, value, cost, ttl, links)
+	if ok {
+		n.state.metrics.incSet()
+		n.state.metrics.addCost(cost)
+	}
+	return ok
+}
+
+// Delete removes a key from the namespace.
+func (n *Namespace[V]) Delete(key string) bool {
+	ok := n.c.Delete(n.prefix + key)
+	if ok {
+		n.state.metrics.incDelete()
+	}
+	return ok
+}
+
+// Len returns the number of entries in the namespace.
+func (n *Namespace[V]) Len() int {
+	return n.c.namespaces.keys.count(n.state.name)
+}
+
+// Cost returns the total cost of the entries in the namespace.
+func (n *Namespace[V]) Cost() int64 {
+	return n.state.cost.Load()
+}
+
+// Clear removes all entries of the namespace and resets its metrics.
+// Other namespaces and the rest of the cache are not affected.
+func (n *Namespace[V]) Clear() {
+	if n.c.closed.Load() {
+		return
+	}
+	if n.c.writeBuffer != nil {
+		n.c.writeBuffer.FlushSync()
+	}
+	for _, key := range n.c.namespaces.keys.keys(n.state.name) {
+		n.c.compareAndDelete(key, n.owns)
+	}
+	n.state.metrics.Reset()
+}
+
+// Metrics returns the metrics of the namespace: hits, misses, sets,
+// deletes and rejections through the view, and the entries evicted to
+// keep it within its quota. They are collected only if the cache was
+// created with WithMetrics.
+func (n *Namespace[V]) Metrics() MetricsSnapshot {
+	return n.state.metrics.Snapshot()
+}
+
+// makeRoom evicts entries of the namespace until an entry for key costing
+// cost fits in its quota. It returns false if the entry can never fit.
+func (n *Namespace[V]) makeRoom(key string, cost int64) bool {
+	s := n.state
+	if s.maxCost <= 0 {
+		return true
+	}
+	if cost > s.maxCost {
+		return false
+	}
+	// Overwriting an entry of the namespace frees its cost.
+	if e, ok := n.c.store.PeekByHash(key, n.c.store.KeyHash(key)); ok && n.owns(e) {
+		cost -= e.Cost
+	}
+
+	s.evictMu.Lock()
+	defer s.evictMu.Unlock()
+	for s.cost.Load()+cost > s.maxCost {
+		if !n.evictOne(key) {
+			break
+		}
+	}
+	return true
+}
+
+// evictOne evicts the least recently written of a few sampled entries of
+// the namespace other than keep. It returns false if there are none.
+func (n *Namespace[V]) evictOne(keep string) bool {
+	var victim *store.Entry[string, V]
+	for _, key := range n.c.namespaces.keys.sample(n.state.name, quotaSamples+1) {
+		if key == keep {
+			continue
+		}
+		e, ok := n.c.store.PeekByHash(key, n.c.store.KeyHash(key))
+		if ok && n.owns(e) && (victim == nil || e.WrittenAt < victim.WrittenAt) {
+			victim = e
+		}
+	}
+	if victim == nil {
+		return false
+	}
+
+	deleted, _ := n.c.compareAndDelete(victim.Key, func(e *store.Entry[string, V]) bool {
+		return e.Version == victim.Version
+	})
+	if deleted {
+		n.state.metrics.incEviction()
+		n.state.metrics.addEvictedCost(victim.Cost)
+	}
+	return true
+}
+
+// owns reports whether e was written through the namespace.
+func (n *Namespace[V]) owns(e *store.Entry[string, V]) bool {
+	return e.Links != nil && e.Links.Namespace == n.state.name
+}
+
+// namespaceState is the state shared by all views of one namespace.
+type namespaceState struct {
+	name    string
+	maxCost int64
+	cost    atomic.Int64 // Total cost of the entries in the namespace
+	metrics *Metrics     // nil unless the cache collects metrics
+	evictMu sync.Mutex   // Serializes quota evictions
+}
+
+// namespaceIndex tracks the namespaces of a cache and their entries.
+type namespaceIndex[K comparable] struct {
+	keys   linkIndex[string, K]
+	byName sync.Map // name -> *namespaceState
+}
+
+// get returns the state of the namespace called name, creating it if
+// needed.
+func (x *namespaceIndex[K]) get(name string, opts []NamespaceOption, metrics bool) *namespaceState {
+	if s, ok := x.byName.Load(name); ok {
+		return s.(*namespaceState)
+	}
+	var cfg namespaceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	s := &namespaceState{name: name, maxCost: cfg.MaxCost}
+	if metrics {
+		s.metrics = newMetrics()
+	}
+	actual, _ := x.byName.LoadOrStore(name, s)
+	return actual.(*namespaceState)
+}
+
+// add records an entry of cost stored under key in namespace name, if any.
+func (x *namespaceIndex[K]) add(name string, key K, cost int64) {
+	if name == "" {
+		return
+	}
+	x.keys.add([]string{name}, key)
+	if s, ok := x.byName.Load(name); ok {
+		s.(*namespaceState).cost.Add(cost)
+	}
+}
+
+// remove undoes add for an entry that was deleted or replaced.
+func (x *namespaceIndex[K]) remove(name string, key K, cost int64) {
+	if name == "" {
+		return
+	}
+	x.keys.remove([]string{name}, key)
+	if s, ok := x.byName.Load(name); ok {
+		s.(*namespaceState).cost.Add(-cost)
+	}
+}
+
+// clear forgets all entries, keeping the namespaces and their quotas.
+func (x *namespaceIndex[K]) clear() {
+	x.keys.clear()
+	x.byName.Range(func(_, s any) bool {
+		s.(*namespaceState).cost.Store(0)
+		s.(*namespaceState).metrics.Reset()
+		return true
+	})
+}
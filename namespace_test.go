@@ -0,0 +1,152 @@
+package mcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestNamespaceKeySpace(t *testing.T) {
+	c := NewCache[string, int](WithMetrics[string, int](true))
+	defer c.Close()
+
+	a := c.Namespace("a")
+	b := c.Namespace("b")
+	a.Set("k", 1, 0)
+	b.Set("k", 2, 0)
+	c.Set("k", 3, 0)
+
+	if v, _ := a.Get("k"); v != 1 {
+		t.Errorf("Expected 1, got %d", v)
+	}
+	if v, _ := b.Get("k"); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+	if v, _ := c.Get("k"); v != 3 {
+		t.Errorf("Expected 3, got %d", v)
+	}
+	if v, _ := c.Get("a\x00k"); v != 1 {
+		t.Errorf("Expected the cache to see a's entry, got %d", v)
+	}
+	if _, ok := a.Get("missing"); ok {
+		t.Error("Expected a miss")
+	}
+
+	m := a.Metrics()
+	if m.Hits != 1 || m.Misses != 1 || m.Sets != 1 {
+		t.Errorf("Expected 1 hit, 1 miss and 1 set, got %+v", m)
+	}
+	if c.Namespace("a").Len() != 1 || a.Cost() != 1 {
+		t.Errorf("Expected 1 entry costing 1, got %d costing %d", a.Len(), a.Cost())
+	}
+
+	a.Clear()
+	if a.Has("k") || !b.Has("k") || !c.Has("k") {
+		t.Error("Expected Clear to remove only the namespace's entries")
+	}
+	if a.Len() != 0 || a.Cost() != 0 {
+		t.Errorf("Expected an empty namespace, got %d entries costing %d", a.Len(), a.Cost())
+	}
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	c := NewCache[string, int](WithMetrics[string, int](true))
+	defer c.Close()
+
+	small := c.Namespace("small", WithNamespaceMaxCost(10))
+	big := c.Namespace("big")
+	for i := 0; i < 100; i++ {
+		if !small.Set(fmt.Sprint(i), i, 0) {
+			t.Fatalf("Expected set %d to succeed", i)
+		}
+		big.Set(fmt.Sprint(i), i, 0)
+	}
+
+	if small.Cost() > 10 || small.Len() > 10 {
+		t.Errorf("Expected at most 10 entries, got %d costing %d", small.Len(), small.Cost())
+	}
+	if big.Len() != 100 {
+		t.Errorf("Expected the other namespace to keep 100 entries, got %d", big.Len())
+	}
+	if !small.Has("99") {
+		t.Error("Expected the latest write to be kept")
+	}
+	if m := small.Metrics(); m.Evictions != 90 {
+		t.Errorf("Expected 90 quota evictions, got %d", m.Evictions)
+	}
+
+	if small.SetWithCost("huge", 0, 11, 0) {
+		t.Error("Expected a value above the quota to be rejected")
+	}
+
+	// Overwrites only count the difference in cost.
+	small.SetWithCost("99", 1, 2, 0)
+	if small.Cost() > 10 || !small.Has("99") {
+		t.Errorf("Expected the quota to hold on overwrite, got cost %d", small.Cost())
+	}
+}
+
+func TestNamespaceAccounting(t *testing.T) {
+	c := NewCache[string, int](WithMaxEntries[string, int](100))
+	defer c.Close()
+
+	ns := c.Namespace("t")
+	for i := 0; i < 1000; i++ {
+		ns.SetWithCost(fmt.Sprint(i), i, 3, 0)
+	}
+	// Entries evicted by the cache leave the namespace.
+	if n := ns.Len(); n != c.Len() || ns.Cost() != int64(3*n) {
+		t.Errorf("Expected %d entries costing %d, got %d costing %d", c.Len(), 3*c.Len(), n, ns.Cost())
+	}
+
+	c.Clear()
+	if ns.Len() != 0 || ns.Cost() != 0 {
+		t.Errorf("Expected Clear to empty the namespace, got %d costing %d", ns.Len(), ns.Cost())
+	}
+}
+
+func TestNamespacePanics(t *testing.T) {
+	expectPanic := func(name string, want error, fn func()) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r != want {
+				t.Errorf("%s: expected panic %v, got %v", name, want, r)
+			}
+		}()
+		fn()
+	}
+
+	ints := NewCache[int, int]()
+	defer ints.Close()
+	expectPanic("int keys", ErrNamespaceKeys, func() { ints.Namespace("x") })
+
+	c := NewCache[string, int]()
+	defer c.Close()
+	expectPanic("empty name", ErrNamespaceName, func() { c.Namespace("") })
+	expectPanic("NUL name", ErrNamespaceName, func() { c.Namespace("a\x00b") })
+}
+
+func TestNamespaceConcurrentQuota(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			ns := c.Namespace(fmt.Sprintf("tenant%d", g%2), WithNamespaceMaxCost(50))
+			for i := 0; i < 1000; i++ {
+				ns.Set(fmt.Sprintf("%d-%d", g, i), i, 0)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i := 0; i < 2; i++ {
+		ns := c.Namespace(fmt.Sprintf("tenant%d", i))
+		if ns.Cost() != int64(ns.Len()) || ns.Len() > 52 {
+			t.Errorf("%s: expected about 50 entries, got %d costing %d", ns.Name(), ns.Len(), ns.Cost())
+		}
+	}
+}
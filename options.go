@@ -1,6 +1,9 @@
 package mcache
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 // config holds the configuration for a Cache instance.
 type config[K comparable, V any] struct {
@@ -24,12 +27,27 @@ type config[K comparable, V any] struct {
 	OnExpire func(key K, value V)             // Called when entry expires
 	OnReject func(key K, value V)             // Called when entry is rejected by TinyLFU
 
+	// Loading
+	Loader       Loader[K, V]  // Cache-wide loader used by GetOrLoad
+	RefreshAfter time.Duration // Age after which a hit triggers an async reload (0 = disabled)
+
+	// Persistence
+	Codec              Codec[K, V]   // Key/value codec for snapshots and other persistence
+	WALDir             string        // Write-ahead log directory ("" = disabled)
+	WALSync            WALSyncPolicy // When WAL records are fsynced
+	WALSyncInterval    time.Duration // Fsync interval for WALSyncInterval
+	WALSegmentSize     int64         // WAL segment rotation size in bytes
+	WALCompactInterval time.Duration // Interval between WAL compactions (0 = manual)
+
 	// Cost estimation
 	CostFunc func(value V) int64 // Custom cost calculator
 
 	// Key handling
 	KeyHasher func(K) uint64 // Custom key hasher
 
+	// Value comparison
+	Equal func(a, b V) bool // Equality used by CompareAndSwap and CompareAndDelete
+
 	// GC settings
 	DefaultTTL time.Duration // Default TTL for entries without explicit TTL
 
@@ -37,7 +55,32 @@ type config[K comparable, V any] struct {
 	IgnoreInternalCost bool // Ignore internal metadata cost in cost calculations
 
 	// Policy selection
-	UseLockFreePolicy bool // Use lock-free policy for reduced contention (default: true)
+	UseLockFreePolicy bool       // Use lock-free policy for reduced contention (default: true)
+	Policy            Policer[K] // Custom admission/eviction policy (nil = TinyLFU)
+
+	// Partitions
+	Partition        func(K) string     // Names the partition of a key (nil = none)
+	PartitionWeights map[string]float64 // Eviction weight per partition (default 1)
+
+	// Backing store
+	Writer              BackingWriter[K, V] // Receives cache writes (nil = disabled)
+	WriterMode          WriterMode          // When writes are passed to Writer
+	WriterBatchSize     int                 // Maximum writes per write-behind batch
+	WriterFlushInterval time.Duration       // Maximum delay of a write-behind write
+	WriterRetries       int                 // Retries of a failed write-behind batch
+	WriterBackoff       time.Duration       // Delay before the first retry, doubled after each
+
+	// Invalidation
+	Invalidator Invalidator // Broadcasts Delete, Clear and InvalidateTag to other caches (nil = disabled)
+
+	// Tiering
+	L2WriteMode L2WriteMode                                // When TieredCache writes to L2
+	Demote      func(key K, value V, cost, expireAt int64) // Receives evicted entries (set by TieredCache)
+	GuardLoad   func(key K) func(store func() bool) bool   // Guards values stored by GetOrLoad (set by TieredCache)
+
+	// Diagnostics
+	TraceRecorder io.Writer // Destination for access traces (nil = disabled)
+	Tracer        Tracer    // Span hooks for context-aware methods (nil = disabled)
 
 	// Prefix search (opt-in for string keys)
 	EnablePrefixSearch bool // Enable radix tree for prefix search (default: false)
@@ -49,14 +92,22 @@ type Option[K comparable, V any] func(*config[K, V])
 // defaultConfig returns the default configuration.
 func defaultConfig[K comparable, V any]() *config[K, V] {
 	return &config[K, V]{
-		MaxEntries:        0,    // unlimited
-		MaxCost:           0,    // unlimited
-		NumCounters:       0,    // will be set based on MaxEntries
-		ShardCount:        1024, // 1024 shards
-		BufferItems:       0,    // No buffering by default (synchronous writes)
-		MetricsEnabled:    true,
-		ExpiryResolution:  100 * time.Millisecond,
-		UseLockFreePolicy: true, // Use lock-free policy by default for better read performance
+		MaxEntries:          0,    // unlimited
+		MaxCost:             0,    // unlimited
+		NumCounters:         0,    // will be set based on MaxEntries
+		ShardCount:          1024, // 1024 shards
+		BufferItems:         0,    // No buffering by default (synchronous writes)
+		MetricsEnabled:      true,
+		ExpiryResolution:    100 * time.Millisecond,
+		UseLockFreePolicy:   true, // Use lock-free policy by default for better read performance
+		WALSync:             WALSyncInterval,
+		WALSyncInterval:     time.Second,
+		WALCompactInterval:  5 * time.Minute,
+		Equal:               defaultEqual[V],
+		WriterBatchSize:     64,
+		WriterFlushInterval: 100 * time.Millisecond,
+		WriterRetries:       3,
+		WriterBackoff:       100 * time.Millisecond,
 	}
 }
 
@@ -164,6 +215,15 @@ func WithKeyHasher[K comparable, V any](fn func(K) uint64) Option[K, V] {
 	}
 }
 
+// WithEqualFunc sets the function CompareAndSwap and CompareAndDelete use
+// to compare values. If not set, values are compared with ==, or with
+// bytes.Equal and reflect.DeepEqual when they are not comparable.
+func WithEqualFunc[K comparable, V any](fn func(a, b V) bool) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Equal = fn
+	}
+}
+
 // WithDefaultTTL sets the default TTL for entries that don't specify one.
 // A value of 0 means no expiration (default).
 func WithDefaultTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
@@ -211,3 +271,179 @@ func WithPrefixSearch[K comparable, V any](enabled bool) Option[K, V] {
 		c.EnablePrefixSearch = enabled
 	}
 }
+
+// WithLoader sets a cache-wide loader used by GetOrLoad when no per-call
+// loader is given. Concurrent misses for the same key share one call.
+func WithLoader[K comparable, V any](loader Loader[K, V]) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Loader = loader
+	}
+}
+
+// WithRefreshAfter enables refresh-ahead for entries older than d.
+// A Get that hits such an entry returns the cached value immediately and
+// starts one asynchronous reload through the loader set with WithLoader.
+// Only entries that are still present are refreshed; a failed reload keeps
+// the current value and is retried on a later hit.
+// A value of 0 disables refresh-ahead (default).
+func WithRefreshAfter[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.RefreshAfter = d
+	}
+}
+
+// WithCodec sets the Codec used to serialize entries for SaveTo/LoadFrom
+// and other persistence features.
+func WithCodec[K comparable, V any](codec Codec[K, V]) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Codec = codec
+	}
+}
+
+// WithWAL enables crash durability through a write-ahead log in dir.
+// Every Set, Delete, eviction, expiration and Clear is appended to a
+// segmented log, which is periodically compacted into a snapshot.
+// On start the cache replays the snapshot and the log.
+// Requires WithCodec. Writes buffered with WithBufferItems become durable
+// when their batch is applied.
+func WithWAL[K comparable, V any](dir string) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WALDir = dir
+	}
+}
+
+// WithWALSync sets the WAL fsync policy. interval is only used with
+// WALSyncInterval. Default: WALSyncInterval, every second.
+func WithWALSync[K comparable, V any](policy WALSyncPolicy, interval time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WALSync = policy
+		if interval > 0 {
+			c.WALSyncInterval = interval
+		}
+	}
+}
+
+// WithWALSegmentSize sets the size at which WAL segments rotate.
+// Default: 64MB.
+func WithWALSegmentSize[K comparable, V any](bytes int64) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WALSegmentSize = bytes
+	}
+}
+
+// WithWALCompactInterval sets how often the WAL is compacted into a
+// snapshot. A value of 0 disables automatic compaction; CompactWAL can
+// still be called manually. Default: 5 minutes.
+func WithWALCompactInterval[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WALCompactInterval = d
+	}
+}
+
+// WithPolicy sets the admission and eviction policy, either one of the
+// built-in policies (NewWTinyLFUPolicy, NewLRUPolicy, NewLFUPolicy,
+// NewARCPolicy, NewS3FIFOPolicy) or a custom Policer implementation.
+// The policy's limits are set from WithMaxCost and WithMaxEntries.
+// WithLockFreePolicy is ignored when a policy is set. Not supported by
+// SerializedCache, which always uses TinyLFU.
+// Default: TinyLFU admission with sampled LFU eviction.
+func WithPolicy[K comparable, V any](p Policer[K]) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Policy = p
+	}
+}
+
+// WithPartitions splits the keys into partitions, such as tenants, named
+// by partitionOf and weighted by weights; partitions not listed have a
+// weight of 1. The default policies then evict from the partition using
+// the most cost relative to its weight first, so a noisy partition evicts
+// its own entries rather than those of others. Metrics reports hits,
+// misses, evictions and cost per partition. Use NamespacePartition to
+// partition by Namespace. Other policies keep their eviction order.
+// Default: no partitions.
+func WithPartitions[K comparable, V any](partitionOf func(K) string, weights map[string]float64) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Partition = partitionOf
+		c.PartitionWeights = weights
+	}
+}
+
+// WithWriter passes the writes made with Set, Delete and their variants
+// to w, such as a database, as set by WithWriterMode. Default: nil.
+func WithWriter[K comparable, V any](w BackingWriter[K, V]) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Writer = w
+	}
+}
+
+// WithWriterMode sets when writes are passed to the writer set with
+// WithWriter. Default: WriteThrough.
+func WithWriterMode[K comparable, V any](mode WriterMode) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WriterMode = mode
+	}
+}
+
+// WithWriterBatch sets the maximum number of writes in a write-behind
+// batch and the maximum time a write waits before its batch is written.
+// Default: 64 writes, 100ms.
+func WithWriterBatch[K comparable, V any](size int, interval time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WriterBatchSize = size
+		c.WriterFlushInterval = interval
+	}
+}
+
+// WithWriterRetry sets how many times a failed write-behind batch is
+// retried, and the delay before the first retry, which doubles after each
+// one. A batch failing every attempt is dropped and reported by
+// WriterError. Default: 3 retries, 100ms.
+func WithWriterRetry[K comparable, V any](retries int, backoff time.Duration) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.WriterRetries = retries
+		c.WriterBackoff = backoff
+	}
+}
+
+// WithInvalidator keeps the cache consistent with other caches, usually
+// in other processes, subscribed to the same Invalidator: Delete,
+// DeleteMany, CompareAndDelete, CompareAndDeleteVersion, deletes made by
+// Compute, Clear and InvalidateTag are published to it, and those
+// received from other caches are applied locally without being published
+// again. Sets are not published, so update the source of truth and
+// Delete the key to invalidate the other copies. Requires WithCodec to
+// encode keys. Default: nil (disabled).
+func WithInvalidator[K comparable, V any](inv Invalidator) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Invalidator = inv
+	}
+}
+
+// WithL2WriteMode sets when a TieredCache writes to its L2Store. It is
+// ignored by Cache. Default: L2WriteThrough.
+func WithL2WriteMode[K comparable, V any](mode L2WriteMode) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.L2WriteMode = mode
+	}
+}
+
+// WithTracer sets a Tracer that starts a span for each call of a
+// context-aware method such as GetCtx or GetOrLoad.
+// If not set, no spans are started.
+func WithTracer[K comparable, V any](t Tracer) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.Tracer = t
+	}
+}
+
+// WithTraceRecorder records a compact event (key hash, op, cost,
+// timestamp) for every Get, Set and Delete to w, for offline replay with
+// cmd/mcache-sim. Events are buffered; Close flushes them, FlushTrace
+// flushes on demand and TraceError reports write errors. Recording
+// serializes operations on a mutex, so enable it for sampling windows
+// rather than permanently. Not supported by SerializedCache.
+func WithTraceRecorder[K comparable, V any](w io.Writer) Option[K, V] {
+	return func(c *config[K, V]) {
+		c.TraceRecorder = w
+	}
+}
@@ -0,0 +1,74 @@
+package mcache
+
+import "github.com/OrlovEvgeny/go-mcache/internal/policy"
+
+// Policer decides which entries are admitted and which are evicted.
+// Implementations must be safe for concurrent use and must stop tracking
+// the victims they return from Add. The cache sets the limits with
+// SetMaxCost and SetMaxEntries when it is created, so a Policer must not be
+// shared between caches.
+type Policer[K comparable] = policy.Policer[K]
+
+// Victim is an entry selected for eviction by a Policer.
+type Victim[K comparable] = policy.Victim[K]
+
+// partitioner is implemented by policies that support WithPartitions.
+type partitioner[K comparable] interface {
+	SetPartitions(partitionOf func(K) string, weights map[string]float64)
+	PartitionCosts() map[string]int64
+}
+
+// hitObserver is implemented by policies that adapt to the hit rate.
+type hitObserver interface {
+	RecordHit()
+	RecordMiss()
+}
+
+// NewTinyLFUPolicy returns the default policy: TinyLFU admission with
+// sampled LFU eviction. numCounters is the number of sketch counters
+// (10x the expected number of entries is recommended).
+func NewTinyLFUPolicy[K comparable](numCounters int64) Policer[K] {
+	return policy.NewPolicyLockFree[K](numCounters, 0, 0)
+}
+
+// NewWTinyLFUPolicy returns a Window TinyLFU policy: a small LRU admission
+// window in front of a segmented LRU main space guarded by a TinyLFU
+// frequency sketch. It performs well across recency- and frequency-biased
+// workloads and resists scans. numCounters is the number of sketch
+// counters (10x the expected number of entries is recommended).
+func NewWTinyLFUPolicy[K comparable](numCounters int64) Policer[K] {
+	return policy.NewWTinyLFU[K](numCounters)
+}
+
+// NewAdaptiveWTinyLFUPolicy returns a Window TinyLFU policy whose admission
+// window is resized online by hill climbing on the cache's hit rate: it
+// grows for recency-biased workloads and shrinks for frequency-biased
+// ones. The current size is reported as MetricsSnapshot.WindowFraction.
+func NewAdaptiveWTinyLFUPolicy[K comparable](numCounters int64) Policer[K] {
+	return policy.NewAdaptiveWTinyLFU[K](numCounters)
+}
+
+// NewLRUPolicy returns a least-recently-used policy without admission
+// control.
+func NewLRUPolicy[K comparable]() Policer[K] {
+	return policy.NewLRU[K]()
+}
+
+// NewLFUPolicy returns a least-frequently-used policy with exact,
+// non-decaying counts and O(1) operations.
+func NewLFUPolicy[K comparable]() Policer[K] {
+	return policy.NewLFU[K]()
+}
+
+// NewARCPolicy returns an Adaptive Replacement Cache policy, which balances
+// recency and frequency using ghost lists of recently evicted keys.
+func NewARCPolicy[K comparable]() Policer[K] {
+	return policy.NewARC[K]()
+}
+
+// NewS3FIFOPolicy returns an S3-FIFO policy: a small FIFO queue filters out
+// one-hit wonders before they reach the main queue, which makes it cheap
+// and resistant to scans.
+func NewS3FIFOPolicy[K comparable]() Policer[K] {
+	return policy.NewS3FIFO[K]()
+}
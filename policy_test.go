@@ -0,0 +1,189 @@
+package mcache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithPolicyBuiltins(t *testing.T) {
+	policies := map[string]func() Policer[string]{
+		"TinyLFU":  func() Policer[string] { return NewTinyLFUPolicy[string](10000) },
+		"WTinyLFU": func() Policer[string] { return NewWTinyLFUPolicy[string](10000) },
+		"LRU":      NewLRUPolicy[string],
+		"LFU":      NewLFUPolicy[string],
+		"ARC":      NewARCPolicy[string],
+		"S3FIFO":   NewS3FIFOPolicy[string],
+	}
+	for name, newPolicy := range policies {
+		t.Run(name, func(t *testing.T) {
+			c := NewCache[string, int](
+				WithMaxEntries[string, int](100),
+				WithPolicy[string, int](newPolicy()),
+			)
+			defer c.Close()
+
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key-%d", i%300)
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, i, 0)
+				}
+			}
+			c.Wait()
+			if n := c.Len(); n > 101 || n == 0 {
+				t.Errorf("Expected about 100 entries, got %d", n)
+			}
+		})
+	}
+}
+
+func TestWithPolicyLRUOrder(t *testing.T) {
+	c := NewCache[int, int](
+		WithMaxEntries[int, int](3),
+		WithPolicy[int, int](NewLRUPolicy[int]()),
+	)
+	defer c.Close()
+
+	c.Set(1, 1, 0)
+	c.Set(2, 2, 0)
+	c.Set(3, 3, 0)
+	c.Get(1)
+	c.Wait()
+	c.Set(4, 4, 0)
+
+	if c.Has(2) {
+		t.Error("Expected least recently used key 2 to be evicted")
+	}
+	for _, key := range []int{1, 3, 4} {
+		if !c.Has(key) {
+			t.Errorf("Expected key %d to be present", key)
+		}
+	}
+}
+
+// rejectOddPolicy is a custom Policer that only admits even keys.
+type rejectOddPolicy struct {
+	Policer[int]
+	adds atomic.Int32
+}
+
+func (p *rejectOddPolicy) Add(key int, keyHash uint64, cost int64) ([]Victim[int], bool) {
+	p.adds.Add(1)
+	if key%2 != 0 {
+		return nil, false
+	}
+	return p.Policer.Add(key, keyHash, cost)
+}
+
+func TestWithPolicyCustom(t *testing.T) {
+	p := &rejectOddPolicy{Policer: NewLRUPolicy[int]()}
+	c := NewCache[int, int](WithPolicy[int, int](p))
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		c.Set(i, i, 0)
+	}
+	if c.Len() != 5 {
+		t.Errorf("Expected 5 admitted entries, got %d", c.Len())
+	}
+	if p.adds.Load() != 10 {
+		t.Errorf("Expected custom policy to see 10 adds, got %d", p.adds.Load())
+	}
+	if m := c.Metrics(); m.Rejections != 5 {
+		t.Errorf("Expected 5 rejections, got %d", m.Rejections)
+	}
+}
+
+// replayWindowFraction replays keys against an adaptive W-TinyLFU policy
+// holding up to 200 entries, the way the cache drives it but on a single
+// goroutine, and returns the final window fraction.
+func replayWindowFraction(keys []int) float64 {
+	p := NewAdaptiveWTinyLFUPolicy[int](2000)
+	p.SetMaxEntries(200)
+	obs := p.(hitObserver)
+	for _, key := range keys {
+		keyHash := uint64(key) * 0x9e3779b97f4a7c15
+		if p.Has(key) {
+			obs.RecordHit()
+			p.Access(keyHash)
+			continue
+		}
+		obs.RecordMiss()
+		p.Add(key, keyHash, 1)
+	}
+	return p.(interface{ WindowFraction() float64 }).WindowFraction()
+}
+
+func TestAdaptivePolicyWindowFraction(t *testing.T) {
+	// Every key is reused a few times right after it first appears and
+	// never again, which favours a larger window. The reuse distances are
+	// short enough that a tiny window loses the keys to the main space.
+	var recency []int
+	for i := 0; i < 20000; i++ {
+		for j := max(i-40, 0); j <= i; j += 5 {
+			recency = append(recency, j)
+		}
+	}
+	if f := replayWindowFraction(recency); f <= 0.01 {
+		t.Errorf("Expected the window to grow on a recency-biased trace, got %v", f)
+	}
+
+	// A stable set of hot keys requested between one-off keys favours the
+	// frequency filter of the main space over a larger window.
+	var frequency []int
+	for i := 0; i < 100000; i++ {
+		frequency = append(frequency, i%150, -1-i)
+	}
+	if f := replayWindowFraction(frequency); f > 0.01 {
+		t.Errorf("Expected the window not to grow on a frequency-biased trace, got %v", f)
+	}
+
+	c := NewCache[int, int](
+		WithMaxEntries[int, int](200),
+		WithPolicy[int, int](NewAdaptiveWTinyLFUPolicy[int](2000)),
+	)
+	defer c.Close()
+	if f := c.Metrics().WindowFraction; f != 0.01 {
+		t.Errorf("Expected initial window fraction 0.01, got %v", f)
+	}
+
+	plain := NewCache[int, int](WithMaxEntries[int, int](200))
+	defer plain.Close()
+	if f := plain.Metrics().WindowFraction; f != 0 {
+		t.Errorf("Expected no window fraction for the default policy, got %v", f)
+	}
+}
+
+func TestPartitionedEviction(t *testing.T) {
+	c := NewCache[string, int](
+		WithMaxEntries[string, int](100),
+		WithPartitions[string, int](NamespacePartition, map[string]float64{"quiet": 1, "noisy": 1}),
+	)
+	defer c.Close()
+
+	quiet := c.Namespace("quiet")
+	noisy := c.Namespace("noisy")
+	for i := 0; i < 20; i++ {
+		quiet.Set(fmt.Sprint(i), i, 0)
+	}
+	for i := 0; i < 5000; i++ {
+		noisy.Set(fmt.Sprint(i), i, 0)
+	}
+	c.Wait()
+
+	if n := quiet.Len(); n != 20 {
+		t.Errorf("Expected the quiet tenant to keep its 20 entries, got %d", n)
+	}
+	for i := 0; i < 10; i++ {
+		quiet.Get(fmt.Sprint(i))
+	}
+
+	m := c.Metrics()
+	q, n := m.Partitions["quiet"], m.Partitions["noisy"]
+	if q.Hits != 10 || q.Evictions != 0 || q.Cost != 20 {
+		t.Errorf("Expected 10 hits, no evictions and cost 20 for quiet, got %+v", q)
+	}
+	if n.Evictions == 0 || n.Cost != int64(noisy.Len()) {
+		t.Errorf("Expected evictions and cost %d for noisy, got %+v", noisy.Len(), n)
+	}
+}
@@ -0,0 +1,540 @@
+package mcache
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/buffer"
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/glob"
+	"github.com/OrlovEvgeny/go-mcache/internal/policy"
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+// SerializedCache is a cache that stores keys and values encoded with a
+// Codec, off the Go heap's pointer graph. Entries are serialized into large
+// per-shard byte buffers indexed by key hash, so the GC never scans them
+// regardless of cache size. Values are encoded on Set and decoded on Get.
+//
+// It accepts the same options as Cache and provides the same TinyLFU
+// admission, TTL expiration, metrics and scanning. Writes are always
+// synchronous (WithBufferItems is ignored).
+//
+// Entries are identified by the 64-bit hash of their key: Get never
+// returns a value stored under a different key, but a write whose key
+// hashes like an existing entry replaces it.
+type SerializedCache[K comparable, V any] struct {
+	store       *store.GCFreeStore
+	policy      policy.Policer[uint64]
+	expiryWheel *store.ExpiryWheel[uint64]
+	metrics     *Metrics
+	config      *config[K, V]
+	codec       Codec[K, V]
+	readBuffer  *buffer.LossyBuffer[uint64]
+	hasher      func(K) uint64
+	scratch     sync.Pool // *[]byte encoding buffers
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	closed   atomic.Bool
+	clearMu  sync.Mutex // Serializes Clear() with removeExpired()
+	liveCost atomic.Int64
+
+	isStringKey bool
+}
+
+// BytesCache is a SerializedCache of []byte values under string keys.
+// Values are copied in on Set and out on Get.
+type BytesCache = SerializedCache[string, []byte]
+
+// NewBytesCache creates a new BytesCache with the given options.
+func NewBytesCache(opts ...Option[string, []byte]) *BytesCache {
+	return NewSerializedCache[string, []byte](StringBytesCodec{}, opts...)
+}
+
+// NewSerializedCache creates a new SerializedCache encoding entries with
+// codec. If codec is nil, the Codec set with WithCodec is used; it panics
+// with ErrNoCodec if there is none.
+// Unless WithCostFunc or an explicit cost is used, the cost of an entry is
+// the number of bytes it occupies once encoded, so WithMaxCost is a byte
+// budget.
+func NewSerializedCache[K comparable, V any](codec Codec[K, V], opts ...Option[K, V]) *SerializedCache[K, V] {
+	cfg := defaultConfig[K, V]()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if codec == nil {
+		codec = cfg.Codec
+	}
+	if codec == nil {
+		panic(ErrNoCodec)
+	}
+
+	if cfg.NumCounters <= 0 {
+		if cfg.MaxEntries > 0 {
+			cfg.NumCounters = cfg.MaxEntries * 10
+		} else {
+			cfg.NumCounters = 1 << 20 // Default 1M
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The policy and expiry wheel track key hashes only, keeping their
+	// bookkeeping free of pointers as well.
+	var pol policy.Policer[uint64]
+	if cfg.UseLockFreePolicy {
+		pol = policy.NewPolicyLockFree[uint64](cfg.NumCounters, cfg.MaxCost, cfg.MaxEntries)
+	} else {
+		pol = policy.NewPolicy[uint64](cfg.NumCounters, cfg.MaxCost, cfg.MaxEntries)
+	}
+
+	c := &SerializedCache[K, V]{
+		store:       store.NewGCFreeStore(cfg.ShardCount, 0),
+		policy:      pol,
+		expiryWheel: store.NewExpiryWheel[uint64](cfg.ExpiryResolution),
+		config:      cfg,
+		codec:       codec,
+		hasher:      cfg.KeyHasher,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	if c.hasher == nil {
+		c.hasher = defaultKeyHasher[K]
+	}
+	if cfg.MetricsEnabled {
+		c.metrics = newMetrics()
+	}
+	if cfg.MaxEntries > 0 || cfg.MaxCost > 0 {
+		c.readBuffer = buffer.NewLossyBuffer[uint64](
+			1024,
+			64,
+			50*time.Microsecond,
+			c.processReadBatch,
+		)
+	}
+
+	var zeroK K
+	_, c.isStringKey = any(zeroK).(string)
+
+	// Start background expiration worker
+	c.wg.Add(1)
+	go c.expirationWorker()
+
+	return c
+}
+
+// getScratch returns an empty encoding buffer from the pool.
+func (c *SerializedCache[K, V]) getScratch() *[]byte {
+	if buf, ok := c.scratch.Get().(*[]byte); ok {
+		*buf = (*buf)[:0]
+		return buf
+	}
+	buf := make([]byte, 0, 256)
+	return &buf
+}
+
+// putScratch returns buf to the pool unless it grew unusually large.
+func (c *SerializedCache[K, V]) putScratch(buf *[]byte) {
+	if cap(*buf) <= 64<<10 {
+		c.scratch.Put(buf)
+	}
+}
+
+// Get retrieves and decodes the value stored under key.
+func (c *SerializedCache[K, V]) Get(key K) (V, bool) {
+	var value V
+
+	if c.closed.Load() {
+		return value, false
+	}
+
+	buf := c.getScratch()
+	defer c.putScratch(buf)
+	keyBytes, err := c.codec.AppendKey(*buf, key)
+	if err != nil {
+		c.metrics.incMiss()
+		return value, false
+	}
+	*buf = keyBytes
+
+	keyHash := c.hasher(key)
+	var decodeErr error
+	_, ok := c.store.GetFunc(keyHash, keyBytes, func(v []byte) {
+		value, decodeErr = c.codec.DecodeValue(v)
+	})
+	if !ok || decodeErr != nil {
+		c.metrics.incMiss()
+		var zero V
+		return zero, false
+	}
+
+	c.recordAccess(keyHash)
+	c.metrics.incHit()
+	return value, true
+}
+
+// Has checks if a key exists in the cache.
+func (c *SerializedCache[K, V]) Has(key K) bool {
+	if c.closed.Load() {
+		return false
+	}
+
+	buf := c.getScratch()
+	defer c.putScratch(buf)
+	keyBytes, err := c.codec.AppendKey(*buf, key)
+	if err != nil {
+		return false
+	}
+	*buf = keyBytes
+	return c.store.Has(c.hasher(key), keyBytes)
+}
+
+// Set stores value with the given TTL, costed by its encoded size.
+// A TTL of 0 means the entry never expires.
+// Returns true if the value was stored, false if it was rejected by the
+// admission policy or could not be encoded.
+func (c *SerializedCache[K, V]) Set(key K, value V, ttl time.Duration) bool {
+	return c.SetWithCost(key, value, 0, ttl)
+}
+
+// SetWithCost stores value with a specified cost.
+// A cost of 0 falls back to CostFunc or the encoded entry size.
+func (c *SerializedCache[K, V]) SetWithCost(key K, value V, cost int64, ttl time.Duration) bool {
+	if c.closed.Load() {
+		return false
+	}
+
+	// Encode key and value back to back into one scratch buffer.
+	buf := c.getScratch()
+	defer c.putScratch(buf)
+	encoded, err := c.codec.AppendKey(*buf, key)
+	if err != nil {
+		return false
+	}
+	keyLen := len(encoded)
+	if encoded, err = c.codec.AppendValue(encoded, value); err != nil {
+		return false
+	}
+	*buf = encoded
+	keyBytes, valueBytes := encoded[:keyLen:keyLen], encoded[keyLen:]
+
+	if cost <= 0 {
+		if c.config.CostFunc != nil {
+			cost = c.config.CostFunc(value)
+		} else {
+			cost = store.GCFreeEntrySize(len(keyBytes), len(valueBytes))
+		}
+	}
+	if ttl <= 0 && c.config.DefaultTTL > 0 {
+		ttl = c.config.DefaultTTL
+	}
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = clock.NowNano() + int64(ttl)
+	}
+
+	keyHash := c.hasher(key)
+
+	// Update in place when the key is already resident
+	if prevCost, ok := c.store.Update(keyHash, keyBytes, valueBytes, expireAt, cost); ok {
+		c.stored(keyHash, cost, prevCost, expireAt)
+		c.policy.Update(keyHash, keyHash, cost)
+		return true
+	}
+
+	victims, added := c.policy.Add(keyHash, keyHash, cost)
+	if !added {
+		c.metrics.incRejection()
+		if c.config.OnReject != nil {
+			c.config.OnReject(key, value)
+		}
+		return false
+	}
+
+	for _, victim := range victims {
+		c.evictVictim(victim.KeyHash)
+	}
+
+	prevCost, _ := c.store.Set(keyHash, keyBytes, valueBytes, expireAt, cost)
+	c.stored(keyHash, cost, prevCost, expireAt)
+	return true
+}
+
+// stored updates bookkeeping after an entry was written.
+func (c *SerializedCache[K, V]) stored(keyHash uint64, cost, prevCost, expireAt int64) {
+	c.liveCost.Add(cost - prevCost)
+	if expireAt > 0 {
+		c.expiryWheel.Schedule(keyHash, keyHash, expireAt)
+	}
+	c.metrics.incSet()
+	c.metrics.addCost(cost)
+}
+
+// removed is the state captured from an entry deleted out of the store.
+// Key and value bytes are only copied out when a callback needs them.
+type removed struct {
+	cost       int64
+	key, value []byte
+}
+
+// capture returns a DeleteFunc callback recording the entry into r.
+func (r *removed) capture(copyData bool) func(key, value []byte, expireAt, cost int64) {
+	return func(key, value []byte, _, cost int64) {
+		r.cost = cost
+		if copyData {
+			r.key, r.value = bytes.Clone(key), bytes.Clone(value)
+		}
+	}
+}
+
+// decode decodes a captured entry for a callback.
+func (c *SerializedCache[K, V]) decode(r *removed) (K, V, bool) {
+	var value V
+	key, err := c.codec.DecodeKey(r.key)
+	if err != nil {
+		return key, value, false
+	}
+	if value, err = c.codec.DecodeValue(r.value); err != nil {
+		return key, value, false
+	}
+	return key, value, true
+}
+
+// evictVictim evicts an entry selected by the admission policy.
+func (c *SerializedCache[K, V]) evictVictim(keyHash uint64) {
+	onEvict := c.config.OnEvict
+
+	var r removed
+	if !c.store.DeleteFunc(keyHash, nil, r.capture(onEvict != nil)) {
+		return
+	}
+	c.liveCost.Add(-r.cost)
+
+	c.metrics.incEviction()
+	c.metrics.addEvictedCost(r.cost)
+
+	if onEvict != nil {
+		if key, value, ok := c.decode(&r); ok {
+			onEvict(key, value, r.cost)
+		}
+	}
+}
+
+// Delete removes a value from the cache.
+// Returns true if the value was found and deleted.
+func (c *SerializedCache[K, V]) Delete(key K) bool {
+	if c.closed.Load() {
+		return false
+	}
+
+	buf := c.getScratch()
+	defer c.putScratch(buf)
+	keyBytes, err := c.codec.AppendKey(*buf, key)
+	if err != nil {
+		return false
+	}
+	*buf = keyBytes
+
+	keyHash := c.hasher(key)
+	var r removed
+	deleted := c.store.DeleteFunc(keyHash,
+		func(k []byte, _ int64) bool { return bytes.Equal(k, keyBytes) },
+		r.capture(false),
+	)
+	if !deleted {
+		return false
+	}
+	c.liveCost.Add(-r.cost)
+	c.policy.Del(keyHash, keyHash)
+
+	c.metrics.incDelete()
+	return true
+}
+
+// Len returns the number of entries in the cache.
+func (c *SerializedCache[K, V]) Len() int {
+	return c.store.Len()
+}
+
+// MemoryUsage returns the approximate number of bytes held by the store,
+// including space not yet reclaimed from deleted entries.
+func (c *SerializedCache[K, V]) MemoryUsage() int64 {
+	return c.store.MemoryUsage()
+}
+
+// Metrics returns the cache metrics.
+func (c *SerializedCache[K, V]) Metrics() MetricsSnapshot {
+	return c.metrics.Snapshot()
+}
+
+// Scan returns an iterator over cache entries.
+// cursor is the starting position (0 for beginning).
+// count is the maximum number of entries to return per iteration.
+// Entries that fail to decode are skipped.
+func (c *SerializedCache[K, V]) Scan(cursor uint64, count int) *Iterator[K, V] {
+	return newScanIterator(c.scanPage, cursor, count, "", nil)
+}
+
+// ScanPrefix returns an iterator over entries with keys matching the prefix.
+// Only works when K is string.
+func (c *SerializedCache[K, V]) ScanPrefix(prefix string, cursor uint64, count int) *Iterator[K, V] {
+	if !c.isStringKey {
+		return newEmptyIterator[K, V]()
+	}
+	return newScanIterator(c.scanPage, cursor, count, prefix, nil)
+}
+
+// ScanMatch returns an iterator over entries with keys matching the glob pattern.
+// Only works when K is string.
+// Supported patterns: * (any chars), ? (single char), [abc] (char class).
+func (c *SerializedCache[K, V]) ScanMatch(pattern string, cursor uint64, count int) *Iterator[K, V] {
+	if !c.isStringKey {
+		return newEmptyIterator[K, V]()
+	}
+
+	pat, err := glob.Compile(pattern)
+	if err != nil {
+		return newEmptyIterator[K, V]()
+	}
+	return newScanIterator(c.scanPage, cursor, count, pat.Prefix(), pat)
+}
+
+// scanPage decodes up to count entries out of the store for an Iterator.
+func (c *SerializedCache[K, V]) scanPage(cursor uint64, count int) ([]*store.Entry[K, V], uint64) {
+	if c.closed.Load() {
+		return nil, 0
+	}
+	entries := make([]*store.Entry[K, V], 0, count)
+	next := c.store.Scan(cursor, count, func(k, v []byte, expireAt, cost int64) {
+		key, err := c.codec.DecodeKey(k)
+		if err != nil {
+			return
+		}
+		value, err := c.codec.DecodeValue(v)
+		if err != nil {
+			return
+		}
+		entries = append(entries, &store.Entry[K, V]{
+			Key:      key,
+			Value:    value,
+			ExpireAt: expireAt,
+			Cost:     cost,
+		})
+	})
+	return entries, next
+}
+
+// Clear removes all entries from the cache.
+func (c *SerializedCache[K, V]) Clear() {
+	if c.readBuffer != nil {
+		c.readBuffer.FlushSync()
+	}
+	c.clearMu.Lock()
+	defer c.clearMu.Unlock()
+	c.store.Clear()
+	c.policy.Clear()
+	c.expiryWheel.Clear()
+	c.metrics.Reset()
+	c.liveCost.Store(0)
+}
+
+// Close stops the cache and releases resources.
+func (c *SerializedCache[K, V]) Close() {
+	if c.closed.Swap(true) {
+		return // Already closed
+	}
+
+	c.cancel()
+
+	if c.readBuffer != nil {
+		c.readBuffer.Close()
+	}
+
+	c.wg.Wait()
+}
+
+// processReadBatch replays access events in batches.
+func (c *SerializedCache[K, V]) processReadBatch(items []uint64) {
+	if len(items) == 0 {
+		return
+	}
+	if batcher, ok := c.policy.(interface{ AccessBatch([]uint64) }); ok {
+		batcher.AccessBatch(items)
+		return
+	}
+	for _, keyHash := range items {
+		c.policy.Access(keyHash)
+	}
+}
+
+func (c *SerializedCache[K, V]) recordAccess(keyHash uint64) {
+	cfg := c.config
+	switch {
+	case cfg.MaxEntries <= 0 && cfg.MaxCost <= 0:
+		return
+	case cfg.MaxEntries > 0 && int64(c.store.Len()) >= cfg.MaxEntries/2:
+	case cfg.MaxCost > 0 && c.liveCost.Load() >= cfg.MaxCost/2:
+	default:
+		return
+	}
+	if c.readBuffer != nil {
+		c.readBuffer.Push(keyHash)
+		return
+	}
+	c.policy.Access(keyHash)
+}
+
+// expirationWorker periodically removes expired entries.
+func (c *SerializedCache[K, V]) expirationWorker() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.expiryWheel.Resolution())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.removeExpired()
+		}
+	}
+}
+
+// removeExpired drains the timing wheel and deletes entries whose stored
+// expiration still matches the scheduled one.
+func (c *SerializedCache[K, V]) removeExpired() {
+	c.clearMu.Lock()
+	defer c.clearMu.Unlock()
+
+	now := clock.NowNano()
+	onExpire := c.config.OnExpire
+
+	for _, item := range c.expiryWheel.Advance(now) {
+		var r removed
+		deleted := c.store.DeleteFunc(item.KeyHash,
+			func(_ []byte, expireAt int64) bool {
+				return expireAt == item.ExpireAt && expireAt > 0 && now > expireAt
+			},
+			r.capture(onExpire != nil),
+		)
+		if !deleted {
+			continue
+		}
+		c.liveCost.Add(-r.cost)
+		c.policy.Del(item.KeyHash, item.KeyHash)
+
+		c.metrics.incExpiration()
+
+		if onExpire != nil {
+			if key, value, ok := c.decode(&r); ok {
+				onExpire(key, value)
+			}
+		}
+	}
+}
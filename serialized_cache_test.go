@@ -0,0 +1,91 @@
+package mcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+type serializedUser struct {
+	Name  string
+	Email string
+	Age   int
+}
+
+func TestSerializedCacheRoundTrip(t *testing.T) {
+	c := NewSerializedCache[int, serializedUser](GobCodec[int, serializedUser]{})
+	defer c.Close()
+
+	want := serializedUser{Name: "Alice", Email: "alice@example.com", Age: 30}
+	if !c.Set(1, want, time.Minute) {
+		t.Fatal("Set failed")
+	}
+	got, ok := c.Get(1)
+	if !ok || got != want {
+		t.Errorf("Expected %+v, got %+v, ok=%v", want, got, ok)
+	}
+	if _, ok := c.Get(2); ok {
+		t.Error("Expected miss for absent key")
+	}
+
+	items := c.Scan(0, 10).All()
+	if len(items) != 1 || items[0].Key != 1 || items[0].Value != want {
+		t.Errorf("Expected scan to decode the entry, got %+v", items)
+	}
+	if it := c.ScanMatch("*", 0, 10); it.Next() {
+		t.Error("Expected ScanMatch to be empty for non-string keys")
+	}
+}
+
+func TestSerializedCacheCostIsEncodedSize(t *testing.T) {
+	c := NewSerializedCache[string, []byte](nil,
+		WithCodec[string, []byte](StringBytesCodec{}),
+	)
+	defer c.Close()
+
+	c.Set("key", make([]byte, 100), 0)
+	if want := store.GCFreeEntrySize(3, 100); c.liveCost.Load() != want {
+		t.Errorf("Expected cost %d, got %d", want, c.liveCost.Load())
+	}
+
+	c.Set("key", make([]byte, 10), 0)
+	if want := store.GCFreeEntrySize(3, 10); c.liveCost.Load() != want {
+		t.Errorf("Expected cost %d after overwrite, got %d", want, c.liveCost.Load())
+	}
+
+	c.SetWithCost("other", []byte("x"), 1000, 0)
+	if m := c.Metrics(); m.CostAdded != store.GCFreeEntrySize(3, 100)+store.GCFreeEntrySize(3, 10)+1000 {
+		t.Errorf("Expected explicit cost to be used, got CostAdded=%d", m.CostAdded)
+	}
+}
+
+func TestSerializedCacheRequiresCodec(t *testing.T) {
+	defer func() {
+		if r := recover(); r != ErrNoCodec {
+			t.Errorf("Expected panic with ErrNoCodec, got %v", r)
+		}
+	}()
+	NewSerializedCache[string, int](nil)
+}
+
+func TestSerializedCacheEvictCallbackDecodes(t *testing.T) {
+	evicted := make(chan serializedUser, 100)
+	c := NewSerializedCache[int, serializedUser](GobCodec[int, serializedUser]{},
+		WithMaxEntries[int, serializedUser](10),
+		WithOnEvict(func(key int, value serializedUser, cost int64) {
+			evicted <- value
+		}),
+	)
+	defer c.Close()
+
+	for i := 0; i < 50; i++ {
+		c.Set(i, serializedUser{Name: "user", Age: i}, 0)
+	}
+	if len(evicted) == 0 {
+		t.Fatal("Expected evictions")
+	}
+	if v := <-evicted; v.Name != "user" {
+		t.Errorf("Expected decoded value in OnEvict, got %+v", v)
+	}
+}
@@ -0,0 +1,492 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// command describes a command handler. arity follows the Redis
+// convention: the exact number of arguments including the command name,
+// or the negated minimum.
+type command struct {
+	arity   int
+	handler func(s *Server, c *conn, args [][]byte)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get":      {2, (*Server).get},
+		"set":      {-3, (*Server).set},
+		"del":      {-2, (*Server).del},
+		"exists":   {-2, (*Server).exists},
+		"ttl":      {2, (*Server).ttl},
+		"pttl":     {2, (*Server).pttl},
+		"expire":   {3, (*Server).expire},
+		"mget":     {-2, (*Server).mget},
+		"mset":     {-3, (*Server).mset},
+		"scan":     {-2, (*Server).scan},
+		"keys":     {2, (*Server).keys},
+		"dbsize":   {1, (*Server).dbsize},
+		"flushdb":  {-1, (*Server).flushdb},
+		"flushall": {-1, (*Server).flushdb},
+		"info":     {-1, (*Server).info},
+		"ping":     {-1, (*Server).ping},
+		"echo":     {2, (*Server).echo},
+		"hello":    {-1, (*Server).hello},
+		"select":   {2, (*Server).selectDB},
+		"client":   {-2, (*Server).client},
+		"command":  {-1, (*Server).command},
+		"quit":     {-1, (*Server).quit},
+	}
+}
+
+// execute runs one command and writes its reply.
+func (s *Server) execute(c *conn, args [][]byte) {
+	name := strings.ToLower(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		c.w.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		c.w.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return
+	}
+	cmd.handler(s, c, args)
+}
+
+const (
+	errSyntax     = "ERR syntax error"
+	errNotInteger = "ERR value is not an integer or out of range"
+)
+
+func parseInt(b []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	return n, err == nil
+}
+
+func (s *Server) get(c *conn, args [][]byte) {
+	if v, ok := s.cache.Get(string(args[1])); ok {
+		c.w.writeBulk(v)
+		return
+	}
+	c.w.writeNull()
+}
+
+// set implements SET key value [NX|XX] [EX seconds|PX milliseconds].
+func (s *Server) set(c *conn, args [][]byte) {
+	key, value := string(args[1]), args[2]
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ex", "px":
+			if ttl != 0 || i+1 >= len(args) {
+				c.w.writeError(errSyntax)
+				return
+			}
+			i++
+			n, ok := parseInt(args[i])
+			if !ok {
+				c.w.writeError(errNotInteger)
+				return
+			}
+			if n <= 0 {
+				c.w.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+			unit := time.Second
+			if opt == "px" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			c.w.writeError(errSyntax)
+			return
+		}
+	}
+	if nx && xx {
+		c.w.writeError(errSyntax)
+		return
+	}
+
+	defer s.locks.Lock(key)()
+	if nx || xx {
+		if exists := s.cache.Has(key); (nx && exists) || (xx && !exists) {
+			c.w.writeNull()
+			return
+		}
+	}
+	s.cache.SetWithCost(key, value, 0, ttl)
+	c.w.writeSimple("OK")
+}
+
+func (s *Server) del(c *conn, args [][]byte) {
+	var n int64
+	for _, arg := range args[1:] {
+		key := string(arg)
+		unlock := s.locks.Lock(key)
+		if s.cache.Delete(key) {
+			n++
+		}
+		unlock()
+	}
+	c.w.writeInt(n)
+}
+
+func (s *Server) exists(c *conn, args [][]byte) {
+	var n int64
+	for _, key := range args[1:] {
+		if s.cache.Has(string(key)) {
+			n++
+		}
+	}
+	c.w.writeInt(n)
+}
+
+// remainingTTL returns the TTL reply value in unit: -2 for a missing key,
+// -1 for a key without expiration.
+func (s *Server) remainingTTL(key string, unit time.Duration) int64 {
+	ttl, ok := s.cache.TTL(key)
+	if !ok {
+		return -2
+	}
+	if ttl == 0 {
+		return -1
+	}
+	return int64((ttl + unit/2) / unit)
+}
+
+func (s *Server) ttl(c *conn, args [][]byte) {
+	c.w.writeInt(s.remainingTTL(string(args[1]), time.Second))
+}
+
+func (s *Server) pttl(c *conn, args [][]byte) {
+	c.w.writeInt(s.remainingTTL(string(args[1]), time.Millisecond))
+}
+
+// expire implements EXPIRE key seconds. A non-positive TTL deletes the key.
+func (s *Server) expire(c *conn, args [][]byte) {
+	key := string(args[1])
+	seconds, ok := parseInt(args[2])
+	if !ok {
+		c.w.writeError(errNotInteger)
+		return
+	}
+
+	defer s.locks.Lock(key)()
+	var done bool
+	if seconds <= 0 {
+		done = s.cache.Delete(key)
+	} else {
+		done = s.cache.Expire(key, time.Duration(seconds)*time.Second)
+	}
+	if done {
+		c.w.writeInt(1)
+	} else {
+		c.w.writeInt(0)
+	}
+}
+
+func (s *Server) mget(c *conn, args [][]byte) {
+	c.w.writeArrayLen(len(args) - 1)
+	for _, key := range args[1:] {
+		if v, ok := s.cache.Get(string(key)); ok {
+			c.w.writeBulk(v)
+		} else {
+			c.w.writeNull()
+		}
+	}
+}
+
+func (s *Server) mset(c *conn, args [][]byte) {
+	if len(args)%2 != 1 {
+		c.w.writeError("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	for i := 1; i < len(args); i += 2 {
+		key := string(args[i])
+		unlock := s.locks.Lock(key)
+		s.cache.SetWithCost(key, args[i+1], 0, 0)
+		unlock()
+	}
+	c.w.writeSimple("OK")
+}
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count] [TYPE type].
+func (s *Server) scan(c *conn, args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[1]), 10, 64)
+	if err != nil {
+		c.w.writeError("ERR invalid cursor")
+		return
+	}
+	pattern, count := "", 10
+	onlyStrings := true
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			c.w.writeError(errSyntax)
+			return
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				c.w.writeError(errNotInteger)
+				return
+			}
+			if n < 1 {
+				c.w.writeError(errSyntax)
+				return
+			}
+			count = int(n)
+		case "type":
+			onlyStrings = strings.EqualFold(string(args[i+1]), "string")
+		default:
+			c.w.writeError(errSyntax)
+			return
+		}
+	}
+
+	var keys []string
+	var next uint64
+	if onlyStrings {
+		keys, next = s.cache.ScanKeys(cursor, count, pattern)
+	}
+	c.w.writeArrayLen(2)
+	c.w.writeBulkString(strconv.FormatUint(next, 10))
+	c.w.writeArrayLen(len(keys))
+	for _, key := range keys {
+		c.w.writeBulkString(key)
+	}
+}
+
+func (s *Server) keys(c *conn, args [][]byte) {
+	pattern := string(args[1])
+	var all []string
+	var cursor uint64
+	for {
+		keys, next := s.cache.ScanKeys(cursor, 1024, pattern)
+		all = append(all, keys...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	c.w.writeArrayLen(len(all))
+	for _, key := range all {
+		c.w.writeBulkString(key)
+	}
+}
+
+func (s *Server) dbsize(c *conn, args [][]byte) {
+	c.w.writeInt(int64(s.cache.Len()))
+}
+
+// flushdb implements FLUSHDB and FLUSHALL [ASYNC|SYNC]; both clear the
+// cache synchronously.
+func (s *Server) flushdb(c *conn, args [][]byte) {
+	if len(args) > 2 {
+		c.w.writeError(errSyntax)
+		return
+	}
+	if len(args) == 2 {
+		if mode := strings.ToLower(string(args[1])); mode != "async" && mode != "sync" {
+			c.w.writeError(errSyntax)
+			return
+		}
+	}
+	s.cache.Clear()
+	c.w.writeSimple("OK")
+}
+
+// info implements INFO [section ...] with the server, clients, memory,
+// stats and keyspace sections.
+func (s *Server) info(c *conn, args [][]byte) {
+	want := func(section string) bool {
+		if len(args) == 1 {
+			return true
+		}
+		for _, arg := range args[1:] {
+			if name := strings.ToLower(string(arg)); name == section || name == "all" || name == "everything" || name == "default" {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b bytes.Buffer
+	field := func(name string, value any) {
+		fmt.Fprintf(&b, "%s:%v\r\n", name, value)
+	}
+	section := func(name string) {
+		if b.Len() > 0 {
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "# %s\r\n", name)
+	}
+
+	if want("server") {
+		uptime := time.Since(s.started)
+		section("Server")
+		field("redis_version", "7.0.0")
+		field("redis_mode", "standalone")
+		field("mcache_server", "1")
+		field("arch_bits", strconv.IntSize)
+		field("go_version", runtime.Version())
+		field("uptime_in_seconds", int64(uptime.Seconds()))
+		field("uptime_in_days", int64(uptime.Hours()/24))
+	}
+	if want("clients") {
+		section("Clients")
+		field("connected_clients", s.base.NumConns())
+	}
+	if want("memory") {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		section("Memory")
+		field("used_memory", ms.HeapAlloc)
+		field("used_memory_rss", ms.Sys)
+	}
+	m := s.cache.Metrics()
+	if want("stats") {
+		section("Stats")
+		field("total_connections_received", s.base.TotalConns())
+		field("total_commands_processed", s.totalCommands.Load())
+		field("keyspace_hits", m.Hits)
+		field("keyspace_misses", m.Misses)
+		field("evicted_keys", m.Evictions)
+		field("expired_keys", m.Expirations)
+		field("rejected_keys", m.Rejections)
+	}
+	if want("keyspace") {
+		section("Keyspace")
+		if n := s.cache.Len(); n > 0 {
+			fmt.Fprintf(&b, "db0:keys=%d,expires=0,avg_ttl=0\r\n", n)
+		}
+	}
+	c.w.writeBulk(b.Bytes())
+}
+
+func (s *Server) ping(c *conn, args [][]byte) {
+	switch len(args) {
+	case 1:
+		c.w.writeSimple("PONG")
+	case 2:
+		c.w.writeBulk(args[1])
+	default:
+		c.w.writeError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func (s *Server) echo(c *conn, args [][]byte) {
+	c.w.writeBulk(args[1])
+}
+
+// hello implements HELLO [protover [AUTH user pass] [SETNAME name]].
+// Authentication options are accepted and ignored.
+func (s *Server) hello(c *conn, args [][]byte) {
+	proto := c.w.proto
+	if len(args) > 1 {
+		n, ok := parseInt(args[1])
+		if !ok {
+			c.w.writeError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if n != 2 && n != 3 {
+			c.w.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = int(n)
+		for i := 2; i < len(args); i++ {
+			switch strings.ToLower(string(args[i])) {
+			case "auth":
+				i += 2
+			case "setname":
+				if i+1 < len(args) {
+					c.name = string(args[i+1])
+				}
+				i++
+			default:
+				c.w.writeError(errSyntax)
+				return
+			}
+		}
+	}
+	c.w.proto = proto
+
+	c.w.writeMapLen(7)
+	c.w.writeBulkString("server")
+	c.w.writeBulkString("redis")
+	c.w.writeBulkString("version")
+	c.w.writeBulkString("7.0.0")
+	c.w.writeBulkString("proto")
+	c.w.writeInt(int64(proto))
+	c.w.writeBulkString("id")
+	c.w.writeInt(c.id)
+	c.w.writeBulkString("mode")
+	c.w.writeBulkString("standalone")
+	c.w.writeBulkString("role")
+	c.w.writeBulkString("master")
+	c.w.writeBulkString("modules")
+	c.w.writeArrayLen(0)
+}
+
+func (s *Server) selectDB(c *conn, args [][]byte) {
+	if string(args[1]) != "0" {
+		c.w.writeError("ERR DB index is out of range")
+		return
+	}
+	c.w.writeSimple("OK")
+}
+
+// client implements the CLIENT subcommands clients send on connect.
+func (s *Server) client(c *conn, args [][]byte) {
+	switch strings.ToLower(string(args[1])) {
+	case "setname":
+		if len(args) != 3 {
+			c.w.writeError(errSyntax)
+			return
+		}
+		c.name = string(args[2])
+		c.w.writeSimple("OK")
+	case "getname":
+		if c.name == "" {
+			c.w.writeNull()
+			return
+		}
+		c.w.writeBulkString(c.name)
+	case "id":
+		c.w.writeInt(c.id)
+	case "setinfo":
+		c.w.writeSimple("OK")
+	default:
+		c.w.writeError(fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+	}
+}
+
+// command answers COMMAND introspection with an empty reply, which clients
+// treat as "no command metadata available".
+func (s *Server) command(c *conn, args [][]byte) {
+	if len(args) > 1 && strings.EqualFold(string(args[1]), "count") {
+		c.w.writeInt(int64(len(commands)))
+		return
+	}
+	c.w.writeArrayLen(0)
+}
+
+func (s *Server) quit(c *conn, args [][]byte) {
+	c.w.writeSimple("OK")
+	c.quit = true
+}
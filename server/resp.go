@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+const (
+	// maxBulkLen bounds a single argument, as in Redis (proto-max-bulk-len).
+	maxBulkLen = 512 << 20
+
+	// maxArgs bounds the number of arguments of one command.
+	maxArgs = 1 << 20
+
+	// maxInlineLen bounds an inline command line.
+	maxInlineLen = 64 << 10
+
+	// readChunk is the step in which argument buffers grow while reading,
+	// so a large length sent with little data cannot force a large
+	// allocation.
+	readChunk = 1 << 20
+
+	// argsChunk is the number of arguments allocated up front, for the
+	// same reason.
+	argsChunk = 1024
+)
+
+// protocolError is returned for malformed requests; the connection is
+// closed after the error is reported.
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// readCommand reads one request, either a RESP array of bulk strings or an
+// inline command. Every argument is a fresh slice that the caller may keep.
+func readCommand(br *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if line[0] != '*' {
+		if len(line) > maxInlineLen {
+			return nil, protocolError("too big inline request")
+		}
+		fields := bytes.Fields(line)
+		args := make([][]byte, len(fields))
+		for i, f := range fields {
+			args[i] = bytes.Clone(f)
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	args := make([][]byte, 0, min(n, argsChunk))
+	for range n {
+		line, err := readLine(br)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError(fmt.Sprintf("expected '$', got '%c'", firstByte(line)))
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, protocolError("invalid bulk length")
+		}
+		arg, err := readBulk(br, size+2)
+		if err != nil {
+			return nil, err
+		}
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, protocolError("bulk string not terminated by CRLF")
+		}
+		args = append(args, arg[:size:size])
+	}
+	return args, nil
+}
+
+// readBulk reads size bytes, growing the buffer by at most readChunk
+// beyond what has been read.
+func readBulk(br *bufio.Reader, size int) ([]byte, error) {
+	var buf []byte
+	for len(buf) < size {
+		n := min(size-len(buf), readChunk)
+		buf = slices.Grow(buf, n)
+		if _, err := io.ReadFull(br, buf[len(buf):len(buf)+n]); err != nil {
+			return nil, err
+		}
+		buf = buf[:len(buf)+n]
+	}
+	return buf, nil
+}
+
+// readLine reads a CRLF- or LF-terminated line without the terminator.
+func readLine(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, protocolError("line too long")
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+func firstByte(b []byte) byte {
+	if len(b) == 0 {
+		return ' '
+	}
+	return b[0]
+}
+
+// respWriter encodes replies for RESP2 or RESP3 clients.
+type respWriter struct {
+	bw    *bufio.Writer
+	proto int // 2 or 3, negotiated with HELLO
+	buf   []byte
+}
+
+func newRespWriter(w io.Writer) *respWriter {
+	return &respWriter{bw: bufio.NewWriterSize(w, 16<<10), proto: 2}
+}
+
+func (w *respWriter) writeHeader(prefix byte, n int64) {
+	w.buf = append(w.buf[:0], prefix)
+	w.buf = strconv.AppendInt(w.buf, n, 10)
+	w.buf = append(w.buf, '\r', '\n')
+	w.bw.Write(w.buf)
+}
+
+// writeSimple writes a simple string such as OK.
+func (w *respWriter) writeSimple(s string) {
+	w.bw.WriteByte('+')
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// writeError writes an error reply; msg starts with an error code such as
+// ERR or WRONGTYPE.
+func (w *respWriter) writeError(msg string) {
+	w.bw.WriteByte('-')
+	w.bw.WriteString(msg)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *respWriter) writeInt(n int64) {
+	w.writeHeader(':', n)
+}
+
+func (w *respWriter) writeBulk(b []byte) {
+	w.writeHeader('$', int64(len(b)))
+	w.bw.Write(b)
+	w.bw.WriteString("\r\n")
+}
+
+func (w *respWriter) writeBulkString(s string) {
+	w.writeHeader('$', int64(len(s)))
+	w.bw.WriteString(s)
+	w.bw.WriteString("\r\n")
+}
+
+// writeNull writes a null bulk string (RESP2) or null (RESP3).
+func (w *respWriter) writeNull() {
+	if w.proto == 3 {
+		w.bw.WriteString("_\r\n")
+		return
+	}
+	w.bw.WriteString("$-1\r\n")
+}
+
+func (w *respWriter) writeArrayLen(n int) {
+	w.writeHeader('*', int64(n))
+}
+
+// writeMapLen starts a map of n pairs; RESP2 clients get a flat array.
+func (w *respWriter) writeMapLen(n int) {
+	if w.proto == 3 {
+		w.writeHeader('%', int64(n))
+		return
+	}
+	w.writeHeader('*', int64(2*n))
+}
+
+func (w *respWriter) flush() error {
+	return w.bw.Flush()
+}
@@ -0,0 +1,105 @@
+// Package server serves a Cache[string, []byte] over the Redis protocol
+// (RESP2, and RESP3 after HELLO 3), so stock Redis clients can use an
+// mcache process as a sidecar cache.
+//
+// Supported commands: GET, SET (EX, PX, NX, XX), DEL, EXISTS, TTL, PTTL,
+// EXPIRE, MGET, MSET, SCAN (MATCH, COUNT), KEYS, DBSIZE, FLUSHDB, FLUSHALL
+// and INFO, plus PING, ECHO, HELLO, SELECT 0, CLIENT, COMMAND and QUIT for
+// client compatibility. There is a single database and only string values.
+//
+// Writes go through the cache's admission policy, so a SET may be
+// acknowledged but not retained, as with any cache under memory pressure.
+// Writes to a key are serialized by the Server, so conditional writes
+// (SET NX/XX) are atomic with respect to other clients of the same Server,
+// but not with respect to direct writes to the cache from the process.
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+	"github.com/OrlovEvgeny/go-mcache/internal/netserver"
+)
+
+// ErrServerClosed is returned by Serve and ListenAndServe after Close.
+var ErrServerClosed = errors.New("server: closed")
+
+// Server serves a cache over the Redis protocol.
+type Server struct {
+	cache   *mcache.Cache[string, []byte]
+	base    *netserver.Server
+	locks   netserver.Stripes // Serialize writes per key
+	started time.Time
+
+	nextID        atomic.Int64
+	totalCommands atomic.Int64
+}
+
+// New returns a Server for cache. The caller keeps ownership of the cache
+// and closes it after the server.
+func New(cache *mcache.Cache[string, []byte]) *Server {
+	return &Server{
+		cache:   cache,
+		base:    netserver.New(ErrServerClosed),
+		started: time.Now(),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.base.ListenAndServe(addr, s.serveConn)
+}
+
+// Serve accepts connections on ln until Close is called, when it returns
+// ErrServerClosed. ln is closed on return.
+func (s *Server) Serve(ln net.Listener) error {
+	return s.base.Serve(ln, s.serveConn)
+}
+
+// Close stops all listeners, closes all connections and waits for their
+// handlers to return.
+func (s *Server) Close() error {
+	s.base.Close()
+	return nil
+}
+
+// conn is the state of one client connection.
+type conn struct {
+	id   int64
+	name string
+	w    *respWriter
+	quit bool
+}
+
+// serveConn reads and executes commands until the client disconnects.
+// Replies to pipelined commands are flushed together.
+func (s *Server) serveConn(nc net.Conn) {
+	br := bufio.NewReaderSize(nc, 64<<10)
+	c := &conn{id: s.nextID.Add(1), w: newRespWriter(nc)}
+
+	for !c.quit {
+		args, err := readCommand(br)
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				c.w.writeError("ERR " + perr.Error())
+				c.w.flush()
+			}
+			return
+		}
+		if len(args) > 0 {
+			s.totalCommands.Add(1)
+			s.execute(c, args)
+		}
+		if br.Buffered() == 0 {
+			if err := c.w.flush(); err != nil {
+				return
+			}
+		}
+	}
+	c.w.flush()
+}
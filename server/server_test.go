@@ -0,0 +1,311 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mcache "github.com/OrlovEvgeny/go-mcache"
+)
+
+// client is a minimal RESP client for tests.
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	br *bufio.Reader
+}
+
+func startServer(t *testing.T, opts ...mcache.Option[string, []byte]) (*client, *mcache.Cache[string, []byte]) {
+	t.Helper()
+	cache := mcache.NewCache[string, []byte](opts...)
+	srv := New(cache)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nc.Close()
+		srv.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Expected ErrServerClosed, got %v", err)
+		}
+		cache.Close()
+	})
+	return &client{t: t, nc: nc, br: bufio.NewReader(nc)}, cache
+}
+
+func (c *client) send(args ...string) {
+	c.t.Helper()
+	buf := fmt.Appendf(nil, "*%d\r\n", len(args))
+	for _, a := range args {
+		buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.nc.Write(buf); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read decodes one reply: strings for simple and bulk strings, "ERR..."
+// for errors, int64 for integers, nil for nulls and []any for arrays and
+// maps.
+func (c *client) read() any {
+	c.t.Helper()
+	c.nc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+', '-':
+		return line[1:]
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.br, buf); err != nil {
+			c.t.Fatal(err)
+		}
+		return string(buf[:n])
+	case '*', '%':
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i] = c.read()
+		}
+		return items
+	}
+	c.t.Fatalf("Unexpected reply %q", line)
+	return nil
+}
+
+func (c *client) do(args ...string) any {
+	c.t.Helper()
+	c.send(args...)
+	return c.read()
+}
+
+func (c *client) expect(want any, args ...string) {
+	c.t.Helper()
+	if got := c.do(args...); fmt.Sprint(got) != fmt.Sprint(want) {
+		c.t.Errorf("%v: expected %v, got %v", args, want, got)
+	}
+}
+
+func TestGetSetDel(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect("PONG", "PING")
+	c.expect(nil, "GET", "a")
+	c.expect("OK", "SET", "a", "hello")
+	c.expect("hello", "GET", "a")
+	c.expect(int64(2), "EXISTS", "a", "a")
+	c.expect(int64(1), "DEL", "a", "b")
+	c.expect(int64(0), "EXISTS", "a")
+	c.expect("ERR wrong number of arguments for 'get' command", "GET")
+	c.expect("ERR unknown command 'NOPE'", "NOPE")
+}
+
+func TestSetOptions(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect(nil, "SET", "k", "1", "XX")
+	c.expect("OK", "SET", "k", "1", "NX")
+	c.expect(nil, "SET", "k", "2", "NX")
+	c.expect("1", "GET", "k")
+	c.expect("OK", "SET", "k", "3", "XX", "EX", "100")
+	c.expect("3", "GET", "k")
+	c.expect(int64(100), "TTL", "k")
+
+	c.expect("OK", "SET", "p", "v", "PX", "1500")
+	if pttl := c.do("PTTL", "p").(int64); pttl <= 1000 || pttl > 1500 {
+		t.Errorf("Expected PTTL in (1000, 1500], got %d", pttl)
+	}
+
+	c.expect("ERR syntax error", "SET", "k", "v", "NX", "XX")
+	c.expect("ERR syntax error", "SET", "k", "v", "EX")
+	c.expect("ERR invalid expire time in 'set' command", "SET", "k", "v", "EX", "0")
+	c.expect("ERR value is not an integer or out of range", "SET", "k", "v", "PX", "abc")
+}
+
+func TestTTLAndExpire(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect(int64(-2), "TTL", "missing")
+	c.expect("OK", "SET", "k", "v")
+	c.expect(int64(-1), "TTL", "k")
+	c.expect(int64(-1), "PTTL", "k")
+	c.expect(int64(1), "EXPIRE", "k", "60")
+	c.expect(int64(60), "TTL", "k")
+	c.expect(int64(0), "EXPIRE", "missing", "60")
+	c.expect(int64(1), "EXPIRE", "k", "0")
+	c.expect(nil, "GET", "k")
+
+	c.expect("OK", "SET", "short", "v", "PX", "50")
+	time.Sleep(100 * time.Millisecond)
+	c.expect(nil, "GET", "short")
+	c.expect(int64(-2), "TTL", "short")
+}
+
+func TestMGetMSet(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect("OK", "MSET", "a", "1", "b", "2")
+	c.expect([]any{"1", nil, "2"}, "MGET", "a", "x", "b")
+	c.expect("ERR wrong number of arguments for 'mset' command", "MSET", "a", "1", "b")
+	c.expect(int64(2), "DBSIZE")
+	c.expect("OK", "FLUSHDB")
+	c.expect(int64(0), "DBSIZE")
+}
+
+func TestScanAndKeys(t *testing.T) {
+	c, _ := startServer(t, mcache.WithShardCount[string, []byte](16))
+
+	want := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		c.expect("OK", "SET", key, "v")
+		want[key] = true
+		c.expect("OK", "SET", fmt.Sprintf("order:%d", i), "v")
+	}
+
+	seen := make(map[string]bool)
+	cursor := "0"
+	for calls := 0; ; calls++ {
+		reply := c.do("SCAN", cursor, "MATCH", "user:*", "COUNT", "20").([]any)
+		for _, k := range reply[1].([]any) {
+			seen[k.(string)] = true
+		}
+		cursor = reply[0].(string)
+		if cursor == "0" {
+			break
+		}
+		if calls > 1000 {
+			t.Fatal("SCAN did not terminate")
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("Expected SCAN to return %d keys, got %d", len(want), len(seen))
+	}
+	for key := range seen {
+		if !want[key] {
+			t.Errorf("Unexpected key %q", key)
+		}
+	}
+
+	keys := c.do("KEYS", "order:1?").([]any)
+	var got []string
+	for _, k := range keys {
+		got = append(got, k.(string))
+	}
+	slices.Sort(got)
+	if len(got) != 10 || got[0] != "order:10" || got[9] != "order:19" {
+		t.Errorf("Expected order:10..order:19, got %v", got)
+	}
+
+	reply := c.do("SCAN", "0", "TYPE", "hash").([]any)
+	if reply[0] != "0" || len(reply[1].([]any)) != 0 {
+		t.Errorf("Expected no keys of type hash, got %v", reply)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.expect("OK", "SET", "a", "1")
+	c.do("GET", "a")
+	info := c.do("INFO").(string)
+	for _, want := range []string{"# Server", "redis_version:", "# Stats", "keyspace_hits:1", "db0:keys=1"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Expected INFO to contain %q:\n%s", want, info)
+		}
+	}
+	if stats := c.do("INFO", "stats").(string); strings.Contains(stats, "# Server") {
+		t.Error("Expected INFO stats to omit other sections")
+	}
+}
+
+func TestHelloRESP3(t *testing.T) {
+	c, _ := startServer(t)
+
+	hello := c.do("HELLO", "3", "SETNAME", "app").([]any)
+	if len(hello) != 14 || hello[0] != "server" || hello[5] != int64(3) {
+		t.Errorf("Unexpected HELLO reply %v", hello)
+	}
+	c.expect("app", "CLIENT", "GETNAME")
+
+	// RESP3 null
+	c.send("GET", "missing")
+	if line, _ := c.br.ReadString('\n'); line != "_\r\n" {
+		t.Errorf("Expected RESP3 null, got %q", line)
+	}
+	c.expect("NOPROTO unsupported protocol version", "HELLO", "4")
+}
+
+func TestPipeliningAndInline(t *testing.T) {
+	c, _ := startServer(t)
+
+	if _, err := c.nc.Write([]byte("SET a 1\r\nGET a\r\nPING\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []any{"OK", "1", "PONG"} {
+		if got := c.read(); got != want {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestProtocolError(t *testing.T) {
+	c, _ := startServer(t)
+
+	c.nc.Write([]byte("*1\r\n+GET\r\n"))
+	if got := c.read(); got != "ERR Protocol error: expected '$', got '+'" {
+		t.Errorf("Unexpected reply %v", got)
+	}
+	if _, err := c.br.ReadByte(); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestReadCommandBoundedAllocation(t *testing.T) {
+	inputs := []string{
+		"*1048576\r\n$3\r\nGET\r\n",
+		"*2\r\n$3\r\nGET\r\n$536870912\r\nshort",
+	}
+	for _, in := range inputs {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := readCommand(bufio.NewReader(strings.NewReader(in))); err == nil {
+			t.Errorf("Expected an error for truncated input %q", in)
+		}
+		runtime.ReadMemStats(&after)
+		if n := after.TotalAlloc - before.TotalAlloc; n > 4<<20 {
+			t.Errorf("Expected a bounded allocation for %q, got %d bytes", in, n)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package mcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+// Snapshot format (all integers little-endian):
+//
+//	header:  [4:magic "MCSN"][2:version][2:reserved][8:savedAt unix nanos]
+//	block:   [uvarint:len][len:payload][4:crc32c(payload)]   (repeated)
+//	trailer: [uvarint:0][8:entry count][4:crc32c(count)]
+//
+// A payload is a sequence of records:
+//
+//	[uvarint:keyLen][key][uvarint:valueLen][value][varint:ttl nanos][varint:cost]
+//
+// ttl is the remaining lifetime at savedAt, 0 meaning no expiration.
+// Every block is verified before any of its entries are applied.
+const (
+	snapshotMagic   = "MCSN"
+	snapshotVersion = 1
+
+	snapshotHeaderSize = 16
+
+	// snapshotBlockTarget is the payload size at which a block is flushed.
+	snapshotBlockTarget = 1 << 20
+
+	// snapshotMaxBlock bounds the block size accepted when reading.
+	snapshotMaxBlock = 1 << 30
+
+	// snapshotReadChunk is the step in which block buffers grow while
+	// reading, so a corrupt length in a short input cannot force a large
+	// allocation.
+	snapshotReadChunk = 1 << 20
+)
+
+var (
+	// ErrNoCodec is returned by persistence operations when no Codec is
+	// configured with WithCodec.
+	ErrNoCodec = errors.New("mcache: no codec configured")
+
+	// ErrSnapshotCorrupt is returned by LoadFrom when the input is not a
+	// valid snapshot or fails checksum verification.
+	ErrSnapshotCorrupt = errors.New("mcache: corrupt snapshot")
+)
+
+var snapshotCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// SaveTo writes every live entry to w using the configured Codec.
+// It is safe to call on a live cache: shards are visited one at a time and
+// only briefly read-locked, so the snapshot is consistent per shard but
+// not across shards.
+func (c *Cache[K, V]) SaveTo(w io.Writer) error {
+	codec := c.config.Codec
+	if codec == nil {
+		return ErrNoCodec
+	}
+
+	bw := bufio.NewWriter(w)
+	savedAt := clock.NowNano()
+
+	var header [snapshotHeaderSize]byte
+	copy(header[0:4], snapshotMagic)
+	binary.LittleEndian.PutUint16(header[4:6], snapshotVersion)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(savedAt))
+	if _, err := bw.Write(header[:]); err != nil {
+		return err
+	}
+
+	var (
+		count   uint64
+		payload []byte
+		entries []*store.Entry[K, V]
+		err     error
+	)
+	for i := 0; i < c.store.ShardCount(); i++ {
+		// Entries are immutable snapshots, so collect them under the shard
+		// lock and encode outside of it.
+		entries = entries[:0]
+		c.store.RangeShard(i, func(entry *store.Entry[K, V]) bool {
+			entries = append(entries, entry)
+			return true
+		})
+
+		for _, entry := range entries {
+			var ttl int64
+			if entry.ExpireAt > 0 {
+				ttl = entry.ExpireAt - savedAt
+				if ttl <= 0 {
+					continue
+				}
+			}
+			payload, err = appendSnapshotRecord(payload, codec, entry.Key, entry.Value, ttl, entry.Cost)
+			if err != nil {
+				return err
+			}
+			count++
+
+			if len(payload) >= snapshotBlockTarget {
+				if err := writeSnapshotBlock(bw, payload); err != nil {
+					return err
+				}
+				payload = payload[:0]
+			}
+		}
+		clear(entries)
+	}
+	if len(payload) > 0 {
+		if err := writeSnapshotBlock(bw, payload); err != nil {
+			return err
+		}
+	}
+
+	// Trailer
+	var trailer [binary.MaxVarintLen64 + 12]byte
+	n := binary.PutUvarint(trailer[:], 0)
+	binary.LittleEndian.PutUint64(trailer[n:n+8], count)
+	binary.LittleEndian.PutUint32(trailer[n+8:n+12], crc32.Checksum(trailer[n:n+8], snapshotCRC))
+	if _, err := bw.Write(trailer[:n+12]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// LoadFrom reads a snapshot written by SaveTo and stores its entries.
+// Entries whose TTL ran out since the snapshot was taken are skipped, and
+// the rest go through the admission policy, so MaxCost and MaxEntries are
+// respected. Returns the number of entries stored.
+func (c *Cache[K, V]) LoadFrom(r io.Reader) (int, error) {
+	codec := c.config.Codec
+	if codec == nil {
+		return 0, ErrNoCodec
+	}
+	if c.closed.Load() {
+		return 0, ErrClosed
+	}
+
+	br := bufio.NewReader(r)
+
+	var header [snapshotHeaderSize]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return 0, snapshotReadErr(err)
+	}
+	if string(header[0:4]) != snapshotMagic {
+		return 0, fmt.Errorf("%w: bad magic", ErrSnapshotCorrupt)
+	}
+	if v := binary.LittleEndian.Uint16(header[4:6]); v != snapshotVersion {
+		return 0, fmt.Errorf("%w: unsupported version %d", ErrSnapshotCorrupt, v)
+	}
+	savedAt := int64(binary.LittleEndian.Uint64(header[8:16]))
+	elapsed := clock.NowNano() - savedAt
+
+	var (
+		loaded  int
+		decoded uint64
+		payload []byte
+	)
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return loaded, snapshotReadErr(err)
+		}
+		if size == 0 {
+			break
+		}
+		if size > snapshotMaxBlock {
+			return loaded, fmt.Errorf("%w: block too large", ErrSnapshotCorrupt)
+		}
+
+		if payload, err = readSnapshotBlock(br, payload[:0], int(size)); err != nil {
+			return loaded, snapshotReadErr(err)
+		}
+		var sum [4]byte
+		if _, err := io.ReadFull(br, sum[:]); err != nil {
+			return loaded, snapshotReadErr(err)
+		}
+		if binary.LittleEndian.Uint32(sum[:]) != crc32.Checksum(payload, snapshotCRC) {
+			return loaded, fmt.Errorf("%w: checksum mismatch", ErrSnapshotCorrupt)
+		}
+
+		for rest := payload; len(rest) > 0; {
+			var (
+				key   K
+				value V
+				ttl   int64
+				cost  int64
+			)
+			key, value, ttl, cost, rest, err = decodeSnapshotRecord(rest, codec)
+			if err != nil {
+				return loaded, err
+			}
+			decoded++
+
+			var expireAt int64
+			if ttl > 0 {
+				remaining := ttl - elapsed
+				if remaining <= 0 {
+					continue
+				}
+				expireAt = clock.NowNano() + remaining
+			}
+			if cost <= 0 {
+				cost, _ = c.normalizeWrite(value, 0, 0)
+			}
+			if c.setSync(key, value, c.store.KeyHash(key), cost, expireAt, nil) {
+				loaded++
+			}
+		}
+	}
+
+	c.finishWrite()
+
+	var trailer [12]byte
+	if _, err := io.ReadFull(br, trailer[:]); err != nil {
+		return loaded, snapshotReadErr(err)
+	}
+	if binary.LittleEndian.Uint32(trailer[8:12]) != crc32.Checksum(trailer[0:8], snapshotCRC) {
+		return loaded, fmt.Errorf("%w: trailer checksum mismatch", ErrSnapshotCorrupt)
+	}
+	if count := binary.LittleEndian.Uint64(trailer[0:8]); count != decoded {
+		return loaded, fmt.Errorf("%w: expected %d entries, read %d", ErrSnapshotCorrupt, count, decoded)
+	}
+	return loaded, nil
+}
+
+// writeSnapshotBlock writes a length-prefixed, checksummed block.
+func writeSnapshotBlock(w *bufio.Writer, payload []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(payload)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.LittleEndian.PutUint32(sum[:], crc32.Checksum(payload, snapshotCRC))
+	_, err := w.Write(sum[:])
+	return err
+}
+
+// appendSnapshotRecord appends one encoded entry to dst.
+func appendSnapshotRecord[K comparable, V any](dst []byte, codec Codec[K, V], key K, value V, ttl, cost int64) ([]byte, error) {
+	var err error
+	if dst, err = appendEncoded(dst, key, codec.AppendKey); err != nil {
+		return dst, err
+	}
+	if dst, err = appendEncoded(dst, value, codec.AppendValue); err != nil {
+		return dst, err
+	}
+	dst = binary.AppendVarint(dst, ttl)
+	dst = binary.AppendVarint(dst, cost)
+	return dst, nil
+}
+
+// decodeSnapshotRecord decodes one entry from src and returns the remainder.
+func decodeSnapshotRecord[K comparable, V any](src []byte, codec Codec[K, V]) (key K, value V, ttl, cost int64, rest []byte, err error) {
+	keyBytes, src, err := readLengthPrefixed(src)
+	if err != nil {
+		return key, value, 0, 0, nil, err
+	}
+	valueBytes, src, err := readLengthPrefixed(src)
+	if err != nil {
+		return key, value, 0, 0, nil, err
+	}
+	ttl, n := binary.Varint(src)
+	if n <= 0 {
+		return key, value, 0, 0, nil, fmt.Errorf("%w: bad ttl", ErrSnapshotCorrupt)
+	}
+	src = src[n:]
+	cost, n = binary.Varint(src)
+	if n <= 0 {
+		return key, value, 0, 0, nil, fmt.Errorf("%w: bad cost", ErrSnapshotCorrupt)
+	}
+	src = src[n:]
+
+	if key, err = codec.DecodeKey(keyBytes); err != nil {
+		return key, value, 0, 0, nil, err
+	}
+	if value, err = codec.DecodeValue(valueBytes); err != nil {
+		return key, value, 0, 0, nil, err
+	}
+	return key, value, ttl, cost, src, nil
+}
+
+// appendEncoded appends a uvarint length followed by the encoding of v.
+// The length is patched in after encoding, so the encoder writes in place.
+func appendEncoded[T any](dst []byte, v T, enc func([]byte, T) ([]byte, error)) ([]byte, error) {
+	start := len(dst)
+	// Reserve the maximum varint width, then shift the body if the
+	// actual length needs fewer bytes.
+	dst = append(dst, make([]byte, binary.MaxVarintLen64)...)
+	body := len(dst)
+	dst, err := enc(dst, v)
+	if err != nil {
+		return dst[:start], err
+	}
+	size := len(dst) - body
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(size))
+	copy(dst[start:], lenBuf[:n])
+	copy(dst[start+n:], dst[body:])
+	return dst[:start+n+size], nil
+}
+
+// readLengthPrefixed splits a uvarint-length-prefixed byte string off src.
+func readLengthPrefixed(src []byte) (field, rest []byte, err error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 || uint64(len(src)-n) < size {
+		return nil, nil, fmt.Errorf("%w: truncated record", ErrSnapshotCorrupt)
+	}
+	end := n + int(size)
+	return src[n:end], src[end:], nil
+}
+
+// snapshotReadErr maps a premature end of input to ErrSnapshotCorrupt.
+func snapshotReadErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: unexpected end of input", ErrSnapshotCorrupt)
+	}
+	return err
+}
+
+// readSnapshotBlock appends size bytes from r to buf, growing it by at most
+// snapshotReadChunk beyond what has been read.
+func readSnapshotBlock(r io.Reader, buf []byte, size int) ([]byte, error) {
+	for len(buf) < size {
+		n := min(size-len(buf), snapshotReadChunk)
+		buf = slices.Grow(buf, n)
+		if _, err := io.ReadFull(r, buf[len(buf):len(buf)+n]); err != nil {
+			return buf, err
+		}
+		buf = buf[:len(buf)+n]
+	}
+	return buf, nil
+}
@@ -0,0 +1,129 @@
+package mcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src := NewCache[string, []byte](
+		WithCodec[string, []byte](StringBytesCodec{}),
+	)
+	defer src.Close()
+
+	for i := 0; i < 1000; i++ {
+		src.SetWithCost(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), int64(i+1), 0)
+	}
+	src.Set("short", []byte("x"), 20*time.Millisecond)
+	src.Set("long", []byte("y"), time.Hour)
+
+	var buf bytes.Buffer
+	if err := src.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo: %v", err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	dst := NewCache[string, []byte](
+		WithCodec[string, []byte](StringBytesCodec{}),
+	)
+	defer dst.Close()
+
+	n, err := dst.LoadFrom(&buf)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if n != 1001 {
+		t.Errorf("Expected 1001 loaded entries, got %d", n)
+	}
+	for i := 0; i < 1000; i++ {
+		v, ok := dst.Get(fmt.Sprintf("key%d", i))
+		if !ok || string(v) != fmt.Sprintf("value%d", i) {
+			t.Fatalf("key%d: got %q, ok=%v", i, v, ok)
+		}
+	}
+	if dst.Has("short") {
+		t.Error("Expected entry that expired since the snapshot to be skipped")
+	}
+	if !dst.Has("long") {
+		t.Error("Expected entry with remaining TTL to be loaded")
+	}
+}
+
+func TestSnapshotRespectsMaxEntries(t *testing.T) {
+	src := NewCache[int, string](WithCodec[int, string](GobCodec[int, string]{}))
+	defer src.Close()
+	for i := 0; i < 100; i++ {
+		src.Set(i, fmt.Sprint(i), 0)
+	}
+
+	var buf bytes.Buffer
+	if err := src.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo: %v", err)
+	}
+
+	dst := NewCache[int, string](
+		WithCodec[int, string](GobCodec[int, string]{}),
+		WithMaxEntries[int, string](10),
+	)
+	defer dst.Close()
+	if _, err := dst.LoadFrom(&buf); err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if dst.Len() > 11 {
+		t.Errorf("Expected load to respect MaxEntries, got %d entries", dst.Len())
+	}
+}
+
+func TestSnapshotDetectsCorruption(t *testing.T) {
+	src := NewCache[string, []byte](WithCodec[string, []byte](StringBytesCodec{}))
+	defer src.Close()
+	src.Set("a", []byte("apple"), 0)
+	src.Set("b", []byte("banana"), 0)
+
+	var buf bytes.Buffer
+	if err := src.SaveTo(&buf); err != nil {
+		t.Fatalf("SaveTo: %v", err)
+	}
+	data := buf.Bytes()
+
+	flipped := bytes.Clone(data)
+	flipped[snapshotHeaderSize+3] ^= 0xFF
+	dst := NewCache[string, []byte](WithCodec[string, []byte](StringBytesCodec{}))
+	defer dst.Close()
+	if _, err := dst.LoadFrom(bytes.NewReader(flipped)); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected ErrSnapshotCorrupt for flipped byte, got %v", err)
+	}
+	if dst.Len() != 0 {
+		t.Errorf("Expected no entries from a corrupt block, got %d", dst.Len())
+	}
+
+	if _, err := dst.LoadFrom(bytes.NewReader(data[:len(data)-5])); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected ErrSnapshotCorrupt for truncated input, got %v", err)
+	}
+	// A huge block length in a short input fails without allocating it.
+	huge := binary.AppendUvarint(bytes.Clone(data[:snapshotHeaderSize]), snapshotMaxBlock)
+	huge = append(huge, "short"...)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := dst.LoadFrom(bytes.NewReader(huge)); !errors.Is(err, ErrSnapshotCorrupt) {
+		t.Errorf("Expected ErrSnapshotCorrupt for a truncated huge block, got %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 16<<20 {
+		t.Errorf("Expected a bounded allocation for a truncated block, got %d bytes", n)
+	}
+}
+
+func TestSnapshotRequiresCodec(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+	if err := c.SaveTo(&bytes.Buffer{}); !errors.Is(err, ErrNoCodec) {
+		t.Errorf("Expected ErrNoCodec, got %v", err)
+	}
+}
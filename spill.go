@@ -0,0 +1,166 @@
+package mcache
+
+import (
+	"context"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+// SpillStore is an L2Store on local disk for datasets slightly larger
+// than memory. Entries are encoded with a Codec and appended, in the
+// record layout of SerializedCache, to memory-mapped segment files,
+// indexed by key hash in memory. A segment is rewritten once half of it
+// holds overwritten or deleted entries, and with WithSpillMaxBytes the
+// oldest segment is dropped to make room. The index is rebuilt from the
+// segments when the store is reopened.
+//
+// As in SerializedCache, entries are identified by the hash of their
+// encoded key, so a write replaces an entry whose key hashes alike.
+// OpenSpillCache sets up a TieredCache that spills evicted entries to a
+// SpillStore.
+type SpillStore[K comparable, V any] struct {
+	segs  *store.SegmentStore
+	codec Codec[K, V]
+}
+
+// SpillOption configures a SpillStore.
+type SpillOption func(*spillConfig)
+
+type spillConfig struct {
+	SegmentSize int
+	MaxBytes    int64
+}
+
+// WithSpillSegmentSize sets the size of each segment file; an entry must
+// fit in one. Default: 64MB.
+func WithSpillSegmentSize(size int) SpillOption {
+	return func(cfg *spillConfig) {
+		cfg.SegmentSize = size
+	}
+}
+
+// WithSpillMaxBytes limits the total size of the segment files. When a
+// new segment would exceed it, the oldest segment and its entries are
+// dropped. Default: 0 (unlimited).
+func WithSpillMaxBytes(n int64) SpillOption {
+	return func(cfg *spillConfig) {
+		cfg.MaxBytes = n
+	}
+}
+
+// OpenSpillStore opens a SpillStore in dir, creating the directory if
+// needed, and encoding entries with codec.
+func OpenSpillStore[K comparable, V any](dir string, codec Codec[K, V], opts ...SpillOption) (*SpillStore[K, V], error) {
+	if codec == nil {
+		return nil, ErrNoCodec
+	}
+	cfg := spillConfig{SegmentSize: store.DefaultSegmentSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	segs, err := store.OpenSegmentStore(dir, cfg.SegmentSize, cfg.MaxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &SpillStore[K, V]{segs: segs, codec: codec}, nil
+}
+
+// OpenSpillCache creates a TieredCache with a SpillStore in dir as L2 and
+// L2WriteOnEvict, so entries live in memory until the policy evicts them,
+// then spill to disk and are served from there on a miss in memory. opts
+// configure the in-memory tier as for NewCache.
+func OpenSpillCache[K comparable, V any](dir string, codec Codec[K, V], spillOpts []SpillOption, opts ...Option[K, V]) (*TieredCache[K, V], error) {
+	spill, err := OpenSpillStore(dir, codec, spillOpts...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]Option[K, V]{WithL2WriteMode[K, V](L2WriteOnEvict)}, opts...)
+	t, err := OpenTieredCache[K, V](spill, opts...)
+	if err != nil {
+		spill.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Get implements L2Store.
+func (s *SpillStore[K, V]) Get(ctx context.Context, key K) (Item[K, V], bool, error) {
+	var item Item[K, V]
+	if err := ctx.Err(); err != nil {
+		return item, false, err
+	}
+	encKey, err := s.codec.AppendKey(nil, key)
+	if err != nil {
+		return item, false, err
+	}
+	data, expireAt, cost, ok := s.segs.Get(encKey)
+	if !ok {
+		return item, false, nil
+	}
+	ttl, live := remainingTTL(expireAt)
+	if !live {
+		return item, false, nil
+	}
+	value, err := s.codec.DecodeValue(data)
+	if err != nil {
+		return item, false, err
+	}
+	return Item[K, V]{Key: key, Value: value, Cost: cost, TTL: ttl}, true, nil
+}
+
+// Set implements L2Store. It returns store.ErrEntryTooLarge for an entry
+// that does not fit in a segment.
+func (s *SpillStore[K, V]) Set(ctx context.Context, item Item[K, V]) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	encKey, err := s.codec.AppendKey(nil, item.Key)
+	if err != nil {
+		return err
+	}
+	value, err := s.codec.AppendValue(nil, item.Value)
+	if err != nil {
+		return err
+	}
+	var expireAt int64
+	if item.TTL > 0 {
+		expireAt = clock.NowNano() + int64(item.TTL)
+	}
+	return s.segs.Set(encKey, value, expireAt, item.Cost)
+}
+
+// Delete implements L2Store.
+func (s *SpillStore[K, V]) Delete(ctx context.Context, key K) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	encKey, err := s.codec.AppendKey(nil, key)
+	if err != nil {
+		return false, err
+	}
+	return s.segs.Delete(encKey), nil
+}
+
+// Clear implements L2Store.
+func (s *SpillStore[K, V]) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.segs.Clear()
+}
+
+// Close implements L2Store.
+func (s *SpillStore[K, V]) Close() error {
+	return s.segs.Close()
+}
+
+// Len returns the number of entries on disk.
+func (s *SpillStore[K, V]) Len() int {
+	return s.segs.Len()
+}
+
+// Size returns the total size of the segment files in bytes.
+func (s *SpillStore[K, V]) Size() int64 {
+	return s.segs.Size()
+}
@@ -0,0 +1,166 @@
+package mcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSpillStore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	s, err := OpenSpillStore[string, []byte](dir, StringBytesCodec{}, WithSpillSegmentSize(4096))
+	if err != nil {
+		t.Fatalf("OpenSpillStore: %v", err)
+	}
+
+	s.Set(ctx, Item[string, []byte]{Key: "a", Value: []byte("alpha"), Cost: 5})
+	s.Set(ctx, Item[string, []byte]{Key: "short", Value: []byte("x"), TTL: 20 * time.Millisecond})
+	item, ok, err := s.Get(ctx, "a")
+	if err != nil || !ok || string(item.Value) != "alpha" || item.Cost != 5 {
+		t.Errorf("Expected alpha with cost 5, got %+v, %v, %v", item, ok, err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, ok, _ := s.Get(ctx, "short"); ok {
+		t.Error("Expected an expired entry to read as missing")
+	}
+
+	// Overwrites fill segments with dead records, which compaction
+	// reclaims.
+	value := make([]byte, 100)
+	for i := 0; i < 1000; i++ {
+		s.Set(ctx, Item[string, []byte]{Key: fmt.Sprintf("k%d", i%10), Value: value})
+	}
+	if size := s.Size(); size > 8*4096 {
+		t.Errorf("Expected compaction to bound the files, got %d bytes", size)
+	}
+	for i := 0; i < 10; i++ {
+		if _, ok, _ := s.Get(ctx, fmt.Sprintf("k%d", i)); !ok {
+			t.Errorf("Expected k%d to survive compaction", i)
+		}
+	}
+
+	if ok, _ := s.Delete(ctx, "k0"); !ok {
+		t.Error("Expected Delete to report an existing entry")
+	}
+	if _, err := s.Delete(ctx, "k0"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+
+	// Entries survive reopening.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s, err = OpenSpillStore[string, []byte](dir, StringBytesCodec{}, WithSpillSegmentSize(4096))
+	if err != nil {
+		t.Fatalf("OpenSpillStore: %v", err)
+	}
+	defer s.Close()
+	if item, ok, _ := s.Get(ctx, "a"); !ok || string(item.Value) != "alpha" {
+		t.Errorf("Expected alpha after reopening, got %+v, %v", item, ok)
+	}
+	if _, ok, _ := s.Get(ctx, "k0"); ok {
+		t.Error("Expected a deleted entry to stay deleted after reopening")
+	}
+	for i := 1; i < 10; i++ {
+		if _, ok, _ := s.Get(ctx, fmt.Sprintf("k%d", i)); !ok {
+			t.Errorf("Expected k%d after reopening", i)
+		}
+	}
+
+	if err := s.Clear(ctx); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Expected Clear to remove every entry, got %d", s.Len())
+	}
+}
+
+func TestSpillStoreMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSpillStore[string, []byte](t.TempDir(), StringBytesCodec{},
+		WithSpillSegmentSize(4096),
+		WithSpillMaxBytes(4*4096),
+	)
+	if err != nil {
+		t.Fatalf("OpenSpillStore: %v", err)
+	}
+	defer s.Close()
+
+	value := make([]byte, 100)
+	for i := 0; i < 1000; i++ {
+		s.Set(ctx, Item[string, []byte]{Key: fmt.Sprintf("k%d", i), Value: value})
+	}
+	if size := s.Size(); size > 4*4096 {
+		t.Errorf("Expected at most %d bytes, got %d", 4*4096, size)
+	}
+	if _, ok, _ := s.Get(ctx, "k0"); ok {
+		t.Error("Expected the oldest entries to be dropped")
+	}
+	if _, ok, _ := s.Get(ctx, "k999"); !ok {
+		t.Error("Expected the newest entry to be kept")
+	}
+
+	if err := s.Set(ctx, Item[string, []byte]{Key: "big", Value: make([]byte, 8192)}); err == nil {
+		t.Error("Expected an entry larger than a segment to be refused")
+	}
+}
+
+func TestSpillStoreCompactThenLargeWrite(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSpillStore[string, []byte](t.TempDir(), StringBytesCodec{}, WithSpillSegmentSize(4096))
+	if err != nil {
+		t.Fatalf("OpenSpillStore: %v", err)
+	}
+	defer s.Close()
+
+	// Half of the first segment is dead, so the rotation for the large
+	// write compacts its live half into the new segment first.
+	value := make([]byte, 100)
+	for i := 0; i < 30; i++ {
+		s.Set(ctx, Item[string, []byte]{Key: fmt.Sprintf("k%02d", i), Value: value})
+	}
+	for i := 0; i < 30; i += 2 {
+		s.Delete(ctx, fmt.Sprintf("k%02d", i))
+	}
+	big := make([]byte, 3500)
+	big[len(big)-1] = 1
+	if err := s.Set(ctx, Item[string, []byte]{Key: "big", Value: big}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if item, ok, _ := s.Get(ctx, "big"); !ok || len(item.Value) != len(big) || item.Value[len(big)-1] != 1 {
+		t.Errorf("Expected the large value back, got %d bytes, %v", len(item.Value), ok)
+	}
+	for i := 1; i < 30; i += 2 {
+		if _, ok, _ := s.Get(ctx, fmt.Sprintf("k%02d", i)); !ok {
+			t.Errorf("Expected k%02d to survive compaction", i)
+		}
+	}
+}
+
+func TestSpillCache(t *testing.T) {
+	c, err := OpenSpillCache[string, []byte](t.TempDir(), StringBytesCodec{}, nil,
+		WithMaxEntries[string, []byte](100),
+	)
+	if err != nil {
+		t.Fatalf("OpenSpillCache: %v", err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 1000; i++ {
+		c.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), 0)
+	}
+	c.Wait()
+
+	spill := c.L2().(*SpillStore[string, []byte])
+	if spill.Len() < 800 {
+		t.Errorf("Expected evicted entries to spill to disk, got %d", spill.Len())
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := c.Get(fmt.Sprintf("key%d", i)); !ok || string(v) != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected key%d from memory or disk, got %q, %v", i, v, ok)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package mcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/store"
+)
+
+// SetWithTags is Set for an entry carrying tags. InvalidateTag removes all
+// entries with a given tag. Tags belong to the entry: any later write to
+// the key other than Compute and Expire replaces them, and they are not
+// saved in snapshots or the write-ahead log.
+func (c *Cache[K, V]) SetWithTags(key K, value V, ttl time.Duration, tags ...string) bool {
+	ok, _ := c.setWithCost(context.Background(), key, value, 1, ttl, newLinks[K](tags, nil))
+	return ok
+}
+
+// InvalidateTag deletes every entry tagged with tag and returns the number
+// of entries deleted. It takes time proportional to the number of entries
+// carrying the tag. Buffered writes are applied first, so an entry written
+// with the tag before the call is always removed.
+func (c *Cache[K, V]) InvalidateTag(tag string) int {
+	if c.closed.Load() {
+		return 0
+	}
+	n := c.invalidateTag(tag)
+	var zero K
+	c.publish(InvalidationTag, zero, tag)
+	return n
+}
+
+// invalidateTag implements InvalidateTag without publishing it to the
+// Invalidator.
+func (c *Cache[K, V]) invalidateTag(tag string) int {
+	if c.writeBuffer != nil {
+		c.writeBuffer.FlushSync()
+	}
+
+	n := 0
+	for _, key := range c.tags.keys(tag) {
+		// The index may briefly lag a concurrent write, so only delete
+		// the entry if it still carries the tag.
+		deleted, _ := c.compareAndDelete(key, func(e *store.Entry[K, V]) bool {
+			return e.Links.HasTag(tag)
+		})
+		if deleted {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,150 @@
+package mcache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	c.SetWithTags("user:1", 1, 0, "users", "team:a")
+	c.SetWithTags("user:2", 2, 0, "users", "team:b", "users")
+	c.SetWithTags("team:a", 3, 0, "team:a")
+	c.Set("other", 4, 0)
+
+	if n := c.InvalidateTag("users"); n != 2 {
+		t.Errorf("Expected 2 entries invalidated, got %d", n)
+	}
+	for _, key := range []string{"user:1", "user:2"} {
+		if c.Has(key) {
+			t.Errorf("Expected %s to be invalidated", key)
+		}
+	}
+	if !c.Has("team:a") || !c.Has("other") {
+		t.Error("Expected untagged and differently tagged entries to remain")
+	}
+
+	// user:1 is gone, so only team:a remains under its tag.
+	if n := c.InvalidateTag("team:a"); n != 1 {
+		t.Errorf("Expected 1 entry invalidated, got %d", n)
+	}
+	if n := c.InvalidateTag("missing"); n != 0 {
+		t.Errorf("Expected 0 entries invalidated, got %d", n)
+	}
+}
+
+func TestInvalidateTagOverwrite(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	c.SetWithTags("k", 1, 0, "old")
+	c.Set("k", 2, 0)
+	if n := c.InvalidateTag("old"); n != 0 {
+		t.Errorf("Expected an overwrite to drop the tags, got %d invalidated", n)
+	}
+
+	c.SetWithTags("k", 3, 0, "new")
+	c.Compute("k", func(old int, exists bool) (int, ComputeOp) {
+		return old + 1, ComputeSet
+	})
+	if n := c.InvalidateTag("new"); n != 1 {
+		t.Errorf("Expected Compute to keep the tags, got %d invalidated", n)
+	}
+}
+
+func TestInvalidateTagIndexConsistency(t *testing.T) {
+	c := NewCache[string, int](WithMaxEntries[string, int](100))
+	defer c.Close()
+
+	c.SetWithTags("deleted", 1, 0, "t")
+	c.SetWithTags("expired", 1, 10*time.Millisecond, "t")
+	c.Delete("deleted")
+	time.Sleep(50 * time.Millisecond)
+	c.Get("expired")
+
+	// Recreate the deleted key without the tag.
+	c.Set("deleted", 2, 0)
+	if n := c.InvalidateTag("t"); n != 0 {
+		t.Errorf("Expected 0 entries invalidated, got %d", n)
+	}
+	if !c.Has("deleted") {
+		t.Error("Expected the untagged entry to remain")
+	}
+
+	c.SetMany([]Item[string, int]{
+		{Key: "a", Value: 1, Tags: []string{"batch"}},
+		{Key: "b", Value: 2, Tags: []string{"batch"}},
+		{Key: "c", Value: 3},
+	})
+	if n := c.InvalidateTag("batch"); n != 2 {
+		t.Errorf("Expected 2 entries invalidated, got %d", n)
+	}
+
+	c.SetWithTags("x", 1, 0, "cleared")
+	c.Clear()
+	if keys := c.tags.keys("cleared"); len(keys) != 0 {
+		t.Errorf("Expected Clear to reset the index, got %v", keys)
+	}
+}
+
+func TestInvalidateTagEviction(t *testing.T) {
+	c := NewCache[int, int](WithMaxEntries[int, int](50))
+	defer c.Close()
+
+	for i := 0; i < 1000; i++ {
+		c.SetWithTags(i, i, 0, "all")
+	}
+	// Evicted entries must have left the index.
+	if keys := c.tags.keys("all"); len(keys) != c.Len() {
+		t.Errorf("Expected %d indexed keys, got %d", c.Len(), len(keys))
+	}
+	c.InvalidateTag("all")
+	if c.Len() != 0 {
+		t.Errorf("Expected all entries invalidated, %d remain", c.Len())
+	}
+}
+
+func TestInvalidateTagBuffered(t *testing.T) {
+	c := NewCache[string, int](WithBufferItems[string, int](64))
+	defer c.Close()
+
+	c.SetWithTags("k", 1, 0, "t")
+	if n := c.InvalidateTag("t"); n != 1 {
+		t.Errorf("Expected a buffered write to be invalidated, got %d", n)
+	}
+}
+
+func TestInvalidateTagConcurrent(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	const keys = 20
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("k%d", i%keys)
+				if i%3 == 0 {
+					c.Set(key, i, 0)
+				} else {
+					c.SetWithTags(key, i, 0, "t")
+				}
+				if i%50 == 0 {
+					c.InvalidateTag("t")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.InvalidateTag("t")
+	if len(c.tags.byLink) != 0 {
+		t.Errorf("Expected an empty index, got %v", c.tags.byLink)
+	}
+}
@@ -0,0 +1,521 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+)
+
+// errL2Miss is returned by TieredCache.load when L2 has no item for a key.
+var errL2Miss = errors.New("mcache: not found in L2")
+
+// L2Store is the second tier of a TieredCache, such as a local disk or a
+// remote cache. Items carry the cost and remaining TTL of an entry, 0
+// meaning no expiration; tags and dependencies are not stored.
+// Implementations must be safe for concurrent use and must not return
+// expired items.
+type L2Store[K comparable, V any] interface {
+	// Get returns the item stored under key, or false if there is none.
+	Get(ctx context.Context, key K) (Item[K, V], bool, error)
+	// Set stores item, replacing any item with the same key.
+	Set(ctx context.Context, item Item[K, V]) error
+	// Delete removes key, reporting whether it was present.
+	Delete(ctx context.Context, key K) (bool, error)
+	// Clear removes all items.
+	Clear(ctx context.Context) error
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// L2WriteMode controls when a TieredCache writes to its L2Store.
+type L2WriteMode int
+
+const (
+	// L2WriteThrough writes to L2 synchronously, then to L1, so a write
+	// fails without touching L1 if L2 fails.
+	L2WriteThrough L2WriteMode = iota
+	// L2WriteBehind writes to L1 and queues the write to L2. Queued writes
+	// to the same key are coalesced and applied in the background; errors
+	// are reported by L2Error.
+	L2WriteBehind
+	// L2WriteOnEvict writes to L1 only. Values reach L2 when L1 evicts
+	// them or its admission policy rejects them, which keeps the tiers
+	// mostly exclusive.
+	L2WriteOnEvict
+)
+
+// TieredCache layers a Cache (L1) over an L2Store (L2), keeping hot
+// entries in memory and a warm tail in a larger, slower store:
+//
+//   - A miss in L1 reads through to L2. An L2 hit is promoted back into L1
+//     through its admission policy; concurrent misses for the same key
+//     share one L2 read. A value read before a Set, Delete or Clear of
+//     the key finished is returned but not promoted.
+//   - Writes reach L2 as set by WithL2WriteMode.
+//   - Entries evicted from L1 to make room are demoted to L2 in the
+//     background instead of being dropped, whatever the write mode.
+//   - Deletes and Clear remove entries from both tiers.
+//
+// It accepts the same options as Cache, which configure L1; writes made
+// directly to L1 reach L2 only by demotion. Metrics reports the L2 hits,
+// misses, errors and demotions along with the metrics of L1.
+type TieredCache[K comparable, V any] struct {
+	l1     *Cache[K, V]
+	l2     L2Store[K, V]
+	mode   L2WriteMode
+	queue  l2Queue[K, V]
+	guards [tieredGuards]writeGuard
+	closed atomic.Bool
+
+	errMu sync.Mutex
+	err   error // First error of a queued L2 write
+}
+
+// NewTieredCache creates a TieredCache over l2 with the given options.
+// It panics if L1 cannot be set up; use OpenTieredCache to handle such
+// errors.
+func NewTieredCache[K comparable, V any](l2 L2Store[K, V], opts ...Option[K, V]) *TieredCache[K, V] {
+	t, err := OpenTieredCache(l2, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// OpenTieredCache is like NewTieredCache but returns an error instead of
+// panicking, as OpenCache does.
+func OpenTieredCache[K comparable, V any](l2 L2Store[K, V], opts ...Option[K, V]) (*TieredCache[K, V], error) {
+	t := &TieredCache[K, V]{l2: l2}
+	t.queue.init()
+
+	opts = append(slices.Clip(opts), func(cfg *config[K, V]) {
+		cfg.Demote = t.demote
+		cfg.GuardLoad = t.guardLoad
+	})
+	l1, err := OpenCache(opts...)
+	if err != nil {
+		return nil, err
+	}
+	t.l1 = l1
+	t.mode = l1.config.L2WriteMode
+	go t.queue.run(t.apply)
+	return t, nil
+}
+
+// L1 returns the in-memory tier.
+func (t *TieredCache[K, V]) L1() *Cache[K, V] {
+	return t.l1
+}
+
+// L2 returns the second tier.
+func (t *TieredCache[K, V]) L2() L2Store[K, V] {
+	return t.l2
+}
+
+// Get retrieves a value from L1, or from L2 on a miss in L1.
+// L2 errors are treated as misses; use GetCtx to see them.
+func (t *TieredCache[K, V]) Get(key K) (V, bool) {
+	value, ok, _ := t.GetCtx(context.Background(), key)
+	return value, ok
+}
+
+// GetCtx is Get with a context, which is passed on to L2. It returns the
+// error of L2, if any, ctx.Err() if ctx is done before the value is read,
+// and ErrClosed if the cache is closed.
+func (t *TieredCache[K, V]) GetCtx(ctx context.Context, key K) (V, bool, error) {
+	value, err := t.l1.GetOrLoad(ctx, key, t.load)
+	switch {
+	case err == nil:
+		return value, true, nil
+	case errors.Is(err, errL2Miss):
+		err = nil
+	}
+	var zero V
+	return zero, false, err
+}
+
+// load reads key from L2 for GetOrLoad, which promotes the value into L1.
+// Queued writes are newer than L2, so they are looked up first.
+func (t *TieredCache[K, V]) load(ctx context.Context, key K) (V, int64, time.Duration, error) {
+	var zero V
+
+	item, found, err := t.queued(key)
+	if !found {
+		item, found, err = t.l2.Get(ctx, key)
+	}
+	if err != nil {
+		t.l1.metrics.incL2Error()
+		return zero, 0, 0, err
+	}
+	if !found {
+		t.l1.metrics.incL2Miss()
+		return zero, 0, 0, errL2Miss
+	}
+	t.l1.metrics.incL2Hit()
+	return item.Value, item.Cost, item.TTL, nil
+}
+
+// queued returns the item of a queued write to key. found is true if a
+// write is queued; err is errL2Miss if it is a delete.
+func (t *TieredCache[K, V]) queued(key K) (item Item[K, V], found bool, err error) {
+	op, ok := t.queue.get(key)
+	if !ok {
+		return item, false, nil
+	}
+	ttl, live := remainingTTL(op.expireAt)
+	if op.del || !live {
+		return item, true, errL2Miss
+	}
+	return Item[K, V]{Key: key, Value: op.value, Cost: op.cost, TTL: ttl}, true, nil
+}
+
+// Set stores a value with a cost of 1.
+func (t *TieredCache[K, V]) Set(key K, value V, ttl time.Duration) bool {
+	return t.SetWithCost(key, value, 1, ttl)
+}
+
+// SetWithCost stores a value with an explicit cost. It reports whether
+// the value was stored in either tier or, with L2WriteBehind, queued for
+// L2.
+func (t *TieredCache[K, V]) SetWithCost(key K, value V, cost int64, ttl time.Duration) bool {
+	ok, _ := t.SetWithCostCtx(context.Background(), key, value, cost, ttl)
+	return ok
+}
+
+// SetWithCostCtx is SetWithCost with a context, which is passed on to L2.
+// It returns the error of L2 with L2WriteThrough, and ErrClosed if the
+// cache is closed.
+func (t *TieredCache[K, V]) SetWithCostCtx(ctx context.Context, key K, value V, cost int64, ttl time.Duration) (bool, error) {
+	if t.closed.Load() {
+		return false, ErrClosed
+	}
+	cost, expireAt := t.l1.normalizeWrite(value, cost, ttl)
+	g := t.guard(key)
+	g.begin()
+	defer g.end()
+
+	switch t.mode {
+	case L2WriteThrough:
+		t.queue.drop(key)
+		item := Item[K, V]{Key: key, Value: value, Cost: cost}
+		item.TTL, _ = remainingTTL(expireAt)
+		if err := t.l2.Set(ctx, item); err != nil {
+			t.l1.metrics.incL2Error()
+			return false, err
+		}
+		_, err := t.l1.setWithCost(ctx, key, value, cost, ttl, nil)
+		return err == nil, err
+	case L2WriteBehind:
+		t.queue.put(key, l2Op[V]{value: value, cost: cost, expireAt: expireAt})
+		_, err := t.l1.setWithCost(ctx, key, value, cost, ttl, nil)
+		return err == nil, err
+	}
+
+	// An older demoted value must not outlive the new one in L1. Queue
+	// its delete first so that a demotion of the new value replaces it.
+	t.queue.put(key, l2Op[V]{del: true})
+	// Write synchronously, bypassing the write buffer, to learn whether
+	// admission rejected the value.
+	ok := t.l1.setSync(key, value, t.l1.store.KeyHash(key), cost, expireAt, nil)
+	t.l1.finishWrite()
+	if !ok {
+		// Keep the rejected value in L2 instead.
+		t.queue.put(key, l2Op[V]{value: value, cost: cost, expireAt: expireAt})
+	}
+	return true, nil
+}
+
+// Delete removes a key from both tiers.
+func (t *TieredCache[K, V]) Delete(key K) bool {
+	ok, _ := t.DeleteCtx(context.Background(), key)
+	return ok
+}
+
+// DeleteCtx is Delete with a context, which is passed on to L2. It
+// reports whether either tier held the key; with L2WriteBehind, L2 is
+// updated in the background and only L1 is reported.
+func (t *TieredCache[K, V]) DeleteCtx(ctx context.Context, key K) (bool, error) {
+	if t.closed.Load() {
+		return false, ErrClosed
+	}
+	g := t.guard(key)
+	g.begin()
+	defer g.end()
+
+	ok, err := t.l1.delete(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if t.mode == L2WriteBehind {
+		t.queue.put(key, l2Op[V]{del: true})
+		return ok, nil
+	}
+
+	t.queue.drop(key)
+	found, err := t.l2.Delete(ctx, key)
+	if err != nil {
+		t.l1.metrics.incL2Error()
+		return ok, err
+	}
+	return ok || found, nil
+}
+
+// Clear removes all entries from both tiers, dropping queued writes.
+func (t *TieredCache[K, V]) Clear() error {
+	if t.closed.Load() {
+		return ErrClosed
+	}
+	for i := range t.guards {
+		t.guards[i].begin()
+		defer t.guards[i].end()
+	}
+	t.l1.Clear()
+	t.queue.reset()
+	if err := t.l2.Clear(context.Background()); err != nil {
+		t.l1.metrics.incL2Error()
+		return err
+	}
+	return nil
+}
+
+// Wait blocks until pending writes to L1 are applied and queued writes
+// and demotions have reached L2.
+func (t *TieredCache[K, V]) Wait() {
+	t.l1.Wait()
+	t.queue.wait()
+}
+
+// Metrics returns the metrics of L1, including the L2 counters.
+func (t *TieredCache[K, V]) Metrics() MetricsSnapshot {
+	return t.l1.Metrics()
+}
+
+// L2Error returns the first error of a queued write or demotion to L2,
+// or nil. Failed writes are not retried.
+func (t *TieredCache[K, V]) L2Error() error {
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+	return t.err
+}
+
+// Close closes L1, applies the queued writes to L2 and closes L2,
+// returning its error.
+func (t *TieredCache[K, V]) Close() error {
+	if t.closed.Swap(true) {
+		return nil
+	}
+	t.l1.Close()
+	t.queue.close()
+	return t.l2.Close()
+}
+
+// demote queues an entry evicted from L1 for L2.
+func (t *TieredCache[K, V]) demote(key K, value V, cost, expireAt int64) {
+	t.queue.put(key, l2Op[V]{value: value, cost: cost, expireAt: expireAt})
+}
+
+// apply performs a queued write to L2.
+func (t *TieredCache[K, V]) apply(key K, op l2Op[V]) {
+	ctx := context.Background()
+	var err error
+	if op.del {
+		_, err = t.l2.Delete(ctx, key)
+	} else if ttl, live := remainingTTL(op.expireAt); live {
+		err = t.l2.Set(ctx, Item[K, V]{Key: key, Value: op.value, Cost: op.cost, TTL: ttl})
+	}
+	if err == nil {
+		return
+	}
+	t.l1.metrics.incL2Error()
+	t.errMu.Lock()
+	if t.err == nil {
+		t.err = err
+	}
+	t.errMu.Unlock()
+}
+
+// guard returns the write guard of key.
+func (t *TieredCache[K, V]) guard(key K) *writeGuard {
+	return &t.guards[t.l1.store.KeyHash(key)%tieredGuards]
+}
+
+// guardLoad is the GuardLoad of L1. It lets a promotion store its value
+// only if no write to the stripe of the key started since the L2 read, so
+// a value read before a Set, Delete or Clear never replaces it in L1.
+func (t *TieredCache[K, V]) guardLoad(key K) func(store func() bool) bool {
+	g := t.guard(key)
+	gen := g.generation()
+	return func(store func() bool) bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.writing > 0 || g.gen != gen {
+			return false
+		}
+		return store()
+	}
+}
+
+// tieredGuards is the number of write guards of a TieredCache. Keys share
+// a guard by hash, so a write may needlessly skip the promotion of another
+// key, which is then read from L2 again.
+const tieredGuards = 256
+
+// writeGuard tracks the writes to the keys of a stripe.
+type writeGuard struct {
+	mu      sync.Mutex
+	writing int    // Writes in progress
+	gen     uint64 // Incremented when a write starts or ends
+}
+
+// begin marks the start of a write.
+func (g *writeGuard) begin() {
+	g.mu.Lock()
+	g.writing++
+	g.gen++
+	g.mu.Unlock()
+}
+
+// end marks the end of a write started by begin.
+func (g *writeGuard) end() {
+	g.mu.Lock()
+	g.writing--
+	g.gen++
+	g.mu.Unlock()
+}
+
+// generation returns the current generation.
+func (g *writeGuard) generation() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gen
+}
+
+// remainingTTL converts an absolute expiration to the remaining lifetime,
+// 0 meaning none. live is false if it has passed.
+func remainingTTL(expireAt int64) (ttl time.Duration, live bool) {
+	if expireAt == 0 {
+		return 0, true
+	}
+	ttl = time.Duration(expireAt - clock.NowNano())
+	return ttl, ttl > 0
+}
+
+// l2Op is a queued write to L2: a set, or a delete if del is true.
+type l2Op[V any] struct {
+	value    V
+	cost     int64
+	expireAt int64
+	del      bool
+}
+
+// l2Queue holds the writes to L2 that are applied in the background,
+// keeping only the latest one per key. Each round applies everything
+// queued so far as one batch.
+type l2Queue[K comparable, V any] struct {
+	mu       sync.Mutex
+	cond     sync.Cond // Signalled when ops are queued or a batch is applied
+	pending  map[K]l2Op[V]
+	inflight map[K]l2Op[V] // Batch being applied; nil between rounds
+	closed   bool
+	done     chan struct{}
+}
+
+func (q *l2Queue[K, V]) init() {
+	q.cond.L = &q.mu
+	q.pending = make(map[K]l2Op[V])
+	q.done = make(chan struct{})
+}
+
+// run applies queued ops until the queue is closed and drained.
+func (q *l2Queue[K, V]) run(apply func(K, l2Op[V])) {
+	defer close(q.done)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		for len(q.pending) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.pending) == 0 {
+			return
+		}
+		q.inflight, q.pending = q.pending, make(map[K]l2Op[V])
+		q.mu.Unlock()
+		for key, op := range q.inflight {
+			apply(key, op)
+		}
+		q.mu.Lock()
+		q.inflight = nil
+		q.cond.Broadcast()
+	}
+}
+
+// put queues op for key, replacing any op queued before it.
+func (q *l2Queue[K, V]) put(key K, op l2Op[V]) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.pending[key] = op
+	q.cond.Broadcast()
+}
+
+// get returns the latest op queued or being applied for key.
+func (q *l2Queue[K, V]) get(key K) (l2Op[V], bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if op, ok := q.pending[key]; ok {
+		return op, true
+	}
+	op, ok := q.inflight[key]
+	return op, ok
+}
+
+// drop discards the op queued for key and waits until an op for key that
+// is being applied is done, so a synchronous write to L2 that follows is
+// not overtaken by an older one.
+func (q *l2Queue[K, V]) drop(key K) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, key)
+	for {
+		if _, ok := q.inflight[key]; !ok {
+			return
+		}
+		q.cond.Wait()
+	}
+}
+
+// wait blocks until every queued op is applied.
+func (q *l2Queue[K, V]) wait() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.pending) > 0 || q.inflight != nil {
+		q.cond.Wait()
+	}
+}
+
+// reset discards the queued ops and waits for the batch being applied.
+func (q *l2Queue[K, V]) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	clear(q.pending)
+	for q.inflight != nil {
+		q.cond.Wait()
+	}
+}
+
+// close applies the queued ops and stops run. Later ops are dropped.
+func (q *l2Queue[K, V]) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+	<-q.done
+}
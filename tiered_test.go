@@ -0,0 +1,348 @@
+package mcache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapL2 is an in-memory L2Store for tests.
+type mapL2[K comparable, V any] struct {
+	mu    sync.Mutex
+	items map[K]Item[K, V]
+	sets  int
+}
+
+func newMapL2[K comparable, V any]() *mapL2[K, V] {
+	return &mapL2[K, V]{items: make(map[K]Item[K, V])}
+}
+
+func (m *mapL2[K, V]) Get(_ context.Context, key K) (Item[K, V], bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[key]
+	return item, ok, nil
+}
+
+func (m *mapL2[K, V]) Set(_ context.Context, item Item[K, V]) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[item.Key] = item
+	m.sets++
+	return nil
+}
+
+func (m *mapL2[K, V]) Delete(_ context.Context, key K) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.items[key]
+	delete(m.items, key)
+	return ok, nil
+}
+
+func (m *mapL2[K, V]) Clear(context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clear(m.items)
+	return nil
+}
+
+func (m *mapL2[K, V]) Close() error { return nil }
+
+func (m *mapL2[K, V]) has(key K) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.items[key]
+	return ok
+}
+
+func TestTieredCacheDemoteAndPromote(t *testing.T) {
+	l2 := newMapL2[string, int]()
+	c := NewTieredCache[string, int](l2,
+		WithMaxEntries[string, int](10),
+		WithL2WriteMode[string, int](L2WriteOnEvict),
+	)
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		if !c.Set(fmt.Sprintf("key%d", i), i, 0) {
+			t.Fatalf("Expected Set of key%d to succeed", i)
+		}
+	}
+	c.Wait()
+
+	// The policy makes room after adding an entry, so L1 may hold one more.
+	if n := c.L1().Len(); n > 11 {
+		t.Errorf("Expected at most 11 entries in L1, got %d", n)
+	}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if !c.L1().Has(key) && !l2.has(key) {
+			t.Errorf("Expected %s to be in one of the tiers", key)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if v, ok := c.Get(fmt.Sprintf("key%d", i)); !ok || v != i {
+			t.Errorf("Expected key%d = %d, got %d, %v", i, i, v, ok)
+		}
+	}
+
+	m := c.Metrics()
+	if m.Demotions == 0 {
+		t.Error("Expected evicted entries to be demoted")
+	}
+	if m.L2Hits == 0 {
+		t.Error("Expected L1 misses to be served by L2")
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Expected a miss for a key in neither tier")
+	}
+	if m := c.Metrics(); m.L2Misses != 1 {
+		t.Errorf("Expected 1 L2 miss, got %d", m.L2Misses)
+	}
+}
+
+func TestTieredCachePromotionKeepsTTL(t *testing.T) {
+	l2 := newMapL2[string, int]()
+	c := NewTieredCache[string, int](l2)
+	defer c.Close()
+
+	l2.Set(context.Background(), Item[string, int]{Key: "k", Value: 1, TTL: time.Hour})
+	if v, ok := c.Get("k"); !ok || v != 1 {
+		t.Fatalf("Expected k = 1 from L2, got %d, %v", v, ok)
+	}
+	if !c.L1().Has("k") {
+		t.Error("Expected an L2 hit to be promoted into L1")
+	}
+	if ttl, ok := c.L1().TTL("k"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected the promoted entry to keep its TTL, got %v", ttl)
+	}
+}
+
+func TestTieredCacheWriteModes(t *testing.T) {
+	modes := map[string]L2WriteMode{
+		"through":  L2WriteThrough,
+		"behind":   L2WriteBehind,
+		"on-evict": L2WriteOnEvict,
+	}
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			l2 := newMapL2[string, int]()
+			c := NewTieredCache[string, int](l2, WithL2WriteMode[string, int](mode))
+			defer c.Close()
+
+			c.Set("a", 1, 0)
+			if mode == L2WriteThrough && !l2.has("a") {
+				t.Error("Expected a write-through Set to reach L2 before returning")
+			}
+			c.Wait()
+			if got := l2.has("a"); got != (mode != L2WriteOnEvict) {
+				t.Errorf("Expected a in L2 to be %v, got %v", mode != L2WriteOnEvict, got)
+			}
+
+			l2.Set(context.Background(), Item[string, int]{Key: "b", Value: 2})
+			if !c.Delete("a") {
+				t.Error("Expected Delete to report a key held by L1")
+			}
+			// Write-behind deletes report L1 only.
+			if ok := c.Delete("b"); ok != (mode != L2WriteBehind) {
+				t.Errorf("Expected Delete of a key held by L2 only to report %v, got %v", mode != L2WriteBehind, ok)
+			}
+			c.Wait()
+			if c.L1().Has("a") || l2.has("a") || l2.has("b") {
+				t.Error("Expected Delete to remove keys from both tiers")
+			}
+		})
+	}
+}
+
+func TestTieredCacheWriteBehindCoalesces(t *testing.T) {
+	l2 := newMapL2[string, int]()
+	c := NewTieredCache[string, int](l2, WithL2WriteMode[string, int](L2WriteBehind))
+	defer c.Close()
+
+	for i := 0; i < 1000; i++ {
+		c.Set("k", i, 0)
+	}
+	c.Wait()
+
+	if item, ok, _ := l2.Get(context.Background(), "k"); !ok || item.Value != 999 {
+		t.Errorf("Expected the last write in L2, got %d, %v", item.Value, ok)
+	}
+	l2.mu.Lock()
+	sets := l2.sets
+	l2.mu.Unlock()
+	if sets >= 1000 {
+		t.Errorf("Expected writes to the same key to be coalesced, got %d L2 writes", sets)
+	}
+
+	c.Set("gone", 1, 0)
+	c.Delete("gone")
+	c.L1().Delete("k")
+	if v, ok := c.Get("k"); !ok || v != 999 {
+		t.Errorf("Expected k = 999 from L2, got %d, %v", v, ok)
+	}
+	if _, ok := c.Get("gone"); ok {
+		t.Error("Expected a queued delete to hide the key")
+	}
+}
+
+// blockingL2 is a mapL2 whose Get signals entered and waits for release
+// before reading.
+type blockingL2[K comparable, V any] struct {
+	*mapL2[K, V]
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingL2[K, V]) Get(ctx context.Context, key K) (Item[K, V], bool, error) {
+	b.entered <- struct{}{}
+	<-b.release
+	return b.mapL2.Get(ctx, key)
+}
+
+func TestTieredCachePromotionAfterWrite(t *testing.T) {
+	writes := map[string]func(c *TieredCache[string, int]){
+		"set":    func(c *TieredCache[string, int]) { c.Set("k", 2, 0) },
+		"delete": func(c *TieredCache[string, int]) { c.Delete("k") },
+		"clear":  func(c *TieredCache[string, int]) { c.Clear() },
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			l2 := &blockingL2[string, int]{
+				mapL2:   newMapL2[string, int](),
+				entered: make(chan struct{}),
+				release: make(chan struct{}),
+			}
+			l2.mapL2.Set(context.Background(), Item[string, int]{Key: "k", Value: 1})
+			c := NewTieredCache[string, int](l2)
+			defer c.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.Get("k")
+			}()
+			<-l2.entered
+			write(c)
+			// The read returns the value of L2 from before the write.
+			l2.mapL2.Set(context.Background(), Item[string, int]{Key: "k", Value: 1})
+			close(l2.release)
+			<-done
+			c.Wait()
+
+			v, ok := c.L1().Get("k")
+			if name == "set" {
+				if !ok || v != 2 {
+					t.Errorf("Expected k = 2 after Set, got %d, %v", v, ok)
+				}
+			} else if ok {
+				t.Errorf("Expected k to stay deleted, got %d", v)
+			}
+		})
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	s, err := OpenDiskStore[string, []byte](dir, StringBytesCodec{})
+	if err != nil {
+		t.Fatalf("OpenDiskStore: %v", err)
+	}
+
+	s.Set(ctx, Item[string, []byte]{Key: "a", Value: []byte("alpha"), Cost: 5})
+	s.Set(ctx, Item[string, []byte]{Key: "short", Value: []byte("x"), TTL: 20 * time.Millisecond})
+	s.Set(ctx, Item[string, []byte]{Key: "long", Value: []byte("y"), TTL: time.Hour})
+
+	item, ok, err := s.Get(ctx, "a")
+	if err != nil || !ok || string(item.Value) != "alpha" || item.Cost != 5 || item.TTL != 0 {
+		t.Errorf("Expected alpha with cost 5 and no TTL, got %+v, %v, %v", item, ok, err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok, _ := s.Get(ctx, "short"); ok {
+		t.Error("Expected an expired item to read as missing")
+	}
+	if item, ok, _ := s.Get(ctx, "long"); !ok || item.TTL <= 0 || item.TTL > time.Hour {
+		t.Errorf("Expected long to keep its TTL, got %+v, %v", item, ok)
+	}
+
+	// Items survive reopening.
+	s.Close()
+	s, err = OpenDiskStore[string, []byte](dir, StringBytesCodec{})
+	if err != nil {
+		t.Fatalf("OpenDiskStore: %v", err)
+	}
+	if item, ok, _ := s.Get(ctx, "a"); !ok || string(item.Value) != "alpha" {
+		t.Errorf("Expected alpha after reopening, got %+v, %v", item, ok)
+	}
+
+	// Corrupt files read as missing.
+	_, path, _ := s.locate("a")
+	data, _ := os.ReadFile(path)
+	data[len(data)-5] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("Expected a corrupt item to read as missing")
+	}
+
+	if ok, _ := s.Delete(ctx, "long"); !ok {
+		t.Error("Expected Delete to report an existing item")
+	}
+	if ok, _ := s.Delete(ctx, "long"); ok {
+		t.Error("Expected Delete to report a missing item")
+	}
+
+	s.Set(ctx, Item[string, []byte]{Key: "b", Value: []byte("beta")})
+	// Files the store did not make are kept.
+	os.WriteFile(filepath.Join(dir, "notes"), nil, 0o644)
+	os.Mkdir(filepath.Join(dir, "ab-backup"), 0o755)
+	if err := s.Clear(ctx); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	entries, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(entries) != 2 {
+		t.Errorf("Expected Clear to remove the items only, got %v", entries)
+	}
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("Expected Clear to remove b")
+	}
+}
+
+func TestTieredCacheDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	l2, err := OpenDiskStore[string, []byte](dir, StringBytesCodec{})
+	if err != nil {
+		t.Fatalf("OpenDiskStore: %v", err)
+	}
+	c := NewTieredCache[string, []byte](l2,
+		WithMaxEntries[string, []byte](50),
+		WithL2WriteMode[string, []byte](L2WriteBehind),
+	)
+	for i := 0; i < 200; i++ {
+		c.Set(fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), 0)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A new cache over the same directory starts cold and reads through.
+	l2, _ = OpenDiskStore[string, []byte](dir, StringBytesCodec{})
+	c = NewTieredCache[string, []byte](l2, WithMaxEntries[string, []byte](50))
+	defer c.Close()
+	for i := 0; i < 200; i++ {
+		if v, ok := c.Get(fmt.Sprintf("key%d", i)); !ok || string(v) != fmt.Sprintf("value%d", i) {
+			t.Errorf("Expected key%d from disk, got %q, %v", i, v, ok)
+		}
+	}
+	if err := c.L2Error(); err != nil {
+		t.Errorf("Expected no L2 error, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package mcache
+
+import (
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/trace"
+)
+
+// traceEvent records an access when WithTraceRecorder is set.
+func (c *Cache[K, V]) traceEvent(op trace.Op, keyHash uint64, cost int64) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.Write(trace.Event{Op: op, KeyHash: keyHash, Cost: cost, Time: clock.NowNano()})
+}
+
+// FlushTrace writes buffered trace records to the recorder's writer.
+// Close flushes automatically.
+func (c *Cache[K, V]) FlushTrace() error {
+	if c.recorder == nil {
+		return nil
+	}
+	return c.recorder.Flush()
+}
+
+// TraceError returns the first error encountered while writing the trace,
+// or nil. Once an error occurs no further events are recorded.
+func (c *Cache[K, V]) TraceError() error {
+	if c.recorder == nil {
+		return nil
+	}
+	return c.recorder.Err()
+}
+
+func (c *Cache[K, V]) closeTrace() {
+	if c.recorder != nil {
+		c.recorder.Flush()
+	}
+}
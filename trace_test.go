@@ -0,0 +1,59 @@
+package mcache
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/trace"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCache[string, int](WithTraceRecorder[string, int](&buf))
+
+	c.SetWithCost("a", 1, 5, 0)
+	c.Get("a")
+	c.Get("b")
+	c.Delete("a")
+	c.Close()
+
+	if err := c.TraceError(); err != nil {
+		t.Fatalf("Unexpected trace error: %v", err)
+	}
+	events, err := trace.ReadAll(trace.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hashA, hashB := c.store.KeyHash("a"), c.store.KeyHash("b")
+	want := []trace.Event{
+		{Op: trace.OpSet, KeyHash: hashA, Cost: 5},
+		{Op: trace.OpGet, KeyHash: hashA},
+		{Op: trace.OpGet, KeyHash: hashB},
+		{Op: trace.OpDelete, KeyHash: hashA},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(events))
+	}
+	for i, e := range events {
+		if e.Op != want[i].Op || e.KeyHash != want[i].KeyHash || e.Cost != want[i].Cost {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want[i], e)
+		}
+		if e.Time == 0 {
+			t.Errorf("Event %d: expected a timestamp", i)
+		}
+	}
+}
+
+func TestTraceRecorderDisabled(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	c.Set("a", 1, 0)
+	if err := c.FlushTrace(); err != nil {
+		t.Errorf("Expected FlushTrace to be a no-op, got %v", err)
+	}
+	if err := c.TraceError(); err != nil {
+		t.Errorf("Expected no trace error, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package mcache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/clock"
+	"github.com/OrlovEvgeny/go-mcache/internal/wal"
+)
+
+// WALSyncPolicy controls when write-ahead log records are fsynced.
+type WALSyncPolicy int
+
+const (
+	// WALSyncAlways fsyncs once per committed write or write batch.
+	WALSyncAlways WALSyncPolicy = iota
+	// WALSyncInterval fsyncs periodically (see WithWALSync).
+	// A process crash loses nothing; an OS crash loses at most one interval.
+	WALSyncInterval
+	// WALSyncNever leaves fsync to the OS and only fsyncs on Close.
+	WALSyncNever
+)
+
+// WAL record opcodes.
+const (
+	walOpSet byte = iota + 1
+	walOpDelete
+	walOpClear
+)
+
+// walStripes is the number of key-striped locks that keep log order
+// consistent with store order for writes to the same key.
+const walStripes = 256
+
+// walWriter encodes cache mutations into the write-ahead log.
+type walWriter[K comparable, V any] struct {
+	log     *wal.Log
+	codec   Codec[K, V]
+	stripes [walStripes]sync.Mutex
+
+	mu  sync.Mutex // Guards buf and err
+	buf []byte
+	err error // First encoding error
+}
+
+// stripe returns the lock serializing logged writes to keys with keyHash.
+func (w *walWriter[K, V]) stripe(keyHash uint64) *sync.Mutex {
+	return &w.stripes[keyHash%walStripes]
+}
+
+// append encodes a record with fn and appends it to the log. After an
+// encoding error nothing more is appended, so replay restores the state
+// before the failed write instead of later writes on top of a stale value.
+func (w *walWriter[K, V]) append(op byte, fn func(dst []byte) ([]byte, error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+	rec, err := fn(append(w.buf[:0], op))
+	if err != nil {
+		if w.err == nil {
+			w.err = fmt.Errorf("mcache: wal encode: %w", err)
+		}
+		return
+	}
+	w.buf = rec
+	_ = w.log.Append(rec) // Sticky; surfaced by WALError
+}
+
+func (w *walWriter[K, V]) set(key K, value V, expireAt, cost int64) {
+	w.append(walOpSet, func(dst []byte) ([]byte, error) {
+		var err error
+		if dst, err = appendEncoded(dst, key, w.codec.AppendKey); err != nil {
+			return dst, err
+		}
+		if dst, err = appendEncoded(dst, value, w.codec.AppendValue); err != nil {
+			return dst, err
+		}
+		dst = binary.AppendVarint(dst, expireAt)
+		dst = binary.AppendVarint(dst, cost)
+		return dst, nil
+	})
+}
+
+func (w *walWriter[K, V]) delete(key K) {
+	w.append(walOpDelete, func(dst []byte) ([]byte, error) {
+		return appendEncoded(dst, key, w.codec.AppendKey)
+	})
+}
+
+func (w *walWriter[K, V]) clear() {
+	w.append(walOpClear, func(dst []byte) ([]byte, error) {
+		return dst, nil
+	})
+}
+
+// openWAL replays the log in the configured directory and starts logging.
+func (c *Cache[K, V]) openWAL() error {
+	cfg := c.config
+	if cfg.Codec == nil {
+		return fmt.Errorf("mcache: WithWAL requires WithCodec: %w", ErrNoCodec)
+	}
+
+	err := wal.Recover(cfg.WALDir,
+		func(r io.Reader) error {
+			_, err := c.LoadFrom(r)
+			return err
+		},
+		c.applyWALRecord,
+	)
+	if err != nil {
+		return err
+	}
+
+	var sync wal.SyncPolicy
+	switch cfg.WALSync {
+	case WALSyncAlways:
+		sync = wal.SyncAlways
+	case WALSyncInterval:
+		sync = wal.SyncInterval
+	default:
+		sync = wal.SyncNever
+	}
+	log, err := wal.Open(cfg.WALDir, wal.Options{
+		SegmentSize: cfg.WALSegmentSize,
+		Sync:        sync,
+	})
+	if err != nil {
+		return err
+	}
+	c.wal = &walWriter[K, V]{log: log, codec: cfg.Codec}
+
+	if cfg.WALSync == WALSyncInterval && cfg.WALSyncInterval > 0 {
+		c.wg.Add(1)
+		go c.walSyncWorker()
+	}
+	if cfg.WALCompactInterval > 0 {
+		c.wg.Add(1)
+		go c.walCompactionWorker()
+	}
+	return nil
+}
+
+// applyWALRecord replays one log record. Logging is not yet enabled.
+func (c *Cache[K, V]) applyWALRecord(rec []byte) error {
+	if len(rec) == 0 {
+		return fmt.Errorf("%w: empty wal record", ErrSnapshotCorrupt)
+	}
+	codec := c.config.Codec
+
+	switch op, body := rec[0], rec[1:]; op {
+	case walOpSet:
+		keyBytes, body, err := readLengthPrefixed(body)
+		if err != nil {
+			return err
+		}
+		valueBytes, body, err := readLengthPrefixed(body)
+		if err != nil {
+			return err
+		}
+		expireAt, n := binary.Varint(body)
+		if n <= 0 {
+			return fmt.Errorf("%w: bad wal expiration", ErrSnapshotCorrupt)
+		}
+		cost, n := binary.Varint(body[n:])
+		if n <= 0 {
+			return fmt.Errorf("%w: bad wal cost", ErrSnapshotCorrupt)
+		}
+		key, err := codec.DecodeKey(keyBytes)
+		if err != nil {
+			return err
+		}
+		keyHash := c.store.KeyHash(key)
+
+		// An expired write still supersedes earlier ones for the key.
+		if expireAt > 0 && expireAt <= clock.NowNano() {
+			c.doDelete(key, keyHash)
+			return nil
+		}
+		value, err := codec.DecodeValue(valueBytes)
+		if err != nil {
+			return err
+		}
+		c.setSync(key, value, keyHash, cost, expireAt, nil)
+
+	case walOpDelete:
+		keyBytes, _, err := readLengthPrefixed(body)
+		if err != nil {
+			return err
+		}
+		key, err := codec.DecodeKey(keyBytes)
+		if err != nil {
+			return err
+		}
+		c.doDelete(key, c.store.KeyHash(key))
+
+	case walOpClear:
+		c.clear()
+
+	default:
+		return fmt.Errorf("%w: unknown wal op %d", ErrSnapshotCorrupt, op)
+	}
+	return nil
+}
+
+// walLock serializes a logged write with other logged writes to the same
+// key and returns the matching unlock. It is a no-op without a WAL.
+func (c *Cache[K, V]) walLock(keyHash uint64) func() {
+	if c.wal == nil {
+		return func() {}
+	}
+	mu := c.wal.stripe(keyHash)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (c *Cache[K, V]) walSet(key K, value V, expireAt, cost int64) {
+	if c.wal != nil {
+		c.wal.set(key, value, expireAt, cost)
+	}
+}
+
+func (c *Cache[K, V]) walDelete(key K) {
+	if c.wal != nil {
+		c.wal.delete(key)
+	}
+}
+
+// walCommit group-commits the records appended by the current operation
+// or write batch according to the sync policy.
+func (c *Cache[K, V]) walCommit() {
+	if c.wal != nil {
+		_ = c.wal.log.Commit() // Sticky; surfaced by WALError
+	}
+}
+
+// WALError returns the first error encountered while writing the
+// write-ahead log, or nil. Once an error occurs no further records are
+// written, so callers relying on durability should check it periodically.
+func (c *Cache[K, V]) WALError() error {
+	if c.wal == nil {
+		return nil
+	}
+	c.wal.mu.Lock()
+	err := c.wal.err
+	c.wal.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.wal.log.Err()
+}
+
+// CompactWAL writes a snapshot of the cache into the WAL directory and
+// removes the log segments it supersedes. It runs automatically every
+// WAL compaction interval.
+func (c *Cache[K, V]) CompactWAL() error {
+	if c.wal == nil {
+		return nil
+	}
+	seq, err := c.wal.log.Rotate()
+	if err != nil {
+		return err
+	}
+	return c.wal.log.WriteSnapshot(seq, c.SaveTo)
+}
+
+// walSyncWorker fsyncs the log every WALSyncInterval.
+func (c *Cache[K, V]) walSyncWorker() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.WALSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.wal.log.Sync()
+		}
+	}
+}
+
+// walCompactionWorker periodically compacts the log into a snapshot.
+func (c *Cache[K, V]) walCompactionWorker() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.WALCompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.CompactWAL()
+		}
+	}
+}
+
+// closeWAL flushes and closes the log. Called after all writers stopped.
+func (c *Cache[K, V]) closeWAL() {
+	if c.wal != nil {
+		_ = c.wal.log.Close()
+	}
+}
@@ -0,0 +1,131 @@
+package mcache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func openWALCache(t *testing.T, dir string, opts ...Option[string, []byte]) *Cache[string, []byte] {
+	t.Helper()
+	opts = append([]Option[string, []byte]{
+		WithCodec[string, []byte](StringBytesCodec{}),
+		WithWAL[string, []byte](dir),
+		WithWALSync[string, []byte](WALSyncAlways, 0),
+	}, opts...)
+	c, err := OpenCache(opts...)
+	if err != nil {
+		t.Fatalf("OpenCache: %v", err)
+	}
+	return c
+}
+
+func TestWALReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	c := openWALCache(t, dir)
+	c.Set("keep", []byte("v1"), 0)
+	c.Set("keep", []byte("v2"), 0)
+	c.Set("gone", []byte("x"), 0)
+	c.Delete("gone")
+	c.Set("short", []byte("y"), 20*time.Millisecond)
+	if err := c.WALError(); err != nil {
+		t.Fatalf("WALError: %v", err)
+	}
+	c.Close()
+
+	time.Sleep(40 * time.Millisecond)
+
+	c = openWALCache(t, dir)
+	defer c.Close()
+	if v, ok := c.Get("keep"); !ok || string(v) != "v2" {
+		t.Errorf("Expected v2, got %q, ok=%v", v, ok)
+	}
+	if c.Has("gone") {
+		t.Error("Expected deleted key to stay deleted after replay")
+	}
+	if c.Has("short") {
+		t.Error("Expected expired key not to be replayed")
+	}
+}
+
+func TestWALReplaysClear(t *testing.T) {
+	dir := t.TempDir()
+
+	c := openWALCache(t, dir)
+	c.Set("a", []byte("1"), 0)
+	c.Clear()
+	c.Set("b", []byte("2"), 0)
+	c.Close()
+
+	c = openWALCache(t, dir)
+	defer c.Close()
+	if c.Has("a") {
+		t.Error("Expected key written before Clear to be gone")
+	}
+	if !c.Has("b") {
+		t.Error("Expected key written after Clear to be replayed")
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	c := openWALCache(t, dir)
+	for i := 0; i < 100; i++ {
+		c.Set("key", []byte{byte(i)}, 0)
+	}
+	if err := c.CompactWAL(); err != nil {
+		t.Fatalf("CompactWAL: %v", err)
+	}
+	c.Set("after", []byte("z"), 0)
+	c.Close()
+
+	c = openWALCache(t, dir)
+	defer c.Close()
+	if v, ok := c.Get("key"); !ok || len(v) != 1 || v[0] != 99 {
+		t.Errorf("Expected [99], got %v, ok=%v", v, ok)
+	}
+	if !c.Has("after") {
+		t.Error("Expected write after compaction to be replayed")
+	}
+}
+
+func TestWALRequiresCodec(t *testing.T) {
+	_, err := OpenCache(WithWAL[string, int](t.TempDir()))
+	if !errors.Is(err, ErrNoCodec) {
+		t.Errorf("Expected ErrNoCodec, got %v", err)
+	}
+}
+
+// failingCodec is StringBytesCodec failing to encode the value "bad".
+type failingCodec struct{ StringBytesCodec }
+
+func (failingCodec) AppendValue(dst []byte, value []byte) ([]byte, error) {
+	if string(value) == "bad" {
+		return dst, errors.New("cannot encode")
+	}
+	return append(dst, value...), nil
+}
+
+func TestWALStopsAfterEncodeError(t *testing.T) {
+	dir := t.TempDir()
+
+	c := openWALCache(t, dir, WithCodec[string, []byte](failingCodec{}))
+	c.Set("k", []byte("v1"), 0)
+	c.Set("k", []byte("bad"), 0)
+	c.Set("later", []byte("x"), 0)
+	if err := c.WALError(); err == nil {
+		t.Error("Expected the encoding error from WALError")
+	}
+	c.Close()
+
+	c = openWALCache(t, dir)
+	defer c.Close()
+	if v, ok := c.Get("k"); !ok || string(v) != "v1" {
+		t.Errorf("Expected the state before the failed write, got %q, ok=%v", v, ok)
+	}
+	if c.Has("later") {
+		t.Error("Expected no writes after the failed one to be replayed")
+	}
+}
@@ -0,0 +1,275 @@
+package mcache
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/glob"
+)
+
+// EventType identifies the change reported by an Event.
+type EventType int
+
+const (
+	// EventSet reports a value stored under a key that was not present.
+	EventSet EventType = iota + 1
+	// EventUpdate reports a value replacing the value of a present key.
+	EventUpdate
+	// EventDelete reports an entry removed by a delete or a dependency
+	// cascade.
+	EventDelete
+	// EventEvict reports an entry evicted by the policy to make room.
+	EventEvict
+	// EventExpire reports an entry removed because its TTL elapsed.
+	EventExpire
+	// EventReject reports a value the admission policy refused to store.
+	EventReject
+	// EventClear reports that Clear removed all entries. It has no key and
+	// is delivered to every watcher regardless of its pattern.
+	EventClear
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventUpdate:
+		return "update"
+	case EventDelete:
+		return "delete"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	case EventReject:
+		return "reject"
+	case EventClear:
+		return "clear"
+	}
+	return "unknown"
+}
+
+// EventCause tells why a change reported by an Event happened.
+type EventCause int
+
+const (
+	// CauseUser is a cache method called by the application.
+	CauseUser EventCause = iota
+	// CauseDependency is a change of an entry the key depends on; see
+	// SetWithDeps.
+	CauseDependency
+	// CauseCapacity is the admission policy making room or refusing an
+	// entry.
+	CauseCapacity
+	// CauseTTL is the expiration of the entry.
+	CauseTTL
+)
+
+// Event is a change to the cache reported by Watch. OldValue is set for
+// EventUpdate, EventDelete, EventEvict and EventExpire; NewValue for
+// EventSet, EventUpdate and EventReject.
+type Event[K comparable, V any] struct {
+	Type     EventType
+	Cause    EventCause
+	Key      K
+	OldValue V
+	NewValue V
+}
+
+// WatchOption configures a watcher.
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	Buffer   int
+	Blocking bool
+	Types    []EventType
+}
+
+// WithWatchBuffer sets the number of events a watcher buffers before it
+// drops or blocks. Default: 256.
+func WithWatchBuffer(n int) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.Buffer = n
+	}
+}
+
+// WithWatchBlocking makes the cache wait for a full watcher instead of
+// dropping events, until the watcher's context is done. The operation
+// that caused the event waits with it, possibly holding internal locks,
+// so the receiver must not call the cache while the buffer is full.
+// Default: events for a full watcher are dropped and counted in
+// MetricsSnapshot.WatchDrops.
+func WithWatchBlocking() WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.Blocking = true
+	}
+}
+
+// WithWatchTypes limits a watcher to the given event types.
+// Default: all types.
+func WithWatchTypes(types ...EventType) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.Types = types
+	}
+}
+
+// Watch returns a channel receiving the changes to keys matching the glob
+// pattern filter, such as "user:*"; an empty filter matches every key, and
+// only string keys can match another one. Any number of watchers can be
+// active, each with its own buffer. Events are sent after the change is
+// visible to readers. The channel is closed when ctx is done or the cache
+// is closed.
+func (c *Cache[K, V]) Watch(ctx context.Context, filter string, opts ...WatchOption) (<-chan Event[K, V], error) {
+	cfg := watchConfig{Buffer: 256}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w := &watcher[K, V]{
+		ctx:      ctx,
+		closing:  c.ctx.Done(),
+		blocking: cfg.Blocking,
+		ch:       make(chan Event[K, V], max(cfg.Buffer, 0)),
+	}
+	if filter != "" && filter != "*" {
+		pat, err := glob.Compile(filter)
+		if err != nil {
+			return nil, err
+		}
+		w.pattern = pat
+	}
+	for _, t := range cfg.Types {
+		w.types |= 1 << t
+	}
+
+	if c.closed.Load() {
+		close(w.ch)
+		return w.ch, nil
+	}
+	c.watchers.add(w)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.ctx.Done():
+		}
+		c.watchers.remove(w)
+		w.close()
+	}()
+	return w.ch, nil
+}
+
+// watching reports whether any watcher is active, so callers can skip
+// capturing the data of events nobody receives.
+func (c *Cache[K, V]) watching() bool {
+	return c.watchers.list.Load() != nil
+}
+
+// notify delivers ev to the matching watchers.
+func (c *Cache[K, V]) notify(ev Event[K, V]) {
+	list := c.watchers.list.Load()
+	if list == nil {
+		return
+	}
+	for _, w := range *list {
+		if !w.matches(ev) {
+			continue
+		}
+		if !w.send(ev) {
+			c.metrics.incWatchDrop()
+		}
+	}
+}
+
+// watcher is one subscriber created by Watch.
+type watcher[K comparable, V any] struct {
+	ctx      context.Context
+	closing  <-chan struct{} // Closed when the cache is closed
+	pattern  *glob.Pattern   // nil = all keys
+	types    uint32          // bit per EventType; 0 = all
+	blocking bool
+
+	mu     sync.RWMutex // Held for reading while sending, for writing to close
+	closed bool
+	ch     chan Event[K, V]
+}
+
+func (w *watcher[K, V]) matches(ev Event[K, V]) bool {
+	if w.types != 0 && w.types&(1<<ev.Type) == 0 {
+		return false
+	}
+	if w.pattern == nil || ev.Type == EventClear {
+		return true
+	}
+	key, ok := any(ev.Key).(string)
+	return ok && w.pattern.Match(key)
+}
+
+// send delivers ev, returning false if it was dropped.
+func (w *watcher[K, V]) send(ev Event[K, V]) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return true
+	}
+	if w.blocking {
+		select {
+		case w.ch <- ev:
+			return true
+		case <-w.ctx.Done():
+			return false
+		case <-w.closing:
+			return false
+		}
+	}
+	select {
+	case w.ch <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *watcher[K, V]) close() {
+	w.mu.Lock()
+	w.closed = true
+	close(w.ch)
+	w.mu.Unlock()
+}
+
+// watcherSet holds the active watchers. The list is replaced on every
+// change so notify can read it without locking.
+type watcherSet[K comparable, V any] struct {
+	mu   sync.Mutex
+	list atomic.Pointer[[]*watcher[K, V]] // nil when there are none
+}
+
+func (s *watcherSet[K, V]) add(w *watcher[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []*watcher[K, V]
+	if cur := s.list.Load(); cur != nil {
+		list = slices.Clone(*cur)
+	}
+	list = append(list, w)
+	s.list.Store(&list)
+}
+
+func (s *watcherSet[K, V]) remove(w *watcher[K, V]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur := s.list.Load()
+	if cur == nil {
+		return
+	}
+	list := slices.DeleteFunc(slices.Clone(*cur), func(x *watcher[K, V]) bool {
+		return x == w
+	})
+	if len(list) == 0 {
+		s.list.Store(nil)
+		return
+	}
+	s.list.Store(&list)
+}
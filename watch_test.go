@@ -0,0 +1,156 @@
+package mcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nextEvent receives one event or fails the test after a timeout.
+func nextEvent[K comparable, V any](t *testing.T, ch <-chan Event[K, V]) Event[K, V] {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an event")
+	}
+	panic("unreachable")
+}
+
+func TestWatchEvents(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := c.Watch(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("a", 1, 0)
+	c.Set("a", 2, 0)
+	c.Delete("a")
+	c.Set("dep", 1, 0)
+	c.SetWithDeps("derived", 2, 0, "dep")
+	c.Delete("dep")
+	c.Clear()
+
+	want := []Event[string, int]{
+		{Type: EventSet, Key: "a", NewValue: 1},
+		{Type: EventUpdate, Key: "a", OldValue: 1, NewValue: 2},
+		{Type: EventDelete, Key: "a", OldValue: 2},
+		{Type: EventSet, Key: "dep", NewValue: 1},
+		{Type: EventSet, Key: "derived", NewValue: 2},
+		{Type: EventDelete, Key: "dep", OldValue: 1},
+		{Type: EventDelete, Cause: CauseDependency, Key: "derived", OldValue: 2},
+		{Type: EventClear},
+	}
+	for i, w := range want {
+		if ev := nextEvent(t, ch); ev != w {
+			t.Errorf("Event %d: expected %+v, got %+v", i, w, ev)
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestWatchEvictExpireReject(t *testing.T) {
+	c := NewCache[int, int](WithMaxEntries[int, int](10))
+	defer c.Close()
+
+	ch, err := c.Watch(context.Background(), "", WithWatchBuffer(10000),
+		WithWatchTypes(EventEvict, EventExpire, EventReject))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set(-1, -1, 20*time.Millisecond)
+	if ev := nextEvent(t, ch); ev.Type != EventExpire || ev.Cause != CauseTTL || ev.Key != -1 {
+		t.Errorf("Expected the expiry of -1, got %+v", ev)
+	}
+
+	seen := map[EventType]bool{}
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i, 0)
+	}
+	for len(ch) > 0 {
+		ev := <-ch
+		if ev.Cause != CauseCapacity {
+			t.Errorf("Expected a capacity event, got %+v", ev)
+		}
+		seen[ev.Type] = true
+	}
+	if !seen[EventEvict] {
+		t.Error("Expected evictions")
+	}
+	if m := c.Metrics(); seen[EventReject] != (m.Rejections > 0) {
+		t.Errorf("Expected reject events to match %d rejections", m.Rejections)
+	}
+}
+
+func TestWatchFilter(t *testing.T) {
+	c := NewCache[string, int]()
+	defer c.Close()
+
+	users, err := c.Watch(context.Background(), "user:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := c.Watch(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Watch(context.Background(), "[a"); err == nil {
+		t.Error("Expected an invalid pattern to fail")
+	}
+
+	c.Set("order:1", 1, 0)
+	c.Set("user:1", 2, 0)
+	if ev := nextEvent(t, users); ev.Key != "user:1" {
+		t.Errorf("Expected user:1, got %+v", ev)
+	}
+	if ev := nextEvent(t, all); ev.Key != "order:1" {
+		t.Errorf("Expected order:1, got %+v", ev)
+	}
+	if ev := nextEvent(t, all); ev.Key != "user:1" {
+		t.Errorf("Expected user:1, got %+v", ev)
+	}
+}
+
+func TestWatchDelivery(t *testing.T) {
+	c := NewCache[int, int](WithMetrics[int, int](true))
+
+	lossy, _ := c.Watch(context.Background(), "", WithWatchBuffer(2))
+	blocking, _ := c.Watch(context.Background(), "", WithWatchBuffer(1), WithWatchBlocking())
+
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range blocking {
+			n++
+		}
+		received <- n
+	}()
+
+	for i := 0; i < 100; i++ {
+		c.Set(i, i, 0)
+	}
+	if len(lossy) != 2 {
+		t.Errorf("Expected the lossy buffer to be full, got %d", len(lossy))
+	}
+	if m := c.Metrics(); m.WatchDrops != 98 {
+		t.Errorf("Expected 98 drops, got %d", m.WatchDrops)
+	}
+
+	// Close ends both watchers.
+	c.Close()
+	if n := <-received; n != 100 {
+		t.Errorf("Expected the blocking watcher to get all 100 events, got %d", n)
+	}
+	for range lossy {
+	}
+}
@@ -0,0 +1,198 @@
+package mcache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/OrlovEvgeny/go-mcache/internal/buffer"
+)
+
+// WriterMode controls when a Cache passes writes to its BackingWriter.
+type WriterMode int
+
+const (
+	// WriteThrough calls the writer before the cache is updated, so a write
+	// the writer fails is not applied and its error is returned.
+	WriteThrough WriterMode = iota
+	// WriteBehind updates the cache and queues the write. Queued writes are
+	// passed to the writer in batches, keeping only the latest write per
+	// key in a batch, and failed batches are retried with backoff.
+	WriteBehind
+)
+
+// BackingWrite is a write passed to a BackingWriter: Key set to Value, or
+// Key deleted if Delete is true.
+type BackingWrite[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Delete bool
+}
+
+// BackingWriter persists the writes made to a Cache to a backing store,
+// such as a database. Only these methods are passed on: Set, SetWithCost,
+// SetCtx, SetWithCostCtx, SetWithTags, SetWithDeps, SetMany, Delete,
+// DeleteCtx, DeleteMany and the Set and Delete of a Namespace. Conditional
+// writes (SetIfAbsent, Replace, CompareAndSwap, CompareAndDelete and their
+// Version forms), Compute and its variants, CounterCache, evictions,
+// expirations, dependency cascades, tag invalidations, Clear and values
+// stored by loaders are not; persist their effects yourself if needed. A
+// batch holds at most one write per key. With WriteBehind, Write is called
+// from one goroutine at a time.
+type BackingWriter[K comparable, V any] interface {
+	Write(ctx context.Context, writes []BackingWrite[K, V]) error
+}
+
+// BackingWriterFunc adapts a function to a BackingWriter.
+type BackingWriterFunc[K comparable, V any] func(ctx context.Context, writes []BackingWrite[K, V]) error
+
+// Write implements BackingWriter.
+func (f BackingWriterFunc[K, V]) Write(ctx context.Context, writes []BackingWrite[K, V]) error {
+	return f(ctx, writes)
+}
+
+// writerStripes is the number of key-striped locks that keep the order of
+// the writes passed to the writer consistent with the cache for writes to
+// the same key.
+const writerStripes = 256
+
+// backingWriter passes cache writes to the configured BackingWriter.
+type backingWriter[K comparable, V any] struct {
+	w       BackingWriter[K, V]
+	buffer  *buffer.WriteBuffer[BackingWrite[K, V]] // nil with WriteThrough
+	retries int
+	backoff time.Duration
+	metrics *Metrics
+	stripes [writerStripes]sync.Mutex
+
+	mu  sync.Mutex // Guards err
+	err error      // First write-behind batch that failed every attempt
+}
+
+// openWriter sets up the writer configured with WithWriter.
+func (c *Cache[K, V]) openWriter() {
+	cfg := c.config
+	w := &backingWriter[K, V]{
+		w:       cfg.Writer,
+		retries: cfg.WriterRetries,
+		backoff: cfg.WriterBackoff,
+		metrics: c.metrics,
+	}
+	if cfg.WriterMode == WriteBehind {
+		size := max(cfg.WriterBatchSize, 1)
+		w.buffer = buffer.NewWriteBuffer[BackingWrite[K, V]](
+			size*16,
+			size,
+			cfg.WriterFlushInterval,
+			w.flush,
+		)
+	}
+	c.writer = w
+}
+
+// writerLock serializes a write passed to the writer, together with the
+// matching cache update, with other such writes to the same key and
+// returns the matching unlock. It is a no-op without a writer.
+func (c *Cache[K, V]) writerLock(keyHash uint64) func() {
+	if c.writer == nil {
+		return func() {}
+	}
+	mu := &c.writer.stripes[keyHash%writerStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// persist passes a write to the writer, if any. With WriteThrough it
+// returns the error of the writer; with WriteBehind it waits for the
+// queue to drain while it is full, giving up with ctx.Err(). The caller
+// holds the writerLock of the key.
+func (c *Cache[K, V]) persist(ctx context.Context, bw BackingWrite[K, V]) error {
+	w := c.writer
+	if w == nil {
+		return nil
+	}
+	if w.buffer == nil {
+		return w.w.Write(ctx, []BackingWrite[K, V]{bw})
+	}
+	if w.buffer.Push(bw) {
+		return nil
+	}
+	c.metrics.incBufferDrop()
+	for {
+		if err := w.buffer.FlushSyncContext(ctx); err != nil {
+			return err
+		}
+		if c.closed.Load() {
+			return ErrClosed
+		}
+		if w.buffer.Push(bw) {
+			return nil
+		}
+	}
+}
+
+// flush writes a batch of queued writes, keeping the last write of each
+// key, and retries it with exponential backoff.
+func (w *backingWriter[K, V]) flush(batch []BackingWrite[K, V]) {
+	writes := coalesceWrites(batch)
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		err := w.w.Write(context.Background(), writes)
+		if err == nil {
+			return
+		}
+		if attempt >= w.retries {
+			w.fail(len(writes), err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// fail records a batch of n writes that failed every attempt.
+func (w *backingWriter[K, V]) fail(n int, err error) {
+	w.metrics.addWriterErrors(int64(n))
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = fmt.Errorf("mcache: backing write: %w", err)
+	}
+	w.mu.Unlock()
+}
+
+// coalesceWrites returns the last write of each key in batch, in the
+// order of those writes.
+func coalesceWrites[K comparable, V any](batch []BackingWrite[K, V]) []BackingWrite[K, V] {
+	last := make(map[K]int, len(batch))
+	for i, bw := range batch {
+		last[bw.Key] = i
+	}
+	writes := make([]BackingWrite[K, V], 0, len(last))
+	for i, bw := range batch {
+		if last[bw.Key] == i {
+			writes = append(writes, bw)
+		}
+	}
+	return writes
+}
+
+// WriterError returns the first error of a write-behind batch that failed
+// every attempt, or nil. The writes of such a batch are dropped and
+// counted in MetricsSnapshot.WriterErrors.
+func (c *Cache[K, V]) WriterError() error {
+	if c.writer == nil {
+		return nil
+	}
+	c.writer.mu.Lock()
+	defer c.writer.mu.Unlock()
+	return c.writer.err
+}
+
+// closeWriter writes the queued writes and stops the writer.
+func (c *Cache[K, V]) closeWriter() {
+	if c.writer != nil && c.writer.buffer != nil {
+		c.writer.buffer.Close()
+	}
+}
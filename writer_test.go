@@ -0,0 +1,219 @@
+package mcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingWriter is a BackingWriter that records its batches and can fail.
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]BackingWrite[string, int]
+	fails   int // Number of calls to fail before succeeding
+}
+
+func (w *recordingWriter) Write(_ context.Context, writes []BackingWrite[string, int]) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fails > 0 {
+		w.fails--
+		return errors.New("unavailable")
+	}
+	w.batches = append(w.batches, append([]BackingWrite[string, int](nil), writes...))
+	return nil
+}
+
+// state returns the backing store contents after applying every batch.
+func (w *recordingWriter) state() map[string]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state := make(map[string]int)
+	for _, batch := range w.batches {
+		for _, bw := range batch {
+			if bw.Delete {
+				delete(state, bw.Key)
+			} else {
+				state[bw.Key] = bw.Value
+			}
+		}
+	}
+	return state
+}
+
+func (w *recordingWriter) writes() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, batch := range w.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+func TestWriteThrough(t *testing.T) {
+	w := &recordingWriter{}
+	c := NewCache[string, int](WithWriter[string, int](w))
+	defer c.Close()
+
+	c.Set("a", 1, 0)
+	c.SetMany([]Item[string, int]{{Key: "b", Value: 2}, {Key: "c", Value: 3}})
+	c.Delete("b")
+	if state := w.state(); len(state) != 2 || state["a"] != 1 || state["c"] != 3 {
+		t.Errorf("Expected writes to reach the writer synchronously, got %v", state)
+	}
+
+	w.mu.Lock()
+	w.fails = 1
+	w.mu.Unlock()
+	if _, err := c.SetCtx(context.Background(), "a", 10, 0); err == nil {
+		t.Error("Expected the writer error to be returned")
+	}
+	if v, _ := c.Get("a"); v != 1 {
+		t.Errorf("Expected a failed write-through to leave the cache unchanged, got %d", v)
+	}
+
+	// Evictions, expirations and Clear stay in the cache.
+	c.Set("short", 1, 10*time.Millisecond)
+	n := w.writes()
+	c.Clear()
+	time.Sleep(50 * time.Millisecond)
+	if w.writes() != n {
+		t.Error("Expected Clear not to reach the writer")
+	}
+}
+
+func TestWriteBehind(t *testing.T) {
+	w := &recordingWriter{}
+	c := NewCache[string, int](
+		WithWriter[string, int](w),
+		WithWriterMode[string, int](WriteBehind),
+		WithWriterBatch[string, int](1000, time.Hour),
+	)
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set("hot", i, 0)
+	}
+	c.Set("gone", 1, 0)
+	c.Delete("gone")
+	if n := w.writes(); n != 0 {
+		t.Errorf("Expected writes to be queued, got %d written", n)
+	}
+
+	c.Wait()
+	if state := w.state(); len(state) != 1 || state["hot"] != 99 {
+		t.Errorf("Expected the last write of each key, got %v", state)
+	}
+	if n := w.writes(); n != 2 {
+		t.Errorf("Expected writes to be coalesced per key into 2, got %d", n)
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	w := &recordingWriter{fails: 2}
+	c := NewCache[string, int](
+		WithWriter[string, int](w),
+		WithWriterMode[string, int](WriteBehind),
+		WithWriterRetry[string, int](2, time.Millisecond),
+	)
+	c.Set("a", 1, 0)
+	c.Wait()
+	if state := w.state(); state["a"] != 1 {
+		t.Errorf("Expected the write to succeed on retry, got %v", state)
+	}
+	if err := c.WriterError(); err != nil {
+		t.Errorf("Expected no writer error, got %v", err)
+	}
+
+	w.mu.Lock()
+	w.fails = 3
+	w.mu.Unlock()
+	c.Set("b", 2, 0)
+	c.Close() // Flushes queued writes
+	if err := c.WriterError(); err == nil {
+		t.Error("Expected a writer error after every attempt failed")
+	}
+	if m := c.Metrics(); m.WriterErrors != 1 {
+		t.Errorf("Expected 1 dropped write, got %d", m.WriterErrors)
+	}
+}
+
+func TestWriteThroughKeepsOrderPerKey(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		written []int
+	)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	w := BackingWriterFunc[string, int](func(_ context.Context, writes []BackingWrite[string, int]) error {
+		mu.Lock()
+		written = append(written, writes[0].Value)
+		mu.Unlock()
+		if writes[0].Value == 1 {
+			close(entered)
+			<-release
+		}
+		return nil
+	})
+	c := NewCache[string, int](WithWriter[string, int](w))
+	defer c.Close()
+
+	done := make(chan struct{})
+	go func() {
+		c.Set("k", 1, 0)
+		close(done)
+	}()
+	<-entered
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	c.Set("k", 2, 0)
+	<-done
+
+	mu.Lock()
+	last := written[len(written)-1]
+	mu.Unlock()
+	if v, _ := c.Get("k"); v != last {
+		t.Errorf("Expected the cache to hold the last value written, %d, got %d", last, v)
+	}
+}
+
+func TestWriteBehindFullQueueSingleWriter(t *testing.T) {
+	var inflight, overlaps atomic.Int32
+	w := BackingWriterFunc[string, int](func(context.Context, []BackingWrite[string, int]) error {
+		if inflight.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		inflight.Add(-1)
+		return nil
+	})
+	c := NewCache[string, int](
+		WithWriter[string, int](w),
+		WithWriterMode[string, int](WriteBehind),
+		WithWriterBatch[string, int](1, time.Hour),
+	)
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				c.Set(fmt.Sprintf("key-%d-%d", g, i), i, 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := overlaps.Load(); n != 0 {
+		t.Errorf("Expected Write to be called from one goroutine at a time, got %d overlapping calls", n)
+	}
+}